@@ -1,10 +1,12 @@
 package config
 
 import (
+	"crypto"
 	"fmt"
 	"log"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -18,6 +20,14 @@ func convertToInt(value, name string) int {
 	return result
 }
 
+// ใช้กับ config ที่เพิ่มมาทีหลัง เพื่อให้ไฟล์ .env เดิมยังใช้ได้
+func convertToIntOrDefault(value, name string, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	return convertToInt(value, name)
+}
+
 // func convertToBool(value string) bool {
 // 	reslut, err := strconv.ParseBool(value)
 // 	if err != nil {
@@ -34,6 +44,209 @@ func convertToDuration(value, nameEnv string) time.Duration {
 	return time.Duration(int64(t) * int64(math.Pow10(10)))
 }
 
+// แปลงค่า "name:value,name:value" ให้เป็น map ใช้กับ config ที่ต้องการหลายค่าใน key เดียว
+func convertToMap(value, nameEnv string, size int) map[string][]string {
+	result := make(map[string][]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) != size {
+			log.Fatalf("Error convert to map name: %s item: %s", nameEnv, item)
+		}
+		result[fields[0]] = fields[1:]
+	}
+	return result
+}
+
+// ค่าเริ่มต้นของทุก group ที่ route ใช้ .env เดิมที่ไม่มี RATE_LIMIT_GROUPS จึงยังใช้งานได้
+// apikey คือจำนวน request ต่อ ip ก่อนตรวจ api key
+const defaultRateLimitGroups = "catalog:120:30,orders:30:10,apikey:600:100"
+
+// group ที่กำหนดใน RATE_LIMIT_GROUPS แทนที่ค่าเริ่มต้นทีละ group
+func convertToRateLimitGroups(value, nameEnv string) map[string][2]int {
+	result := make(map[string][2]int)
+	for _, groups := range []string{defaultRateLimitGroups, value} {
+		for name, fields := range convertToMap(groups, nameEnv, 3) {
+			result[name] = [2]int{convertToInt(fields[0], nameEnv), convertToInt(fields[1], nameEnv)}
+		}
+	}
+	return result
+}
+
+func convertToRateLimitTiers(value, nameEnv string) map[string]float64 {
+	result := make(map[string]float64)
+	for name, fields := range convertToMap(value, nameEnv, 2) {
+		multiplier, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			log.Fatalf("Error convert to float name: %s err: %s", nameEnv, err)
+		}
+		result[name] = multiplier
+	}
+	return result
+}
+
+// อ่าน provider จาก OIDC_PROVIDERS="google,line" และค่าของแต่ละ provider จาก OIDC_<NAME>_*
+func convertToOIDCProviders(envMap map[string]string) []*OIDCProvider {
+	result := make([]*OIDCProvider, 0)
+	for _, name := range strings.Split(envMap["OIDC_PROVIDERS"], ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := &OIDCProvider{
+			Name:         name,
+			Issuer:       envMap[prefix+"ISSUER"],
+			ClientId:     envMap[prefix+"CLIENT_ID"],
+			ClientSecret: envMap[prefix+"CLIENT_SECRET"],
+			RedirectUrl:  envMap[prefix+"REDIRECT_URL"],
+			Scopes:       strings.Fields(envMap[prefix+"SCOPES"]),
+			AllowHS256:   envMap[prefix+"ALLOW_HS256"] == "true",
+		}
+		if p.Issuer == "" || p.ClientId == "" || p.RedirectUrl == "" {
+			log.Fatalf("Error oidc provider: %s require %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		if p.AllowHS256 && p.ClientSecret == "" {
+			log.Fatalf("Error oidc provider: %s require %sCLIENT_SECRET when %sALLOW_HS256 is true", name, prefix, prefix)
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func convertToJwt(envMap map[string]string) *jwt {
+	signers, publicKeys := convertToSigningKeys(envMap["JWT_SIGNING_KEYS"], "JWT_SIGNING_KEYS")
+	signingKeyId := envMap["JWT_SIGNING_KID"]
+	if len(publicKeys) > 0 && signers[signingKeyId] == nil {
+		log.Fatalf("Error JWT_SIGNING_KID: %q must be a private key in JWT_SIGNING_KEYS", signingKeyId)
+	}
+
+	return &jwt{
+		adminKey:         envMap["JWT_ADMIN_KEY"],
+		secertKey:        envMap["JWT_SECERT_KEY"],
+		accessExpiresAt:  convertToInt(envMap["JWT_ACCESS_EXPIRES"], "JWT_ACCESS_EXPIRES"),
+		refreshExpiresAt: convertToInt(envMap["JWT_REFRESH_EXPIRES"], "JWT_REFRESH_EXPIRES"),
+		signingKeyId:     signingKeyId,
+		signingKey:       signers[signingKeyId],
+		publicKeys:       publicKeys,
+		legacyUntil:      convertToTime(envMap["JWT_LEGACY_SECRET_UNTIL"], "JWT_LEGACY_SECRET_UNTIL"),
+		apiKey:           envMap["JWT_API_KEY"],
+		apiKeyUntil:      convertToTime(envMap["JWT_API_KEY_LEGACY_UNTIL"], "JWT_API_KEY_LEGACY_UNTIL"),
+	}
+}
+
+// RFC3339 ว่างคือเวลาศูนย์ ใช้กับ config ที่เปิดไว้ถึงเวลาที่กำหนดเท่านั้น
+func convertToTime(value, nameEnv string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("Error convert to time name: %s err: %s", nameEnv, err)
+	}
+	return t
+}
+
+// แปลง IMAGE_RENDITIONS="thumbnail:150,medium:600" โดยเรียงตามลำดับที่กำหนด
+func convertToImageRenditions(value, nameEnv string) []*ImageRendition {
+	result := make([]*ImageRendition, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) != 2 {
+			log.Fatalf("Error convert to image rendition name: %s item: %s", nameEnv, item)
+		}
+		result = append(result, &ImageRendition{
+			Name:    fields[0],
+			MaxSize: convertToInt(fields[1], nameEnv),
+		})
+	}
+	return result
+}
+
+// ใช้ APP_GCP_BUCKET ได้เหมือนเดิมถ้าไม่ได้ตั้ง STORAGE_BUCKET
+func convertToStorageBucket(envMap map[string]string) string {
+	if envMap["STORAGE_BUCKET"] != "" {
+		return envMap["STORAGE_BUCKET"]
+	}
+	return envMap["APP_GCP_BUCKET"]
+}
+
+// local storage ถูก serve ที่ /static ของ app เอง
+func convertToStorageUrl(envMap map[string]string) string {
+	if envMap["STORAGE_PUBLIC_URL"] != "" {
+		return envMap["STORAGE_PUBLIC_URL"]
+	}
+	if envMap["STORAGE_DRIVER"] == "" || envMap["STORAGE_DRIVER"] == "local" {
+		return fmt.Sprintf("http://%s:%s/static", envMap["APP_HOST"], envMap["APP_PORT"])
+	}
+	return ""
+}
+
+// signed url ของ local storage ถูกตรวจและส่งไฟล์โดย /v1/files/signed ของ app เอง
+func convertToStorageSignedUrl(envMap map[string]string) string {
+	if envMap["STORAGE_SIGNED_URL"] != "" {
+		return envMap["STORAGE_SIGNED_URL"]
+	}
+	return fmt.Sprintf("http://%s:%s/v1/files/signed", envMap["APP_HOST"], envMap["APP_PORT"])
+}
+
+func convertToDownloadUrl(envMap map[string]string) string {
+	if envMap["PRODUCT_DOWNLOAD_URL"] != "" {
+		return envMap["PRODUCT_DOWNLOAD_URL"]
+	}
+	return fmt.Sprintf("http://%s:%s/v1/downloads", envMap["APP_HOST"], envMap["APP_PORT"])
+}
+
+func convertToVerifyEmailUrl(envMap map[string]string) string {
+	if envMap["MAIL_VERIFY_EMAIL_URL"] != "" {
+		return envMap["MAIL_VERIFY_EMAIL_URL"]
+	}
+	return fmt.Sprintf("http://%s:%s/v1/users/verify-email", envMap["APP_HOST"], envMap["APP_PORT"])
+}
+
+func convertToGuestLookupUrl(envMap map[string]string) string {
+	if envMap["ORDER_GUEST_LOOKUP_URL"] != "" {
+		return envMap["ORDER_GUEST_LOOKUP_URL"]
+	}
+	return fmt.Sprintf("http://%s:%s/v1/orders/guest", envMap["APP_HOST"], envMap["APP_PORT"])
+}
+
+// ORDER_CANCEL_WINDOWS="waiting:0,shipping:3600" ถ้าว่างลูกค้ายกเลิกได้เฉพาะ order ที่ยังรอชำระเงิน
+func convertToCancelWindows(envMap map[string]string) map[string]time.Duration {
+	value := envMap["ORDER_CANCEL_WINDOWS"]
+	if strings.TrimSpace(value) == "" {
+		value = "waiting:0"
+	}
+	result := make(map[string]time.Duration)
+	for status, fields := range convertToMap(value, "ORDER_CANCEL_WINDOWS", 2) {
+		seconds := convertToInt(fields[0], "ORDER_CANCEL_WINDOWS")
+		if seconds < 0 {
+			log.Fatalf("ORDER_CANCEL_WINDOWS of %s must not be negative", status)
+		}
+		result[status] = time.Duration(seconds) * time.Second
+	}
+	return result
+}
+
+// chunk ถูกส่งมาใน request body เดียว จึงต้องไม่เกิน APP_BODY_LIMIT
+func convertToUploadChunkSize(envMap map[string]string) int64 {
+	chunkSize := convertToIntOrDefault(envMap["UPLOAD_CHUNK_SIZE"], "UPLOAD_CHUNK_SIZE", 5<<20)
+	if chunkSize <= 0 {
+		log.Fatalf("UPLOAD_CHUNK_SIZE must be greater than 0")
+	}
+	if bodyLimit := convertToInt(envMap["APP_BODY_LIMIT"], "APP_BODY_LIMIT"); chunkSize > bodyLimit {
+		log.Fatalf("UPLOAD_CHUNK_SIZE must not exceed APP_BODY_LIMIT")
+	}
+	return int64(chunkSize)
+}
+
 func LoadConfig(path string) ConfigImpl {
 
 	envMap, err := godotenv.Read(path)
@@ -65,12 +278,75 @@ func LoadConfig(path string) ConfigImpl {
 			sslMode:       envMap["DB_SSL_MODE"],
 			maxConnection: convertToInt(envMap["DB_MAX_CONNECTIONS"], "DB_MAX_CONNECTIONS"),
 		},
-		jwt: &jwt{
-			adminKey:         envMap["JWT_ADMIN_KEY"],
-			secertKey:        envMap["JWT_SECERT_KEY"],
-			apiKey:           envMap["JWT_API_KEY"],
-			accessExpiresAt:  convertToInt(envMap["JWT_ACCESS_EXPIRES"], "JWT_ACCESS_EXPIRES"),
-			refreshExpiresAt: convertToInt(envMap["JWT_REFRESH_EXPIRES"], "JWT_REFRESH_EXPIRES"),
+		jwt: convertToJwt(envMap),
+		rateLimit: &rateLimit{
+			groups: convertToRateLimitGroups(envMap["RATE_LIMIT_GROUPS"], "RATE_LIMIT_GROUPS"),
+			tiers:  convertToRateLimitTiers(envMap["RATE_LIMIT_TIERS"], "RATE_LIMIT_TIERS"),
+		},
+		storage: &storage{
+			driver:      envMap["STORAGE_DRIVER"],
+			bucket:      convertToStorageBucket(envMap),
+			publicUrl:   convertToStorageUrl(envMap),
+			signedUrl:   convertToStorageSignedUrl(envMap),
+			signingKey:  envMap["STORAGE_SIGNING_KEY"],
+			signedTTL:   convertToIntOrDefault(envMap["STORAGE_SIGNED_URL_EXPIRES"], "STORAGE_SIGNED_URL_EXPIRES", 15*60),
+			localRoot:   envMap["STORAGE_LOCAL_ROOT"],
+			s3Endpoint:  envMap["STORAGE_S3_ENDPOINT"],
+			s3Region:    envMap["STORAGE_S3_REGION"],
+			s3AccessKey: envMap["STORAGE_S3_ACCESS_KEY"],
+			s3SecretKey: envMap["STORAGE_S3_SECRET_KEY"],
+			orphanGrace: convertToIntOrDefault(envMap["FILES_ORPHAN_GRACE"], "FILES_ORPHAN_GRACE", 24*60*60),
+			sweepEvery:  convertToIntOrDefault(envMap["FILES_SWEEP_INTERVAL"], "FILES_SWEEP_INTERVAL", 60*60),
+		},
+		image: &image{
+			renditions: convertToImageRenditions(envMap["IMAGE_RENDITIONS"], "IMAGE_RENDITIONS"),
+			formats:    strings.Fields(strings.ReplaceAll(envMap["IMAGE_FORMATS"], ",", " ")),
+			quality:    convertToIntOrDefault(envMap["IMAGE_QUALITY"], "IMAGE_QUALITY", 82),
+		},
+		upload: &upload{
+			userQuota:     int64(convertToIntOrDefault(envMap["UPLOAD_USER_QUOTA"], "UPLOAD_USER_QUOTA", 0)),
+			maxPixels:     convertToIntOrDefault(envMap["UPLOAD_MAX_PIXELS"], "UPLOAD_MAX_PIXELS", 40_000_000),
+			scannerDriver: envMap["SCANNER_DRIVER"],
+			clamavAddr:    envMap["SCANNER_CLAMAV_ADDR"],
+			chunkSize:     convertToUploadChunkSize(envMap),
+			maxSize:       int64(convertToIntOrDefault(envMap["UPLOAD_MAX_SIZE"], "UPLOAD_MAX_SIZE", 2<<30)),
+			sessionTTL:    convertToIntOrDefault(envMap["UPLOAD_SESSION_EXPIRES"], "UPLOAD_SESSION_EXPIRES", 24*60*60),
+		},
+		oidc: &oidc{
+			providers: convertToOIDCProviders(envMap),
+		},
+		product: &product{
+			scheduleEvery: convertToIntOrDefault(envMap["PRODUCT_SCHEDULE_INTERVAL"], "PRODUCT_SCHEDULE_INTERVAL", 60),
+			downloadUrl:   convertToDownloadUrl(envMap),
+			downloadTTL:   convertToIntOrDefault(envMap["PRODUCT_DOWNLOAD_LINK_EXPIRES"], "PRODUCT_DOWNLOAD_LINK_EXPIRES", 15*60),
+		},
+		trash: &trash{
+			retention:  convertToIntOrDefault(envMap["TRASH_RETENTION"], "TRASH_RETENTION", 30*24*60*60),
+			purgeEvery: convertToIntOrDefault(envMap["TRASH_PURGE_INTERVAL"], "TRASH_PURGE_INTERVAL", 60*60),
+		},
+		currency: &currency{
+			importerDriver: envMap["EXCHANGE_RATE_IMPORTER"],
+			importerUrl:    envMap["EXCHANGE_RATE_URL"],
+			importEvery:    convertToIntOrDefault(envMap["EXCHANGE_RATE_IMPORT_INTERVAL"], "EXCHANGE_RATE_IMPORT_INTERVAL", 0),
+		},
+		mail: &mail{
+			driver:         envMap["MAIL_DRIVER"],
+			from:           envMap["MAIL_FROM"],
+			smtpHost:       envMap["MAIL_SMTP_HOST"],
+			smtpPort:       convertToIntOrDefault(envMap["MAIL_SMTP_PORT"], "MAIL_SMTP_PORT", 587),
+			smtpUsername:   envMap["MAIL_SMTP_USERNAME"],
+			smtpPassword:   envMap["MAIL_SMTP_PASSWORD"],
+			verifyEmailUrl: convertToVerifyEmailUrl(envMap),
+		},
+		order: &order{
+			guestLookupUrl: convertToGuestLookupUrl(envMap),
+			cancelWindows:  convertToCancelWindows(envMap),
+			unpaidExpires:  convertToIntOrDefault(envMap["ORDER_UNPAID_EXPIRES"], "ORDER_UNPAID_EXPIRES", 0),
+			expireEvery:    convertToIntOrDefault(envMap["ORDER_EXPIRE_INTERVAL"], "ORDER_EXPIRE_INTERVAL", 5*60),
+		},
+		idempotency: &idempotency{
+			expires:    convertToIntOrDefault(envMap["IDEMPOTENCY_KEY_EXPIRES"], "IDEMPOTENCY_KEY_EXPIRES", 24*60*60),
+			purgeEvery: convertToIntOrDefault(envMap["IDEMPOTENCY_PURGE_INTERVAL"], "IDEMPOTENCY_PURGE_INTERVAL", 60*60),
 		},
 	}
 }
@@ -79,12 +355,34 @@ type ConfigImpl interface {
 	App() AppConfigImpl
 	DB() DBConfigImpl
 	JWT() JWTConfigImpl
+	RateLimit() RateLimitConfigImpl
+	OIDC() OIDCConfigImpl
+	Storage() StorageConfigImpl
+	Image() ImageConfigImpl
+	Upload() UploadConfigImpl
+	Product() ProductConfigImpl
+	Trash() TrashConfigImpl
+	Currency() CurrencyConfigImpl
+	Mail() MailConfigImpl
+	Order() OrderConfigImpl
+	Idempotency() IdempotencyConfigImpl
 }
 
 type config struct {
-	app *app
-	db  *db
-	jwt *jwt
+	app         *app
+	db          *db
+	jwt         *jwt
+	rateLimit   *rateLimit
+	oidc        *oidc
+	storage     *storage
+	image       *image
+	upload      *upload
+	product     *product
+	trash       *trash
+	currency    *currency
+	mail        *mail
+	order       *order
+	idempotency *idempotency
 }
 
 func (c *config) App() AppConfigImpl {
@@ -161,19 +459,35 @@ func (db *db) MaxOpenConnection() int { return db.maxConnection }
 type JWTConfigImpl interface {
 	SecretKey() []byte
 	AdminKey() []byte
-	ApiKey() []byte
 	AccessExpiresAt() int
 	RefreshExpiresAt() int
 	SetJwtAccessExpires(int)
 	SetJwtRefreshExpires(int)
+	SigningKeyId() string
+	SigningKey() crypto.Signer
+	PublicKey(kid string) (crypto.PublicKey, bool)
+	PublicKeys() map[string]crypto.PublicKey
+	AcceptsSecretKey() bool
+	ApiKey() []byte
+	ApiKeyLegacyUntil() time.Time
+	AcceptsLegacyApiKey() bool
 }
 
 type jwt struct {
 	adminKey         string
 	secertKey        string
-	apiKey           string
 	accessExpiresAt  int //seconds
 	refreshExpiresAt int //seconds
+
+	// ถ้าไม่ได้ตั้งค่า key จะ sign ด้วย JWT_SECERT_KEY แบบเดิม
+	signingKeyId string
+	signingKey   crypto.Signer
+	publicKeys   map[string]crypto.PublicKey // kid -> public key ที่ยังใช้ verify ได้
+	// token ที่ sign ด้วย JWT_SECERT_KEY ยังใช้ได้ถึงเวลานี้หลังเปลี่ยนไปใช้ JWT_SIGNING_KEYS
+	legacyUntil time.Time
+	// api key แบบ JWT รุ่นเก่าที่ sign ด้วย JWT_API_KEY ใช้ได้ถึงเวลานี้ เพื่อให้มีเวลาเปลี่ยนไปใช้ api key ใหม่
+	apiKey      string
+	apiKeyUntil time.Time
 }
 
 func (c *config) JWT() JWTConfigImpl {
@@ -182,8 +496,356 @@ func (c *config) JWT() JWTConfigImpl {
 
 func (j *jwt) SecretKey() []byte          { return []byte(j.secertKey) }
 func (j *jwt) AdminKey() []byte           { return []byte(j.adminKey) }
-func (j *jwt) ApiKey() []byte             { return []byte(j.apiKey) }
 func (j *jwt) AccessExpiresAt() int       { return j.accessExpiresAt }
 func (j *jwt) RefreshExpiresAt() int      { return j.refreshExpiresAt }
 func (j *jwt) SetJwtAccessExpires(t int)  { j.accessExpiresAt = t }
 func (j *jwt) SetJwtRefreshExpires(t int) { j.refreshExpiresAt = t }
+func (j *jwt) SigningKeyId() string       { return j.signingKeyId }
+func (j *jwt) SigningKey() crypto.Signer  { return j.signingKey }
+func (j *jwt) PublicKeys() map[string]crypto.PublicKey {
+	return j.publicKeys
+}
+
+// เมื่อมี key แล้ว secret เดิมต้องใช้ไม่ได้ ไม่อย่างนั้นยังใช้ secret เดิมสร้าง token ได้อยู่
+func (j *jwt) AcceptsSecretKey() bool {
+	if len(j.secertKey) == 0 {
+		return false
+	}
+	return len(j.publicKeys) == 0 || time.Now().Before(j.legacyUntil)
+}
+
+func (j *jwt) ApiKey() []byte               { return []byte(j.apiKey) }
+func (j *jwt) ApiKeyLegacyUntil() time.Time { return j.apiKeyUntil }
+
+func (j *jwt) AcceptsLegacyApiKey() bool {
+	return len(j.apiKey) > 0 && time.Now().Before(j.apiKeyUntil)
+}
+
+func (j *jwt) PublicKey(kid string) (crypto.PublicKey, bool) {
+	key, ok := j.publicKeys[kid]
+	return key, ok
+}
+
+type RateLimitConfigImpl interface {
+	Group(name string) (perMinute int, burst int, ok bool)
+	TierMultiplier(tier string) (float64, bool)
+}
+
+type rateLimit struct {
+	groups map[string][2]int  // name -> [requests per minute, burst]
+	tiers  map[string]float64 // tier ของ api key -> ตัวคูณ limit
+}
+
+func (c *config) RateLimit() RateLimitConfigImpl {
+	return c.rateLimit
+}
+
+func (r *rateLimit) Group(name string) (int, int, bool) {
+	g, ok := r.groups[name]
+	return g[0], g[1], ok
+}
+
+func (r *rateLimit) TierMultiplier(tier string) (float64, bool) {
+	m, ok := r.tiers[tier]
+	return m, ok
+}
+
+type OIDCConfigImpl interface {
+	Providers() []*OIDCProvider
+}
+
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+	// id_token ที่ sign ด้วย client secret ใช้ได้เฉพาะ provider ที่เปิดไว้ เช่น LINE
+	AllowHS256 bool
+}
+
+type oidc struct {
+	providers []*OIDCProvider
+}
+
+func (c *config) OIDC() OIDCConfigImpl {
+	return c.oidc
+}
+
+func (o *oidc) Providers() []*OIDCProvider { return o.providers }
+
+type StorageConfigImpl interface {
+	Driver() string
+	Bucket() string
+	PublicUrl() string
+	SignedUrl() string
+	SigningKey() []byte
+	SignedUrlExpires() time.Duration
+	LocalRoot() string
+	S3Endpoint() string
+	S3Region() string
+	S3AccessKey() string
+	S3SecretKey() string
+	OrphanGrace() int
+	SweepInterval() time.Duration
+}
+
+type storage struct {
+	driver      string // local, gcs หรือ s3
+	bucket      string
+	publicUrl   string // url ที่ใช้เข้าถึงไฟล์ ถ้าว่างจะใช้ url ของ provider
+	signedUrl   string
+	signingKey  string // ใช้ sign url ของไฟล์ private บน local storage
+	signedTTL   int    // seconds ที่ signed url ใช้งานได้ ถ้า client ไม่ได้ระบุ
+	localRoot   string
+	s3Endpoint  string
+	s3Region    string
+	s3AccessKey string
+	s3SecretKey string
+	orphanGrace int // seconds ที่ไฟล์ซึ่งไม่มีใครใช้งานจะถูกเก็บไว้ก่อนลบ
+	sweepEvery  int // seconds, 0 คือไม่ลบอัตโนมัติ
+}
+
+func (c *config) Storage() StorageConfigImpl {
+	return c.storage
+}
+
+func (s *storage) Driver() string {
+	if s.driver == "" {
+		return "local"
+	}
+	return s.driver
+}
+func (s *storage) Bucket() string     { return s.bucket }
+func (s *storage) PublicUrl() string  { return s.publicUrl }
+func (s *storage) SignedUrl() string  { return s.signedUrl }
+func (s *storage) SigningKey() []byte { return []byte(s.signingKey) }
+func (s *storage) SignedUrlExpires() time.Duration {
+	return time.Duration(s.signedTTL) * time.Second
+}
+func (s *storage) LocalRoot() string {
+	if s.localRoot == "" {
+		return "./assets/images"
+	}
+	return s.localRoot
+}
+func (s *storage) S3Endpoint() string  { return s.s3Endpoint }
+func (s *storage) S3Region() string    { return s.s3Region }
+func (s *storage) S3AccessKey() string { return s.s3AccessKey }
+func (s *storage) S3SecretKey() string { return s.s3SecretKey }
+func (s *storage) OrphanGrace() int    { return s.orphanGrace }
+func (s *storage) SweepInterval() time.Duration {
+	return time.Duration(s.sweepEvery) * time.Second
+}
+
+type ImageConfigImpl interface {
+	Renditions() []*ImageRendition
+	Formats() []string
+	Quality() int
+}
+
+type ImageRendition struct {
+	Name    string
+	MaxSize int
+}
+
+type image struct {
+	renditions []*ImageRendition
+	formats    []string // webp, avif จะสร้างได้เมื่อมี cwebp, avifenc ในเครื่อง
+	quality    int
+}
+
+func (c *config) Image() ImageConfigImpl {
+	return c.image
+}
+
+func (i *image) Renditions() []*ImageRendition { return i.renditions }
+func (i *image) Formats() []string             { return i.formats }
+func (i *image) Quality() int                  { return i.quality }
+
+type UploadConfigImpl interface {
+	UserQuota() int64
+	MaxPixels() int
+	ScannerDriver() string
+	ClamAVAddr() string
+	ChunkSize() int64
+	MaxSize() int64
+	SessionExpires() time.Duration
+}
+
+type upload struct {
+	userQuota     int64 // bytes ที่ user แต่ละคน upload ได้รวมกัน 0 คือไม่จำกัด
+	maxPixels     int   // กันรูปที่ขนาดไฟล์เล็กแต่ decode แล้วใช้ memory มหาศาล
+	scannerDriver string
+	clamavAddr    string
+	chunkSize     int64 // bytes สูงสุดของแต่ละ chunk ใน resumable upload
+	maxSize       int64 // bytes สูงสุดของไฟล์ที่ upload แบบ resumable
+	sessionTTL    int   // seconds ที่ upload ที่ยังไม่เสร็จจะถูกเก็บไว้ก่อนลบ
+}
+
+func (c *config) Upload() UploadConfigImpl {
+	return c.upload
+}
+
+func (u *upload) UserQuota() int64      { return u.userQuota }
+func (u *upload) MaxPixels() int        { return u.maxPixels }
+func (u *upload) ScannerDriver() string { return u.scannerDriver }
+func (u *upload) ClamAVAddr() string    { return u.clamavAddr }
+func (u *upload) ChunkSize() int64      { return u.chunkSize }
+func (u *upload) MaxSize() int64        { return u.maxSize }
+func (u *upload) SessionExpires() time.Duration {
+	return time.Duration(u.sessionTTL) * time.Second
+}
+
+type ProductConfigImpl interface {
+	ScheduleInterval() time.Duration
+	DownloadUrl() string
+	DownloadLinkExpires() time.Duration
+}
+
+type product struct {
+	scheduleEvery int    // seconds ที่ตรวจ publish_at และ unpublish_at, 0 คือไม่เปลี่ยนสถานะอัตโนมัติ
+	downloadUrl   string // url ของ endpoint ที่ส่งไฟล์ของสินค้าดิจิทัล
+	downloadTTL   int    // seconds ที่ลิงก์ดาวน์โหลดใช้ได้
+}
+
+func (c *config) Product() ProductConfigImpl {
+	return c.product
+}
+
+func (p *product) ScheduleInterval() time.Duration {
+	return time.Duration(p.scheduleEvery) * time.Second
+}
+
+func (p *product) DownloadUrl() string { return p.downloadUrl }
+func (p *product) DownloadLinkExpires() time.Duration {
+	return time.Duration(p.downloadTTL) * time.Second
+}
+
+type TrashConfigImpl interface {
+	Retention() time.Duration
+	PurgeInterval() time.Duration
+}
+
+type trash struct {
+	retention  int // seconds ที่ข้อมูลที่ถูกลบจะอยู่ในถังขยะก่อนถูกลบถาวร
+	purgeEvery int // seconds, 0 คือไม่ลบถาวรอัตโนมัติ
+}
+
+func (c *config) Trash() TrashConfigImpl {
+	return c.trash
+}
+
+func (t *trash) Retention() time.Duration {
+	return time.Duration(t.retention) * time.Second
+}
+func (t *trash) PurgeInterval() time.Duration {
+	return time.Duration(t.purgeEvery) * time.Second
+}
+
+type CurrencyConfigImpl interface {
+	ImporterDriver() string
+	ImporterUrl() string
+	ImportInterval() time.Duration
+}
+
+type currency struct {
+	importerDriver string
+	importerUrl    string
+	importEvery    int // seconds, 0 คือไม่ดึงอัตราแลกเปลี่ยนอัตโนมัติ
+}
+
+func (c *config) Currency() CurrencyConfigImpl {
+	return c.currency
+}
+
+func (c *currency) ImporterDriver() string { return c.importerDriver }
+func (c *currency) ImporterUrl() string    { return c.importerUrl }
+func (c *currency) ImportInterval() time.Duration {
+	return time.Duration(c.importEvery) * time.Second
+}
+
+type MailConfigImpl interface {
+	Driver() string
+	From() string
+	SMTPHost() string
+	SMTPPort() int
+	SMTPUsername() string
+	SMTPPassword() string
+	VerifyEmailUrl() string
+}
+
+type mail struct {
+	driver         string
+	from           string
+	smtpHost       string
+	smtpPort       int
+	smtpUsername   string
+	smtpPassword   string
+	verifyEmailUrl string // url ของหน้ายืนยัน email ที่ส่งไปหลังสมัครสมาชิก
+}
+
+func (c *config) Mail() MailConfigImpl {
+	return c.mail
+}
+
+func (m *mail) Driver() string         { return m.driver }
+func (m *mail) From() string           { return m.from }
+func (m *mail) SMTPHost() string       { return m.smtpHost }
+func (m *mail) SMTPPort() int          { return m.smtpPort }
+func (m *mail) SMTPUsername() string   { return m.smtpUsername }
+func (m *mail) SMTPPassword() string   { return m.smtpPassword }
+func (m *mail) VerifyEmailUrl() string { return m.verifyEmailUrl }
+
+type OrderConfigImpl interface {
+	GuestLookupUrl() string
+	CancelWindows() map[string]time.Duration
+	UnpaidExpires() time.Duration
+	ExpireInterval() time.Duration
+}
+
+type order struct {
+	guestLookupUrl string // url ของหน้าติดตาม order ที่ส่งให้ลูกค้าที่ไม่ได้สมัครสมาชิกทาง email
+	// เวลาที่ลูกค้ายกเลิก order เองได้ในแต่ละสถานะ นับจากตอนสั่งซื้อ 0 คือไม่จำกัดเวลา
+	// สถานะที่ไม่ได้กำหนดลูกค้ายกเลิกเองไม่ได้ ส่วน admin ยกเลิกได้เสมอ
+	cancelWindows map[string]time.Duration
+	unpaidExpires int // seconds ที่ order ยังไม่แนบ slip ได้ก่อนถูกยกเลิกอัตโนมัติ 0 คือไม่ยกเลิกอัตโนมัติ
+	expireEvery   int // seconds
+}
+
+func (c *config) Order() OrderConfigImpl {
+	return c.order
+}
+
+func (o *order) GuestLookupUrl() string                  { return o.guestLookupUrl }
+func (o *order) CancelWindows() map[string]time.Duration { return o.cancelWindows }
+
+func (o *order) UnpaidExpires() time.Duration {
+	return time.Duration(o.unpaidExpires) * time.Second
+}
+
+func (o *order) ExpireInterval() time.Duration {
+	return time.Duration(o.expireEvery) * time.Second
+}
+
+type IdempotencyConfigImpl interface {
+	Expires() time.Duration
+	PurgeInterval() time.Duration
+}
+
+type idempotency struct {
+	expires    int // seconds ที่เก็บ response ไว้ตอบ request ที่ส่ง Idempotency-Key เดิมซ้ำ
+	purgeEvery int // seconds, 0 คือไม่ลบ key ที่หมดอายุแล้วอัตโนมัติ
+}
+
+func (c *config) Idempotency() IdempotencyConfigImpl {
+	return c.idempotency
+}
+
+func (i *idempotency) Expires() time.Duration {
+	return time.Duration(i.expires) * time.Second
+}
+func (i *idempotency) PurgeInterval() time.Duration {
+	return time.Duration(i.purgeEvery) * time.Second
+}
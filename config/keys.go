@@ -0,0 +1,60 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"os"
+)
+
+// อ่าน key จากไฟล์ pem ใน JWT_SIGNING_KEYS="kid:path,kid:path"
+// ไฟล์ที่เป็น private key ใช้ sign และ verify ได้ ส่วน public key ใช้ verify token เก่าได้อย่างเดียว
+func convertToSigningKeys(value, nameEnv string) (map[string]crypto.Signer, map[string]crypto.PublicKey) {
+	signers := make(map[string]crypto.Signer)
+	publicKeys := make(map[string]crypto.PublicKey)
+
+	for kid, fields := range convertToMap(value, nameEnv, 2) {
+		data, err := os.ReadFile(fields[0])
+		if err != nil {
+			log.Fatalf("Error read signing key name: %s kid: %s err: %s", nameEnv, kid, err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			log.Fatalf("Error decode signing key name: %s kid: %s", nameEnv, kid)
+		}
+
+		key, err := parseSigningKey(block.Bytes)
+		if err != nil {
+			log.Fatalf("Error parse signing key name: %s kid: %s err: %s", nameEnv, kid, err)
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			signers[kid] = k
+			publicKeys[kid] = &k.PublicKey
+		case ed25519.PrivateKey:
+			signers[kid] = k
+			publicKeys[kid] = k.Public()
+		case *rsa.PublicKey, ed25519.PublicKey:
+			publicKeys[kid] = k
+		default:
+			log.Fatalf("Error signing key name: %s kid: %s must be rsa or ed25519", nameEnv, kid)
+		}
+	}
+	return signers, publicKeys
+}
+
+func parseSigningKey(der []byte) (any, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKIXPublicKey(der)
+}
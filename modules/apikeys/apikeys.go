@@ -0,0 +1,78 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// key ที่สร้างจะขึ้นต้นด้วย prefix นี้ เพื่อให้รู้ว่าเป็น api key ของระบบเรา
+const keyPrefix = "gec_"
+
+// tier ที่ใช้เมื่อไม่ได้กำหนด ใช้คู่กับ rate limit
+const DefaultTier = "default"
+
+// api key แบบ JWT รุ่นเก่าไม่มี scope จึงได้เท่ากับ route ที่เคยใช้ได้
+var LegacyScopes = []string{"products:read", "categories:read"}
+
+func IsLegacyKey(key string) bool {
+	return !strings.HasPrefix(key, keyPrefix)
+}
+
+type ApiKey struct {
+	Id         string   `db:"id" json:"id"`
+	Name       string   `db:"name" json:"name"`
+	OwnerId    string   `db:"owner_id" json:"owner_id"`
+	Prefix     string   `db:"prefix" json:"prefix"`
+	Scopes     []string `db:"scopes" json:"scopes"`
+	Tier       string   `db:"tier" json:"tier"`
+	ExpiresAt  *string  `db:"expires_at" json:"expires_at"`
+	LastUsedAt *string  `db:"last_used_at" json:"last_used_at"`
+	RevokedAt  *string  `db:"revoked_at" json:"revoked_at"`
+	CreatedAt  string   `db:"created_at" json:"created_at"`
+	UpdatedAt  string   `db:"updated_at" json:"updated_at"`
+	Key        string   `db:"-" json:"key,omitempty"` // ส่งกลับแค่ครั้งเดียวตอนสร้าง
+}
+
+type ApiKeyReq struct {
+	Id        string   `json:"-"`
+	Name      string   `json:"name" form:"name"`
+	OwnerId   string   `json:"owner_id" form:"owner_id"`
+	Scopes    []string `json:"scopes" form:"scopes"`
+	Tier      string   `json:"tier" form:"tier"`
+	ExpiresAt string   `json:"expires_at" form:"expires_at"` // RFC3339
+	KeyHash   string   `json:"-"`
+	Prefix    string   `json:"-"`
+}
+
+type ApiKeyFilter struct {
+	OwnerId string `query:"owner_id"`
+	Revoked bool   `query:"revoked"`
+}
+
+func (a *ApiKey) HasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// สร้าง key แบบสุ่ม คืนค่า key จริงและ prefix ที่ใช้แสดงผล
+func GenerateKey() (string, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate api key failed: %v", err)
+	}
+	key := keyPrefix + hex.EncodeToString(b)
+	return key, key[:len(keyPrefix)+8], nil
+}
+
+// เก็บเฉพาะ hash ของ key ลง database
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package apikeysHandlers
+
+import (
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys/apikeysUsecases"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+type apikeysHandlersErrCode string
+
+const (
+	findApiKeyErr    apikeysHandlersErrCode = "apikeys-001"
+	findOneApiKeyErr apikeysHandlersErrCode = "apikeys-002"
+	addApiKeyErr     apikeysHandlersErrCode = "apikeys-003"
+	updateApiKeyErr  apikeysHandlersErrCode = "apikeys-004"
+	revokeApiKeyErr  apikeysHandlersErrCode = "apikeys-005"
+)
+
+type IApikeysHandler interface {
+	FindApiKey(c *fiber.Ctx) error
+	FindOneApiKey(c *fiber.Ctx) error
+	AddApiKey(c *fiber.Ctx) error
+	UpdateApiKey(c *fiber.Ctx) error
+	RevokeApiKey(c *fiber.Ctx) error
+}
+
+type apikeysHandler struct {
+	cfg            config.ConfigImpl
+	apikeysUsecase apikeysUsecases.IApikeysUsecase
+}
+
+func ApikeysHandler(cfg config.ConfigImpl, apikeysUsecase apikeysUsecases.IApikeysUsecase) IApikeysHandler {
+	return &apikeysHandler{
+		cfg:            cfg,
+		apikeysUsecase: apikeysUsecase,
+	}
+}
+
+// tier ต้องเป็นค่าที่ตั้งไว้ใน RATE_LIMIT_TIERS
+func (h *apikeysHandler) isValidTier(tier string) bool {
+	if tier == "" {
+		return true
+	}
+	_, ok := h.cfg.RateLimit().TierMultiplier(tier)
+	return ok
+}
+
+func (h *apikeysHandler) FindApiKey(c *fiber.Ctx) error {
+	req := new(apikeys.ApiKeyFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+
+	result, err := h.apikeysUsecase.FindApiKey(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *apikeysHandler) FindOneApiKey(c *fiber.Ctx) error {
+	apiKeyId := strings.Trim(c.Params("apikey_id"), " ")
+
+	result, err := h.apikeysUsecase.FindOneApiKey(apiKeyId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findOneApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *apikeysHandler) AddApiKey(c *fiber.Ctx) error {
+	req := new(apikeys.ApiKeyReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	if req.OwnerId == "" {
+		req.OwnerId = c.Locals("userId").(string)
+	}
+	if !h.isValidTier(req.Tier) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addApiKeyErr),
+			"tier is invalid",
+		).Res()
+	}
+
+	result, err := h.apikeysUsecase.AddApiKey(req)
+	if err != nil {
+		switch err.Error() {
+		case "name is required", "scope is invalid", "expires_at is invalid", "expires_at must be in the future":
+			return entities.NewResponse(c).Error(fiber.ErrBadRequest.Code, string(addApiKeyErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(addApiKeyErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, result).Res()
+}
+
+func (h *apikeysHandler) UpdateApiKey(c *fiber.Ctx) error {
+	req := new(apikeys.ApiKeyReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("apikey_id"), " ")
+	if !h.isValidTier(req.Tier) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateApiKeyErr),
+			"tier is invalid",
+		).Res()
+	}
+
+	result, err := h.apikeysUsecase.UpdateApiKey(req)
+	if err != nil {
+		switch err.Error() {
+		case "scope is invalid", "expires_at is invalid", "expires_at must be in the future":
+			return entities.NewResponse(c).Error(fiber.ErrBadRequest.Code, string(updateApiKeyErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(updateApiKeyErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *apikeysHandler) RevokeApiKey(c *fiber.Ctx) error {
+	apiKeyId := strings.Trim(c.Params("apikey_id"), " ")
+
+	if err := h.apikeysUsecase.RevokeApiKey(apiKeyId); err != nil {
+		switch err.Error() {
+		case "api key not found":
+			return entities.NewResponse(c).Error(fiber.ErrNotFound.Code, string(revokeApiKeyErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(revokeApiKeyErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			ApiKeyId string `json:"apikey_id"`
+		}{
+			ApiKeyId: apiKeyId,
+		},
+	).Res()
+}
@@ -0,0 +1,218 @@
+package apikeysRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
+	"github.com/jmoiron/sqlx"
+)
+
+type IApikeysRepository interface {
+	FindApiKey(req *apikeys.ApiKeyFilter) ([]*apikeys.ApiKey, error)
+	FindOneApiKey(apiKeyId string) (*apikeys.ApiKey, error)
+	InsertApiKey(req *apikeys.ApiKeyReq) (string, error)
+	UpdateApiKey(req *apikeys.ApiKeyReq) error
+	RevokeApiKey(apiKeyId string) error
+	CountPermission(codes []string) (int, error)
+}
+
+type apikeysRepository struct {
+	db *sqlx.DB
+}
+
+func ApikeysRepository(db *sqlx.DB) IApikeysRepository {
+	return &apikeysRepository{db: db}
+}
+
+const apiKeyFields = `
+			"k"."id",
+			"k"."name",
+			"k"."owner_id",
+			"k"."prefix",
+			"k"."scopes",
+			"k"."tier",
+			"k"."expires_at",
+			"k"."last_used_at",
+			"k"."revoked_at",
+			"k"."created_at",
+			"k"."updated_at"`
+
+func (r *apikeysRepository) FindApiKey(req *apikeys.ApiKeyFilter) ([]*apikeys.ApiKey, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT` + apiKeyFields + `
+		FROM "api_keys" "k"
+		WHERE 1 = 1`
+
+	values := make([]any, 0)
+	if req.OwnerId != "" {
+		values = append(values, req.OwnerId)
+		query += fmt.Sprintf(`
+		AND "k"."owner_id" = $%d`, len(values))
+	}
+	if !req.Revoked {
+		query += `
+		AND "k"."revoked_at" IS NULL`
+	}
+	query += `
+		ORDER BY "k"."created_at" DESC
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, values...); err != nil {
+		return nil, fmt.Errorf("get api keys failed: %v", err)
+	}
+
+	keys := make([]*apikeys.ApiKey, 0)
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("unmarshal api keys failed: %v", err)
+	}
+	return keys, nil
+}
+
+func (r *apikeysRepository) FindOneApiKey(apiKeyId string) (*apikeys.ApiKey, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT` + apiKeyFields + `
+		FROM "api_keys" "k"
+		WHERE "k"."id" = $1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, apiKeyId); err != nil {
+		return nil, fmt.Errorf("get api key failed: %v", err)
+	}
+
+	key := new(apikeys.ApiKey)
+	if err := json.Unmarshal(raw, key); err != nil {
+		return nil, fmt.Errorf("unmarshal api key failed: %v", err)
+	}
+	return key, nil
+}
+
+func (r *apikeysRepository) InsertApiKey(req *apikeys.ApiKeyReq) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	scopes, err := json.Marshal(req.Scopes)
+	if err != nil {
+		return "", fmt.Errorf("marshal scopes failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "api_keys" (
+		"name",
+		"owner_id",
+		"prefix",
+		"key_hash",
+		"scopes",
+		"tier",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::TIMESTAMPTZ)
+		RETURNING "id";`
+
+	var id string
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.Name,
+		req.OwnerId,
+		req.Prefix,
+		req.KeyHash,
+		scopes,
+		req.Tier,
+		req.ExpiresAt,
+	).Scan(&id); err != nil {
+		return "", fmt.Errorf("insert api key failed: %v", err)
+	}
+	return id, nil
+}
+
+func (r *apikeysRepository) UpdateApiKey(req *apikeys.ApiKeyReq) error {
+	query := `
+	UPDATE "api_keys" SET`
+
+	fields := make([]string, 0)
+	values := make([]any, 0)
+
+	if req.Name != "" {
+		values = append(values, req.Name)
+		fields = append(fields, fmt.Sprintf(`
+		"name" = $%d`, len(values)))
+	}
+	if req.Scopes != nil {
+		scopes, err := json.Marshal(req.Scopes)
+		if err != nil {
+			return fmt.Errorf("marshal scopes failed: %v", err)
+		}
+		values = append(values, scopes)
+		fields = append(fields, fmt.Sprintf(`
+		"scopes" = $%d`, len(values)))
+	}
+	if req.Tier != "" {
+		values = append(values, req.Tier)
+		fields = append(fields, fmt.Sprintf(`
+		"tier" = $%d`, len(values)))
+	}
+	if req.ExpiresAt != "" {
+		values = append(values, req.ExpiresAt)
+		fields = append(fields, fmt.Sprintf(`
+		"expires_at" = ($%d)::TIMESTAMPTZ`, len(values)))
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for i := range fields {
+		if i != len(fields)-1 {
+			query += fields[i] + ","
+		} else {
+			query += fields[i]
+		}
+	}
+	values = append(values, req.Id)
+	query += fmt.Sprintf(`
+	WHERE "id" = $%d
+	AND "revoked_at" IS NULL;`, len(values))
+
+	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
+		return fmt.Errorf("update api key failed: %v", err)
+	}
+	return nil
+}
+
+func (r *apikeysRepository) RevokeApiKey(apiKeyId string) error {
+	query := `
+	UPDATE "api_keys" SET
+		"revoked_at" = now()
+	WHERE "id" = $1
+	AND "revoked_at" IS NULL;`
+
+	result, err := r.db.ExecContext(context.Background(), query, apiKeyId)
+	if err != nil {
+		return fmt.Errorf("revoke api key failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
+
+// ใช้ตรวจสอบว่า scope ที่ส่งมาเป็น permission ที่มีอยู่จริง
+func (r *apikeysRepository) CountPermission(codes []string) (int, error) {
+	query := `SELECT COUNT(*) FROM "permissions" WHERE "code" = ANY($1);`
+
+	var count int
+	if err := r.db.Get(&count, query, codes); err != nil {
+		return 0, fmt.Errorf("count permissions failed: %v", err)
+	}
+	return count, nil
+}
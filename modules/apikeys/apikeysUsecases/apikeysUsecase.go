@@ -0,0 +1,128 @@
+package apikeysUsecases
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys/apikeysRepositories"
+)
+
+type IApikeysUsecase interface {
+	FindApiKey(req *apikeys.ApiKeyFilter) ([]*apikeys.ApiKey, error)
+	FindOneApiKey(apiKeyId string) (*apikeys.ApiKey, error)
+	AddApiKey(req *apikeys.ApiKeyReq) (*apikeys.ApiKey, error)
+	UpdateApiKey(req *apikeys.ApiKeyReq) (*apikeys.ApiKey, error)
+	RevokeApiKey(apiKeyId string) error
+}
+
+type apikeysUsecase struct {
+	apikeysRepository apikeysRepositories.IApikeysRepository
+}
+
+func ApikeysUsecase(apikeysRepository apikeysRepositories.IApikeysRepository) IApikeysUsecase {
+	return &apikeysUsecase{
+		apikeysRepository: apikeysRepository,
+	}
+}
+
+// ตรวจสอบ scope และ expires_at ก่อนบันทึก
+func (u *apikeysUsecase) validate(req *apikeys.ApiKeyReq) error {
+	if req.Scopes != nil {
+		seen := make(map[string]bool)
+		scopes := make([]string, 0)
+		for _, s := range req.Scopes {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if s == "" || seen[s] {
+				continue
+			}
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+		req.Scopes = scopes
+
+		if len(scopes) > 0 {
+			count, err := u.apikeysRepository.CountPermission(scopes)
+			if err != nil {
+				return err
+			}
+			if count != len(scopes) {
+				return fmt.Errorf("scope is invalid")
+			}
+		}
+	}
+
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("expires_at is invalid")
+		}
+		if expiresAt.Before(time.Now()) {
+			return fmt.Errorf("expires_at must be in the future")
+		}
+	}
+	return nil
+}
+
+func (u *apikeysUsecase) FindApiKey(req *apikeys.ApiKeyFilter) ([]*apikeys.ApiKey, error) {
+	return u.apikeysRepository.FindApiKey(req)
+}
+
+func (u *apikeysUsecase) FindOneApiKey(apiKeyId string) (*apikeys.ApiKey, error) {
+	return u.apikeysRepository.FindOneApiKey(apiKeyId)
+}
+
+func (u *apikeysUsecase) AddApiKey(req *apikeys.ApiKeyReq) (*apikeys.ApiKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Scopes == nil {
+		req.Scopes = make([]string, 0)
+	}
+	if req.Tier == "" {
+		req.Tier = apikeys.DefaultTier
+	}
+	if err := u.validate(req); err != nil {
+		return nil, err
+	}
+
+	key, prefix, err := apikeys.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	req.KeyHash = apikeys.HashKey(key)
+	req.Prefix = prefix
+
+	apiKeyId, err := u.apikeysRepository.InsertApiKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := u.apikeysRepository.FindOneApiKey(apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+	result.Key = key
+	return result, nil
+}
+
+func (u *apikeysUsecase) UpdateApiKey(req *apikeys.ApiKeyReq) (*apikeys.ApiKey, error) {
+	if _, err := u.apikeysRepository.FindOneApiKey(req.Id); err != nil {
+		return nil, err
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := u.validate(req); err != nil {
+		return nil, err
+	}
+
+	if err := u.apikeysRepository.UpdateApiKey(req); err != nil {
+		return nil, err
+	}
+	return u.apikeysRepository.FindOneApiKey(req.Id)
+}
+
+func (u *apikeysUsecase) RevokeApiKey(apiKeyId string) error {
+	return u.apikeysRepository.RevokeApiKey(apiKeyId)
+}
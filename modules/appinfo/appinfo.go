@@ -8,3 +8,28 @@ type Category struct {
 	Id    int    `db:"id" json:"id"`
 	Title string `db:"title" json:"title"`
 }
+
+// ชนิดของ attribute ที่หมวดหมู่กำหนดให้สินค้า
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeEnum    = "enum"
+	AttributeBoolean = "boolean"
+)
+
+func IsAttributeType(t string) bool {
+	return t == AttributeText || t == AttributeNumber || t == AttributeEnum || t == AttributeBoolean
+}
+
+// ค่าของ attribute เก็บไว้ที่สินค้าด้วย code เป็น key
+type CategoryAttribute struct {
+	Id         int      `json:"id"`
+	CategoryId int      `json:"category_id"`
+	Code       string   `json:"code"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Options    []string `json:"options"` // ค่าที่เลือกได้ของ enum
+	Unit       string   `json:"unit"`
+	Required   bool     `json:"required"`
+	Filterable bool     `json:"filterable"` // ใช้กรองรายการสินค้าได้
+}
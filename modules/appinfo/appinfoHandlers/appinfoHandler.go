@@ -1,6 +1,7 @@
 package appinfoHandlers
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -8,24 +9,27 @@ import (
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo/appinfoUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
-	"github.com/Doittikorn/go-e-commerce/pkg/auth"
 	"github.com/gofiber/fiber/v2"
 )
 
 type appinfoHandlersErrCode string
 
 const (
-	generateApiKeyErr appinfoHandlersErrCode = "appinfo-001"
-	findCategoryErr   appinfoHandlersErrCode = "appinfo-002"
-	addCategoryErr    appinfoHandlersErrCode = "appinfo-003"
-	removeCategoryErr appinfoHandlersErrCode = "appinfo-004"
+	findCategoryErr    appinfoHandlersErrCode = "appinfo-002"
+	addCategoryErr     appinfoHandlersErrCode = "appinfo-003"
+	removeCategoryErr  appinfoHandlersErrCode = "appinfo-004"
+	findAttributeErr   appinfoHandlersErrCode = "appinfo-005"
+	addAttributeErr    appinfoHandlersErrCode = "appinfo-006"
+	removeAttributeErr appinfoHandlersErrCode = "appinfo-007"
 )
 
 type IAppinfoHandler interface {
-	GenerateApiKey(c *fiber.Ctx) error
 	FindCategory(c *fiber.Ctx) error
 	AddCategory(c *fiber.Ctx) error
 	RemoveCategory(c *fiber.Ctx) error
+	FindCategoryAttribute(c *fiber.Ctx) error
+	AddCategoryAttribute(c *fiber.Ctx) error
+	RemoveCategoryAttribute(c *fiber.Ctx) error
 }
 
 type appinfoHandler struct {
@@ -40,29 +44,6 @@ func AppinfoHandler(cfg config.ConfigImpl, appinfoUsecase appinfoUsecases.IAppin
 	}
 }
 
-func (h *appinfoHandler) GenerateApiKey(c *fiber.Ctx) error {
-	apiKey, err := auth.New(
-		auth.ApiKey,
-		h.cfg.JWT(),
-		nil,
-	)
-	if err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(generateApiKeyErr),
-			err.Error(),
-		).Res()
-	}
-	return entities.NewResponse(c).Success(
-		fiber.StatusOK,
-		&struct {
-			Key string `json:"key"`
-		}{
-			Key: apiKey.SignToken(),
-		},
-	).Res()
-}
-
 func (h *appinfoHandler) FindCategory(c *fiber.Ctx) error {
 	req := new(appinfo.CategoryFilter)
 	if err := c.QueryParser(req); err != nil {
@@ -130,19 +111,164 @@ func (h *appinfoHandler) RemoveCategory(c *fiber.Ctx) error {
 	}
 
 	if err := h.appinfoUsecase.DeleteCategory(categoryIdInt); err != nil {
+		switch err.Error() {
+		case "category not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(removeCategoryErr),
+				err.Error(),
+			).Res()
+		case "category is still used by products":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(removeCategoryErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(removeCategoryErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			CategoryId int `json:"category_id"`
+		}{
+			CategoryId: categoryIdInt,
+		},
+	).Res()
+}
+
+func parseIdParam(c *fiber.Ctx, key string) (int, error) {
+	id, err := strconv.Atoi(strings.Trim(c.Params(key), " "))
+	if err != nil {
+		return 0, fmt.Errorf("id type is invalid")
+	}
+	if id <= 0 {
+		return 0, fmt.Errorf("id must more than 0")
+	}
+	return id, nil
+}
+
+func (h *appinfoHandler) FindCategoryAttribute(c *fiber.Ctx) error {
+	categoryId, err := parseIdParam(c, "category_id")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findAttributeErr),
+			err.Error(),
+		).Res()
+	}
+
+	attributes, err := h.appinfoUsecase.FindCategoryAttribute(categoryId)
+	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
-			string(removeCategoryErr),
+			string(findAttributeErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, attributes).Res()
+}
+
+func (h *appinfoHandler) AddCategoryAttribute(c *fiber.Ctx) error {
+	categoryId, err := parseIdParam(c, "category_id")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addAttributeErr),
+			err.Error(),
+		).Res()
+	}
+
+	req := &appinfo.CategoryAttribute{
+		Options: make([]string, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addAttributeErr),
+			err.Error(),
+		).Res()
+	}
+	req.CategoryId = categoryId
+
+	if err := h.appinfoUsecase.InsertCategoryAttribute(req); err != nil {
+		switch err.Error() {
+		case "attribute code is invalid", "attribute name is required", "attribute type is invalid", "attribute options are invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(addAttributeErr),
+				err.Error(),
+			).Res()
+		case "category not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(addAttributeErr),
+				err.Error(),
+			).Res()
+		case "attribute code already exists":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(addAttributeErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(addAttributeErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, req).Res()
+}
+
+func (h *appinfoHandler) RemoveCategoryAttribute(c *fiber.Ctx) error {
+	categoryId, err := parseIdParam(c, "category_id")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(removeAttributeErr),
+			err.Error(),
+		).Res()
+	}
+	attributeId, err := parseIdParam(c, "attribute_id")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(removeAttributeErr),
 			err.Error(),
 		).Res()
 	}
 
+	if err := h.appinfoUsecase.DeleteCategoryAttribute(categoryId, attributeId); err != nil {
+		switch err.Error() {
+		case "attribute not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(removeAttributeErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(removeAttributeErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
 	return entities.NewResponse(c).Success(
 		fiber.StatusOK,
 		&struct {
-			CategoryId int `json:"category_id"`
+			AttributeId int `json:"attribute_id"`
 		}{
-			CategoryId: categoryIdInt,
+			AttributeId: attributeId,
 		},
 	).Res()
 }
@@ -2,6 +2,9 @@ package appinfoRepositories
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -13,6 +16,9 @@ type IAppinfoRepository interface {
 	FindCategory(req *appinfo.CategoryFilter) ([]*appinfo.Category, error)
 	InsertCategory(req []*appinfo.Category) error
 	DeleteCategory(categoryId int) error
+	FindCategoryAttribute(categoryId int) ([]*appinfo.CategoryAttribute, error)
+	InsertCategoryAttribute(req *appinfo.CategoryAttribute) error
+	DeleteCategoryAttribute(categoryId, attributeId int) error
 }
 
 type appinfoRepository struct {
@@ -28,12 +34,13 @@ func (r *appinfoRepository) FindCategory(req *appinfo.CategoryFilter) ([]*appinf
 	SELECT
 		"id",
 		"title"
-	FROM "categories"`
+	FROM "categories"
+	WHERE "deleted_at" IS NULL`
 
 	filterValues := make([]any, 0)
 	if req.Title != "" {
 		query += `
-		WHERE (LOWER("title") LIKE $1)`
+		AND (LOWER("title") LIKE $1)`
 
 		filterValues = append(filterValues, "%"+strings.ToLower(req.Title)+"%")
 	}
@@ -97,13 +104,164 @@ func (r *appinfoRepository) InsertCategory(req []*appinfo.Category) error {
 	return nil
 }
 
+// ย้ายหมวดหมู่ไปถังขยะ ต้องย้ายสินค้าที่ยังใช้หมวดหมู่นี้ออกก่อน เพื่อไม่ให้สินค้าหลุดจากหมวดหมู่โดยไม่รู้ตัว
 func (r *appinfoRepository) DeleteCategory(categoryId int) error {
 	ctx := context.Background()
 
-	query := `DELETE FROM "categories" WHERE "id" = $1;`
+	query := `
+	UPDATE "categories" SET
+		"deleted_at" = now()
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "products_categories" "pc"
+			JOIN "products" "p" ON "p"."id" = "pc"."product_id"
+		WHERE "pc"."category_id" = $1
+		AND "p"."deleted_at" IS NULL
+	)
+	RETURNING "id";`
+
+	var id int
+	if err := r.db.QueryRowxContext(ctx, query, categoryId).Scan(&id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("delete cateogry failed: %v", err)
+		}
+
+		var exists bool
+		if err := r.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM "categories" WHERE "id" = $1 AND "deleted_at" IS NULL);`, categoryId); err != nil {
+			return fmt.Errorf("delete cateogry failed: %v", err)
+		}
+		if !exists {
+			return fmt.Errorf("category not found")
+		}
+		return fmt.Errorf("category is still used by products")
+	}
+	return nil
+}
+
+func (r *appinfoRepository) FindCategoryAttribute(categoryId int) ([]*appinfo.CategoryAttribute, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t" ORDER BY "t"."id")), '[]'::json)
+	FROM (
+		SELECT
+			"a"."id",
+			"a"."category_id",
+			"a"."code",
+			"a"."name",
+			"a"."type",
+			"a"."options",
+			"a"."unit",
+			"a"."required",
+			"a"."filterable"
+		FROM "category_attributes" "a"
+			JOIN "categories" "c" ON "c"."id" = "a"."category_id"
+		WHERE "a"."category_id" = $1
+		AND "c"."deleted_at" IS NULL
+	) AS "t";`
+
+	attributesBytes := make([]byte, 0)
+	if err := r.db.Get(&attributesBytes, query, categoryId); err != nil {
+		return nil, fmt.Errorf("get category attributes failed: %v", err)
+	}
+
+	attributes := make([]*appinfo.CategoryAttribute, 0)
+	if err := json.Unmarshal(attributesBytes, &attributes); err != nil {
+		return nil, fmt.Errorf("unmarshal category attributes failed: %v", err)
+	}
+	return attributes, nil
+}
+
+func (r *appinfoRepository) InsertCategoryAttribute(req *appinfo.CategoryAttribute) error {
+	optionsJson, err := json.Marshal(req.Options)
+	if err != nil {
+		return fmt.Errorf("marshal attribute options failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "category_attributes" (
+		"category_id",
+		"code",
+		"name",
+		"type",
+		"options",
+		"unit",
+		"required",
+		"filterable"
+	)
+	SELECT
+		"id", $2, $3, $4, $5::jsonb, $6, $7, $8
+	FROM "categories"
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL
+	RETURNING "id";`
 
-	if _, err := r.db.ExecContext(ctx, query, categoryId); err != nil {
-		return fmt.Errorf("delete cateogry failed: %v", err)
+	if err := r.db.QueryRowxContext(
+		context.Background(),
+		query,
+		req.CategoryId,
+		req.Code,
+		req.Name,
+		req.Type,
+		string(optionsJson),
+		req.Unit,
+		req.Required,
+		req.Filterable,
+	).Scan(&req.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("category not found")
+		}
+		if strings.Contains(err.Error(), "category_attributes_category_id_code_key") {
+			return fmt.Errorf("attribute code already exists")
+		}
+		return fmt.Errorf("insert category attribute failed: %v", err)
+	}
+	return nil
+}
+
+// ลบค่าของ attribute นี้ออกจากสินค้าในหมวดหมู่ด้วย เพื่อไม่ให้เหลือค่าที่ตรวจไม่ได้แล้ว
+func (r *appinfoRepository) DeleteCategoryAttribute(categoryId, attributeId int) error {
+	ctx := context.Background()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM "category_attributes"
+	WHERE "id" = $1
+	AND "category_id" = $2
+	RETURNING "code";`
+
+	var code string
+	if err := tx.QueryRowxContext(ctx, query, attributeId, categoryId).Scan(&code); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("attribute not found")
+		}
+		return fmt.Errorf("delete category attribute failed: %v", err)
+	}
+
+	queryProducts := `
+	UPDATE "products" SET
+		"attributes" = "attributes" - $1::text
+	WHERE "attributes" ? $1::text
+	AND "id" IN (
+		SELECT
+			"product_id"
+		FROM "products_categories"
+		WHERE "category_id" = $2
+	);`
+
+	if _, err := tx.ExecContext(ctx, queryProducts, code, categoryId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete product attributes failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 	return nil
 }
@@ -1,6 +1,10 @@
 package appinfoUsecases
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo/appinfoRepositories"
 )
@@ -9,6 +13,9 @@ type IAppinfoUsecase interface {
 	FindCategory(req *appinfo.CategoryFilter) ([]*appinfo.Category, error)
 	InsertCategory(req []*appinfo.Category) error
 	DeleteCategory(categoryId int) error
+	FindCategoryAttribute(categoryId int) ([]*appinfo.CategoryAttribute, error)
+	InsertCategoryAttribute(req *appinfo.CategoryAttribute) error
+	DeleteCategoryAttribute(categoryId, attributeId int) error
 }
 
 type appinfoUsecase struct {
@@ -42,3 +49,52 @@ func (u *appinfoUsecase) DeleteCategory(categoryId int) error {
 	}
 	return nil
 }
+
+// code ใช้เป็น key ของค่าในสินค้าและชื่อ query ตอนกรอง
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+func (u *appinfoUsecase) FindCategoryAttribute(categoryId int) ([]*appinfo.CategoryAttribute, error) {
+	return u.appinfoRepository.FindCategoryAttribute(categoryId)
+}
+
+func (u *appinfoUsecase) InsertCategoryAttribute(req *appinfo.CategoryAttribute) error {
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Unit = strings.TrimSpace(req.Unit)
+
+	if !attributeCodePattern.MatchString(req.Code) {
+		return fmt.Errorf("attribute code is invalid")
+	}
+	if req.Name == "" {
+		return fmt.Errorf("attribute name is required")
+	}
+	if !appinfo.IsAttributeType(req.Type) {
+		return fmt.Errorf("attribute type is invalid")
+	}
+
+	// เฉพาะ enum ที่มีตัวเลือก
+	if req.Type != appinfo.AttributeEnum {
+		req.Options = make([]string, 0)
+	} else {
+		options := make([]string, 0, len(req.Options))
+		seen := make(map[string]bool)
+		for _, o := range req.Options {
+			o = strings.TrimSpace(o)
+			if o == "" || seen[o] {
+				return fmt.Errorf("attribute options are invalid")
+			}
+			seen[o] = true
+			options = append(options, o)
+		}
+		if len(options) == 0 {
+			return fmt.Errorf("attribute options are invalid")
+		}
+		req.Options = options
+	}
+
+	return u.appinfoRepository.InsertCategoryAttribute(req)
+}
+
+func (u *appinfoUsecase) DeleteCategoryAttribute(categoryId, attributeId int) error {
+	return u.appinfoRepository.DeleteCategoryAttribute(categoryId, attributeId)
+}
@@ -0,0 +1,19 @@
+package audits
+
+const (
+	SignInLocked   = "signin_locked"
+	AdminInvited   = "admin_invited"
+	AdminCreated   = "admin_created"
+	IdentityLinked = "identity_linked"
+	UserDeleted    = "user_deleted"
+)
+
+type Audit struct {
+	Id        string `db:"id" json:"id"`
+	ActorId   string `db:"actor_id" json:"actor_id"`
+	Action    string `db:"action" json:"action"`
+	Target    string `db:"target" json:"target"`
+	Ip        string `db:"ip" json:"ip"`
+	Detail    any    `db:"detail" json:"detail"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,63 @@
+package auditsRepositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/audits"
+	"github.com/jmoiron/sqlx"
+)
+
+type IAuditsRepository interface {
+	InsertAudit(req *audits.Audit) error
+}
+
+type auditsRepository struct {
+	db *sqlx.DB
+}
+
+func AuditsRepository(db *sqlx.DB) IAuditsRepository {
+	return &auditsRepository{db: db}
+}
+
+func (r *auditsRepository) InsertAudit(req *audits.Audit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	query := `
+	INSERT INTO "audit_logs" (
+		"actor_id",
+		"action",
+		"target",
+		"ip",
+		"detail"
+	)
+	VALUES ($1, $2, $3, $4, $5)
+		RETURNING "id";`
+
+	// actor ว่างได้ เช่น ระบบเป็นคนสร้าง log เอง
+	var actorId any
+	if req.ActorId != "" {
+		actorId = req.ActorId
+	}
+
+	detail, err := json.Marshal(req.Detail)
+	if err != nil {
+		return fmt.Errorf("marshal audit detail failed: %v", err)
+	}
+
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		actorId,
+		req.Action,
+		req.Target,
+		req.Ip,
+		detail,
+	).Scan(&req.Id); err != nil {
+		return fmt.Errorf("insert audit failed: %v", err)
+	}
+	return nil
+}
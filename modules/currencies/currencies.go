@@ -0,0 +1,73 @@
+package currencies
+
+import (
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
+)
+
+// ที่มาของอัตราที่ admin ตั้งเอง อัตราที่ดึงมาใช้ชื่อของ importer
+const SourceManual = "manual"
+
+type Currency struct {
+	Code     string `db:"code" json:"code"`
+	Name     string `db:"name" json:"name"`
+	Symbol   string `db:"symbol" json:"symbol"`
+	Decimals int    `db:"decimals" json:"decimals"`
+	IsBase   bool   `db:"is_base" json:"is_base"`
+	Active   bool   `db:"active" json:"active"`
+	// อัตราล่าสุดต่อ 1 หน่วยของสกุลเงินหลัก null คือยังไม่มีอัตรา
+	Rate          *float64 `db:"rate" json:"rate"`
+	RateUpdatedAt *string  `db:"rate_updated_at" json:"rate_updated_at"`
+	CreatedAt     string   `db:"created_at" json:"created_at"`
+	UpdatedAt     string   `db:"updated_at" json:"updated_at"`
+}
+
+// nil คือไม่แก้ค่านั้น
+type CurrencyUpdate struct {
+	Name     *string `json:"name"`
+	Symbol   *string `json:"symbol"`
+	Decimals *int    `json:"decimals"`
+	Active   *bool   `json:"active"`
+}
+
+type ExchangeRate struct {
+	Id           string  `db:"id" json:"id"`
+	CurrencyCode string  `db:"currency_code" json:"currency_code"`
+	Rate         float64 `db:"rate" json:"rate"`
+	Source       string  `db:"source" json:"source"`
+	CreatedBy    *string `db:"created_by" json:"created_by"`
+	CreatedAt    string  `db:"created_at" json:"created_at"`
+}
+
+type ImportReport struct {
+	Source   string   `json:"source"`
+	Imported int      `json:"imported"`
+	Skipped  []string `json:"skipped"` // สกุลเงินที่ลงทะเบียนไว้แต่ไม่มีอัตราจาก importer
+}
+
+// อัตราที่ใช้แปลงจำนวนเงินจากสกุลเงินหลักเพื่อแสดงผล
+type Conversion struct {
+	Currency string  `json:"currency"`
+	Symbol   string  `json:"symbol"`
+	Rate     float64 `json:"rate"`
+	Decimals int     `json:"-"`
+	Base     bool    `json:"-"`
+}
+
+// ปัดตามจำนวนทศนิยมของสกุลเงิน
+func (c *Conversion) Convert(amount money.Amount) money.Display {
+	return amount.Convert(c.Rate, c.Decimals)
+}
+
+// ใช้อัตราอื่นแทน เช่นอัตราที่ order บันทึกไว้ตอนสั่งซื้อ
+func (c *Conversion) WithRate(rate float64) *Conversion {
+	converted := *c
+	converted.Rate = rate
+	return &converted
+}
+
+// error จาก Conversion ที่เกิดจากสกุลเงินที่ขอมา ไม่ใช่ error ของระบบ
+func IsConversionError(err error) bool {
+	return strings.HasPrefix(err.Error(), "currency ") || strings.HasPrefix(err.Error(), "exchange rate of ")
+}
@@ -0,0 +1,176 @@
+package currenciesHandlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesUsecases"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/pkg/exchangerate"
+	"github.com/gofiber/fiber/v2"
+)
+
+type currenciesHandlersErrCode string
+
+const (
+	findCurrencyErr   currenciesHandlersErrCode = "currencies-001"
+	addCurrencyErr    currenciesHandlersErrCode = "currencies-002"
+	updateCurrencyErr currenciesHandlersErrCode = "currencies-003"
+	findRateErr       currenciesHandlersErrCode = "currencies-004"
+	addRateErr        currenciesHandlersErrCode = "currencies-005"
+	importRateErr     currenciesHandlersErrCode = "currencies-006"
+)
+
+type ICurrenciesHandler interface {
+	FindCurrency(c *fiber.Ctx) error
+	FindAdminCurrency(c *fiber.Ctx) error
+	AddCurrency(c *fiber.Ctx) error
+	UpdateCurrency(c *fiber.Ctx) error
+	FindExchangeRate(c *fiber.Ctx) error
+	AddExchangeRate(c *fiber.Ctx) error
+	ImportExchangeRate(c *fiber.Ctx) error
+}
+
+type currenciesHandler struct {
+	cfg               config.ConfigImpl
+	currenciesUsecase currenciesUsecases.ICurrenciesUsecase
+}
+
+func CurrenciesHandler(cfg config.ConfigImpl, currenciesUsecase currenciesUsecases.ICurrenciesUsecase) ICurrenciesHandler {
+	return &currenciesHandler{
+		cfg:               cfg,
+		currenciesUsecase: currenciesUsecase,
+	}
+}
+
+func currencyErrorStatus(err error) int {
+	switch err.Error() {
+	case "currency not found":
+		return fiber.ErrNotFound.Code
+	case "currency already exists":
+		return fiber.ErrConflict.Code
+	case "currency code is invalid", "currency name is required", "currency decimals must be between 0 and 4",
+		"base currency cannot be deactivated", "exchange rate must be more than 0", "exchange rate of base currency is always 1":
+		return fiber.ErrBadRequest.Code
+	}
+	return fiber.ErrInternalServerError.Code
+}
+
+// endpoint สาธารณะเห็นเฉพาะสกุลเงินที่เปิดใช้
+func (h *currenciesHandler) FindCurrency(c *fiber.Ctx) error {
+	return h.findCurrency(c, true)
+}
+
+func (h *currenciesHandler) FindAdminCurrency(c *fiber.Ctx) error {
+	return h.findCurrency(c, false)
+}
+
+func (h *currenciesHandler) findCurrency(c *fiber.Ctx, activeOnly bool) error {
+	result, err := h.currenciesUsecase.FindCurrencies(activeOnly)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *currenciesHandler) AddCurrency(c *fiber.Ctx) error {
+	req := new(currencies.Currency)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	currency, err := h.currenciesUsecase.AddCurrency(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			currencyErrorStatus(err),
+			string(addCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, currency).Res()
+}
+
+func (h *currenciesHandler) UpdateCurrency(c *fiber.Ctx) error {
+	req := new(currencies.CurrencyUpdate)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+
+	currency, err := h.currenciesUsecase.UpdateCurrency(strings.Trim(c.Params("code"), " "), req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			currencyErrorStatus(err),
+			string(updateCurrencyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, currency).Res()
+}
+
+func (h *currenciesHandler) FindExchangeRate(c *fiber.Ctx) error {
+	rates, err := h.currenciesUsecase.FindExchangeRates(strings.Trim(c.Params("code"), " "))
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			currencyErrorStatus(err),
+			string(findRateErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, rates).Res()
+}
+
+func (h *currenciesHandler) AddExchangeRate(c *fiber.Ctx) error {
+	req := new(currencies.ExchangeRate)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addRateErr),
+			err.Error(),
+		).Res()
+	}
+	req.CurrencyCode = strings.Trim(c.Params("code"), " ")
+	req.CreatedBy = nil
+	if userId, ok := c.Locals("userId").(string); ok && userId != "" {
+		req.CreatedBy = &userId
+	}
+
+	rate, err := h.currenciesUsecase.AddExchangeRate(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			currencyErrorStatus(err),
+			string(addRateErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, rate).Res()
+}
+
+func (h *currenciesHandler) ImportExchangeRate(c *fiber.Ctx) error {
+	report, err := h.currenciesUsecase.ImportExchangeRates()
+	if err != nil {
+		code := fiber.ErrBadGateway.Code
+		if errors.Is(err, exchangerate.ErrNotConfigured) {
+			code = fiber.ErrNotImplemented.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(importRateErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, report).Res()
+}
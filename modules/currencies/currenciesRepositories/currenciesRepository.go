@@ -0,0 +1,235 @@
+package currenciesRepositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICurrenciesRepository interface {
+	FindCurrencies(activeOnly bool) ([]*currencies.Currency, error)
+	FindOneCurrency(code string) (*currencies.Currency, error)
+	FindBaseCurrency() (*currencies.Currency, error)
+	InsertCurrency(req *currencies.Currency) error
+	UpdateCurrency(code string, req *currencies.CurrencyUpdate) error
+	FindExchangeRates(code string, limit int) ([]*currencies.ExchangeRate, error)
+	InsertExchangeRate(req *currencies.ExchangeRate) error
+	InsertExchangeRates(rates map[string]float64, source string) (int, error)
+}
+
+type currenciesRepository struct {
+	db *sqlx.DB
+}
+
+func CurrenciesRepository(db *sqlx.DB) ICurrenciesRepository {
+	return &currenciesRepository{db: db}
+}
+
+// อัตราล่าสุดของแต่ละสกุลเงิน สกุลเงินหลักเป็น 1 เสมอ
+const currencyQuery = `
+	SELECT
+		"c"."code",
+		"c"."name",
+		"c"."symbol",
+		"c"."decimals",
+		"c"."is_base",
+		"c"."active",
+		CASE WHEN "c"."is_base" THEN 1 ELSE "r"."rate" END AS "rate",
+		"r"."created_at" AS "rate_updated_at",
+		"c"."created_at",
+		"c"."updated_at"
+	FROM "currencies" "c"
+		LEFT JOIN LATERAL (
+			SELECT
+				"er"."rate",
+				"er"."created_at"
+			FROM "exchange_rates" "er"
+			WHERE "er"."currency_code" = "c"."code"
+			ORDER BY "er"."created_at" DESC
+			LIMIT 1
+		) AS "r" ON TRUE`
+
+func (r *currenciesRepository) FindCurrencies(activeOnly bool) ([]*currencies.Currency, error) {
+	query := currencyQuery + `
+	WHERE (NOT $1 OR "c"."active")
+	ORDER BY "c"."is_base" DESC, "c"."code";`
+
+	result := make([]*currencies.Currency, 0)
+	if err := r.db.Select(&result, query, activeOnly); err != nil {
+		return nil, fmt.Errorf("get currencies failed: %v", err)
+	}
+	return result, nil
+}
+
+func (r *currenciesRepository) FindOneCurrency(code string) (*currencies.Currency, error) {
+	return r.findOneCurrency(`
+	WHERE "c"."code" = $1;`, code)
+}
+
+func (r *currenciesRepository) FindBaseCurrency() (*currencies.Currency, error) {
+	return r.findOneCurrency(`
+	WHERE "c"."is_base";`)
+}
+
+func (r *currenciesRepository) findOneCurrency(condition string, args ...any) (*currencies.Currency, error) {
+	currency := new(currencies.Currency)
+	if err := r.db.Get(currency, currencyQuery+condition, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("currency not found")
+		}
+		return nil, fmt.Errorf("get currency failed: %v", err)
+	}
+	return currency, nil
+}
+
+// สกุลเงินหลักกำหนดได้จาก migration เท่านั้น
+func (r *currenciesRepository) InsertCurrency(req *currencies.Currency) error {
+	query := `
+	INSERT INTO "currencies" (
+		"code",
+		"name",
+		"symbol",
+		"decimals",
+		"active"
+	)
+	VALUES ($1, $2, $3, $4, $5);`
+
+	if _, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		req.Code,
+		req.Name,
+		req.Symbol,
+		req.Decimals,
+		req.Active,
+	); err != nil {
+		if strings.Contains(err.Error(), "currencies_pkey") {
+			return fmt.Errorf("currency already exists")
+		}
+		return fmt.Errorf("insert currency failed: %v", err)
+	}
+	return nil
+}
+
+func (r *currenciesRepository) UpdateCurrency(code string, req *currencies.CurrencyUpdate) error {
+	query := `
+	UPDATE "currencies" SET
+		"name" = COALESCE($2, "name"),
+		"symbol" = COALESCE($3, "symbol"),
+		"decimals" = COALESCE($4, "decimals"),
+		"active" = COALESCE($5, "active")
+	WHERE "code" = $1;`
+
+	result, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		code,
+		req.Name,
+		req.Symbol,
+		req.Decimals,
+		req.Active,
+	)
+	if err != nil {
+		return fmt.Errorf("update currency failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("currency not found")
+	}
+	return nil
+}
+
+// อัตราล่าสุดก่อน
+func (r *currenciesRepository) FindExchangeRates(code string, limit int) ([]*currencies.ExchangeRate, error) {
+	query := `
+	SELECT
+		"id",
+		"currency_code",
+		"rate",
+		"source",
+		"created_by",
+		"created_at"
+	FROM "exchange_rates"
+	WHERE "currency_code" = $1
+	ORDER BY "created_at" DESC
+	LIMIT $2;`
+
+	rates := make([]*currencies.ExchangeRate, 0)
+	if err := r.db.Select(&rates, query, code, limit); err != nil {
+		return nil, fmt.Errorf("get exchange rates failed: %v", err)
+	}
+	return rates, nil
+}
+
+func (r *currenciesRepository) InsertExchangeRate(req *currencies.ExchangeRate) error {
+	query := `
+	INSERT INTO "exchange_rates" (
+		"currency_code",
+		"rate",
+		"source",
+		"created_by"
+	)
+	VALUES ($1, $2, $3, $4)
+	RETURNING "id", "created_at";`
+
+	if err := r.db.QueryRowxContext(
+		context.Background(),
+		query,
+		req.CurrencyCode,
+		req.Rate,
+		req.Source,
+		req.CreatedBy,
+	).Scan(&req.Id, &req.CreatedAt); err != nil {
+		if strings.Contains(err.Error(), "exchange_rates_currency_code_fkey") {
+			return fmt.Errorf("currency not found")
+		}
+		return fmt.Errorf("insert exchange rate failed: %v", err)
+	}
+	return nil
+}
+
+// บันทึกเฉพาะสกุลเงินที่ลงทะเบียนไว้และไม่ใช่สกุลเงินหลัก ทั้งชุดใน transaction เดียว คืนจำนวนที่บันทึก
+func (r *currenciesRepository) InsertExchangeRates(rates map[string]float64, source string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO "exchange_rates" (
+		"currency_code",
+		"rate",
+		"source"
+	)
+	SELECT
+		"code",
+		$2,
+		$3
+	FROM "currencies"
+	WHERE "code" = $1
+	AND NOT "is_base";`
+
+	var imported int
+	for code, rate := range rates {
+		result, err := tx.ExecContext(ctx, query, code, rate, source)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("insert exchange rates failed: %v", err)
+		}
+		rows, _ := result.RowsAffected()
+		imported += int(rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
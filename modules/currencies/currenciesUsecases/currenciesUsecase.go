@@ -0,0 +1,225 @@
+package currenciesUsecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesRepositories"
+	"github.com/Doittikorn/go-e-commerce/pkg/exchangerate"
+)
+
+// จำนวนประวัติอัตราที่แสดง
+const exchangeRateHistoryLimit = 100
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type ICurrenciesUsecase interface {
+	FindCurrencies(activeOnly bool) ([]*currencies.Currency, error)
+	AddCurrency(req *currencies.Currency) (*currencies.Currency, error)
+	UpdateCurrency(code string, req *currencies.CurrencyUpdate) (*currencies.Currency, error)
+	FindExchangeRates(code string) ([]*currencies.ExchangeRate, error)
+	AddExchangeRate(req *currencies.ExchangeRate) (*currencies.ExchangeRate, error)
+	ImportExchangeRates() (*currencies.ImportReport, error)
+	Conversion(code string) (*currencies.Conversion, error)
+	RunImporter()
+}
+
+type currenciesUsecase struct {
+	cfg                  config.ConfigImpl
+	currenciesRepository currenciesRepositories.ICurrenciesRepository
+	importer             exchangerate.ImporterImpl
+}
+
+func CurrenciesUsecase(cfg config.ConfigImpl, currenciesRepository currenciesRepositories.ICurrenciesRepository, importer exchangerate.ImporterImpl) ICurrenciesUsecase {
+	return &currenciesUsecase{
+		cfg:                  cfg,
+		currenciesRepository: currenciesRepository,
+		importer:             importer,
+	}
+}
+
+func (u *currenciesUsecase) FindCurrencies(activeOnly bool) ([]*currencies.Currency, error) {
+	return u.currenciesRepository.FindCurrencies(activeOnly)
+}
+
+func (u *currenciesUsecase) AddCurrency(req *currencies.Currency) (*currencies.Currency, error) {
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	req.Name = strings.TrimSpace(req.Name)
+	req.Symbol = strings.TrimSpace(req.Symbol)
+	if !currencyCodePattern.MatchString(req.Code) {
+		return nil, fmt.Errorf("currency code is invalid")
+	}
+	if req.Name == "" {
+		return nil, fmt.Errorf("currency name is required")
+	}
+	if err := validateDecimals(req.Decimals); err != nil {
+		return nil, err
+	}
+	// สกุลเงินใหม่ยังไม่มีอัตรา จะแสดงราคาได้หลังตั้งอัตราแล้ว
+	req.Active = true
+
+	if err := u.currenciesRepository.InsertCurrency(req); err != nil {
+		return nil, err
+	}
+	return u.currenciesRepository.FindOneCurrency(req.Code)
+}
+
+func (u *currenciesUsecase) UpdateCurrency(code string, req *currencies.CurrencyUpdate) (*currencies.Currency, error) {
+	code = strings.ToUpper(code)
+	old, err := u.currenciesRepository.FindOneCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return nil, fmt.Errorf("currency name is required")
+	}
+	if req.Decimals != nil {
+		if err := validateDecimals(*req.Decimals); err != nil {
+			return nil, err
+		}
+	}
+	if old.IsBase && req.Active != nil && !*req.Active {
+		return nil, fmt.Errorf("base currency cannot be deactivated")
+	}
+
+	if err := u.currenciesRepository.UpdateCurrency(code, req); err != nil {
+		return nil, err
+	}
+	return u.currenciesRepository.FindOneCurrency(code)
+}
+
+func validateDecimals(decimals int) error {
+	// ใช้กับการแสดงผลเท่านั้น จำนวนเงินที่เก็บมีทศนิยม money.Scale ตำแหน่งเสมอ
+	if decimals < 0 || decimals > 4 {
+		return fmt.Errorf("currency decimals must be between 0 and 4")
+	}
+	return nil
+}
+
+func (u *currenciesUsecase) FindExchangeRates(code string) ([]*currencies.ExchangeRate, error) {
+	code = strings.ToUpper(code)
+	if _, err := u.currenciesRepository.FindOneCurrency(code); err != nil {
+		return nil, err
+	}
+	return u.currenciesRepository.FindExchangeRates(code, exchangeRateHistoryLimit)
+}
+
+// อัตราต่อ 1 หน่วยของสกุลเงินหลัก เช่น 1 THB = 0.028 USD
+func (u *currenciesUsecase) AddExchangeRate(req *currencies.ExchangeRate) (*currencies.ExchangeRate, error) {
+	req.CurrencyCode = strings.ToUpper(req.CurrencyCode)
+	if req.Rate <= 0 || math.IsNaN(req.Rate) || math.IsInf(req.Rate, 0) {
+		return nil, fmt.Errorf("exchange rate must be more than 0")
+	}
+
+	currency, err := u.currenciesRepository.FindOneCurrency(req.CurrencyCode)
+	if err != nil {
+		return nil, err
+	}
+	if currency.IsBase {
+		return nil, fmt.Errorf("exchange rate of base currency is always 1")
+	}
+
+	req.Source = currencies.SourceManual
+	if err := u.currenciesRepository.InsertExchangeRate(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// ดึงอัตราจาก importer แล้วบันทึกเฉพาะสกุลเงินที่ลงทะเบียนไว้
+func (u *currenciesUsecase) ImportExchangeRates() (*currencies.ImportReport, error) {
+	base, err := u.currenciesRepository.FindBaseCurrency()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rates, err := u.importer.Rates(ctx, base.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	registered, err := u.currenciesRepository.FindCurrencies(false)
+	if err != nil {
+		return nil, err
+	}
+	report := &currencies.ImportReport{
+		Source:  u.importer.Name(),
+		Skipped: make([]string, 0),
+	}
+	for _, c := range registered {
+		if _, ok := rates[c.Code]; !ok && !c.IsBase {
+			report.Skipped = append(report.Skipped, c.Code)
+		}
+	}
+	sort.Strings(report.Skipped)
+
+	if report.Imported, err = u.currenciesRepository.InsertExchangeRates(rates, report.Source); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// code ว่างคือสกุลเงินหลัก สกุลเงินที่ปิดใช้หรือยังไม่มีอัตราใช้แสดงราคาไม่ได้
+func (u *currenciesUsecase) Conversion(code string) (*currencies.Conversion, error) {
+	var currency *currencies.Currency
+	var err error
+	if code = strings.ToUpper(strings.TrimSpace(code)); code == "" {
+		currency, err = u.currenciesRepository.FindBaseCurrency()
+	} else {
+		currency, err = u.currenciesRepository.FindOneCurrency(code)
+	}
+	if err != nil {
+		if err.Error() == "currency not found" {
+			return nil, fmt.Errorf("currency %s is not supported", code)
+		}
+		return nil, err
+	}
+	if !currency.Active {
+		return nil, fmt.Errorf("currency %s is not supported", code)
+	}
+	if currency.Rate == nil {
+		return nil, fmt.Errorf("exchange rate of %s is not available", code)
+	}
+
+	return &currencies.Conversion{
+		Currency: currency.Code,
+		Symbol:   currency.Symbol,
+		Rate:     *currency.Rate,
+		Decimals: currency.Decimals,
+		Base:     currency.IsBase,
+	}, nil
+}
+
+func (u *currenciesUsecase) RunImporter() {
+	interval := u.cfg.Currency().ImportInterval()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := u.ImportExchangeRates()
+		if err != nil {
+			if errors.Is(err, exchangerate.ErrNotConfigured) {
+				return
+			}
+			log.Printf("import exchange rates failed: %v\n", err)
+			continue
+		}
+		log.Printf("import exchange rates: imported %d, skipped %v\n", report.Imported, report.Skipped)
+	}
+}
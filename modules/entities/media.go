@@ -1,7 +1,17 @@
 package entities
 
 type Image struct {
-	Id       string `db:"id" json:"id"`
-	FileName string `db:"filename" json:"filename"`
-	Url      string `db:"url" json:"url"`
+	Id         string            `db:"id" json:"id"`
+	FileName   string            `db:"filename" json:"filename"`
+	Url        string            `db:"url" json:"url"`
+	Renditions []*ImageRendition `db:"-" json:"renditions"`
+}
+
+// รูปย่อของ Image ใช้ทำ srcset ได้จาก width และ url
+type ImageRendition struct {
+	Name        string `db:"name" json:"name"`
+	ContentType string `db:"content_type" json:"content_type"`
+	Width       int    `db:"width" json:"width"`
+	Height      int    `db:"height" json:"height"`
+	Url         string `db:"url" json:"url"`
 }
@@ -8,6 +8,7 @@ import (
 type ResponseImpl interface {
 	Success(code int, data any) ResponseImpl
 	Error(code int, traceId, msg string) ResponseImpl
+	ErrorDetail(code int, traceId, msg string, details any) ResponseImpl
 	Res() error
 }
 
@@ -22,6 +23,7 @@ type Response struct {
 type ErrorResponse struct {
 	TraceId string `json:"trace_id"`
 	Msg     string `json:"message"`
+	Details any    `json:"details,omitempty"`
 }
 
 func NewResponse(c *fiber.Ctx) ResponseImpl {
@@ -52,6 +54,21 @@ func (r *Response) Error(code int, traceId, msg string) ResponseImpl {
 	return r
 }
 
+// เหมือน Error แต่แนบรายละเอียดเพิ่มเติม เช่น รายการไฟล์ที่ถูกปฏิเสธพร้อมเหตุผล
+func (r *Response) ErrorDetail(code int, traceId, msg string, details any) ResponseImpl {
+	r.StatusCode = code
+	r.ErrorRes = &ErrorResponse{
+		TraceId: traceId,
+		Msg:     msg,
+		Details: details,
+	}
+
+	logger.New(r.Context, &r.ErrorRes).Print().SaveToStorage()
+
+	r.IsError = true
+	return r
+}
+
 // ใช้ในการส่งข้อมูลจาก struct Response ไปยัง client
 func (r *Response) Res() error {
 
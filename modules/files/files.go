@@ -1,19 +1,151 @@
 package files
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+)
 
 type FileReq struct {
 	File        *multipart.FileHeader `form:"file"`
 	Destination string                `form:"destination"`
 	Extension   string
 	FileName    string
+	OwnerId     string
+	SourceName  string // ชื่อไฟล์ที่ user ส่งมา ใช้ตอนแจ้ง error
+	Visibility  string
 }
 
+// ไฟล์ private เช่นสลิปโอนเงินหรือใบกำกับภาษีไม่มี url สาธารณะ ต้องขอ signed url ด้วย id
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
 type FileRes struct {
-	FileName string `json:"filename"`
-	Url      string `json:"url"`
+	Id         string                     `json:"id"`
+	FileName   string                     `json:"filename"`
+	Url        string                     `json:"url"`
+	Visibility string                     `json:"visibility"`
+	Renditions []*entities.ImageRendition `json:"renditions,omitempty"`
+}
+
+type SignedUrlRes struct {
+	Url       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
 }
 
 type DeleteFileReq struct {
 	Destination string `json:"destination"`
 }
+
+// ข้อมูลของไฟล์ที่ถูก upload ใช้ตรวจหาไฟล์ที่ไม่มีใครใช้งาน
+type File struct {
+	Id           string `db:"id" json:"id"`
+	OwnerId      string `db:"owner_id" json:"owner_id"`
+	StorageKey   string `db:"storage_key" json:"storage_key"`
+	Url          string `db:"url" json:"url"`
+	FileName     string `db:"filename" json:"filename"`
+	Size         int64  `db:"size" json:"size"`
+	ContentType  string `db:"content_type" json:"content_type"`
+	Checksum     string `db:"checksum" json:"checksum"`
+	ReferencedBy string `db:"referenced_by" json:"referenced_by"`
+	Visibility   string `db:"visibility" json:"visibility"`
+	CreatedAt    string `db:"created_at" json:"created_at"`
+
+	// ใช้กับรูปย่อที่สร้างจากไฟล์ต้นฉบับ
+	ParentId string `db:"parent_id" json:"parent_id,omitempty"`
+	Variant  string `db:"variant" json:"variant,omitempty"`
+	Width    int    `db:"width" json:"width,omitempty"`
+	Height   int    `db:"height" json:"height,omitempty"`
+}
+
+// จำนวนไฟล์สูงสุดที่ลบในแต่ละรอบ
+const OrphanSweepLimit = 500
+
+type OrphanReport struct {
+	DryRun       bool    `json:"dry_run"`
+	GraceSeconds int     `json:"grace_seconds"`
+	Count        int     `json:"count"`
+	TotalSize    int64   `json:"total_size"`
+	Deleted      int     `json:"deleted"`
+	Files        []*File `json:"files"`
+}
+
+// เหตุผลที่ไฟล์ถูกปฏิเสธ
+const (
+	RejectExtension    = "extension_not_acceptable"
+	RejectFileSize     = "file_too_large"
+	RejectContentType  = "unsupported_content_type"
+	RejectMismatch     = "content_type_mismatch"
+	RejectInvalidImage = "invalid_image"
+	RejectInfected     = "infected"
+	RejectQuota        = "quota_exceeded"
+)
+
+type UploadRejection struct {
+	FileName string `json:"filename"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+}
+
+// ไฟล์อย่างน้อยหนึ่งไฟล์ไม่ผ่านการตรวจ จะไม่มีไฟล์ไหนในชุดถูกบันทึก
+type UploadRejectedError struct {
+	Rejections []*UploadRejection
+}
+
+func (e *UploadRejectedError) Error() string {
+	return "upload rejected"
+}
+
+// resumable upload ส่ง chunk ทีละส่วนด้วย Upload-Offset ต่อจากเดิมได้ถ้าการเชื่อมต่อหลุด
+type UploadInitReq struct {
+	FileName    string `json:"filename"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"` // sha256 hex ของทั้งไฟล์ ตรวจตอน complete
+	Destination string `json:"destination"`
+	Visibility  string `json:"visibility"`
+	OwnerId     string `json:"-"`
+}
+
+type Upload struct {
+	Id          string `db:"id" json:"id"`
+	OwnerId     string `db:"owner_id" json:"-"`
+	StorageKey  string `db:"storage_key" json:"-"`
+	FileName    string `db:"filename" json:"filename"`
+	ContentType string `db:"content_type" json:"content_type"`
+	Visibility  string `db:"visibility" json:"visibility"`
+	Size        int64  `db:"size" json:"size"`
+	Offset      int64  `db:"offset" json:"offset"`
+	Checksum    string `db:"checksum" json:"checksum"`
+	ChunkSize   int64  `db:"-" json:"chunk_size"`
+	ExpiresAt   string `db:"expires_at" json:"expires_at"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+}
+
+// chunk แต่ละส่วนถูกเก็บเป็น object แยกใน storage จนกว่าจะ complete
+type UploadPart struct {
+	Offset     int64  `db:"offset"`
+	Size       int64  `db:"size"`
+	StorageKey string `db:"storage_key"`
+}
+
+// จำนวน upload ที่หมดอายุซึ่งลบในแต่ละรอบ
+const ExpiredUploadSweepLimit = 100
+
+// ชนิดไฟล์ที่ upload แบบ resumable ได้ รูปต้อง upload ผ่าน /files/upload เพื่อลบ metadata และสร้างรูปย่อ
+type ResumableType struct {
+	ContentType string
+	Sniffed     []string // ผลของ http.DetectContentType ที่ยอมรับ
+}
+
+var ResumableTypes = map[string]*ResumableType{
+	"mp4":  {ContentType: "video/mp4", Sniffed: []string{"video/mp4"}},
+	"webm": {ContentType: "video/webm", Sniffed: []string{"video/webm"}},
+	"csv":  {ContentType: "text/csv", Sniffed: []string{"text/plain"}},
+	"xlsx": {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Sniffed: []string{"application/zip"}},
+	// ไฟล์ของสินค้าดิจิทัล
+	"pdf":  {ContentType: "application/pdf", Sniffed: []string{"application/pdf"}},
+	"epub": {ContentType: "application/epub+zip", Sniffed: []string{"application/zip"}},
+	"zip":  {ContentType: "application/zip", Sniffed: []string{"application/zip"}},
+}
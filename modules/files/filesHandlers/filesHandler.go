@@ -1,15 +1,20 @@
 package filesHandlers
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Doittikorn/go-e-commerce/config"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/files"
 	"github.com/Doittikorn/go-e-commerce/modules/files/filesUsecases"
+	"github.com/Doittikorn/go-e-commerce/modules/middlewares"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 	"github.com/Doittikorn/go-e-commerce/pkg/utils"
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,11 +24,28 @@ type filesHandlersErrCode string
 const (
 	uploadErr filesHandlersErrCode = "files-001"
 	deleteErr filesHandlersErrCode = "files-002"
+	orphanErr filesHandlersErrCode = "files-003"
+	signErr   filesHandlersErrCode = "files-004"
+	streamErr filesHandlersErrCode = "files-005"
+
+	initUploadErr     filesHandlersErrCode = "files-006"
+	findUploadErr     filesHandlersErrCode = "files-007"
+	appendUploadErr   filesHandlersErrCode = "files-008"
+	completeUploadErr filesHandlersErrCode = "files-009"
+	abortUploadErr    filesHandlersErrCode = "files-010"
 )
 
 type IFilesHandler interface {
 	UploadFiles(c *fiber.Ctx) error
 	DeleteFile(c *fiber.Ctx) error
+	FindOrphanFiles(c *fiber.Ctx) error
+	SignFileUrl(c *fiber.Ctx) error
+	StreamSignedFile(c *fiber.Ctx) error
+	InitUpload(c *fiber.Ctx) error
+	FindOneUpload(c *fiber.Ctx) error
+	AppendUpload(c *fiber.Ctx) error
+	CompleteUpload(c *fiber.Ctx) error
+	AbortUpload(c *fiber.Ctx) error
 }
 
 type filesHandler struct {
@@ -51,6 +73,18 @@ func (h *filesHandler) UploadFiles(c *fiber.Ctx) error {
 	}
 	filesReq := form.File["files"]
 	destination := c.FormValue("destination")
+	ownerId, _ := c.Locals("userId").(string)
+
+	visibility := strings.ToLower(c.FormValue("visibility", files.VisibilityPublic))
+	switch visibility {
+	case files.VisibilityPublic, files.VisibilityPrivate:
+	default:
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(uploadErr),
+			"visibility is invalid",
+		).Res()
+	}
 
 	// Files ext validation
 	extMap := map[string]string{
@@ -58,46 +92,73 @@ func (h *filesHandler) UploadFiles(c *fiber.Ctx) error {
 		"jpg":  "jpg",
 		"jpeg": "jpeg",
 	}
+	rejections := make([]*files.UploadRejection, 0)
 	for _, file := range filesReq {
-		ext := strings.TrimPrefix(filepath.Ext(file.Filename), ".")
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file.Filename), "."))
 		if extMap[ext] != ext || extMap[ext] == "" {
-			return entities.NewResponse(c).Error(
-				fiber.ErrBadRequest.Code,
-				string(uploadErr),
-				"extension is not acceptable",
-			).Res()
+			rejections = append(rejections, &files.UploadRejection{
+				FileName: file.Filename,
+				Reason:   files.RejectExtension,
+				Message:  "extension is not acceptable",
+			})
+			continue
 		}
 
 		if file.Size > int64(h.cfg.App().FileLimit()) {
+			rejections = append(rejections, &files.UploadRejection{
+				FileName: file.Filename,
+				Reason:   files.RejectFileSize,
+				Message:  fmt.Sprintf("file size must less than %d MiB", int(math.Ceil(float64(h.cfg.App().FileLimit())/math.Pow(1024, 2)))),
+			})
+			continue
+		}
+
+		filename := utils.RandFileName(ext)
+		key, err := storage.ObjectKey(destination, filename, visibility == files.VisibilityPrivate)
+		if err != nil {
 			return entities.NewResponse(c).Error(
 				fiber.ErrBadRequest.Code,
 				string(uploadErr),
-				fmt.Sprintf("file size must less than %d MiB", int(math.Ceil(float64(h.cfg.App().FileLimit())/math.Pow(1024, 2)))),
+				err.Error(),
 			).Res()
 		}
-
-		filename := utils.RandFileName(ext)
 		req = append(req, &files.FileReq{
 			File:        file,
-			Destination: destination + "/" + filename,
+			Destination: key,
 			FileName:    filename,
 			Extension:   ext,
+			OwnerId:     ownerId,
+			SourceName:  file.Filename,
+			Visibility:  visibility,
 		})
 	}
+	if len(rejections) > 0 {
+		return entities.NewResponse(c).ErrorDetail(
+			fiber.ErrBadRequest.Code,
+			string(uploadErr),
+			"upload rejected",
+			rejections,
+		).Res()
+	}
 
-	// res, err := h.filesUsecase.UploadToGCP(req)
-	// if err != nil {
-	// 	return entities.NewResponse(c).Error(
-	// 		fiber.ErrInternalServerError.Code,
-	// 		string(uploadErr),
-	// 		err.Error(),
-	// 	).Res()
-	// }
-
-	// If you want to upload files to your computer please use this function below instead
-
-	res, err := h.filesUsecase.UploadToStorage(req)
+	res, err := h.filesUsecase.UploadFiles(req)
 	if err != nil {
+		var rejected *files.UploadRejectedError
+		if errors.As(err, &rejected) {
+			return entities.NewResponse(c).ErrorDetail(
+				fiber.ErrBadRequest.Code,
+				string(uploadErr),
+				rejected.Error(),
+				rejected.Rejections,
+			).Res()
+		}
+		if err.Error() == "scan file failed" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrServiceUnavailable.Code,
+				string(uploadErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(uploadErr),
@@ -117,17 +178,7 @@ func (h *filesHandler) DeleteFile(c *fiber.Ctx) error {
 		).Res()
 	}
 
-	// if err := h.filesUsecase.DeleteFileOnGCP(req); err != nil {
-	// 	return entities.NewResponse(c).Error(
-	// 		fiber.ErrInternalServerError.Code,
-	// 		string(deleteErr),
-	// 		err.Error(),
-	// 	).Res()
-	// }
-
-	// If you want to upload files to your computer please use this function below instead
-
-	if err := h.filesUsecase.DeleteFileOnStorage(req); err != nil {
+	if err := h.filesUsecase.DeleteFiles(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(deleteErr),
@@ -136,3 +187,97 @@ func (h *filesHandler) DeleteFile(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
 }
+
+// รายงานไฟล์ที่จะถูกลบในรอบถัดไป โดยไม่ลบจริง
+func (h *filesHandler) FindOrphanFiles(c *fiber.Ctx) error {
+	report, err := h.filesUsecase.SweepOrphanFiles(true)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(orphanErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, report).Res()
+}
+
+// expires_in เป็น seconds ถ้าไม่ระบุจะใช้ค่าจาก config
+func (h *filesHandler) SignFileUrl(c *fiber.Ctx) error {
+	fileId := strings.Trim(c.Params("file_id"), " ")
+	userId, _ := c.Locals("userId").(string)
+	isAdmin := middlewares.Permissions(c).Has("files:read_any")
+
+	expiresIn, err := strconv.Atoi(c.Query("expires_in", "0"))
+	if err != nil || expiresIn < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(signErr),
+			"expires_in is invalid",
+		).Res()
+	}
+
+	res, err := h.filesUsecase.SignFileUrl(fileId, userId, isAdmin, time.Duration(expiresIn)*time.Second)
+	if err != nil {
+		switch err.Error() {
+		case "file not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(signErr),
+				err.Error(),
+			).Res()
+		case "no permission to access file":
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(signErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(signErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, res).Res()
+}
+
+// ส่งไฟล์ของ local storage ตาม signed url ไม่ต้อง login เพราะ signature คือสิทธิ์ในการเข้าถึง
+func (h *filesHandler) StreamSignedFile(c *fiber.Ctx) error {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(streamErr),
+			storage.ErrSignatureInvalid.Error(),
+		).Res()
+	}
+
+	r, info, err := h.filesUsecase.OpenSignedFile(c.Params("*"), expires, c.Query("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSignatureInvalid), errors.Is(err, storage.ErrUrlExpired):
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(streamErr),
+				err.Error(),
+			).Res()
+		case errors.Is(err, storage.ErrNotFound):
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(streamErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(streamErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, info.ContentType)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.SendStream(r, int(info.Size))
+}
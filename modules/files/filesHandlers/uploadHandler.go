@@ -0,0 +1,230 @@
+package filesHandlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// offset ปัจจุบันของ upload ถูกส่งกลับใน header นี้ทุกครั้ง
+const uploadOffsetHeader = "Upload-Offset"
+
+func (h *filesHandler) InitUpload(c *fiber.Ctx) error {
+	req := new(files.UploadInitReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(initUploadErr),
+			err.Error(),
+		).Res()
+	}
+	req.OwnerId, _ = c.Locals("userId").(string)
+
+	req.Visibility = strings.ToLower(req.Visibility)
+	if req.Visibility == "" {
+		req.Visibility = files.VisibilityPublic
+	}
+	if req.Visibility != files.VisibilityPublic && req.Visibility != files.VisibilityPrivate {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(initUploadErr),
+			"visibility is invalid",
+		).Res()
+	}
+
+	upload, err := h.filesUsecase.InitUpload(req)
+	if err != nil {
+		var rejected *files.UploadRejectedError
+		if errors.As(err, &rejected) {
+			return entities.NewResponse(c).ErrorDetail(
+				fiber.ErrBadRequest.Code,
+				string(initUploadErr),
+				rejected.Error(),
+				rejected.Rejections,
+			).Res()
+		}
+		if err.Error() == "checksum is invalid" || errors.Is(err, storage.ErrDestinationInvalid) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(initUploadErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(initUploadErr),
+			err.Error(),
+		).Res()
+	}
+	c.Set(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	return entities.NewResponse(c).Success(fiber.StatusCreated, upload).Res()
+}
+
+// ใช้ตอน resume เพื่อดูว่า server ได้รับถึง offset ไหนแล้ว
+func (h *filesHandler) FindOneUpload(c *fiber.Ctx) error {
+	ownerId, _ := c.Locals("userId").(string)
+
+	upload, err := h.filesUsecase.FindOneUpload(strings.Trim(c.Params("upload_id"), " "), ownerId)
+	if err != nil {
+		if err.Error() == "upload not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findUploadErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findUploadErr),
+			err.Error(),
+		).Res()
+	}
+	c.Set(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	return entities.NewResponse(c).Success(fiber.StatusOK, upload).Res()
+}
+
+// body คือข้อมูลของ chunk ส่วน Upload-Checksum (sha256 hex ของ chunk) ไม่บังคับ
+func (h *filesHandler) AppendUpload(c *fiber.Ctx) error {
+	ownerId, _ := c.Locals("userId").(string)
+
+	offset, err := strconv.ParseInt(c.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(appendUploadErr),
+			"upload offset is invalid",
+		).Res()
+	}
+
+	upload, err := h.filesUsecase.AppendUpload(
+		strings.Trim(c.Params("upload_id"), " "),
+		ownerId,
+		offset,
+		c.Body(),
+		c.Get("Upload-Checksum"),
+	)
+	if err != nil {
+		var rejected *files.UploadRejectedError
+		if errors.As(err, &rejected) {
+			return entities.NewResponse(c).ErrorDetail(
+				fiber.ErrBadRequest.Code,
+				string(appendUploadErr),
+				rejected.Error(),
+				rejected.Rejections,
+			).Res()
+		}
+
+		switch err.Error() {
+		case "upload not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(appendUploadErr),
+				err.Error(),
+			).Res()
+		case "upload offset mismatch":
+			c.Set(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+			return entities.NewResponse(c).ErrorDetail(
+				fiber.ErrConflict.Code,
+				string(appendUploadErr),
+				err.Error(),
+				upload,
+			).Res()
+		case "chunk is too large":
+			return entities.NewResponse(c).Error(
+				fiber.ErrRequestEntityTooLarge.Code,
+				string(appendUploadErr),
+				err.Error(),
+			).Res()
+		case "chunk is empty", "chunk exceeds upload size", "chunk checksum mismatch":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(appendUploadErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(appendUploadErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	c.Set(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	return entities.NewResponse(c).Success(fiber.StatusOK, upload).Res()
+}
+
+func (h *filesHandler) CompleteUpload(c *fiber.Ctx) error {
+	ownerId, _ := c.Locals("userId").(string)
+
+	res, err := h.filesUsecase.CompleteUpload(strings.Trim(c.Params("upload_id"), " "), ownerId)
+	if err != nil {
+		var rejected *files.UploadRejectedError
+		if errors.As(err, &rejected) {
+			return entities.NewResponse(c).ErrorDetail(
+				fiber.ErrBadRequest.Code,
+				string(completeUploadErr),
+				rejected.Error(),
+				rejected.Rejections,
+			).Res()
+		}
+
+		switch err.Error() {
+		case "upload not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(completeUploadErr),
+				err.Error(),
+			).Res()
+		case "upload is incomplete":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(completeUploadErr),
+				err.Error(),
+			).Res()
+		case "checksum mismatch", storage.ErrDestinationInvalid.Error():
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnprocessableEntity.Code,
+				string(completeUploadErr),
+				err.Error(),
+			).Res()
+		case "scan file failed":
+			return entities.NewResponse(c).Error(
+				fiber.ErrServiceUnavailable.Code,
+				string(completeUploadErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(completeUploadErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, res).Res()
+}
+
+func (h *filesHandler) AbortUpload(c *fiber.Ctx) error {
+	ownerId, _ := c.Locals("userId").(string)
+
+	if err := h.filesUsecase.AbortUpload(strings.Trim(c.Params("upload_id"), " "), ownerId); err != nil {
+		if err.Error() == "upload not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(abortUploadErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(abortUploadErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
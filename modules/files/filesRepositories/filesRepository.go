@@ -0,0 +1,424 @@
+package filesRepositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/jmoiron/sqlx"
+)
+
+type IFilesRepository interface {
+	InsertFile(req *files.File) error
+	FindOneFile(fileId string) (*files.File, error)
+	DeleteFileByKey(storageKey string) ([]string, error)
+	SumFileSizeByOwner(ownerId string) (int64, error)
+	SyncFileReferences() error
+	FindOrphanFiles(graceSeconds, limit int) ([]*files.File, error)
+	DeleteOrphanFile(fileId string) ([]string, error)
+	InsertUpload(req *files.Upload, expiresSeconds int) error
+	FindOneUpload(uploadId string) (*files.Upload, error)
+	InsertUploadPart(uploadId string, part *files.UploadPart) (int64, error)
+	FindUploadParts(uploadId string) ([]*files.UploadPart, error)
+	DeleteUpload(uploadId string) ([]string, error)
+	FindExpiredUploads(limit int) ([]string, error)
+}
+
+type filesRepository struct {
+	db *sqlx.DB
+}
+
+func FilesRepository(db *sqlx.DB) IFilesRepository {
+	return &filesRepository{
+		db: db,
+	}
+}
+
+// upload ซ้ำที่ key เดิมจะเขียนทับข้อมูลเดิม
+func (r *filesRepository) InsertFile(req *files.File) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "files" (
+		"owner_id",
+		"storage_key",
+		"url",
+		"filename",
+		"size",
+		"content_type",
+		"checksum",
+		"parent_id",
+		"variant",
+		"width",
+		"height",
+		"visibility"
+	)
+	VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid, NULLIF($9, ''), NULLIF($10, 0), NULLIF($11, 0), COALESCE(NULLIF($12, ''), 'public'))
+	ON CONFLICT ("storage_key") DO UPDATE SET
+		"owner_id" = EXCLUDED."owner_id",
+		"url" = EXCLUDED."url",
+		"filename" = EXCLUDED."filename",
+		"size" = EXCLUDED."size",
+		"content_type" = EXCLUDED."content_type",
+		"checksum" = EXCLUDED."checksum",
+		"parent_id" = EXCLUDED."parent_id",
+		"variant" = EXCLUDED."variant",
+		"width" = EXCLUDED."width",
+		"height" = EXCLUDED."height",
+		"visibility" = EXCLUDED."visibility",
+		"created_at" = now()
+	RETURNING "id";`
+
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		req.OwnerId,
+		req.StorageKey,
+		req.Url,
+		req.FileName,
+		req.Size,
+		req.ContentType,
+		req.Checksum,
+		req.ParentId,
+		req.Variant,
+		req.Width,
+		req.Height,
+		req.Visibility,
+	).Scan(&req.Id); err != nil {
+		return fmt.Errorf("insert file failed: %v", err)
+	}
+	return nil
+}
+
+func (r *filesRepository) FindOneFile(fileId string) (*files.File, error) {
+	query := `
+	SELECT
+		"id",
+		COALESCE("owner_id", '') AS "owner_id",
+		"storage_key",
+		"url",
+		"filename",
+		"size",
+		"content_type",
+		"checksum",
+		COALESCE("referenced_by", '') AS "referenced_by",
+		"visibility",
+		"created_at",
+		COALESCE("parent_id"::text, '') AS "parent_id",
+		COALESCE("variant", '') AS "variant",
+		COALESCE("width", 0) AS "width",
+		COALESCE("height", 0) AS "height"
+	FROM "files"
+	WHERE "id"::text = $1;`
+
+	file := new(files.File)
+	if err := r.db.Get(file, query, fileId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("file not found")
+		}
+		return nil, fmt.Errorf("get file failed: %v", err)
+	}
+	return file, nil
+}
+
+// ลบไฟล์และรูปย่อ คืน storage key ของรูปย่อเพื่อนำไปลบไฟล์จริงต่อ
+func (r *filesRepository) DeleteFileByKey(storageKey string) ([]string, error) {
+	query := `
+	WITH "parent" AS (
+		DELETE FROM "files"
+		WHERE "storage_key" = $1
+		RETURNING "id"
+	)
+	DELETE FROM "files"
+	WHERE "parent_id" IN (SELECT "id" FROM "parent")
+	RETURNING "storage_key";`
+
+	keys := make([]string, 0)
+	if err := r.db.Select(&keys, query, storageKey); err != nil {
+		return nil, fmt.Errorf("delete file failed: %v", err)
+	}
+	return keys, nil
+}
+
+// รวมขนาดไฟล์ทั้งหมดของ user รวมรูปย่อด้วย
+func (r *filesRepository) SumFileSizeByOwner(ownerId string) (int64, error) {
+	query := `SELECT COALESCE(SUM("size"), 0) FROM "files" WHERE "owner_id" = $1;`
+
+	var size int64
+	if err := r.db.Get(&size, query, ownerId); err != nil {
+		return 0, fmt.Errorf("sum file size failed: %v", err)
+	}
+	return size, nil
+}
+
+// ไฟล์ที่ไม่มีตารางไหนอ้างถึง ได้แก่รูปของสินค้า สลิปของ order และไฟล์ต้นทางของงาน import สินค้า
+// ตารางที่อ้างถึงไฟล์เพิ่มต้องถูกเพิ่มที่นี่และใน SyncFileReferences ด้วย ไม่เช่นนั้นไฟล์จะถูก sweeper ลบ
+const fileUnreferencedCondition = `
+	AND NOT EXISTS (SELECT 1 FROM "images" "i" WHERE "i"."url" = "f"."url")
+	AND NOT EXISTS (SELECT 1 FROM "orders" "o" WHERE "o"."transfer_slip"->>'url' = "f"."url")
+	AND NOT EXISTS (SELECT 1 FROM "product_import_jobs" "j" WHERE "j"."file_id" = "f"."id")`
+
+// อัปเดต referenced_by จากตารางที่อ้างถึงไฟล์ ใช้ตารางแรกที่พบ
+func (r *filesRepository) SyncFileReferences() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE "files" "f" SET
+		"referenced_by" = "r"."ref"
+	FROM (
+		SELECT
+			"f2"."id",
+			COALESCE(
+				(
+					SELECT 'products:' || "i"."product_id"
+					FROM "images" "i"
+					WHERE "i"."url" = "f2"."url"
+					LIMIT 1
+				),
+				(
+					SELECT 'orders:' || "o"."id"
+					FROM "orders" "o"
+					WHERE "o"."transfer_slip"->>'url' = "f2"."url"
+					LIMIT 1
+				),
+				(
+					SELECT 'product_import_jobs:' || "j"."id"
+					FROM "product_import_jobs" "j"
+					WHERE "j"."file_id" = "f2"."id"
+					LIMIT 1
+				)
+			) AS "ref"
+		FROM "files" "f2"
+		WHERE "f2"."parent_id" IS NULL
+	) AS "r"
+	WHERE "f"."id" = "r"."id"
+	AND "f"."referenced_by" IS DISTINCT FROM "r"."ref";`
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("sync file references failed: %v", err)
+	}
+	return nil
+}
+
+func (r *filesRepository) FindOrphanFiles(graceSeconds, limit int) ([]*files.File, error) {
+	query := `
+	SELECT
+		"f"."id",
+		COALESCE("f"."owner_id", '') AS "owner_id",
+		"f"."storage_key",
+		"f"."url",
+		"f"."filename",
+		"f"."size" + COALESCE((SELECT SUM("c"."size") FROM "files" "c" WHERE "c"."parent_id" = "f"."id"), 0) AS "size",
+		"f"."content_type",
+		"f"."checksum",
+		'' AS "referenced_by",
+		"f"."visibility",
+		"f"."created_at"
+	FROM "files" "f"
+	WHERE "f"."referenced_by" IS NULL
+	AND "f"."parent_id" IS NULL
+	AND "f"."visibility" = 'public'
+	AND "f"."created_at" < now() - make_interval(secs => $1)` + fileUnreferencedCondition + `
+	ORDER BY "f"."created_at" ASC
+	LIMIT $2;`
+
+	result := make([]*files.File, 0)
+	if err := r.db.Select(&result, query, graceSeconds, limit); err != nil {
+		return nil, fmt.Errorf("find orphan files failed: %v", err)
+	}
+	return result, nil
+}
+
+// ลบ row ก่อนลบไฟล์จริง ถ้ามีข้อมูลมาอ้างถึงระหว่างนั้นหรือ instance อื่นลบไปแล้วจะไม่ได้ key กลับมา
+// ไฟล์ private เช่นไฟล์ของสินค้าดิจิทัลไม่ถูกลบอัตโนมัติ
+func (r *filesRepository) DeleteOrphanFile(fileId string) ([]string, error) {
+	query := `
+	WITH "parent" AS (
+		DELETE FROM "files" "f"
+		WHERE "f"."id" = $1
+		AND "f"."referenced_by" IS NULL
+		AND "f"."visibility" = 'public'` + fileUnreferencedCondition + `
+		RETURNING "f"."id", "f"."storage_key"
+	), "children" AS (
+		DELETE FROM "files"
+		WHERE "parent_id" IN (SELECT "id" FROM "parent")
+		RETURNING "storage_key"
+	)
+	SELECT "storage_key" FROM "parent"
+	UNION ALL
+	SELECT "storage_key" FROM "children";`
+
+	keys := make([]string, 0)
+	if err := r.db.Select(&keys, query, fileId); err != nil {
+		return nil, fmt.Errorf("delete orphan file failed: %v", err)
+	}
+	return keys, nil
+}
+
+func (r *filesRepository) InsertUpload(req *files.Upload, expiresSeconds int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "file_uploads" (
+		"owner_id",
+		"storage_key",
+		"filename",
+		"content_type",
+		"visibility",
+		"size",
+		"checksum",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, now() + make_interval(secs => $8))
+	RETURNING "id", "expires_at", "created_at";`
+
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.OwnerId,
+		req.StorageKey,
+		req.FileName,
+		req.ContentType,
+		req.Visibility,
+		req.Size,
+		req.Checksum,
+		expiresSeconds,
+	).Scan(&req.Id, &req.ExpiresAt, &req.CreatedAt); err != nil {
+		return fmt.Errorf("insert upload failed: %v", err)
+	}
+	return nil
+}
+
+// upload ที่หมดอายุแล้วถือว่าไม่มีอยู่
+func (r *filesRepository) FindOneUpload(uploadId string) (*files.Upload, error) {
+	query := `
+	SELECT
+		"id",
+		"owner_id",
+		"storage_key",
+		"filename",
+		"content_type",
+		"visibility",
+		"size",
+		"offset",
+		"checksum",
+		"expires_at",
+		"created_at"
+	FROM "file_uploads"
+	WHERE "id"::text = $1
+	AND "expires_at" > now();`
+
+	upload := new(files.Upload)
+	if err := r.db.Get(upload, query, uploadId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("upload not found")
+		}
+		return nil, fmt.Errorf("get upload failed: %v", err)
+	}
+	return upload, nil
+}
+
+// เลื่อน offset เฉพาะเมื่อ offset ยังตรงกับที่ client ส่งมา กันสอง request เขียนทับ chunk เดียวกัน
+func (r *filesRepository) InsertUploadPart(uploadId string, part *files.UploadPart) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	UPDATE "file_uploads" SET
+		"offset" = "offset" + $3
+	WHERE "id" = $1
+	AND "offset" = $2
+	AND "offset" + $3 <= "size"
+	AND "expires_at" > now()
+	RETURNING "offset";`
+
+	var offset int64
+	if err := tx.QueryRowxContext(ctx, query, uploadId, part.Offset, part.Size).Scan(&offset); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("upload offset mismatch")
+		}
+		return 0, fmt.Errorf("update upload offset failed: %v", err)
+	}
+
+	queryPart := `
+	INSERT INTO "file_upload_parts" (
+		"upload_id",
+		"offset",
+		"size",
+		"storage_key"
+	)
+	VALUES ($1, $2, $3, $4);`
+
+	if _, err := tx.ExecContext(ctx, queryPart, uploadId, part.Offset, part.Size, part.StorageKey); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("insert upload part failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+func (r *filesRepository) FindUploadParts(uploadId string) ([]*files.UploadPart, error) {
+	query := `
+	SELECT
+		"offset",
+		"size",
+		"storage_key"
+	FROM "file_upload_parts"
+	WHERE "upload_id" = $1
+	ORDER BY "offset" ASC;`
+
+	parts := make([]*files.UploadPart, 0)
+	if err := r.db.Select(&parts, query, uploadId); err != nil {
+		return nil, fmt.Errorf("get upload parts failed: %v", err)
+	}
+	return parts, nil
+}
+
+// ลบ upload พร้อม part คืน storage key ของ part เพื่อนำไปลบไฟล์จริงต่อ
+func (r *filesRepository) DeleteUpload(uploadId string) ([]string, error) {
+	query := `
+	WITH "upload" AS (
+		DELETE FROM "file_uploads"
+		WHERE "id" = $1
+		RETURNING "id"
+	)
+	SELECT "p"."storage_key"
+	FROM "file_upload_parts" "p"
+	WHERE "p"."upload_id" IN (SELECT "id" FROM "upload");`
+
+	keys := make([]string, 0)
+	if err := r.db.Select(&keys, query, uploadId); err != nil {
+		return nil, fmt.Errorf("delete upload failed: %v", err)
+	}
+	return keys, nil
+}
+
+func (r *filesRepository) FindExpiredUploads(limit int) ([]string, error) {
+	query := `
+	SELECT "id"
+	FROM "file_uploads"
+	WHERE "expires_at" <= now()
+	ORDER BY "expires_at" ASC
+	LIMIT $1;`
+
+	ids := make([]string, 0)
+	if err := r.db.Select(&ids, query, limit); err != nil {
+		return nil, fmt.Errorf("find expired uploads failed: %v", err)
+	}
+	return ids, nil
+}
@@ -3,291 +3,451 @@ package filesUsecases
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"log"
+	"net/http"
+	"path"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/modules/files/filesRepositories"
+	"github.com/Doittikorn/go-e-commerce/pkg/imaging"
+	"github.com/Doittikorn/go-e-commerce/pkg/scanner"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 )
 
+// จำนวนไฟล์ที่ upload หรือ delete พร้อมกัน
+const numWorkers = 5
+
+// อายุสูงสุดของ signed url ตามที่ GCS V4 รองรับ
+const maxSignedUrlExpires = 7 * 24 * time.Hour
+
 type IFilesUsecase interface {
-	UploadToGCP(req []*files.FileReq) ([]*files.FileRes, error)
-	DeleteFileOnGCP(req []*files.DeleteFileReq) error
-	UploadToStorage(req []*files.FileReq) ([]*files.FileRes, error)
-	DeleteFileOnStorage(req []*files.DeleteFileReq) error
+	UploadFiles(req []*files.FileReq) ([]*files.FileRes, error)
+	DeleteFiles(req []*files.DeleteFileReq) error
+	InitUpload(req *files.UploadInitReq) (*files.Upload, error)
+	FindOneUpload(uploadId, ownerId string) (*files.Upload, error)
+	AppendUpload(uploadId, ownerId string, offset int64, chunk []byte, checksum string) (*files.Upload, error)
+	CompleteUpload(uploadId, ownerId string) (*files.FileRes, error)
+	AbortUpload(uploadId, ownerId string) error
+	SignFileUrl(fileId, userId string, isAdmin bool, expires time.Duration) (*files.SignedUrlRes, error)
+	OpenSignedFile(key string, expires int64, signature string) (io.ReadCloser, *storage.ObjectInfo, error)
+	OpenFile(fileId, userId string, isAdmin bool) (io.ReadCloser, *files.File, error)
+	SweepOrphanFiles(dryRun bool) (*files.OrphanReport, error)
+	RunSweeper()
 }
 
 type filesUsecase struct {
-	cfg config.ConfigImpl
+	cfg             config.ConfigImpl
+	storage         storage.StorageImpl
+	scanner         scanner.ScannerImpl
+	filesRepository filesRepositories.IFilesRepository
 }
 
-func FilesUsecase(cfg config.ConfigImpl) IFilesUsecase {
+func FilesUsecase(cfg config.ConfigImpl, storage storage.StorageImpl, scanner scanner.ScannerImpl, filesRepository filesRepositories.IFilesRepository) IFilesUsecase {
 	return &filesUsecase{
-		cfg: cfg,
+		cfg:             cfg,
+		storage:         storage,
+		scanner:         scanner,
+		filesRepository: filesRepository,
 	}
 }
 
-type filesPub struct {
-	bucket      string
-	destination string
-	file        *files.FileRes
+// content type ที่รับ ตรวจจาก magic bytes ของไฟล์ ไม่ใช่นามสกุล
+var acceptedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
 }
 
-func (f *filesPub) makePublic(ctx context.Context, client *storage.Client) error {
-	acl := client.Bucket(f.bucket).Object(f.destination).ACL()
-	if err := acl.Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
-		return fmt.Errorf("ACLHandle.Set: %v", err)
+// แบ่งงาน n ชิ้นให้ worker ทำพร้อมกัน และคืน error แรกที่เจอ
+func runWorkers(n int, work func(i int) error) error {
+	jobsCh := make(chan int, n)
+	errsCh := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		jobsCh <- i
+	}
+	close(jobsCh)
+
+	for w := 0; w < numWorkers; w++ {
+		go func() {
+			for i := range jobsCh {
+				errsCh <- work(i)
+			}
+		}()
+	}
+
+	var result error
+	for a := 0; a < n; a++ {
+		if err := <-errsCh; err != nil && result == nil {
+			result = err
+		}
 	}
-	fmt.Printf("Blob %v is now publicly accessible.\n", f.destination)
-	return nil
+	return result
 }
 
-func (u *filesUsecase) uploadWorkers(ctx context.Context, client *storage.Client, jobs <-chan *files.FileReq, results chan<- *files.FileRes, errs chan<- error) {
-	for job := range jobs {
-		cotainer, err := job.File.Open()
+func (u *filesUsecase) UploadFiles(req []*files.FileReq) ([]*files.FileRes, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	// อ่านและตรวจทุกไฟล์ก่อน ถ้ามีไฟล์ใดไม่ผ่านจะไม่บันทึกไฟล์ไหนเลย
+	contents := make([][]byte, len(req))
+	rejections := make([]*files.UploadRejection, len(req))
+	if err := runWorkers(len(req), func(i int) error {
+		container, err := req[i].File.Open()
 		if err != nil {
-			errs <- err
-			return
+			return err
 		}
-		b, err := io.ReadAll(cotainer)
+		defer container.Close()
+
+		contents[i], err = io.ReadAll(container)
 		if err != nil {
-			errs <- err
-			return
+			return err
 		}
+		rejections[i], err = u.inspect(ctx, req[i], contents[i])
+		return err
+	}); err != nil {
+		return nil, err
+	}
 
-		buf := bytes.NewBuffer(b)
-
-		// Upload an object with storage.Writer.
-		wc := client.Bucket(u.cfg.App().GCPBucket()).Object(job.Destination).NewWriter(ctx)
-
-		if _, err = io.Copy(wc, buf); err != nil {
-			errs <- fmt.Errorf("io.Copy: %v", err)
-			return
+	rejected := &files.UploadRejectedError{Rejections: make([]*files.UploadRejection, 0)}
+	for _, r := range rejections {
+		if r != nil {
+			rejected.Rejections = append(rejected.Rejections, r)
 		}
-		// Data can continue to be added to the file until the writer is closed.
-		if err := wc.Close(); err != nil {
-			errs <- fmt.Errorf("Writer.Close: %v", err)
-			return
-		}
-		fmt.Printf("%v uploaded to %v.\n", job.FileName, job.Extension)
-
-		newFile := &filesPub{
-			file: &files.FileRes{
-				FileName: job.FileName,
-				Url:      fmt.Sprintf("https://storage.googleapis.com/%s/%s", u.cfg.App().GCPBucket(), job.Destination),
-			},
-			bucket:      u.cfg.App().GCPBucket(),
-			destination: job.Destination,
+	}
+	if len(rejected.Rejections) > 0 {
+		return nil, rejected
+	}
+	if len(req) > 0 {
+		var incoming int64
+		fileNames := make([]string, 0, len(req))
+		for _, r := range req {
+			incoming += r.File.Size
+			fileNames = append(fileNames, r.SourceName)
 		}
-
-		if err := newFile.makePublic(ctx, client); err != nil {
-			errs <- err
-			return
+		if err := u.checkQuota(req[0].OwnerId, incoming, fileNames); err != nil {
+			return nil, err
 		}
-
-		errs <- nil
-		results <- newFile.file
 	}
-}
 
-func (u *filesUsecase) uploadToStorageWorker(ctx context.Context, jobs <-chan *files.FileReq, results chan<- *files.FileRes, errs chan<- error) {
-	for job := range jobs {
-		cotainer, err := job.File.Open()
+	res := make([]*files.FileRes, len(req))
+	if err := runWorkers(len(req), func(i int) error {
+		job := req[i]
+		outputs, err := u.processImage(contents[i], storage.ContentType(job.FileName))
 		if err != nil {
-			errs <- err
-			return
+			return err
 		}
-		b, err := io.ReadAll(cotainer)
+
+		key := storage.CleanKey(job.Destination)
+		original, err := u.putFile(ctx, key, job.FileName, outputs[0], job, "")
 		if err != nil {
-			errs <- err
-			return
+			return err
 		}
 
-		// Upload an object to storage
-		dest := fmt.Sprintf("./assets/images/%s", job.Destination)
-		if err := os.WriteFile(dest, b, 0777); err != nil {
-			if err := os.MkdirAll("./assets/images/"+strings.Replace(job.Destination, job.FileName, "", 1), 0777); err != nil {
-				errs <- fmt.Errorf("mkdir \"./assets/images/%s\" failed: %v", err, job.Destination)
-				return
-			}
-			if err := os.WriteFile(dest, b, 0777); err != nil {
-				errs <- fmt.Errorf("write file failed: %v", err)
-				return
-			}
+		res[i] = &files.FileRes{
+			Id:         original.Id,
+			FileName:   original.FileName,
+			Url:        original.Url,
+			Visibility: original.Visibility,
 		}
 
-		newFile := &filesPub{
-			file: &files.FileRes{
-				FileName: job.FileName,
-				Url:      fmt.Sprintf("http://%s:%d/%s", u.cfg.App().Host(), u.cfg.App().Port(), job.Destination),
-			},
-			destination: job.Destination,
+		// รูปย่อเก็บไว้ข้างไฟล์ต้นฉบับ เช่น images/products/abc_thumbnail.webp
+		stem := strings.TrimSuffix(key, path.Ext(key))
+		for _, out := range outputs[1:] {
+			renditionKey := fmt.Sprintf("%s_%s.%s", stem, out.Name, out.Extension)
+			rendition, err := u.putFile(ctx, renditionKey, path.Base(renditionKey), out, job, original.Id)
+			if err != nil {
+				return err
+			}
+			res[i].Renditions = append(res[i].Renditions, &entities.ImageRendition{
+				Name:        rendition.Variant,
+				ContentType: rendition.ContentType,
+				Width:       rendition.Width,
+				Height:      rendition.Height,
+				Url:         rendition.Url,
+			})
 		}
-
-		errs <- nil
-		results <- newFile.file
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	return res, nil
 }
 
-func (u *filesUsecase) UploadToGCP(req []*files.FileReq) ([]*files.FileRes, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
-	defer cancel()
+// ตรวจ content type จาก magic bytes, decode รูป และสแกนไวรัส คืน nil ถ้าไฟล์ผ่าน
+func (u *filesUsecase) inspect(ctx context.Context, req *files.FileReq, data []byte) (*files.UploadRejection, error) {
+	reject := func(reason, msg string) (*files.UploadRejection, error) {
+		return &files.UploadRejection{
+			FileName: req.SourceName,
+			Reason:   reason,
+			Message:  msg,
+		}, nil
+	}
+
+	sniffed := http.DetectContentType(data)
+	if !acceptedContentTypes[sniffed] {
+		return reject(files.RejectContentType, fmt.Sprintf("content type %s is not acceptable", sniffed))
+	}
+	if expected := storage.ContentType(req.FileName); sniffed != expected {
+		return reject(files.RejectMismatch, fmt.Sprintf("content is %s but extension is %s", sniffed, req.Extension))
+	}
+	if err := imaging.Validate(data, u.cfg.Upload().MaxPixels()); err != nil {
+		return reject(files.RejectInvalidImage, err.Error())
+	}
 
-	client, err := storage.NewClient(ctx)
+	result, err := u.scanner.Scan(ctx, bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("storage.NewClient: %v", err)
+		log.Printf("scan file failed: %v\n", err)
+		return nil, fmt.Errorf("scan file failed")
+	}
+	if !result.Clean {
+		return reject(files.RejectInfected, fmt.Sprintf("file is infected: %s", result.Signature))
 	}
-	defer client.Close()
+	return nil, nil
+}
 
-	jobsCh := make(chan *files.FileReq, len(req))
-	resultsCh := make(chan *files.FileRes, len(req))
-	errsCh := make(chan error, len(req))
+// quota นับจากขนาดไฟล์ต้นฉบับที่ส่งมา รูปย่อที่สร้างขึ้นจะถูกนับในการ upload ครั้งถัดไป
+func (u *filesUsecase) checkQuota(ownerId string, incoming int64, fileNames []string) error {
+	quota := u.cfg.Upload().UserQuota()
+	if quota <= 0 || ownerId == "" {
+		return nil
+	}
 
-	res := make([]*files.FileRes, 0)
+	used, err := u.filesRepository.SumFileSizeByOwner(ownerId)
+	if err != nil {
+		return err
+	}
+	if used+incoming <= quota {
+		return nil
+	}
 
-	for _, r := range req {
-		jobsCh <- r
+	rejected := &files.UploadRejectedError{Rejections: make([]*files.UploadRejection, 0)}
+	for _, name := range fileNames {
+		rejected.Rejections = append(rejected.Rejections, &files.UploadRejection{
+			FileName: name,
+			Reason:   files.RejectQuota,
+			Message:  fmt.Sprintf("upload quota exceeded: used %d of %d bytes", used, quota),
+		})
 	}
-	close(jobsCh)
+	return rejected
+}
 
-	numWorkers := 5
-	for i := 0; i < numWorkers; i++ {
-		go u.uploadWorkers(ctx, client, jobsCh, resultsCh, errsCh)
+// รูปจะถูกลบ metadata และสร้างรูปย่อตาม IMAGE_RENDITIONS ส่วนไฟล์อื่นเก็บตามเดิม
+func (u *filesUsecase) processImage(data []byte, contentType string) ([]*imaging.Output, error) {
+	if !imaging.IsSupported(contentType) {
+		return []*imaging.Output{{
+			ContentType: contentType,
+			Data:        data,
+		}}, nil
 	}
 
-	for a := 0; a < len(req); a++ {
-		err := <-errsCh
-		if err != nil {
-			return nil, err
-		}
+	renditions := make([]*imaging.Rendition, 0)
+	for _, r := range u.cfg.Image().Renditions() {
+		renditions = append(renditions, &imaging.Rendition{
+			Name:    r.Name,
+			MaxSize: r.MaxSize,
+		})
+	}
+	return imaging.Process(data, &imaging.Options{
+		Renditions: renditions,
+		Formats:    u.cfg.Image().Formats(),
+		Quality:    u.cfg.Image().Quality(),
+	})
+}
 
-		result := <-resultsCh
-		res = append(res, result)
+// ไฟล์ private ไม่เก็บ url สาธารณะ
+func (u *filesUsecase) putFile(ctx context.Context, key, filename string, out *imaging.Output, req *files.FileReq, parentId string) (*files.File, error) {
+	if err := u.storage.Put(ctx, key, bytes.NewReader(out.Data), int64(len(out.Data)), out.ContentType); err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	url := u.storage.Url(key)
+	if storage.IsPrivate(key) {
+		url = ""
+	}
+	checksum := sha256.Sum256(out.Data)
+	file := &files.File{
+		OwnerId:     req.OwnerId,
+		StorageKey:  key,
+		Url:         url,
+		Visibility:  req.Visibility,
+		FileName:    filename,
+		Size:        int64(len(out.Data)),
+		ContentType: out.ContentType,
+		Checksum:    hex.EncodeToString(checksum[:]),
+		ParentId:    parentId,
+		Variant:     out.Name,
+		Width:       out.Width,
+		Height:      out.Height,
+	}
+	if err := u.filesRepository.InsertFile(file); err != nil {
+		return nil, err
+	}
+	return file, nil
 }
 
-func (u *filesUsecase) UploadToStorage(req []*files.FileReq) ([]*files.FileRes, error) {
+// ไฟล์ที่ถูกลบไปแล้วไม่ถือว่า error เพื่อให้ลบซ้ำได้
+func (u *filesUsecase) DeleteFiles(req []*files.DeleteFileReq) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
-	jobsCh := make(chan *files.FileReq, len(req))
-	resultsCh := make(chan *files.FileRes, len(req))
-	errsCh := make(chan error, len(req))
-
-	res := make([]*files.FileRes, 0)
-
-	for _, r := range req {
-		jobsCh <- r
-	}
-	close(jobsCh)
-
-	numWorkers := 5
-	for i := 0; i < numWorkers; i++ {
-		go u.uploadToStorageWorker(ctx, jobsCh, resultsCh, errsCh)
-	}
-
-	for a := 0; a < len(req); a++ {
-		err := <-errsCh
+	return runWorkers(len(req), func(i int) error {
+		key := storage.CleanKey(req[i].Destination)
+		renditionKeys, err := u.filesRepository.DeleteFileByKey(key)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		for _, k := range append([]string{key}, renditionKeys...) {
+			if err := u.storage.Delete(ctx, k); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return fmt.Errorf("delete file: %s failed: %v", k, err)
+			}
 		}
+		return nil
+	})
+}
 
-		result := <-resultsCh
-		res = append(res, result)
+// เจ้าของไฟล์หรือ admin เท่านั้นที่เข้าถึงไฟล์ผ่าน id ได้
+func (u *filesUsecase) findAccessibleFile(fileId, userId string, isAdmin bool) (*files.File, error) {
+	file, err := u.filesRepository.FindOneFile(fileId)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	if !isAdmin && (file.OwnerId == "" || file.OwnerId != userId) {
+		return nil, fmt.Errorf("no permission to access file")
+	}
+	return file, nil
 }
 
-func (u *filesUsecase) deleteFileWorkers(ctx context.Context, client *storage.Client, jobs <-chan *files.DeleteFileReq, errs chan<- error) {
-	for job := range jobs {
-		o := client.Bucket(u.cfg.App().GCPBucket()).Object(job.Destination)
+// ถ้าไม่ระบุอายุจะใช้ STORAGE_SIGNED_URL_EXPIRES
+func (u *filesUsecase) SignFileUrl(fileId, userId string, isAdmin bool, expires time.Duration) (*files.SignedUrlRes, error) {
+	file, err := u.findAccessibleFile(fileId, userId, isAdmin)
+	if err != nil {
+		return nil, err
+	}
 
-		// Optional: set a generation-match precondition to avoid potential race
-		// conditions and data corruptions. The request to delete the file is aborted
-		// if the object's generation number does not match your precondition.
-		attrs, err := o.Attrs(ctx)
-		if err != nil {
-			errs <- fmt.Errorf("object.Attrs: %v", err)
-			return
-		}
-		o = o.If(storage.Conditions{GenerationMatch: attrs.Generation})
+	if expires <= 0 {
+		expires = u.cfg.Storage().SignedUrlExpires()
+	}
+	expires = min(expires, maxSignedUrlExpires)
 
-		if err := o.Delete(ctx); err != nil {
-			errs <- fmt.Errorf("Object(%q).Delete: %v", job.Destination, err)
-			return
-		}
-		fmt.Printf("Blob %v deleted.\n", job.Destination)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
-		errs <- nil
+	url, err := u.storage.SignedUrl(ctx, file.StorageKey, expires)
+	if err != nil {
+		return nil, err
 	}
+	return &files.SignedUrlRes{
+		Url:       url,
+		ExpiresAt: time.Now().Add(expires).UTC().Format(time.RFC3339),
+	}, nil
 }
 
-func (u *filesUsecase) DeleteFileOnGCP(req []*files.DeleteFileReq) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
-	defer cancel()
-
-	client, err := storage.NewClient(ctx)
+// ใช้อ่านไฟล์ที่ upload ไว้แล้ว เช่นไฟล์ csv ที่ upload แบบ resumable เพื่อนำไป import
+func (u *filesUsecase) OpenFile(fileId, userId string, isAdmin bool) (io.ReadCloser, *files.File, error) {
+	file, err := u.findAccessibleFile(fileId, userId, isAdmin)
 	if err != nil {
-		return fmt.Errorf("storage.NewClient: %v", err)
+		return nil, nil, err
 	}
-	defer client.Close()
+	r, err := u.storage.Open(context.Background(), file.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, file, nil
+}
 
-	jobsCh := make(chan *files.DeleteFileReq, len(req))
-	errsCh := make(chan error, len(req))
+// ตรวจ signature ของ url ที่ local storage sign ไว้ แล้วเปิดไฟล์ให้ handler stream ต่อ
+func (u *filesUsecase) OpenSignedFile(key string, expires int64, signature string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	key = storage.CleanKey(key)
+	if err := storage.VerifySignature(u.cfg.Storage().SigningKey(), key, expires, signature); err != nil {
+		return nil, nil, err
+	}
 
-	for _, r := range req {
-		jobsCh <- r
+	ctx := context.Background()
+	info, err := u.storage.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
 	}
-	close(jobsCh)
+	r, err := u.storage.Open(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, info, nil
+}
 
-	numWorkers := 5
-	for i := 0; i < numWorkers; i++ {
-		go u.deleteFileWorkers(ctx, client, jobsCh, errsCh)
+// หาไฟล์ที่ไม่ถูกอ้างถึงนานเกิน grace period ถ้า dryRun จะรายงานอย่างเดียวไม่ลบ
+func (u *filesUsecase) SweepOrphanFiles(dryRun bool) (*files.OrphanReport, error) {
+	if err := u.filesRepository.SyncFileReferences(); err != nil {
+		return nil, err
 	}
 
-	for a := 0; a < len(req); a++ {
-		err := <-errsCh
-		return err
+	orphans, err := u.filesRepository.FindOrphanFiles(u.cfg.Storage().OrphanGrace(), files.OrphanSweepLimit)
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (u *filesUsecase) deleteFromStorageFileWorkers(ctx context.Context, jobs <-chan *files.DeleteFileReq, errs chan<- error) {
-	for job := range jobs {
-		if err := os.Remove("./assets/images/" + job.Destination); err != nil {
-			errs <- fmt.Errorf("remove file: %s failed: %v", job.Destination, err)
-			return
-		}
-		errs <- nil
+	report := &files.OrphanReport{
+		DryRun:       dryRun,
+		GraceSeconds: u.cfg.Storage().OrphanGrace(),
+		Count:        len(orphans),
+		Files:        orphans,
+	}
+	for _, f := range orphans {
+		report.TotalSize += f.Size
+	}
+	if dryRun {
+		return report, nil
 	}
-}
 
-func (u *filesUsecase) DeleteFileOnStorage(req []*files.DeleteFileReq) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
 	defer cancel()
 
-	jobsCh := make(chan *files.DeleteFileReq, len(req))
-	errsCh := make(chan error, len(req))
-
-	for _, r := range req {
-		jobsCh <- r
+	for _, f := range orphans {
+		keys, err := u.filesRepository.DeleteOrphanFile(f.Id)
+		if err != nil {
+			return report, err
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		for _, k := range keys {
+			if err := u.storage.Delete(ctx, k); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("sweep orphan file: %s failed: %v\n", k, err)
+			}
+		}
+		report.Deleted++
 	}
-	close(jobsCh)
+	return report, nil
+}
 
-	numWorkers := 5
-	for i := 0; i < numWorkers; i++ {
-		go u.deleteFromStorageFileWorkers(ctx, jobsCh, errsCh)
+// ทำงานตลอดอายุของ server ปิดได้ด้วย FILES_SWEEP_INTERVAL=0
+func (u *filesUsecase) RunSweeper() {
+	interval := u.cfg.Storage().SweepInterval()
+	if interval <= 0 {
+		return
 	}
 
-	for a := 0; a < len(req); a++ {
-		err := <-errsCh
-		return err
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		u.sweepExpiredUploads()
+
+		report, err := u.SweepOrphanFiles(false)
+		if err != nil {
+			log.Printf("sweep orphan files failed: %v\n", err)
+			continue
+		}
+		if report.Deleted > 0 {
+			log.Printf("sweep orphan files: deleted %d files\n", report.Deleted)
+		}
 	}
-	return nil
 }
@@ -0,0 +1,345 @@
+package filesUsecases
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
+	"github.com/Doittikorn/go-e-commerce/pkg/utils"
+	"github.com/google/uuid"
+)
+
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// เวลาสูงสุดที่ใช้ตรวจและรวม chunk ของไฟล์ใหญ่
+const completeUploadTimeout = 30 * time.Minute
+
+func (u *filesUsecase) InitUpload(req *files.UploadInitReq) (*files.Upload, error) {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(req.FileName), "."))
+	fileType, ok := files.ResumableTypes[ext]
+	if !ok {
+		return nil, &files.UploadRejectedError{Rejections: []*files.UploadRejection{{
+			FileName: req.FileName,
+			Reason:   files.RejectExtension,
+			Message:  "extension is not acceptable",
+		}}}
+	}
+	if req.Size <= 0 || req.Size > u.cfg.Upload().MaxSize() {
+		return nil, &files.UploadRejectedError{Rejections: []*files.UploadRejection{{
+			FileName: req.FileName,
+			Reason:   files.RejectFileSize,
+			Message:  fmt.Sprintf("file size must be between 1 and %d bytes", u.cfg.Upload().MaxSize()),
+		}}}
+	}
+	checksum := strings.ToLower(req.Checksum)
+	if !checksumPattern.MatchString(checksum) {
+		return nil, fmt.Errorf("checksum is invalid")
+	}
+	if err := u.checkQuota(req.OwnerId, req.Size, []string{req.FileName}); err != nil {
+		return nil, err
+	}
+
+	key, err := storage.ObjectKey(req.Destination, utils.RandFileName(ext), req.Visibility == files.VisibilityPrivate)
+	if err != nil {
+		return nil, err
+	}
+	upload := &files.Upload{
+		OwnerId:     req.OwnerId,
+		StorageKey:  key,
+		FileName:    path.Base(req.FileName),
+		ContentType: fileType.ContentType,
+		Visibility:  req.Visibility,
+		Size:        req.Size,
+		Checksum:    checksum,
+		ChunkSize:   u.cfg.Upload().ChunkSize(),
+	}
+	if err := u.filesRepository.InsertUpload(upload, int(u.cfg.Upload().SessionExpires().Seconds())); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// upload ของคนอื่นถือว่าไม่มีอยู่ เพื่อไม่ให้เดา id ได้
+func (u *filesUsecase) FindOneUpload(uploadId, ownerId string) (*files.Upload, error) {
+	upload, err := u.filesRepository.FindOneUpload(uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if upload.OwnerId != ownerId {
+		return nil, fmt.Errorf("upload not found")
+	}
+	upload.ChunkSize = u.cfg.Upload().ChunkSize()
+	return upload, nil
+}
+
+// ถ้า offset ไม่ตรงจะคืน upload ปัจจุบันมาด้วยเพื่อให้ client ส่งต่อจาก offset ที่ถูกต้อง
+func (u *filesUsecase) AppendUpload(uploadId, ownerId string, offset int64, chunk []byte, checksum string) (*files.Upload, error) {
+	upload, err := u.FindOneUpload(uploadId, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return upload, fmt.Errorf("upload offset mismatch")
+	}
+	switch {
+	case len(chunk) == 0:
+		return nil, fmt.Errorf("chunk is empty")
+	case int64(len(chunk)) > upload.ChunkSize:
+		return nil, fmt.Errorf("chunk is too large")
+	case offset+int64(len(chunk)) > upload.Size:
+		return nil, fmt.Errorf("chunk exceeds upload size")
+	}
+	if checksum != "" {
+		sum := sha256.Sum256(chunk)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(checksum) {
+			return nil, fmt.Errorf("chunk checksum mismatch")
+		}
+	}
+	if offset == 0 {
+		if rejection := inspectResumable(upload, chunk); rejection != nil {
+			return nil, &files.UploadRejectedError{Rejections: []*files.UploadRejection{rejection}}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	// key ของ part สุ่มเสมอ request ที่แพ้การแย่ง offset จะไม่เขียนทับ part ของอีก request
+	part := &files.UploadPart{
+		Offset:     offset,
+		Size:       int64(len(chunk)),
+		StorageKey: fmt.Sprintf("%suploads/%s/%020d_%s", storage.PrivatePrefix, upload.Id, offset, uuid.NewString()),
+	}
+	if err := u.storage.Put(ctx, part.StorageKey, bytes.NewReader(chunk), part.Size, "application/octet-stream"); err != nil {
+		return nil, err
+	}
+	newOffset, err := u.filesRepository.InsertUploadPart(upload.Id, part)
+	if err != nil {
+		u.deleteKeys(ctx, []string{part.StorageKey})
+		if err.Error() == "upload offset mismatch" {
+			current, findErr := u.FindOneUpload(uploadId, ownerId)
+			if findErr != nil {
+				return nil, findErr
+			}
+			return current, err
+		}
+		return nil, err
+	}
+	upload.Offset = newOffset
+	return upload, nil
+}
+
+// ตรวจ magic bytes จาก chunk แรก
+func inspectResumable(upload *files.Upload, chunk []byte) *files.UploadRejection {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(upload.StorageKey), "."))
+	sniffed := strings.TrimSpace(strings.Split(http.DetectContentType(chunk), ";")[0])
+	for _, t := range files.ResumableTypes[ext].Sniffed {
+		if sniffed == t {
+			return nil
+		}
+	}
+	return &files.UploadRejection{
+		FileName: upload.FileName,
+		Reason:   files.RejectMismatch,
+		Message:  fmt.Sprintf("content is %s but extension is %s", sniffed, ext),
+	}
+}
+
+// ตรวจ checksum และสแกนไวรัสจาก part ทั้งหมดก่อน แล้วจึงรวม part เป็นไฟล์เดียวใน storage
+func (u *filesUsecase) CompleteUpload(uploadId, ownerId string) (*files.FileRes, error) {
+	upload, err := u.FindOneUpload(uploadId, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Offset != upload.Size {
+		return nil, fmt.Errorf("upload is incomplete")
+	}
+	// session ที่เปิดก่อนมีการตรวจ destination อาจมี key ไม่ตรงกับ visibility
+	if storage.IsPrivate(upload.StorageKey) != (upload.Visibility == files.VisibilityPrivate) {
+		return nil, storage.ErrDestinationInvalid
+	}
+	parts, err := u.filesRepository.FindUploadParts(upload.Id)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(parts))
+	var next int64
+	for _, p := range parts {
+		if p.Offset != next {
+			return nil, fmt.Errorf("upload is incomplete")
+		}
+		next += p.Size
+		keys = append(keys, p.StorageKey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completeUploadTimeout)
+	defer cancel()
+
+	hash := sha256.New()
+	r := newPartsReader(ctx, u.storage, keys)
+	tee := io.TeeReader(r, hash)
+	result, err := u.scanner.Scan(ctx, tee)
+	if err == nil {
+		// scanner บางตัวไม่อ่าน stream จนจบ ต้องอ่านส่วนที่เหลือเพื่อให้ hash ครบ
+		_, err = io.Copy(io.Discard, tee)
+	}
+	r.Close()
+	if err != nil {
+		log.Printf("scan file failed: %v\n", err)
+		return nil, fmt.Errorf("scan file failed")
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != upload.Checksum {
+		u.abortUpload(ctx, upload.Id)
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	if !result.Clean {
+		u.abortUpload(ctx, upload.Id)
+		return nil, &files.UploadRejectedError{Rejections: []*files.UploadRejection{{
+			FileName: upload.FileName,
+			Reason:   files.RejectInfected,
+			Message:  fmt.Sprintf("file is infected: %s", result.Signature),
+		}}}
+	}
+
+	r = newPartsReader(ctx, u.storage, keys)
+	defer r.Close()
+	if err := u.storage.Put(ctx, upload.StorageKey, r, upload.Size, upload.ContentType); err != nil {
+		return nil, err
+	}
+
+	url := u.storage.Url(upload.StorageKey)
+	if storage.IsPrivate(upload.StorageKey) {
+		url = ""
+	}
+	file := &files.File{
+		OwnerId:     upload.OwnerId,
+		StorageKey:  upload.StorageKey,
+		Url:         url,
+		Visibility:  upload.Visibility,
+		FileName:    upload.FileName,
+		Size:        upload.Size,
+		ContentType: upload.ContentType,
+		Checksum:    upload.Checksum,
+	}
+	if err := u.filesRepository.InsertFile(file); err != nil {
+		return nil, err
+	}
+	u.abortUpload(ctx, upload.Id)
+
+	return &files.FileRes{
+		Id:         file.Id,
+		FileName:   file.FileName,
+		Url:        file.Url,
+		Visibility: file.Visibility,
+	}, nil
+}
+
+func (u *filesUsecase) AbortUpload(uploadId, ownerId string) error {
+	upload, err := u.FindOneUpload(uploadId, ownerId)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	return u.abortUpload(ctx, upload.Id)
+}
+
+func (u *filesUsecase) abortUpload(ctx context.Context, uploadId string) error {
+	keys, err := u.filesRepository.DeleteUpload(uploadId)
+	if err != nil {
+		return err
+	}
+	u.deleteKeys(ctx, keys)
+	return nil
+}
+
+func (u *filesUsecase) deleteKeys(ctx context.Context, keys []string) {
+	for _, k := range keys {
+		if err := u.storage.Delete(ctx, k); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("delete upload part: %s failed: %v\n", k, err)
+		}
+	}
+}
+
+// ลบ upload ที่ไม่ complete ภายใน UPLOAD_SESSION_EXPIRES
+func (u *filesUsecase) sweepExpiredUploads() {
+	ids, err := u.filesRepository.FindExpiredUploads(files.ExpiredUploadSweepLimit)
+	if err != nil {
+		log.Printf("sweep expired uploads failed: %v\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+
+	for _, id := range ids {
+		if err := u.abortUpload(ctx, id); err != nil {
+			log.Printf("sweep expired upload: %s failed: %v\n", id, err)
+		}
+	}
+}
+
+// อ่าน part ต่อกันทีละไฟล์ เปิด part ถัดไปเมื่ออ่าน part ก่อนหน้าจบ
+type partsReader struct {
+	ctx     context.Context
+	storage storage.StorageImpl
+	keys    []string
+	current io.ReadCloser
+}
+
+func newPartsReader(ctx context.Context, storage storage.StorageImpl, keys []string) *partsReader {
+	return &partsReader{
+		ctx:     ctx,
+		storage: storage,
+		keys:    keys,
+	}
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			current, err := r.storage.Open(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current = current
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -1,6 +1,46 @@
 package middlewares
 
-type Role struct {
-	Id    int    `db:"id"`
-	Title string `db:"title"`
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// permission ของ role ที่ถูก cache ไว้ เพื่อไม่ต้อง query ทุก request
+type RolePermissions struct {
+	RoleId      int
+	Permissions map[string]bool
+	ExpiresAt   time.Time
+}
+
+func (r *RolePermissions) Has(permission string) bool {
+	return r != nil && r.Permissions[permission]
+}
+
+// permission ของ role ที่ RequirePermission หรือ LoadPermissions โหลดไว้ใน request นี้
+// ถ้า route ไม่ได้ผ่าน middleware ดังกล่าวจะคืน nil ซึ่ง Has จะเป็น false เสมอ
+func Permissions(c *fiber.Ctx) *RolePermissions {
+	permissions, _ := c.Locals("userPermissions").(*RolePermissions)
+	return permissions
+}
+
+func (r *RolePermissions) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	IdempotencyMaxKeyLen = 255
+	// request แรกที่ค้างนานกว่านี้ถือว่าล้มเหลวไปแล้ว ส่ง key เดิมมาใหม่ได้
+	IdempotencyLockTimeout = time.Minute
+)
+
+// response ที่เก็บไว้ของ Idempotency-Key ถ้า StatusCode เป็น nil คือ request แรกยังทำงานไม่เสร็จ
+type IdempotencyRecord struct {
+	Scope       string `db:"scope"`
+	Key         string `db:"key"`
+	Fingerprint string `db:"fingerprint"`
+	StatusCode  *int   `db:"status_code"`
+	ContentType string `db:"content_type"`
+	Response    []byte `db:"response"`
 }
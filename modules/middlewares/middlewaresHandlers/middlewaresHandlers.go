@@ -1,14 +1,21 @@
 package middlewaresHandlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/middlewares"
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares/middlewaresUsecases"
 	"github.com/Doittikorn/go-e-commerce/pkg/auth"
-	"github.com/Doittikorn/go-e-commerce/pkg/utils"
+	"github.com/Doittikorn/go-e-commerce/pkg/ratelimit"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
@@ -23,6 +30,9 @@ const (
 	paramsCheckErr middlewareHandlersErrCode = "middleware-003"
 	authorizeErr   middlewareHandlersErrCode = "middleware-004"
 	apiKeyErr      middlewareHandlersErrCode = "middlware-005"
+	adminTokenErr  middlewareHandlersErrCode = "middleware-006"
+	rateLimitErr   middlewareHandlersErrCode = "middleware-007"
+	idempotencyErr middlewareHandlersErrCode = "middleware-008"
 )
 
 type MiddlewaresHandlerImpl interface {
@@ -31,21 +41,28 @@ type MiddlewaresHandlerImpl interface {
 	Logger() fiber.Handler
 	JwtAuth() fiber.Handler
 	VerifyParamUserId() fiber.Handler
-	Authorize(...int) fiber.Handler
-	ApiKeyAuth() fiber.Handler
+	RequirePermission(...string) fiber.Handler
+	LoadPermissions() fiber.Handler
+	ClearPermissionsCache()
+	ApiKeyAuth(...string) fiber.Handler
+	AdminTokenAuth() fiber.Handler
+	RateLimit(group string) fiber.Handler
+	Idempotency() fiber.Handler
 	StreamingFile() fiber.Handler
 }
 
 type middlewaresHandler struct {
 	cfg               config.ConfigImpl
 	middlewareUsecase middlewaresUsecases.MiddlewaresUsecaseImpl
+	rateLimitStore    ratelimit.StoreImpl
 }
 
-func MiddlewaresHandler(cfg config.ConfigImpl, middlewareU middlewaresUsecases.MiddlewaresUsecaseImpl) MiddlewaresHandlerImpl {
+func MiddlewaresHandler(cfg config.ConfigImpl, middlewareU middlewaresUsecases.MiddlewaresUsecaseImpl, rateLimitStore ratelimit.StoreImpl) MiddlewaresHandlerImpl {
 
 	return &middlewaresHandler{
 		middlewareUsecase: middlewareU,
 		cfg:               cfg,
+		rateLimitStore:    rateLimitStore,
 	}
 }
 
@@ -137,69 +154,292 @@ func (h *middlewaresHandler) VerifyParamUserId() fiber.Handler {
 	}
 }
 
-// ตรวจสอบว่า user ที่ส่งมามีสิทธิ์ในการเข้าถึงหรือไม่
-func (h *middlewaresHandler) Authorize(expectedRoleId ...int) fiber.Handler {
+// ตรวจสอบว่า role ของ user มี permission ครบตามที่ route ต้องการหรือไม่
+func (h *middlewaresHandler) RequirePermission(permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userRoleId, ok := c.Locals("userRoleId").(int)
-		if !ok {
+		rolePermissions, status, err := h.loadPermissions(c)
+		if err != nil {
+			return entities.NewResponse(c).Error(status, string(authorizeErr), err.Error()).Res()
+		}
+
+		for _, p := range permissions {
+			if !rolePermissions.Has(p) {
+				return entities.NewResponse(c).Error(
+					http.StatusForbidden,
+					string(authorizeErr),
+					"no permission to access",
+				).Res()
+			}
+		}
+		return c.Next()
+	}
+}
+
+// โหลด permission ของ role ไว้ใน request โดยไม่บังคับว่าต้องมี permission ใด
+// ใช้กับ route ที่ทำได้มากขึ้นตาม permission เช่นเจ้าของไฟล์กับผู้ที่อ่านไฟล์ได้ทุกไฟล์
+func (h *middlewaresHandler) LoadPermissions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, status, err := h.loadPermissions(c); err != nil {
+			return entities.NewResponse(c).Error(status, string(authorizeErr), err.Error()).Res()
+		}
+		return c.Next()
+	}
+}
+
+// เก็บ permission ไว้ใน c.Locals("userPermissions") คืน http status ที่ควรตอบเมื่อโหลดไม่ได้
+func (h *middlewaresHandler) loadPermissions(c *fiber.Ctx) (*middlewares.RolePermissions, int, error) {
+	userRoleId, ok := c.Locals("userRoleId").(int)
+	if !ok {
+		return nil, http.StatusUnauthorized, fmt.Errorf("userId is invalid")
+	}
+	rolePermissions, err := h.middlewareUsecase.FindRolePermissions(userRoleId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	c.Locals("userPermissions", rolePermissions)
+	return rolePermissions, http.StatusOK, nil
+}
+
+func (h *middlewaresHandler) ClearPermissionsCache() {
+	h.middlewareUsecase.ClearPermissionsCache()
+}
+
+// ตรวจสอบ api key ที่ header X-Api-Key ว่ายังใช้งานได้และมี scope ครบตามที่ route ต้องการ
+func (h *middlewaresHandler) ApiKeyAuth(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-Api-Key")
+		if key == "" {
 			return entities.NewResponse(c).Error(
-				http.StatusUnauthorized,
-				string(authorizeErr),
-				"userId is invalid",
+				fiber.ErrUnauthorized.Code,
+				string(apiKeyErr),
+				"apikey is invalid or required",
 			).Res()
 		}
-		roles, err := h.middlewareUsecase.FindRole()
+
+		// จำกัดตาม ip ก่อนค้นหา key ใน database ไม่เช่นนั้น key ที่ไม่ถูกต้องจะไม่ถูกจำกัดเลย
+		if perMinute, burst, ok := h.cfg.RateLimit().Group("apikey"); ok {
+			result, err := h.rateLimitStore.Take("apikey:ip:"+c.IP(), ratelimit.Limit{PerMinute: perMinute, Burst: burst})
+			if err != nil {
+				return entities.NewResponse(c).Error(
+					http.StatusInternalServerError,
+					string(rateLimitErr),
+					err.Error(),
+				).Res()
+			}
+			if !result.Allowed {
+				c.Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+				return entities.NewResponse(c).Error(
+					http.StatusTooManyRequests,
+					string(rateLimitErr),
+					"too many requests",
+				).Res()
+			}
+		}
+
+		apiKey, err := h.findApiKey(c, key)
 		if err != nil {
 			return entities.NewResponse(c).Error(
-				http.StatusInternalServerError,
-				string(authorizeErr),
-				err.Error(),
+				fiber.ErrUnauthorized.Code,
+				string(apiKeyErr),
+				"apikey is invalid or required",
 			).Res()
 		}
 
-		sum := 0
-		for _, v := range expectedRoleId {
-			sum += v
+		for _, s := range scopes {
+			if !apiKey.HasScope(s) {
+				return entities.NewResponse(c).Error(
+					fiber.ErrForbidden.Code,
+					string(apiKeyErr),
+					"apikey has no permission to access",
+				).Res()
+			}
 		}
 
-		expectedValueBinary := utils.BinaryConverter(sum, len(roles))
+		// Set information api key to context
+		c.Locals("apiKeyId", apiKey.Id)
+		c.Locals("apiKeyOwnerId", apiKey.OwnerId)
+		c.Locals("apiKeyTier", apiKey.Tier)
 
-		userValueBinary := utils.BinaryConverter(userRoleId, len(roles))
+		return c.Next()
+	}
+}
 
-		// user -> 0 1 0
-		// expected -> 1 1 0
+// key รุ่นเก่าถูกนับ rate limit แยกตาม hash ของ key และแจ้งวันที่เลิกใช้ผ่าน header Deprecation และ Sunset
+func (h *middlewaresHandler) findApiKey(c *fiber.Ctx, key string) (*apikeys.ApiKey, error) {
+	if !apikeys.IsLegacyKey(key) {
+		return h.middlewareUsecase.FindApiKey(key)
+	}
+	if err := auth.ParseLegacyApiKey(h.cfg.JWT(), key); err != nil {
+		return nil, err
+	}
 
-		for i := range userValueBinary {
-			if userValueBinary[i]&expectedValueBinary[i] == 1 {
-				return c.Next()
-			}
+	c.Set("Deprecation", "true")
+	c.Set("Sunset", h.cfg.JWT().ApiKeyLegacyUntil().UTC().Format(http.TimeFormat))
+	return &apikeys.ApiKey{
+		Id:     "legacy:" + apikeys.HashKey(key)[:16],
+		Scopes: apikeys.LegacyScopes,
+		Tier:   apikeys.DefaultTier,
+	}, nil
+}
+
+// ตรวจสอบ admin token ที่ header X-Admin-Token ว่าถูก sign ด้วย admin key หรือไม่
+func (h *middlewaresHandler) AdminTokenAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get("X-Admin-Token")
+		claims, err := auth.ParseAdminToken(h.cfg.JWT(), token)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(adminTokenErr),
+				"admin token is invalid or required",
+			).Res()
 		}
-		return entities.NewResponse(c).Error(
-			fiber.ErrUnauthorized.Code,
-			string(authorizeErr),
-			"no permission to access",
-		).Res()
 
+		// Set information admin token to context
+		c.Locals("adminTokenId", claims.ID)
+		c.Locals("adminTokenSubject", claims.Subject)
+
+		return c.Next()
 	}
 }
 
-func (h *middlewaresHandler) ApiKeyAuth() fiber.Handler {
+// จำกัดจำนวน request ต่อ client ตาม group ที่ตั้งไว้ใน RATE_LIMIT_GROUPS group ที่ใช้ต้องมีค่าเริ่มต้นใน config
+// ต้องวางไว้หลัง ApiKeyAuth หรือ JwtAuth เพื่อให้นับแยกตาม api key หรือ user ได้ ไม่เช่นนั้นจะนับตาม ip
+func (h *middlewaresHandler) RateLimit(group string) fiber.Handler {
+	perMinute, burst, ok := h.cfg.RateLimit().Group(group)
+	if !ok {
+		panic(fmt.Sprintf("rate limit group %q is not configured", group))
+	}
+
 	return func(c *fiber.Ctx) error {
-		key := c.Get("X-Api-Key")
-		if _, err := auth.ParseApiKey(h.cfg.JWT(), key); err != nil {
+		limit := ratelimit.Limit{PerMinute: perMinute, Burst: burst}
+
+		if _, ok := c.Locals("apiKeyId").(string); ok {
+			tier, _ := c.Locals("apiKeyTier").(string)
+			if multiplier, ok := h.cfg.RateLimit().TierMultiplier(tier); ok {
+				limit.PerMinute = int(float64(limit.PerMinute) * multiplier)
+				limit.Burst = int(float64(limit.Burst) * multiplier)
+			}
+		}
+
+		result, err := h.rateLimitStore.Take(group+":"+clientScope(c), limit)
+		if err != nil {
 			return entities.NewResponse(c).Error(
-				fiber.ErrUnauthorized.Code,
-				string(apiKeyErr),
-				"apikey is invalid or required",
+				http.StatusInternalServerError,
+				string(rateLimitErr),
+				err.Error(),
+			).Res()
+		}
+
+		c.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(int(result.ResetAfter.Seconds())))
+
+		if !result.Allowed {
+			c.Set("Retry-After", strconv.Itoa(int(result.RetryAfter.Seconds())))
+			return entities.NewResponse(c).Error(
+				http.StatusTooManyRequests,
+				string(rateLimitErr),
+				"too many requests",
 			).Res()
 		}
 		return c.Next()
 	}
 }
 
+// ผู้ส่ง request ใช้ api key ก่อน แล้วจึงเป็น user และ ip ตามลำดับ
+func clientScope(c *fiber.Ctx) string {
+	if apiKeyId, ok := c.Locals("apiKeyId").(string); ok {
+		return "apikey:" + apiKeyId
+	}
+	if userId, ok := c.Locals("userId").(string); ok {
+		return "user:" + userId
+	}
+	return "ip:" + c.IP()
+}
+
+// request ที่ส่ง Idempotency-Key เดิมซ้ำจะได้ response เดิมโดยไม่ทำงานซ้ำ ส่ง key เดิมกับ request ที่ต่างไปจะถูกปฏิเสธ
+// ใช้ได้กับทุก route ที่แก้ข้อมูล ต้องวางไว้หลัง ApiKeyAuth หรือ JwtAuth เพื่อแยก key ตาม client ไม่เช่นนั้นจะแยกตาม ip
+// request ที่ไม่ได้ส่ง key หรือเป็น GET, HEAD, OPTIONS ผ่านไปตามปกติ
+func (h *middlewaresHandler) Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(middlewares.IdempotencyKeyHeader)
+		if key == "" || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead || c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+		if len(key) > middlewares.IdempotencyMaxKeyLen {
+			return entities.NewResponse(c).Error(
+				http.StatusBadRequest,
+				string(idempotencyErr),
+				"idempotency key is invalid",
+			).Res()
+		}
+
+		fingerprint := sha256.New()
+		fingerprint.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+		fingerprint.Write(c.Body())
+		req := &middlewares.IdempotencyRecord{
+			Scope:       clientScope(c),
+			Key:         key,
+			Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+		}
+
+		existing, err := h.middlewareUsecase.BeginIdempotent(req, h.cfg.Idempotency().Expires())
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				http.StatusInternalServerError,
+				string(idempotencyErr),
+				err.Error(),
+			).Res()
+		}
+		if existing != nil {
+			if existing.Fingerprint != req.Fingerprint {
+				return entities.NewResponse(c).Error(
+					http.StatusUnprocessableEntity,
+					string(idempotencyErr),
+					"idempotency key is already used with a different request",
+				).Res()
+			}
+			if existing.StatusCode == nil {
+				return entities.NewResponse(c).Error(
+					http.StatusConflict,
+					string(idempotencyErr),
+					"request with this idempotency key is in progress",
+				).Res()
+			}
+			c.Set("Idempotent-Replayed", "true")
+			if existing.ContentType != "" {
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+			}
+			return c.Status(*existing.StatusCode).Send(existing.Response)
+		}
+
+		if err := c.Next(); err != nil {
+			h.middlewareUsecase.ReleaseIdempotent(req)
+			return err
+		}
+
+		// error ของระบบอาจหายไปเมื่อส่งใหม่ จึงไม่เก็บไว้และให้ส่งซ้ำด้วย key เดิมได้
+		status := c.Response().StatusCode()
+		if status >= http.StatusInternalServerError {
+			h.middlewareUsecase.ReleaseIdempotent(req)
+			return nil
+		}
+		req.StatusCode = &status
+		req.ContentType = string(c.Response().Header.ContentType())
+		req.Response = append([]byte(nil), c.Response().Body()...)
+		h.middlewareUsecase.FinishIdempotent(req)
+		return nil
+	}
+}
+
 // Streaming file
+// ไฟล์ใต้ private/ ต้องเข้าผ่าน signed url เท่านั้น
 func (h *middlewaresHandler) StreamingFile() fiber.Handler {
 	return filesystem.New(filesystem.Config{
-		Root: http.Dir("./assets/images"),
+		Next: func(c *fiber.Ctx) bool {
+			return storage.IsPrivate(strings.TrimPrefix(c.Path(), "/static"))
+		},
+		Root: http.Dir(h.cfg.Storage().LocalRoot()),
 	})
 }
@@ -1,15 +1,28 @@
 package middlewaresRepositories
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares"
 	"github.com/jmoiron/sqlx"
 )
 
 type MiddlewaresRepositoryImpl interface {
 	FindAccessToken(userId, accessToken string) bool
-	FindRole() ([]*middlewares.Role, error)
+	FindRolePermissions(roleId int) ([]string, error)
+	FindActiveApiKey(keyHash string) (*apikeys.ApiKey, error)
+	UpdateApiKeyLastUsed(apiKeyId string) error
+	ReserveIdempotencyKey(req *middlewares.IdempotencyRecord, expires, lockTimeout time.Duration) (bool, error)
+	FindIdempotencyKey(scope, key string) (*middlewares.IdempotencyRecord, error)
+	SaveIdempotencyResponse(req *middlewares.IdempotencyRecord) error
+	DeleteIdempotencyKey(req *middlewares.IdempotencyRecord) error
+	DeleteExpiredIdempotencyKeys() (int64, error)
 }
 
 type middlewaresRepository struct {
@@ -34,20 +47,188 @@ func (r *middlewaresRepository) FindAccessToken(userId, accessToken string) bool
 	if err := r.db.Get(&check, query, userId, accessToken); err != nil {
 		return false
 	}
-	return true
+	return check
 }
 
-func (r *middlewaresRepository) FindRole() ([]*middlewares.Role, error) {
+func (r *middlewaresRepository) FindRolePermissions(roleId int) ([]string, error) {
 	query := `
 	SELECT
-		"id",
-		"title"
-	FROM "roles"
-	ORDER BY "id" DESC;`
+		"p"."code"
+	FROM "permissions" "p"
+		LEFT JOIN "role_permissions" "rp" ON "rp"."permission_id" = "p"."id"
+	WHERE "rp"."role_id" = $1;`
 
-	roles := make([]*middlewares.Role, 0)
-	if err := r.db.Select(&roles, query); err != nil {
-		return nil, fmt.Errorf("roles are empty")
+	permissions := make([]string, 0)
+	if err := r.db.Select(&permissions, query, roleId); err != nil {
+		return nil, fmt.Errorf("select role permissions failed: %v", err)
 	}
-	return roles, nil
+	return permissions, nil
+}
+
+// หา api key ที่ยังไม่ถูก revoke ยังไม่หมดอายุ และเจ้าของยังไม่ถูกลบ
+func (r *middlewaresRepository) FindActiveApiKey(keyHash string) (*apikeys.ApiKey, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"k"."id",
+			"k"."name",
+			"k"."owner_id",
+			"k"."prefix",
+			"k"."scopes",
+			"k"."tier"
+		FROM "api_keys" "k"
+		WHERE "k"."key_hash" = $1
+		AND "k"."revoked_at" IS NULL
+		AND ("k"."expires_at" IS NULL OR "k"."expires_at" > now())
+		AND NOT EXISTS (
+			SELECT 1
+			FROM "users" "u"
+			WHERE "u"."id" = "k"."owner_id"
+			AND "u"."deleted_at" IS NOT NULL
+		)
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, keyHash); err != nil {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	key := new(apikeys.ApiKey)
+	if err := json.Unmarshal(raw, key); err != nil {
+		return nil, fmt.Errorf("unmarshal api key failed: %v", err)
+	}
+	return key, nil
+}
+
+// update last_used_at ไม่เกินนาทีละครั้ง เพื่อไม่ให้เขียน database ทุก request
+func (r *middlewaresRepository) UpdateApiKeyLastUsed(apiKeyId string) error {
+	query := `
+	UPDATE "api_keys" SET
+		"last_used_at" = now()
+	WHERE "id" = $1
+	AND ("last_used_at" IS NULL OR "last_used_at" < now() - INTERVAL '1 minute');`
+
+	if _, err := r.db.ExecContext(context.Background(), query, apiKeyId); err != nil {
+		return fmt.Errorf("update api key last used failed: %v", err)
+	}
+	return nil
+}
+
+// จอง key ไว้ก่อนเริ่มทำงาน คืน false ถ้ามี request ที่ใช้ key นี้อยู่แล้ว
+// key ที่หมดอายุหรือ request แรกค้างนานเกิน lockTimeout จะถูกจองใหม่ได้
+func (r *middlewaresRepository) ReserveIdempotencyKey(req *middlewares.IdempotencyRecord, expires, lockTimeout time.Duration) (bool, error) {
+	query := `
+	INSERT INTO "idempotency_keys" (
+		"scope",
+		"key",
+		"fingerprint",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+	ON CONFLICT ("scope", "key") DO UPDATE SET
+		"fingerprint" = EXCLUDED."fingerprint",
+		"status_code" = NULL,
+		"content_type" = NULL,
+		"response" = NULL,
+		"created_at" = now(),
+		"expires_at" = EXCLUDED."expires_at"
+	WHERE "idempotency_keys"."expires_at" < now()
+	OR (
+		"idempotency_keys"."status_code" IS NULL
+		AND "idempotency_keys"."created_at" < now() - make_interval(secs => $5)
+	)
+	RETURNING TRUE;`
+
+	var reserved bool
+	if err := r.db.QueryRowxContext(
+		context.Background(),
+		query,
+		req.Scope,
+		req.Key,
+		req.Fingerprint,
+		expires.Seconds(),
+		lockTimeout.Seconds(),
+	).Scan(&reserved); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reserve idempotency key failed: %v", err)
+	}
+	return reserved, nil
+}
+
+func (r *middlewaresRepository) FindIdempotencyKey(scope, key string) (*middlewares.IdempotencyRecord, error) {
+	query := `
+	SELECT
+		"scope",
+		"key",
+		"fingerprint",
+		"status_code",
+		COALESCE("content_type", '') AS "content_type",
+		COALESCE("response", ''::BYTEA) AS "response"
+	FROM "idempotency_keys"
+	WHERE "scope" = $1
+	AND "key" = $2;`
+
+	record := new(middlewares.IdempotencyRecord)
+	if err := r.db.Get(record, query, scope, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("idempotency key not found")
+		}
+		return nil, fmt.Errorf("get idempotency key failed: %v", err)
+	}
+	return record, nil
+}
+
+// บันทึกเฉพาะเมื่อ key ยังเป็นของ request นี้อยู่
+func (r *middlewaresRepository) SaveIdempotencyResponse(req *middlewares.IdempotencyRecord) error {
+	query := `
+	UPDATE "idempotency_keys" SET
+		"status_code" = $4,
+		"content_type" = $5,
+		"response" = $6
+	WHERE "scope" = $1
+	AND "key" = $2
+	AND "fingerprint" = $3
+	AND "status_code" IS NULL;`
+
+	if _, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		req.Scope,
+		req.Key,
+		req.Fingerprint,
+		req.StatusCode,
+		req.ContentType,
+		req.Response,
+	); err != nil {
+		return fmt.Errorf("save idempotency response failed: %v", err)
+	}
+	return nil
+}
+
+// ปล่อย key ที่จองไว้เมื่อ request ล้มเหลว เพื่อให้ส่งซ้ำด้วย key เดิมได้
+func (r *middlewaresRepository) DeleteIdempotencyKey(req *middlewares.IdempotencyRecord) error {
+	query := `
+	DELETE FROM "idempotency_keys"
+	WHERE "scope" = $1
+	AND "key" = $2
+	AND "fingerprint" = $3
+	AND "status_code" IS NULL;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, req.Scope, req.Key, req.Fingerprint); err != nil {
+		return fmt.Errorf("delete idempotency key failed: %v", err)
+	}
+	return nil
+}
+
+func (r *middlewaresRepository) DeleteExpiredIdempotencyKeys() (int64, error) {
+	result, err := r.db.ExecContext(context.Background(), `DELETE FROM "idempotency_keys" WHERE "expires_at" < now();`)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired idempotency keys failed: %v", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
 }
@@ -1,23 +1,40 @@
 package middlewaresUsecases
 
 import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys"
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares"
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares/middlewaresRepositories"
 )
 
+const permissionsCacheTTL = time.Minute * 5
+
 type MiddlewaresUsecaseImpl interface {
 	FindAccessToken(userId, accessToken string) bool
-	FindRole() ([]*middlewares.Role, error)
+	FindRolePermissions(roleId int) (*middlewares.RolePermissions, error)
+	ClearPermissionsCache()
+	FindApiKey(key string) (*apikeys.ApiKey, error)
+	BeginIdempotent(req *middlewares.IdempotencyRecord, expires time.Duration) (*middlewares.IdempotencyRecord, error)
+	FinishIdempotent(req *middlewares.IdempotencyRecord)
+	ReleaseIdempotent(req *middlewares.IdempotencyRecord)
+	RunIdempotencyPurger(interval time.Duration)
 }
 
 type middlewaresUsecases struct {
 	middlewaresRepository middlewaresRepositories.MiddlewaresRepositoryImpl
+	mu                    sync.RWMutex
+	permissionsCache      map[int]*middlewares.RolePermissions
 }
 
 func MiddlewaresUsecase(middlewareRepository middlewaresRepositories.MiddlewaresRepositoryImpl) MiddlewaresUsecaseImpl {
 
 	return &middlewaresUsecases{
 		middlewaresRepository: middlewareRepository,
+		permissionsCache:      make(map[int]*middlewares.RolePermissions),
 	}
 }
 
@@ -25,6 +42,103 @@ func (u *middlewaresUsecases) FindAccessToken(userId, accessToken string) bool {
 	return u.middlewaresRepository.FindAccessToken(userId, accessToken)
 }
 
-func (u *middlewaresUsecases) FindRole() ([]*middlewares.Role, error) {
-	return u.middlewaresRepository.FindRole()
+// หา permission ของ role จาก cache ก่อน ถ้าไม่มีหรือหมดอายุแล้วค่อย query ใหม่
+func (u *middlewaresUsecases) FindRolePermissions(roleId int) (*middlewares.RolePermissions, error) {
+	u.mu.RLock()
+	cached, ok := u.permissionsCache[roleId]
+	u.mu.RUnlock()
+	if ok && !cached.IsExpired() {
+		return cached, nil
+	}
+
+	codes, err := u.middlewaresRepository.FindRolePermissions(roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &middlewares.RolePermissions{
+		RoleId:      roleId,
+		Permissions: make(map[string]bool),
+		ExpiresAt:   time.Now().Add(permissionsCacheTTL),
+	}
+	for _, code := range codes {
+		result.Permissions[code] = true
+	}
+
+	u.mu.Lock()
+	u.permissionsCache[roleId] = result
+	u.mu.Unlock()
+	return result, nil
+}
+
+func (u *middlewaresUsecases) ClearPermissionsCache() {
+	u.mu.Lock()
+	u.permissionsCache = make(map[int]*middlewares.RolePermissions)
+	u.mu.Unlock()
+}
+
+func (u *middlewaresUsecases) FindApiKey(key string) (*apikeys.ApiKey, error) {
+	apiKey, err := u.middlewaresRepository.FindActiveApiKey(apikeys.HashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.middlewaresRepository.UpdateApiKeyLastUsed(apiKey.Id); err != nil {
+		log.Printf("find api key failed: %v\n", err)
+	}
+	return apiKey, nil
+}
+
+// จอง key ให้ request นี้ คืน nil ถ้าจองได้ หรือคืน record ของ request ก่อนหน้าที่ใช้ key เดียวกัน
+func (u *middlewaresUsecases) BeginIdempotent(req *middlewares.IdempotencyRecord, expires time.Duration) (*middlewares.IdempotencyRecord, error) {
+	// record เดิมอาจถูกลบไประหว่างที่หา เพราะ request แรกล้มเหลว จึงลองจองใหม่อีกครั้ง
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := u.middlewaresRepository.ReserveIdempotencyKey(req, expires, middlewares.IdempotencyLockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		existing, err := u.middlewaresRepository.FindIdempotencyKey(req.Scope, req.Key)
+		if err == nil {
+			return existing, nil
+		}
+		if err.Error() != "idempotency key not found" {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("reserve idempotency key failed: key is changing")
+}
+
+func (u *middlewaresUsecases) FinishIdempotent(req *middlewares.IdempotencyRecord) {
+	if err := u.middlewaresRepository.SaveIdempotencyResponse(req); err != nil {
+		log.Printf("finish idempotent request failed: %v\n", err)
+	}
+}
+
+func (u *middlewaresUsecases) ReleaseIdempotent(req *middlewares.IdempotencyRecord) {
+	if err := u.middlewaresRepository.DeleteIdempotencyKey(req); err != nil {
+		log.Printf("release idempotent request failed: %v\n", err)
+	}
+}
+
+func (u *middlewaresUsecases) RunIdempotencyPurger(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := u.middlewaresRepository.DeleteExpiredIdempotencyKeys()
+		if err != nil {
+			log.Printf("purge idempotency keys failed: %v\n", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("purge idempotency keys: deleted %d\n", deleted)
+		}
+	}
 }
@@ -1,30 +1,131 @@
 package orders
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
+)
+
+const (
+	StatusWaiting   = "waiting"
+	StatusShipping  = "shipping"
+	StatusCompleted = "completed"
+	StatusCanceled  = "canceled"
+)
+
+// ผู้ที่ยกเลิก order system คือถูกยกเลิกอัตโนมัติเพราะไม่ได้ชำระเงินตามเวลา
+const (
+	CanceledByCustomer = "customer"
+	CanceledByAdmin    = "admin"
+	CanceledBySystem   = "system"
+)
+
+const (
+	CancelReasonMaxLen = 500
+	// จำนวน order ที่ยกเลิกอัตโนมัติต่อรอบ ที่เหลือจะถูกยกเลิกในรอบถัดไป
+	ExpireBatchSize = 100
 )
 
+// เวลาที่ลูกค้ายกเลิก order เองได้ในแต่ละสถานะ นับจากตอนสั่งซื้อ 0 คือไม่จำกัดเวลา
+// สถานะที่ไม่มีใน policy ลูกค้ายกเลิกเองไม่ได้
+type CancelPolicy map[string]time.Duration
+
+func (p CancelPolicy) Allows(status string, age time.Duration) bool {
+	window, ok := p[status]
+	return ok && (window == 0 || age <= window)
+}
+
 type OrderFilter struct {
-	Search    string `query:"search"` // user_id, address, contact
+	Search    string `query:"search"` // user_id, guest_email, address, contact
 	Status    string `query:"status"`
 	StartDate string `query:"start_date"`
 	EndDate   string `query:"end_date"`
+	// มาจาก query currency handler แปลงเป็นอัตราก่อนส่งให้ usecase
+	Conversion *currencies.Conversion `query:"-"`
 	*entities.PaginationReq
 	*entities.SortReq
 }
 
 type Order struct {
-	Id           string           `db:"id" json:"id"`
-	UserId       string           `db:"user_id" json:"user_id"`
-	TransferSlip *TransferSlip    `db:"transfer_slip" json:"transfer_slip"`
-	Products     []*ProductsOrder `json:"products"`
-	Address      string           `db:"address" json:"address"`
-	Contact      string           `db:"contact" json:"contact"`
-	Status       string           `db:"status" json:"status"`
-	TotalPaid    float64          `db:"total_paid" json:"total_paid"`
-	CreatedAt    string           `db:"created_at" json:"created_at"`
-	UpdatedAt    string           `db:"updated_at" json:"updated_at"`
+	Id     string `db:"id" json:"id"`
+	UserId string `db:"user_id" json:"user_id"`
+	// order ที่สั่งโดยไม่ได้สมัครสมาชิก user_id ว่างจนกว่า email นี้จะสมัครสมาชิก
+	GuestEmail      string           `db:"guest_email" json:"guest_email,omitempty"`
+	LookupTokenHash string           `db:"lookup_token_hash" json:"-"`
+	TransferSlip    *TransferSlip    `db:"transfer_slip" json:"transfer_slip"`
+	Products        []*ProductsOrder `json:"products"`
+	Address         string           `db:"address" json:"address"`
+	Contact         string           `db:"contact" json:"contact"`
+	Status          string           `db:"status" json:"status"`
+	TotalPaid       money.Amount     `db:"total_paid" json:"total_paid"`
+	// สกุลเงินที่ลูกค้าเลือกและอัตราตอนสั่งซื้อ ยอดที่ชำระยังเป็นสกุลเงินหลัก
+	Currency     string        `db:"currency" json:"currency"`
+	ExchangeRate float64       `db:"exchange_rate" json:"exchange_rate"`
+	Display      *OrderDisplay `json:"display,omitempty"`
+	// เหตุผลที่ลูกค้าหรือ admin ส่งมาตอนยกเลิก ถูกลบเมื่อ order กลับมาใช้อีกครั้ง
+	CancelReason string `db:"cancel_reason" json:"cancel_reason,omitempty"`
+	CanceledBy   string `db:"canceled_by" json:"canceled_by,omitempty"`
+	CanceledAt   string `db:"canceled_at" json:"canceled_at,omitempty"`
+	// กำหนดโดย usecase เมื่อลูกค้ายกเลิกเอง repository ตรวจกับสถานะที่ lock ไว้
+	CancelPolicy CancelPolicy `db:"-" json:"-"`
+	CreatedAt    string       `db:"created_at" json:"created_at"`
+	UpdatedAt    string       `db:"updated_at" json:"updated_at"`
+}
+
+// order ที่ยังไม่แนบ slip เกินเวลา email ว่างคือไม่มีที่อยู่สำหรับแจ้งลูกค้า
+type UnpaidOrder struct {
+	Id    string `db:"id"`
+	Email string `db:"email"`
+}
+
+// token สำหรับดู order ของลูกค้าที่ไม่ได้สมัครสมาชิก ส่งให้ลูกค้าทาง email เท่านั้น
+func GenerateLookupToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate lookup token failed: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// เก็บเฉพาะ hash ของ token ลง database
+func HashLookupToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type OrderDisplay struct {
+	Currency  string        `json:"currency"`
+	Symbol    string        `json:"symbol"`
+	Rate      float64       `json:"rate"`
+	TotalPaid money.Display `json:"total_paid"`
+}
+
+// แสดงยอดในสกุลเงินที่ขอ ถ้าเป็นสกุลเงินเดียวกับตอนสั่งซื้อใช้อัตราที่บันทึกไว้ เพื่อให้ยอดตรงกับที่ลูกค้าเห็นตอนสั่ง
+func (o *Order) SetDisplay(c *currencies.Conversion) {
+	if c == nil || c.Base {
+		return
+	}
+	if c.Currency == o.Currency && o.ExchangeRate > 0 {
+		c = c.WithRate(o.ExchangeRate)
+	}
+	o.Display = &OrderDisplay{
+		Currency:  c.Currency,
+		Symbol:    c.Symbol,
+		Rate:      c.Rate,
+		TotalPaid: c.Convert(o.TotalPaid),
+	}
+	for _, item := range o.Products {
+		if item.Product != nil {
+			item.Product.SetDisplay(c)
+		}
+	}
 }
 
 type TransferSlip struct {
@@ -39,3 +140,28 @@ type ProductsOrder struct {
 	Qty     int               `db:"qty" json:"qty"`
 	Product *products.Product `db:"product" json:"product"`
 }
+
+// สิ่งที่ลูกค้าได้จากรายการสินค้าดิจิทัล เห็นได้เมื่อ order เสร็จสิ้นแล้วเท่านั้น
+type Delivery struct {
+	ProductsOrderId string    `json:"products_order_id"`
+	ProductId       string    `json:"product_id"`
+	Title           string    `json:"title"`
+	LicenseKeys     []string  `json:"license_keys"`
+	Download        *Download `json:"download"`
+}
+
+// download_limit เป็น 0 คือไม่จำกัด
+type Download struct {
+	Id               string  `db:"id" json:"id"`
+	OrderId          string  `db:"order_id" json:"-"`
+	UserId           string  `db:"user_id" json:"-"`
+	OrderStatus      string  `db:"order_status" json:"-"`
+	FileName         string  `db:"filename" json:"filename"`
+	DownloadLimit    int     `db:"download_limit" json:"download_limit"`
+	DownloadCount    int     `db:"download_count" json:"download_count"`
+	LastDownloadedAt *string `db:"last_downloaded_at" json:"last_downloaded_at"`
+}
+
+func (d *Download) Exhausted() bool {
+	return d.DownloadLimit > 0 && d.DownloadCount >= d.DownloadLimit
+}
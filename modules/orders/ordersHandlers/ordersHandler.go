@@ -1,13 +1,18 @@
 package ordersHandlers
 
 import (
+	"errors"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/orders"
 	"github.com/Doittikorn/go-e-commerce/modules/orders/ordersUsecases"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
@@ -19,30 +24,63 @@ const (
 	findOrderErr    ordersHandlersErrCode = "orders-002"
 	insertOrderErr  ordersHandlersErrCode = "orders-003"
 	updateOrderErr  ordersHandlersErrCode = "orders-004"
+	findDeliveryErr ordersHandlersErrCode = "orders-005"
+	signDownloadErr ordersHandlersErrCode = "orders-006"
+	downloadErr     ordersHandlersErrCode = "orders-007"
+	insertGuestErr  ordersHandlersErrCode = "orders-008"
+	findGuestErr    ordersHandlersErrCode = "orders-009"
+	claimGuestErr   ordersHandlersErrCode = "orders-010"
+	guestSlipErr    ordersHandlersErrCode = "orders-011"
 )
 
 type IOrdersHandler interface {
 	FindOneOrder(c *fiber.Ctx) error
 	FindOrder(c *fiber.Ctx) error
 	InsertOrder(c *fiber.Ctx) error
+	InsertGuestOrder(c *fiber.Ctx) error
+	FindGuestOrder(c *fiber.Ctx) error
+	ClaimGuestOrder(c *fiber.Ctx) error
+	AttachGuestTransferSlip(c *fiber.Ctx) error
 	UpdateOrder(c *fiber.Ctx) error
+	FindDeliveries(c *fiber.Ctx) error
+	SignDownload(c *fiber.Ctx) error
+	StreamDownload(c *fiber.Ctx) error
 }
 
 type ordersHandler struct {
-	cfg           config.ConfigImpl
-	ordersUsecase ordersUsecases.IOrdersUsecase
+	cfg               config.ConfigImpl
+	ordersUsecase     ordersUsecases.IOrdersUsecase
+	currenciesUsecase currenciesUsecases.ICurrenciesUsecase
 }
 
-func OrdersHandler(cfg config.ConfigImpl, ordersUsecase ordersUsecases.IOrdersUsecase) IOrdersHandler {
+func OrdersHandler(cfg config.ConfigImpl, ordersUsecase ordersUsecases.IOrdersUsecase, currenciesUsecase currenciesUsecases.ICurrenciesUsecase) IOrdersHandler {
 	return &ordersHandler{
-		cfg:           cfg,
-		ordersUsecase: ordersUsecase,
+		cfg:               cfg,
+		ordersUsecase:     ordersUsecase,
+		currenciesUsecase: currenciesUsecase,
 	}
 }
 
+// อัตราของสกุลเงินจาก query currency ถ้าไม่ระบุจะไม่แปลงยอด
+func (h *ordersHandler) conversion(c *fiber.Ctx) (*currencies.Conversion, error) {
+	if c.Query("currency") == "" {
+		return nil, nil
+	}
+	return h.currenciesUsecase.Conversion(c.Query("currency"))
+}
+
 func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
 	orderId := strings.Trim(c.Params("order_id"), " ")
 
+	conversion, err := h.conversion(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(findOneOrderErr),
+			err.Error(),
+		).Res()
+	}
+
 	order, err := h.ordersUsecase.FindOneOrder(orderId)
 	if err != nil {
 		return entities.NewResponse(c).Error(
@@ -51,6 +89,7 @@ func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
+	order.SetDisplay(conversion)
 
 	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
 }
@@ -118,12 +157,42 @@ func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
 		req.EndDate = end.Format("2006-01-02")
 	}
 
+	conversion, err := h.conversion(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(findOrderErr),
+			err.Error(),
+		).Res()
+	}
+	req.Conversion = conversion
+
 	return entities.NewResponse(c).Success(
 		fiber.StatusOK,
 		h.ordersUsecase.FindOrder(req),
 	).Res()
 }
 
+// stock ไม่พอเป็น conflict เพราะขึ้นกับ order อื่นที่สั่งพร้อมกัน
+func orderErrorStatus(err error) int {
+	switch {
+	case err.Error() == "qty must be more than 0", err.Error() == "invalid email", currencies.IsConversionError(err):
+		return fiber.ErrBadRequest.Code
+	case strings.HasPrefix(err.Error(), "cancel reason must not be longer than "):
+		return fiber.ErrBadRequest.Code
+	case err.Error() == "order not found", err.Error() == "download not found":
+		return fiber.ErrNotFound.Code
+	case err.Error() == "order is not completed", err.Error() == "order cannot be canceled":
+		return fiber.ErrConflict.Code
+	case err.Error() == "download limit reached", err.Error() == "download is not available":
+		return fiber.ErrForbidden.Code
+	case strings.HasSuffix(err.Error(), " is out of stock"):
+		return fiber.ErrConflict.Code
+	default:
+		return fiber.ErrInternalServerError.Code
+	}
+}
+
 func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 	userId := c.Locals("userId").(string)
 
@@ -147,6 +216,7 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 	if c.Locals("userRoleId").(int) != 2 {
 		req.UserId = userId
 	}
+	req.GuestEmail = ""
 
 	req.Status = "waiting"
 	req.TotalPaid = 0
@@ -154,7 +224,7 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 	order, err := h.ordersUsecase.InsertOrder(req)
 	if err != nil {
 		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
+			orderErrorStatus(err),
 			string(insertOrderErr),
 			err.Error(),
 		).Res()
@@ -162,6 +232,138 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
 }
 
+func (h *ordersHandler) InsertGuestOrder(c *fiber.Ctx) error {
+	req := &orders.Order{
+		Products: make([]*orders.ProductsOrder, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestErr),
+			err.Error(),
+		).Res()
+	}
+	if len(req.Products) == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertGuestErr),
+			"products are empty",
+		).Res()
+	}
+
+	req.Status = "waiting"
+	req.TotalPaid = 0
+	req.TransferSlip = nil
+
+	order, err := h.ordersUsecase.InsertGuestOrder(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(insertGuestErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
+func (h *ordersHandler) FindGuestOrder(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	conversion, err := h.conversion(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(findGuestErr),
+			err.Error(),
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.FindGuestOrder(orderId, c.Query("token"))
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(findGuestErr),
+			err.Error(),
+		).Res()
+	}
+	order.SetDisplay(conversion)
+
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+func (h *ordersHandler) ClaimGuestOrder(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	order, err := h.ordersUsecase.ClaimGuestOrder(userId, orderId, c.Query("token"))
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(claimGuestErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
+}
+
+func prepareTransferSlip(slip *orders.TransferSlip) error {
+	if slip == nil {
+		return nil
+	}
+	if slip.Id == "" {
+		slip.Id = uuid.NewString()
+	}
+	if slip.CreatedAt == "" {
+		loc, err := time.LoadLocation("Asia/Bangkok")
+		if err != nil {
+			return err
+		}
+		now := time.Now().In(loc)
+
+		// YYYY-MM-DD HH:MM:SS
+		// 2006-01-02 15:04:05
+		slip.CreatedAt = now.Format("2006-01-02 15:04:05")
+	}
+	return nil
+}
+
+// ลูกค้าที่ไม่ได้สมัครสมาชิกแนบ slip ได้ด้วย token เดียวกับที่ใช้ดู order แก้สถานะไม่ได้
+func (h *ordersHandler) AttachGuestTransferSlip(c *fiber.Ctx) error {
+	orderId := strings.Trim(c.Params("order_id"), " ")
+	req := new(orders.Order)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(guestSlipErr),
+			err.Error(),
+		).Res()
+	}
+	if req.TransferSlip == nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(guestSlipErr),
+			"transfer_slip is required",
+		).Res()
+	}
+	if err := prepareTransferSlip(req.TransferSlip); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(guestSlipErr),
+			err.Error(),
+		).Res()
+	}
+
+	order, err := h.ordersUsecase.AttachGuestTransferSlip(orderId, c.Query("token"), req.TransferSlip)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(guestSlipErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
+
 func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 	orderId := strings.Trim(c.Params("order_id"), " ")
 	req := new(orders.Order)
@@ -175,45 +377,101 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 	req.Id = orderId
 
 	statusMap := map[string]string{
-		"waiting":   "waiting",
-		"shipping":  "shipping",
-		"completed": "completed",
-		"canceled":  "canceled",
+		"waiting":   orders.StatusWaiting,
+		"shipping":  orders.StatusShipping,
+		"completed": orders.StatusCompleted,
+		"canceled":  orders.StatusCanceled,
 	}
+	// ลูกค้าเปลี่ยนสถานะได้แค่ยกเลิก usecase ตรวจเวลาที่ยกเลิกได้จาก CanceledBy
+	// และแก้ได้เฉพาะ order ของตัวเอง ส่วน admin แก้ได้ทุก order
+	req.CanceledBy = orders.CanceledByCustomer
+	req.UserId = c.Locals("userId").(string)
 	if c.Locals("userRoleId").(int) == 2 {
 		req.Status = statusMap[strings.ToLower(req.Status)]
+		req.CanceledBy = orders.CanceledByAdmin
+		req.UserId = ""
 	} else if strings.ToLower(req.Status) == statusMap["canceled"] {
 		req.Status = statusMap["canceled"]
+	} else {
+		req.Status = ""
 	}
 
-	if req.TransferSlip != nil {
-		if req.TransferSlip.Id == "" {
-			req.TransferSlip.Id = uuid.NewString()
-		}
-		if req.TransferSlip.CreatedAt == "" {
-			loc, err := time.LoadLocation("Asia/Bangkok")
-			if err != nil {
-				return entities.NewResponse(c).Error(
-					fiber.ErrInternalServerError.Code,
-					string(updateOrderErr),
-					err.Error(),
-				).Res()
-			}
-			now := time.Now().In(loc)
-
-			// YYYY-MM-DD HH:MM:SS
-			// 2006-01-02 15:04:05
-			req.TransferSlip.CreatedAt = now.Format("2006-01-02 15:04:05")
-		}
+	if err := prepareTransferSlip(req.TransferSlip); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(updateOrderErr),
+			err.Error(),
+		).Res()
 	}
 
 	order, err := h.ordersUsecase.UpdateOrder(req)
 	if err != nil {
 		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
+			orderErrorStatus(err),
 			string(updateOrderErr),
 			err.Error(),
 		).Res()
 	}
 	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
 }
+
+func (h *ordersHandler) FindDeliveries(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	deliveries, err := h.ordersUsecase.FindDeliveries(userId, orderId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(findDeliveryErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, deliveries).Res()
+}
+
+func (h *ordersHandler) SignDownload(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+	downloadId := strings.Trim(c.Params("download_id"), " ")
+
+	link, err := h.ordersUsecase.SignDownload(userId, orderId, downloadId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			orderErrorStatus(err),
+			string(signDownloadErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, link).Res()
+}
+
+// ส่งไฟล์ตามลิงก์ที่ sign ไว้ ทุกครั้งที่เปิดลิงก์ได้จะถูกนับเป็นการดาวน์โหลดหนึ่งครั้ง
+func (h *ordersHandler) StreamDownload(c *fiber.Ctx) error {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(downloadErr),
+			storage.ErrSignatureInvalid.Error(),
+		).Res()
+	}
+
+	r, file, err := h.ordersUsecase.OpenDownload(strings.Trim(c.Params("download_id"), " "), expires, c.Query("signature"))
+	if err != nil {
+		code := orderErrorStatus(err)
+		if errors.Is(err, storage.ErrSignatureInvalid) || errors.Is(err, storage.ErrUrlExpired) {
+			code = fiber.ErrForbidden.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(downloadErr),
+			err.Error(),
+		).Res()
+	}
+
+	c.Set(fiber.HeaderContentType, file.ContentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+strings.ReplaceAll(file.FileName, `"`, "")+`"`)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.SendStream(r, int(file.Size))
+}
@@ -0,0 +1,62 @@
+package ordersPatterns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/jmoiron/sqlx"
+)
+
+// คืน stock และ license key ของ order ที่เพิ่งถูกยกเลิก พร้อมบันทึกว่าใครยกเลิกและเพราะอะไร
+// ต้องเรียกใน transaction เดียวกับที่เปลี่ยนสถานะ และ lock order ไว้แล้ว
+func CancelOrder(ctx context.Context, tx *sqlx.Tx, req *orders.Order) error {
+	items, err := FindProductsOrder(ctx, tx, req.Id)
+	if err != nil {
+		return err
+	}
+	if err := AdjustStock(ctx, tx, StockQuantities(items), 1); err != nil {
+		return err
+	}
+	if err := ReleaseLicenseKeys(ctx, tx, req.Id); err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "orders" SET
+		"cancel_reason" = NULLIF($2, ''),
+		"canceled_by" = NULLIF($3, ''),
+		"canceled_at" = now()
+	WHERE "id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, req.Id, req.CancelReason, req.CanceledBy); err != nil {
+		return fmt.Errorf("update cancellation of order failed: %v", err)
+	}
+	return nil
+}
+
+// order ที่ยกเลิกแล้วกลับมาใช้อีกครั้ง ต้องตัด stock และจ่าย license key ใหม่
+func RestoreOrder(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	items, err := FindProductsOrder(ctx, tx, orderId)
+	if err != nil {
+		return err
+	}
+	if err := AdjustStock(ctx, tx, StockQuantities(items), -1); err != nil {
+		return err
+	}
+	if err := AssignLicenseKeys(ctx, tx, items); err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "orders" SET
+		"cancel_reason" = NULL,
+		"canceled_by" = NULL,
+		"canceled_at" = NULL
+	WHERE "id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, orderId); err != nil {
+		return fmt.Errorf("clear cancellation of order failed: %v", err)
+	}
+	return nil
+}
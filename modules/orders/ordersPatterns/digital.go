@@ -0,0 +1,120 @@
+package ordersPatterns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/jmoiron/sqlx"
+)
+
+// อ่านรายการสินค้าของ order พร้อม id ของแต่ละรายการภายใน transaction
+func FindProductsOrder(ctx context.Context, tx *sqlx.Tx, orderId string) ([]*orders.ProductsOrder, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("pt")), '[]'::json)
+	FROM (
+		SELECT
+			"po"."id",
+			"po"."qty",
+			"po"."product"
+		FROM "products_orders" "po"
+		WHERE "po"."order_id" = $1
+	) AS "pt";`
+
+	raw := make([]byte, 0)
+	if err := tx.GetContext(ctx, &raw, query, orderId); err != nil {
+		return nil, fmt.Errorf("get products order failed: %v", err)
+	}
+
+	items := make([]*orders.ProductsOrder, 0)
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("unmarshal products order failed: %v", err)
+	}
+	return items, nil
+}
+
+func isDigital(item *orders.ProductsOrder) bool {
+	return item.Product != nil && item.Product.Type == products.TypeDigital
+}
+
+// จ่าย key ที่ว่างให้แต่ละรายการตาม qty SKIP LOCKED ทำให้ order ที่สั่งพร้อมกันไม่ได้ key ซ้ำกันและไม่ต้องรอกัน
+// ถ้า key ไม่พอถือว่าสินค้าหมด
+func AssignLicenseKeys(ctx context.Context, tx *sqlx.Tx, items []*orders.ProductsOrder) error {
+	query := `
+	UPDATE "product_license_keys" SET
+		"products_order_id" = $2,
+		"assigned_at" = now()
+	WHERE "id" IN (
+		SELECT
+			"id"
+		FROM "product_license_keys"
+		WHERE "product_id" = $1
+		AND "products_order_id" IS NULL
+		ORDER BY "created_at", "id"
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	);`
+
+	for _, item := range items {
+		if !isDigital(item) || item.Product.UsesLicenseKeys == nil || !*item.Product.UsesLicenseKeys {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, query, item.Product.Id, item.Id, item.Qty)
+		if err != nil {
+			return fmt.Errorf("assign license keys failed: %v", err)
+		}
+		if rows, _ := result.RowsAffected(); int(rows) < item.Qty {
+			return fmt.Errorf("product %s is out of stock", item.Product.Id)
+		}
+	}
+	return nil
+}
+
+// คืน key ของ order ที่ถูกยกเลิกกลับเข้า pool
+func ReleaseLicenseKeys(ctx context.Context, tx *sqlx.Tx, orderId string) error {
+	query := `
+	UPDATE "product_license_keys" SET
+		"products_order_id" = NULL,
+		"assigned_at" = NULL
+	WHERE "products_order_id" IN (
+		SELECT
+			"id"
+		FROM "products_orders"
+		WHERE "order_id" = $1
+	);`
+
+	if _, err := tx.ExecContext(ctx, query, orderId); err != nil {
+		return fmt.Errorf("release license keys failed: %v", err)
+	}
+	return nil
+}
+
+// สร้างสิทธิ์ดาวน์โหลดตาม snapshot ของสินค้า ใช้ได้เมื่อ order เสร็จสิ้นแล้วเท่านั้น
+func InsertDownloads(ctx context.Context, tx *sqlx.Tx, orderId string, items []*orders.ProductsOrder) error {
+	query := `
+	INSERT INTO "order_downloads" (
+		"products_order_id",
+		"order_id",
+		"file_id",
+		"download_limit"
+	)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT ("products_order_id") DO NOTHING;`
+
+	for _, item := range items {
+		if !isDigital(item) || item.Product.DigitalFileId == nil {
+			continue
+		}
+		limit := products.DefaultDownloadLimit
+		if item.Product.DownloadLimit != nil {
+			limit = *item.Product.DownloadLimit
+		}
+		if _, err := tx.ExecContext(ctx, query, item.Id, orderId, *item.Product.DigitalFileId, limit); err != nil {
+			return fmt.Errorf("insert downloads failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -62,8 +62,11 @@ func (b *findOrderBuilder) initQuery() {
 		SELECT
 			"o"."id",
 			"o"."user_id",
+			"o"."guest_email",
 			"o"."transfer_slip",
 			"o"."status",
+			"o"."currency",
+			"o"."exchange_rate",
 			(
 				SELECT
 					array_to_json(array_agg("pt"))
@@ -80,10 +83,13 @@ func (b *findOrderBuilder) initQuery() {
 			"o"."contact",
 			(
 				SELECT
-					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
+					SUM(COALESCE(COALESCE("po"."product"->>'effective_price', "po"."product"->>'price')::NUMERIC*"po"."qty", 0))
 				FROM "products_orders" "po"
 				WHERE "po"."order_id" = "o"."id"
 			) AS "total_paid",
+			"o"."cancel_reason",
+			"o"."canceled_by",
+			"o"."canceled_at",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -105,17 +111,20 @@ func (b *findOrderBuilder) buildWhereSearch() {
 			"%"+strings.ToLower(b.req.Search)+"%",
 			"%"+strings.ToLower(b.req.Search)+"%",
 			"%"+strings.ToLower(b.req.Search)+"%",
+			"%"+strings.ToLower(b.req.Search)+"%",
 		)
 
 		query := fmt.Sprintf(`
 		AND (
 			LOWER("o"."user_id") LIKE $%d OR
+			LOWER("o"."guest_email") LIKE $%d OR
 			LOWER("o"."address") LIKE $%d OR
 			LOWER("o"."contact") LIKE $%d
 		)`,
 			b.lastIndex+1,
 			b.lastIndex+2,
 			b.lastIndex+3,
+			b.lastIndex+4,
 		)
 		temp := b.getQuery()
 		temp += query
@@ -13,6 +13,8 @@ type IInsertOrderBuilder interface {
 	initTransaction() error
 	insertOrder() error
 	insertProductsOrder() error
+	reserveStock() error
+	deliverDigital() error
 	getOrderId() string
 	commit() error
 }
@@ -48,10 +50,14 @@ func (b *insertOrderBuilder) insertOrder() error {
 		"contact",
 		"address",
 		"transfer_slip",
-		"status"
+		"status",
+		"currency",
+		"exchange_rate",
+		"guest_email",
+		"lookup_token_hash"
 	)
 	VALUES
-	($1, $2, $3, $4, $5)
+	(NULLIF($1, ''), $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''))
 		RETURNING "id";`
 
 	if err := b.tx.QueryRowxContext(
@@ -62,6 +68,10 @@ func (b *insertOrderBuilder) insertOrder() error {
 		b.req.Address,
 		b.req.TransferSlip,
 		b.req.Status,
+		b.req.Currency,
+		b.req.ExchangeRate,
+		b.req.GuestEmail,
+		b.req.LookupTokenHash,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert order failed: %v", err)
@@ -107,6 +117,36 @@ func (b *insertOrderBuilder) insertProductsOrder() error {
 	}
 	return nil
 }
+func (b *insertOrderBuilder) reserveStock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := AdjustStock(ctx, b.tx, StockQuantities(b.req.Products), -1); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
+func (b *insertOrderBuilder) deliverDigital() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// ต้องใช้ id ของแต่ละรายการที่เพิ่งบันทึก
+	items, err := FindProductsOrder(ctx, b.tx, b.req.Id)
+	if err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	if err := AssignLicenseKeys(ctx, b.tx, items); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	if err := InsertDownloads(ctx, b.tx, b.req.Id, items); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
 func (b *insertOrderBuilder) commit() error {
 	if err := b.tx.Commit(); err != nil {
 		return err
@@ -135,6 +175,12 @@ func (en *insertOrderEngineer) InsertOrder() (string, error) {
 	if err := en.builder.insertProductsOrder(); err != nil {
 		return "", err
 	}
+	if err := en.builder.reserveStock(); err != nil {
+		return "", err
+	}
+	if err := en.builder.deliverDigital(); err != nil {
+		return "", err
+	}
 	if err := en.builder.commit(); err != nil {
 		return "", err
 	}
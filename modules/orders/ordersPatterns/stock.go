@@ -0,0 +1,56 @@
+package ordersPatterns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/jmoiron/sqlx"
+)
+
+// จำนวนที่ต้องตัดหรือคืน stock ต่อสินค้า สินค้าชุดคิดจากสินค้าในชุดตาม snapshot
+func StockQuantities(items []*orders.ProductsOrder) map[string]int {
+	quantities := make(map[string]int)
+	for _, item := range items {
+		if item.Product == nil {
+			continue
+		}
+		if item.Product.Type != products.TypeBundle {
+			quantities[item.Product.Id] += item.Qty
+			continue
+		}
+		for _, component := range item.Product.Components {
+			quantities[component.ProductId] += component.Qty * item.Qty
+		}
+	}
+	return quantities
+}
+
+// sign เป็น -1 คือตัด stock และ 1 คือคืน stock สินค้าที่ไม่นับ stock จะไม่ถูกแก้
+// เรียงตาม id เพื่อไม่ให้ order ที่ทำพร้อมกัน lock สินค้าสลับลำดับกัน
+func AdjustStock(ctx context.Context, tx *sqlx.Tx, quantities map[string]int, sign int) error {
+	ids := make([]string, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	query := `
+	UPDATE "products" SET
+		"stock" = "stock" + $2
+	WHERE "id" = $1
+	AND "stock" IS NOT NULL;`
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, query, id, sign*quantities[id]); err != nil {
+			if strings.Contains(err.Error(), "products_stock_check") {
+				return fmt.Errorf("product %s is out of stock", id)
+			}
+			return fmt.Errorf("update stock failed: %v", err)
+		}
+	}
+	return nil
+}
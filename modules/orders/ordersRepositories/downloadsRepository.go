@@ -0,0 +1,111 @@
+package ordersRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+)
+
+func (r *ordersRepository) FindDeliveries(orderId string) ([]*orders.Delivery, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t" ORDER BY "t"."title", "t"."products_order_id")), '[]'::json)
+	FROM (
+		SELECT
+			"po"."id" AS "products_order_id",
+			"po"."product"->>'id' AS "product_id",
+			"po"."product"->>'title' AS "title",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("k"."key" ORDER BY "k"."assigned_at", "k"."key")), '[]'::json)
+				FROM "product_license_keys" "k"
+				WHERE "k"."products_order_id" = "po"."id"
+			) AS "license_keys",
+			(
+				SELECT
+					to_jsonb("dt")
+				FROM (
+					SELECT
+						"d"."id",
+						"f"."filename",
+						"d"."download_limit",
+						"d"."download_count",
+						"d"."last_downloaded_at"
+					FROM "order_downloads" "d"
+						JOIN "files" "f" ON "f"."id" = "d"."file_id"
+					WHERE "d"."products_order_id" = "po"."id"
+				) AS "dt"
+			) AS "download"
+		FROM "products_orders" "po"
+		WHERE "po"."order_id" = $1
+		AND "po"."product"->>'type' = 'digital'
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, orderId); err != nil {
+		return nil, fmt.Errorf("get deliveries failed: %v", err)
+	}
+
+	deliveries := make([]*orders.Delivery, 0)
+	if err := json.Unmarshal(raw, &deliveries); err != nil {
+		return nil, fmt.Errorf("unmarshal deliveries failed: %v", err)
+	}
+	return deliveries, nil
+}
+
+func (r *ordersRepository) FindOneDownload(downloadId string) (*orders.Download, error) {
+	query := `
+	SELECT
+		"d"."id",
+		"d"."order_id",
+		COALESCE("o"."user_id", '') AS "user_id",
+		"o"."status" AS "order_status",
+		"f"."filename",
+		"d"."download_limit",
+		"d"."download_count",
+		"d"."last_downloaded_at"
+	FROM "order_downloads" "d"
+		JOIN "orders" "o" ON "o"."id" = "d"."order_id"
+		JOIN "files" "f" ON "f"."id" = "d"."file_id"
+	WHERE "d"."id"::text = $1;`
+
+	download := new(orders.Download)
+	if err := r.db.Get(download, query, downloadId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("download not found")
+		}
+		return nil, fmt.Errorf("get download failed: %v", err)
+	}
+	return download, nil
+}
+
+// นับการดาวน์โหลดในคำสั่งเดียวกับที่ตรวจสิทธิ์ เพื่อไม่ให้โหลดพร้อมกันหลายครั้งแล้วเกิน limit คืน id ของไฟล์
+func (r *ordersRepository) ConsumeDownload(downloadId string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	UPDATE "order_downloads" "d" SET
+		"download_count" = "d"."download_count" + 1,
+		"last_downloaded_at" = now()
+	FROM "orders" "o"
+	WHERE "o"."id" = "d"."order_id"
+	AND "d"."id"::text = $1
+	AND "o"."status" = 'completed'
+	AND ("d"."download_limit" = 0 OR "d"."download_count" < "d"."download_limit")
+	RETURNING "d"."file_id";`
+
+	var fileId string
+	if err := r.db.QueryRowxContext(ctx, query, downloadId).Scan(&fileId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("download is not available")
+		}
+		return "", fmt.Errorf("update download failed: %v", err)
+	}
+	return fileId, nil
+}
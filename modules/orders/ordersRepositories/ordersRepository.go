@@ -2,9 +2,12 @@ package ordersRepositories
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Doittikorn/go-e-commerce/modules/orders"
 	"github.com/Doittikorn/go-e-commerce/modules/orders/ordersPatterns"
@@ -16,6 +19,13 @@ type IOrdersRepository interface {
 	FindOrder(req *orders.OrderFilter) ([]*orders.Order, int)
 	InsertOrder(req *orders.Order) (string, error)
 	UpdateOrder(req *orders.Order) error
+	FindUnpaidOrders(olderThan time.Duration) ([]*orders.UnpaidOrder, error)
+	ExpireOrder(orderId string, olderThan time.Duration) (bool, error)
+	FindLookupTokenHash(orderId string) (string, error)
+	ClaimGuestOrder(orderId, userId string) error
+	FindDeliveries(orderId string) ([]*orders.Delivery, error)
+	FindOneDownload(downloadId string) (*orders.Download, error)
+	ConsumeDownload(downloadId string) (string, error)
 }
 
 type ordersRepository struct {
@@ -34,8 +44,11 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 		SELECT
 			"o"."id",
 			"o"."user_id",
+			"o"."guest_email",
 			"o"."transfer_slip",
 			"o"."status",
+			"o"."currency",
+			"o"."exchange_rate",
 			(
 				SELECT
 					array_to_json(array_agg("pt"))
@@ -52,10 +65,13 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 			"o"."contact",
 			(
 				SELECT
-					SUM(COALESCE(("po"."product"->>'price')::FLOAT*("po"."qty")::FLOAT, 0))
+					SUM(COALESCE(COALESCE("po"."product"->>'effective_price', "po"."product"->>'price')::NUMERIC*"po"."qty", 0))
 				FROM "products_orders" "po"
 				WHERE "po"."order_id" = "o"."id"
 			) AS "total_paid",
+			"o"."cancel_reason",
+			"o"."canceled_by",
+			"o"."canceled_at",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -77,6 +93,37 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 	return orderData, nil
 }
 
+// order ของสมาชิกไม่มี token จึงได้ค่าว่าง
+func (r *ordersRepository) FindLookupTokenHash(orderId string) (string, error) {
+	var hash string
+	if err := r.db.Get(&hash, `SELECT COALESCE("lookup_token_hash", '') FROM "orders" WHERE "id" = $1;`, orderId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("order not found")
+		}
+		return "", fmt.Errorf("get order failed: %v", err)
+	}
+	return hash, nil
+}
+
+// ย้าย order ที่ยังไม่มีเจ้าของเข้าบัญชี และลบ token เพื่อให้ดู order ได้จากบัญชีเท่านั้น
+func (r *ordersRepository) ClaimGuestOrder(orderId, userId string) error {
+	query := `
+	UPDATE "orders" SET
+		"user_id" = $2,
+		"lookup_token_hash" = NULL
+	WHERE "id" = $1
+	AND "user_id" IS NULL;`
+
+	result, err := r.db.ExecContext(context.Background(), query, orderId, userId)
+	if err != nil {
+		return fmt.Errorf("claim guest order failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("order not found")
+	}
+	return nil
+}
+
 func (r *ordersRepository) FindOrder(req *orders.OrderFilter) ([]*orders.Order, int) {
 	builder := ordersPatterns.FindOrderBuilder(r.db, req)
 	engineer := ordersPatterns.FindOrderEngineer(builder)
@@ -132,8 +179,129 @@ func (r *ordersRepository) UpdateOrder(req *orders.Order) error {
 	}
 	query += queryClose
 
-	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// lock order ไว้เพื่อไม่ให้ตัดหรือคืน stock ซ้ำเมื่อแก้สถานะพร้อมกัน
+	// อายุของ order คำนวณใน database เพื่อไม่ให้ขึ้นกับ timezone ของ server
+	// user_id ว่างคือไม่ต้องตรวจเจ้าของ (admin หรือลูกค้าที่ยืนยันด้วย token แล้ว)
+	old := struct {
+		Status string `db:"status"`
+		Age    int64  `db:"age"`
+	}{}
+	queryLock := `
+	SELECT
+		"status",
+		EXTRACT(EPOCH FROM now() - "created_at")::BIGINT AS "age"
+	FROM "orders"
+	WHERE "id" = $1
+	AND ($2 = '' OR "user_id" = $2)
+	FOR UPDATE;`
+
+	if err := tx.GetContext(ctx, &old, queryLock, req.Id, req.UserId); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("update order failed: %v", err)
+	}
+
+	// ยกเลิก order ที่ยกเลิกไปแล้วซ้ำไม่ต้องตรวจ
+	if req.CancelPolicy != nil && old.Status != orders.StatusCanceled && !req.CancelPolicy.Allows(old.Status, time.Duration(old.Age)*time.Second) {
+		tx.Rollback()
+		return fmt.Errorf("order cannot be canceled")
+	}
+
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		tx.Rollback()
 		return fmt.Errorf("update order failed: %v", err)
 	}
+
+	// ยกเลิกแล้วคืน stock ถ้ากลับมาใช้ order อีกครั้งต้องตัด stock ใหม่ license key ก็เช่นกัน
+	switch {
+	case req.Status == orders.StatusCanceled && old.Status != orders.StatusCanceled:
+		err = ordersPatterns.CancelOrder(ctx, tx, req)
+	case req.Status != "" && req.Status != orders.StatusCanceled && old.Status == orders.StatusCanceled:
+		err = ordersPatterns.RestoreOrder(ctx, tx, req.Id)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	return nil
 }
+
+// order ที่ยังรอชำระเงินและไม่แนบ slip เกินเวลา เก่าสุดก่อน ใช้ email ของสมาชิกหรือ email ที่สั่งซื้อแบบไม่ได้สมัครสมาชิก
+func (r *ordersRepository) FindUnpaidOrders(olderThan time.Duration) ([]*orders.UnpaidOrder, error) {
+	query := `
+	SELECT
+		"o"."id",
+		COALESCE("u"."email", "o"."guest_email", '') AS "email"
+	FROM "orders" "o"
+	LEFT JOIN "users" "u" ON "u"."id" = "o"."user_id"
+	WHERE "o"."status" = 'waiting'
+	AND "o"."transfer_slip" IS NULL
+	AND "o"."created_at" < now() - make_interval(secs => $1)
+	ORDER BY "o"."created_at" ASC
+	LIMIT $2;`
+
+	unpaid := make([]*orders.UnpaidOrder, 0)
+	if err := r.db.Select(&unpaid, query, olderThan.Seconds(), orders.ExpireBatchSize); err != nil {
+		return nil, fmt.Errorf("get unpaid orders failed: %v", err)
+	}
+	return unpaid, nil
+}
+
+// ยกเลิก order ที่ยังไม่ได้ชำระเงินเกินเวลา คืน false ถ้าระหว่างนั้น order ถูกแนบ slip หรือเปลี่ยนสถานะไปแล้ว
+func (r *ordersRepository) ExpireOrder(orderId string, olderThan time.Duration) (bool, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+	UPDATE "orders" SET
+		"status" = 'canceled'
+	WHERE "id" = (
+		SELECT
+			"id"
+		FROM "orders"
+		WHERE "id" = $1
+		AND "status" = 'waiting'
+		AND "transfer_slip" IS NULL
+		AND "created_at" < now() - make_interval(secs => $2)
+		FOR UPDATE SKIP LOCKED
+	);`
+
+	result, err := tx.ExecContext(ctx, query, orderId, olderThan.Seconds())
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("expire order failed: %v", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := ordersPatterns.CancelOrder(ctx, tx, &orders.Order{
+		Id:           orderId,
+		CancelReason: "payment was not received in time",
+		CanceledBy:   orders.CanceledBySystem,
+	}); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
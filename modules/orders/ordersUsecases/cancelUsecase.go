@@ -0,0 +1,99 @@
+package ordersUsecases
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
+)
+
+// เหตุผลใช้ได้เฉพาะตอนยกเลิก ลูกค้ายกเลิกเองได้ตามเวลาที่กำหนดในแต่ละสถานะ ส่วน admin ยกเลิกได้เสมอ
+// CanceledBy ถูกกำหนดโดย handler ตาม role ของผู้ที่ส่ง request
+func (u *ordersUsecase) applyCancelRules(req *orders.Order) error {
+	req.CancelPolicy = nil
+	if req.Status != orders.StatusCanceled {
+		req.CancelReason = ""
+		req.CanceledBy = ""
+		return nil
+	}
+
+	req.CancelReason = strings.TrimSpace(req.CancelReason)
+	if utf8.RuneCountInString(req.CancelReason) > orders.CancelReasonMaxLen {
+		return fmt.Errorf("cancel reason must not be longer than %d characters", orders.CancelReasonMaxLen)
+	}
+	if req.CanceledBy == orders.CanceledByCustomer {
+		req.CancelPolicy = orders.CancelPolicy(u.cfg.Order().CancelWindows())
+	}
+	return nil
+}
+
+// order ที่ยังไม่แนบ slip เกินเวลาที่กำหนดจะถูกยกเลิกอัตโนมัติ คืน stock และ license key แล้วแจ้งลูกค้าทาง email
+func (u *ordersUsecase) RunExpirer() {
+	interval, expires := u.cfg.Order().ExpireInterval(), u.cfg.Order().UnpaidExpires()
+	if interval <= 0 || expires <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		u.expireUnpaidOrders(expires)
+	}
+}
+
+func (u *ordersUsecase) expireUnpaidOrders(expires time.Duration) {
+	unpaid, err := u.ordersRepository.FindUnpaidOrders(expires)
+	if err != nil {
+		log.Printf("find unpaid orders failed: %v\n", err)
+		return
+	}
+
+	var count int
+	for _, order := range unpaid {
+		expired, err := u.ordersRepository.ExpireOrder(order.Id, expires)
+		if err != nil {
+			log.Printf("expire order: %s failed: %v\n", order.Id, err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+		count++
+
+		if order.Email == "" {
+			continue
+		}
+		// order ถูกยกเลิกแล้ว ส่ง email ไม่ได้จึงแค่บันทึก log ไว้
+		if err := u.mailer.Send(&mailer.Message{
+			To:      order.Email,
+			Subject: fmt.Sprintf("Your order %s was canceled", order.Id),
+			Body: fmt.Sprintf(
+				"We did not receive payment for order %s within %s, so it was canceled and the reserved items were released.\n\nYou are welcome to place a new order at any time.\n",
+				order.Id,
+				describeDuration(expires),
+			),
+		}); err != nil {
+			log.Printf("send expired email of order: %s failed: %v\n", order.Id, err)
+		}
+	}
+	if count > 0 {
+		log.Printf("expire unpaid orders: canceled %d\n", count)
+	}
+}
+
+// ใช้ใน email ถ้าเป็นชั่วโมงเต็มแสดงเป็นจำนวนชั่วโมง
+func describeDuration(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d > time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	default:
+		return d.String()
+	}
+}
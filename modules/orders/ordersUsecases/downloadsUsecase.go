@@ -0,0 +1,67 @@
+package ordersUsecases
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
+)
+
+// ไฟล์และ license key ส่งให้ลูกค้าหลัง order เสร็จสิ้นแล้วเท่านั้น
+const deliveryStatus = "completed"
+
+// key ที่ใช้ sign ลิงก์ดาวน์โหลด แยกจาก key ของไฟล์ใน storage เพื่อไม่ให้ใช้ signature แทนกันได้
+func downloadKey(downloadId string) string {
+	return "downloads/" + downloadId
+}
+
+func (u *ordersUsecase) FindDeliveries(userId, orderId string) ([]*orders.Delivery, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Status != deliveryStatus {
+		return nil, fmt.Errorf("order is not completed")
+	}
+	return u.ordersRepository.FindDeliveries(orderId)
+}
+
+// ลิงก์ไม่ได้นับการดาวน์โหลด จะนับเมื่อเปิดลิงก์จริง
+func (u *ordersUsecase) SignDownload(userId, orderId, downloadId string) (*files.SignedUrlRes, error) {
+	download, err := u.ordersRepository.FindOneDownload(downloadId)
+	if err != nil {
+		return nil, err
+	}
+	if download.OrderId != orderId || download.UserId != userId {
+		return nil, fmt.Errorf("download not found")
+	}
+	if download.OrderStatus != deliveryStatus {
+		return nil, fmt.Errorf("order is not completed")
+	}
+	if download.Exhausted() {
+		return nil, fmt.Errorf("download limit reached")
+	}
+
+	expiresAt := time.Now().Add(u.cfg.Product().DownloadLinkExpires())
+	signature := storage.Sign(u.cfg.Storage().SigningKey(), downloadKey(download.Id), expiresAt.Unix())
+	return &files.SignedUrlRes{
+		Url:       fmt.Sprintf("%s/%s?expires=%d&signature=%s", u.cfg.Product().DownloadUrl(), download.Id, expiresAt.Unix(), signature),
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// ตรวจ signature แล้วนับการดาวน์โหลดก่อนเปิดไฟล์ ไฟล์เป็น private จึงเปิดด้วยสิทธิ์ของระบบ
+func (u *ordersUsecase) OpenDownload(downloadId string, expires int64, signature string) (io.ReadCloser, *files.File, error) {
+	if err := storage.VerifySignature(u.cfg.Storage().SigningKey(), downloadKey(downloadId), expires, signature); err != nil {
+		return nil, nil, err
+	}
+
+	fileId, err := u.ordersRepository.ConsumeDownload(downloadId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u.filesUsecase.OpenFile(fileId, "", true)
+}
@@ -0,0 +1,93 @@
+package ordersUsecases
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/orders"
+	"github.com/Doittikorn/go-e-commerce/modules/users"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
+)
+
+// order ของลูกค้าที่ไม่ได้สมัครสมาชิก token สำหรับติดตาม order ถูกส่งทาง email เท่านั้น ไม่ได้ตอบกลับไปใน response
+// สินค้าดิจิทัลจะดาวน์โหลดได้เมื่อลูกค้าย้าย order เข้าบัญชีด้วย token นี้แล้ว
+func (u *ordersUsecase) InsertGuestOrder(req *orders.Order) (*orders.Order, error) {
+	req.GuestEmail = strings.ToLower(strings.TrimSpace(req.GuestEmail))
+	if !users.IsEmail(req.GuestEmail) {
+		return nil, fmt.Errorf("invalid email")
+	}
+
+	token, err := orders.GenerateLookupToken()
+	if err != nil {
+		return nil, err
+	}
+	req.UserId = ""
+	req.LookupTokenHash = orders.HashLookupToken(token)
+
+	order, err := u.InsertOrder(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// order ถูกบันทึกแล้ว ส่ง email ไม่ได้จึงไม่ทำให้การสั่งซื้อล้มเหลว
+	if err := u.mailer.Send(&mailer.Message{
+		To:      order.GuestEmail,
+		Subject: fmt.Sprintf("Your order %s", order.Id),
+		Body: fmt.Sprintf(
+			"Thank you for your order.\n\nTrack your order at:\n%s/%s?token=%s\n\nKeep this link private, anyone who has it can see your order.\nTo move the order into your account, sign up with this email and verify it, or sign in and claim it with the same token.\n",
+			strings.TrimSuffix(u.cfg.Order().GuestLookupUrl(), "/"),
+			url.PathEscape(order.Id),
+			url.QueryEscape(token),
+		),
+	}); err != nil {
+		log.Printf("send lookup email of order: %s failed: %v\n", order.Id, err)
+	}
+	return order, nil
+}
+
+// token ผิดตอบเหมือนไม่พบ order เพื่อไม่ให้รู้ว่ามี order นี้อยู่
+func (u *ordersUsecase) verifyLookupToken(orderId, token string) error {
+	hash, err := u.ordersRepository.FindLookupTokenHash(orderId)
+	if err != nil {
+		return err
+	}
+	if hash == "" || token == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(orders.HashLookupToken(token))) != 1 {
+		return fmt.Errorf("order not found")
+	}
+	return nil
+}
+
+func (u *ordersUsecase) FindGuestOrder(orderId, token string) (*orders.Order, error) {
+	if err := u.verifyLookupToken(orderId, token); err != nil {
+		return nil, err
+	}
+	return u.ordersRepository.FindOneOrder(orderId)
+}
+
+// email ของบัญชีอาจไม่ใช่ของจริง จึงย้าย order เข้าบัญชีได้เฉพาะผู้ที่มี token ซึ่งถูกส่งไปทาง email ของ order เท่านั้น
+func (u *ordersUsecase) ClaimGuestOrder(userId, orderId, token string) (*orders.Order, error) {
+	if err := u.verifyLookupToken(orderId, token); err != nil {
+		return nil, err
+	}
+	if err := u.ordersRepository.ClaimGuestOrder(orderId, userId); err != nil {
+		return nil, err
+	}
+	return u.ordersRepository.FindOneOrder(orderId)
+}
+
+// แนบ slip ได้อย่างเดียว สถานะของ order เปลี่ยนโดย admin เท่านั้น
+func (u *ordersUsecase) AttachGuestTransferSlip(orderId, token string, slip *orders.TransferSlip) (*orders.Order, error) {
+	if err := u.verifyLookupToken(orderId, token); err != nil {
+		return nil, err
+	}
+	if err := u.ordersRepository.UpdateOrder(&orders.Order{
+		Id:           orderId,
+		TransferSlip: slip,
+	}); err != nil {
+		return nil, err
+	}
+	return u.ordersRepository.FindOneOrder(orderId)
+}
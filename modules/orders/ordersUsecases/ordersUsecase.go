@@ -2,12 +2,18 @@ package ordersUsecases
 
 import (
 	"fmt"
+	"io"
 	"math"
 
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/modules/files/filesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/orders"
 	"github.com/Doittikorn/go-e-commerce/modules/orders/ordersRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/products/productsRepositories"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
 	"github.com/Doittikorn/go-e-commerce/pkg/utils"
 )
 
@@ -15,18 +21,34 @@ type IOrdersUsecase interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 	InsertOrder(req *orders.Order) (*orders.Order, error)
+	InsertGuestOrder(req *orders.Order) (*orders.Order, error)
+	FindGuestOrder(orderId, token string) (*orders.Order, error)
+	ClaimGuestOrder(userId, orderId, token string) (*orders.Order, error)
+	AttachGuestTransferSlip(orderId, token string, slip *orders.TransferSlip) (*orders.Order, error)
 	UpdateOrder(req *orders.Order) (*orders.Order, error)
+	RunExpirer()
+	FindDeliveries(userId, orderId string) ([]*orders.Delivery, error)
+	SignDownload(userId, orderId, downloadId string) (*files.SignedUrlRes, error)
+	OpenDownload(downloadId string, expires int64, signature string) (io.ReadCloser, *files.File, error)
 }
 
 type ordersUsecase struct {
+	cfg                config.ConfigImpl
 	ordersRepository   ordersRepositories.IOrdersRepository
 	productsRepository productsRepositories.IProductsRepository
+	filesUsecase       filesUsecases.IFilesUsecase
+	currenciesUsecase  currenciesUsecases.ICurrenciesUsecase
+	mailer             mailer.MailerImpl
 }
 
-func OrdersUsecase(ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository) IOrdersUsecase {
+func OrdersUsecase(cfg config.ConfigImpl, ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository, filesUsecase filesUsecases.IFilesUsecase, currenciesUsecase currenciesUsecases.ICurrenciesUsecase, mailer mailer.MailerImpl) IOrdersUsecase {
 	return &ordersUsecase{
+		cfg:                cfg,
 		ordersRepository:   ordersRepository,
 		productsRepository: productsRepository,
+		filesUsecase:       filesUsecase,
+		currenciesUsecase:  currenciesUsecase,
+		mailer:             mailer,
 	}
 }
 
@@ -40,6 +62,9 @@ func (u *ordersUsecase) FindOneOrder(orderId string) (*orders.Order, error) {
 
 func (u *ordersUsecase) FindOrder(req *orders.OrderFilter) *entities.PaginateRes {
 	orders, count := u.ordersRepository.FindOrder(req)
+	for _, o := range orders {
+		o.SetDisplay(req.Conversion)
+	}
 	return &entities.PaginateRes{
 		Data:      orders,
 		Page:      req.Page,
@@ -50,21 +75,37 @@ func (u *ordersUsecase) FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 }
 
 func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
+	// บันทึกอัตรา ณ ตอนสั่งซื้อไว้กับ order ไม่ได้ใช้อัตราที่ส่งมา
+	conversion, err := u.currenciesUsecase.Conversion(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	req.Currency = conversion.Currency
+	req.ExchangeRate = conversion.Rate
+
 	// Check if products is exists
 	for i := range req.Products {
 		if req.Products[i].Product == nil {
 			return nil, fmt.Errorf("product is nil")
 		}
+		if req.Products[i].Qty <= 0 {
+			return nil, fmt.Errorf("qty must be more than 0")
+		}
 
-		prod, err := u.productsRepository.FindOneProduct(req.Products[i].Product.Id)
+		prod, err := u.productsRepository.FindOnePublishedProduct(req.Products[i].Product.Id)
 		if err != nil {
 			return nil, err
 		}
 		utils.Debug(prod)
 
-		// Set price
-		req.TotalPaid += req.Products[i].Product.Price * float64(req.Products[i].Qty)
+		// stock ของสินค้าชุดที่คำนวณได้ใช้ตรวจก่อน ส่วนการตัด stock จริงตรวจอีกครั้งตอนบันทึก order
+		if prod.Stock != nil && *prod.Stock < req.Products[i].Qty {
+			return nil, fmt.Errorf("product %s is out of stock", prod.Id)
+		}
+
+		// snapshot สินค้าเก็บทั้งราคาปกติและราคาที่ขายจริงตอนสั่งซื้อ ยอดรวมคิดจากราคาที่ขายจริง
 		req.Products[i].Product = prod
+		req.TotalPaid += prod.EffectivePrice.Mul(req.Products[i].Qty)
 	}
 
 	orderId, err := u.ordersRepository.InsertOrder(req)
@@ -76,11 +117,15 @@ func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 	if err != nil {
 		return nil, err
 	}
+	order.SetDisplay(conversion)
 
 	return order, nil
 }
 
 func (u *ordersUsecase) UpdateOrder(req *orders.Order) (*orders.Order, error) {
+	if err := u.applyCancelRules(req); err != nil {
+		return nil, err
+	}
 	if err := u.ordersRepository.UpdateOrder(req); err != nil {
 		return nil, err
 	}
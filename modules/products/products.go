@@ -2,23 +2,218 @@ package products
 
 import (
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
+)
+
+// สถานะของสินค้า ลูกค้าเห็นเฉพาะ published
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+func IsStatus(status string) bool {
+	return status == StatusDraft || status == StatusPublished || status == StatusArchived
+}
+
+// สินค้าชุดประกอบด้วยสินค้าธรรมดาเท่านั้น สินค้าดิจิทัลส่งเป็นไฟล์และ license key หลัง order เสร็จสิ้น
+const (
+	TypeSimple  = "simple"
+	TypeBundle  = "bundle"
+	TypeDigital = "digital"
+)
+
+func IsType(productType string) bool {
+	return productType == TypeSimple || productType == TypeBundle || productType == TypeDigital
+}
+
+// จำนวนครั้งที่ดาวน์โหลดได้ต่อการซื้อ ถ้าไม่ได้กำหนด
+const DefaultDownloadLimit = 5
+
+// fixed ใช้ราคาของสินค้าชุดเอง ส่วน sum_discount คือผลรวมราคาที่ขายจริงของสินค้าในชุดหัก BundleDiscount
+const (
+	BundlePricingFixed       = "fixed"
+	BundlePricingSumDiscount = "sum_discount"
 )
 
 type Product struct {
 	Id          string            `json:"id"`
+	Sku         string            `json:"sku"`
 	Title       string            `json:"title"`
 	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	Type        string            `json:"type"`
+	PublishAt   *string           `json:"publish_at"`   // RFC3339, "" คือยกเลิกเวลาที่ตั้งไว้
+	UnpublishAt *string           `json:"unpublish_at"` // RFC3339, "" คือยกเลิกเวลาที่ตั้งไว้
 	Category    *appinfo.Category `json:"category"`
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
-	Price       float64           `json:"price"`
-	Images      []*entities.Image `json:"images"`
+	Price       money.Amount      `json:"price"`
+	// ราคาก่อนลดที่แสดงขีดฆ่าไว้ และราคา sale ในช่วงเวลาที่ตั้งไว้ ตอนแก้ไขส่ง 0 คือยกเลิก
+	CompareAtPrice *money.Amount `json:"compare_at_price"`
+	SalePrice      *money.Amount `json:"sale_price"`
+	SaleStartsAt   *string       `json:"sale_starts_at"` // RFC3339, "" คือยกเลิกเวลาที่ตั้งไว้
+	SaleEndsAt     *string       `json:"sale_ends_at"`   // RFC3339, "" คือยกเลิกเวลาที่ตั้งไว้
+	// ราคาที่ขายจริง ณ เวลาที่ query คำนวณโดย database
+	EffectivePrice money.Amount `json:"effective_price"`
+	// stock ของสินค้าชุดคำนวณจากสินค้าในชุด null คือไม่นับ stock ตอนแก้ไขส่ง -1 คือเลิกนับ
+	Stock          *int          `json:"stock"`
+	BundlePricing  string        `json:"bundle_pricing"`
+	BundleDiscount *money.Amount `json:"bundle_discount"`
+	Components     []*BundleItem `json:"components"` // ตอนแก้ไขส่งมาคือแทนที่สินค้าในชุดทั้งหมด
+	// ไฟล์ private ของสินค้าดิจิทัล ตอนแก้ไขส่ง "" คือเลิกส่งไฟล์ download_limit เป็น 0 คือไม่จำกัด
+	DigitalFileId   *string `json:"digital_file_id"`
+	DownloadLimit   *int    `json:"download_limit"`
+	UsesLicenseKeys *bool   `json:"uses_license_keys"`
+	// ค่าตาม attribute ที่หมวดหมู่กำหนด ตอนแก้ไขส่งเฉพาะ code ที่จะเปลี่ยน และส่ง null คือลบค่านั้น
+	Attributes map[string]any    `json:"attributes"`
+	Images     []*entities.Image `json:"images"`
+	// ราคาในสกุลเงินที่ขอผ่าน query currency ไม่ถูกเก็บไว้ใน snapshot ของ order
+	Display *DisplayPrice `json:"display,omitempty"`
+}
+
+// ใช้แสดงผลเท่านั้น ราคาที่ใช้คิดเงินเป็นสกุลเงินหลักเสมอ
+type DisplayPrice struct {
+	Currency       string         `json:"currency"`
+	Symbol         string         `json:"symbol"`
+	Rate           float64        `json:"rate"`
+	Price          money.Display  `json:"price"`
+	CompareAtPrice *money.Display `json:"compare_at_price"`
+	SalePrice      *money.Display `json:"sale_price"`
+	EffectivePrice money.Display  `json:"effective_price"`
+}
+
+// สกุลเงินหลักไม่ต้องแปลง
+func (p *Product) SetDisplay(c *currencies.Conversion) {
+	if c == nil || c.Base {
+		return
+	}
+	convert := func(amount *money.Amount) *money.Display {
+		if amount == nil {
+			return nil
+		}
+		v := c.Convert(*amount)
+		return &v
+	}
+	p.Display = &DisplayPrice{
+		Currency:       c.Currency,
+		Symbol:         c.Symbol,
+		Rate:           c.Rate,
+		Price:          c.Convert(p.Price),
+		CompareAtPrice: convert(p.CompareAtPrice),
+		SalePrice:      convert(p.SalePrice),
+		EffectivePrice: c.Convert(p.EffectivePrice),
+	}
+}
+
+// สินค้าในชุด ถูก snapshot ไปกับสินค้าชุดใน order เพื่อให้รู้ว่าต้องหยิบอะไรบ้าง
+type BundleItem struct {
+	ProductId      string       `json:"product_id"`
+	Sku            string       `json:"sku"`
+	Title          string       `json:"title"`
+	Qty            int          `json:"qty"`
+	EffectivePrice money.Amount `json:"effective_price"`
+}
+
+type LicenseKeySummary struct {
+	ProductId string `json:"product_id"`
+	Total     int    `json:"total"`
+	Available int    `json:"available"`
+	Added     int    `json:"added"`
+}
+
+type PriceHistory struct {
+	Id             string        `db:"id" json:"id"`
+	ProductId      string        `db:"product_id" json:"product_id"`
+	Price          money.Amount  `db:"price" json:"price"`
+	CompareAtPrice *money.Amount `db:"compare_at_price" json:"compare_at_price"`
+	SalePrice      *money.Amount `db:"sale_price" json:"sale_price"`
+	SaleStartsAt   *string       `db:"sale_starts_at" json:"sale_starts_at"`
+	SaleEndsAt     *string       `db:"sale_ends_at" json:"sale_ends_at"`
+	CreatedAt      string        `db:"created_at" json:"created_at"`
 }
 
 type ProductFilter struct {
-	Id     string `query:"id"`
-	Search string `query:"search"` // title & description
+	Id         string `query:"id"`
+	Search     string `query:"search"` // title & description
+	Status     string `query:"status"` // ใช้ได้เฉพาะ admin
+	CategoryId int    `query:"category_id"`
+	Type       string `query:"type"`
+	// กำหนดโดย handler ของ endpoint สาธารณะ ไม่ได้มาจาก query
+	PublishedOnly bool `query:"-"`
+	// มาจาก query attr.<code> ใช้ได้เฉพาะ attribute ที่ filterable
+	Attributes []*AttributeFilter `query:"-"`
+	// มาจาก query currency handler แปลงเป็นอัตราก่อนส่งให้ usecase
+	Conversion *currencies.Conversion `query:"-"`
 	*entities.PaginationReq
 	*entities.SortReq
 }
+
+// Raw คือค่าจาก query ตัวเลขใช้ min..max เป็นช่วงได้ โดยละฝั่งใดฝั่งหนึ่งได้
+// usecase จะแปลง Raw เป็น Value หรือ Min และ Max ตามชนิดของ attribute
+type AttributeFilter struct {
+	Code  string
+	Raw   string
+	Value any
+	Min   *float64
+	Max   *float64
+}
+
+// สถานะของงาน import สินค้าที่ทำงานอยู่เบื้องหลัง
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+const (
+	// จำนวน error ที่เก็บไว้ในรายงาน แถวที่ผิดเกินจากนี้จะนับแต่ไม่เก็บรายละเอียด
+	ImportMaxErrors = 1000
+	ImportMaxRows   = 50000
+	// บันทึก progress ทุก ๆ จำนวนแถวนี้
+	ImportProgressEvery = 100
+	ExportPageSize      = 500
+)
+
+type ImportJob struct {
+	Id            string            `db:"id" json:"id"`
+	OwnerId       string            `db:"owner_id" json:"owner_id"`
+	FileName      string            `db:"filename" json:"filename"`
+	FileId        string            `db:"file_id" json:"file_id,omitempty"` // ไฟล์ต้นทางที่ upload ไว้ก่อน ว่างคือส่งไฟล์มากับ request
+	Status        string            `db:"status" json:"status"`
+	TotalRows     int               `db:"total_rows" json:"total_rows"`
+	ProcessedRows int               `db:"processed_rows" json:"processed_rows"`
+	CreatedCount  int               `db:"created_count" json:"created_count"`
+	UpdatedCount  int               `db:"updated_count" json:"updated_count"`
+	FailedCount   int               `db:"failed_count" json:"failed_count"`
+	Errors        []*ImportRowError `db:"-" json:"errors"`
+	Message       string            `db:"message" json:"message"`
+	FinishedAt    string            `db:"finished_at" json:"finished_at"`
+	CreatedAt     string            `db:"created_at" json:"created_at"`
+	UpdatedAt     string            `db:"updated_at" json:"updated_at"`
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Sku     string `json:"sku,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// แถวที่ผ่านการตรวจแล้ว ถ้า Images ว่างจะไม่แก้รูปเดิมของสินค้า
+type ImportRow struct {
+	Row         int
+	Sku         string
+	Title       string
+	Description string
+	Price       money.Amount
+	CategoryId  int
+	Status      string // ว่างคือสินค้าใหม่เป็น draft และสินค้าเดิมไม่เปลี่ยนสถานะ
+	Images      []*entities.Image
+}
+
+// column ของไฟล์ import และ export ไฟล์ที่ export ออกไปจึง import กลับเข้ามาได้
+var ExportColumns = []string{"sku", "title", "description", "price", "category_id", "category", "images", "status", "id", "created_at", "updated_at"}
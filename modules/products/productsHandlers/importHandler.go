@@ -0,0 +1,159 @@
+package productsHandlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/middlewares"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/pkg/spreadsheet"
+	"github.com/gofiber/fiber/v2"
+)
+
+// รับไฟล์ csv/xlsx จาก form field file หรือ file_id ของไฟล์ที่ upload ไว้แล้ว
+func (h *productsHandler) ImportProducts(c *fiber.Ctx) error {
+	userId, _ := c.Locals("userId").(string)
+
+	var source io.ReadCloser
+	var fileId, fileName string
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(importProductErr),
+				err.Error(),
+			).Res()
+		}
+		source, fileName = f, file.Filename
+	} else if fileId = strings.Trim(c.FormValue("file_id"), " "); fileId != "" {
+		r, file, err := h.filesUsecase.OpenFile(fileId, userId, middlewares.Permissions(c).Has("files:read_any"))
+		if err != nil {
+			switch err.Error() {
+			case "file not found":
+				return entities.NewResponse(c).Error(
+					fiber.ErrNotFound.Code,
+					string(importProductErr),
+					err.Error(),
+				).Res()
+			case "no permission to access file":
+				return entities.NewResponse(c).Error(
+					fiber.ErrForbidden.Code,
+					string(importProductErr),
+					err.Error(),
+				).Res()
+			default:
+				return entities.NewResponse(c).Error(
+					fiber.ErrInternalServerError.Code,
+					string(importProductErr),
+					err.Error(),
+				).Res()
+			}
+		}
+		source, fileName = r, file.FileName
+	} else {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(importProductErr),
+			"file or file_id is required",
+		).Res()
+	}
+	defer source.Close()
+
+	job, err := h.productsUsecase.ImportProducts(userId, fileId, fileName, source)
+	if err != nil {
+		if err.Error() == "file type is not supported" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(importProductErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(importProductErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusAccepted, job).Res()
+}
+
+func (h *productsHandler) FindOneImportJob(c *fiber.Ctx) error {
+	job, err := h.productsUsecase.FindOneImportJob(strings.Trim(c.Params("job_id"), " "))
+	if err != nil {
+		if err.Error() == "import job not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findImportJobErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findImportJobErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, job).Res()
+}
+
+// ใช้ filter เดียวกับ FindProduct ส่วน page และ limit จะถูกละไว้เพราะ export ทุกหน้า
+func (h *productsHandler) ExportProducts(c *fiber.Ctx) error {
+	req := &products.ProductFilter{
+		PaginationReq: &entities.PaginationReq{},
+		SortReq:       &entities.SortReq{},
+	}
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportProductErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Status != "" && !products.IsStatus(req.Status) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportProductErr),
+			"status is invalid",
+		).Res()
+	}
+
+	req.Attributes = attributeFilters(c)
+	if err := h.productsUsecase.ResolveAttributeFilters(req); err != nil {
+		if isLifecycleError(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(exportProductErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(exportProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	format := strings.ToLower(c.Query("format", spreadsheet.FormatCSV))
+	if format != spreadsheet.FormatCSV && format != spreadsheet.FormatXLSX {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportProductErr),
+			"format is invalid",
+		).Res()
+	}
+
+	c.Set(fiber.HeaderContentType, spreadsheet.ContentType(format))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="products-%s.%s"`, time.Now().Format("20060102-150405"), format))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.productsUsecase.ExportProducts(req, format, w); err != nil {
+			log.Printf("export products failed: %v\n", err)
+		}
+	})
+	return nil
+}
@@ -1,15 +1,15 @@
 package productsHandlers
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/Doittikorn/go-e-commerce/config"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
-	"github.com/Doittikorn/go-e-commerce/modules/files"
 	"github.com/Doittikorn/go-e-commerce/modules/products"
 	"github.com/Doittikorn/go-e-commerce/modules/products/productsUsecases"
 
@@ -24,34 +24,79 @@ const (
 	insertProductErr  productsHandlersErrCode = "products-003"
 	deleteProductErr  productsHandlersErrCode = "products-004"
 	updateProductErr  productsHandlersErrCode = "products-005"
+	importProductErr  productsHandlersErrCode = "products-006"
+	findImportJobErr  productsHandlersErrCode = "products-007"
+	exportProductErr  productsHandlersErrCode = "products-008"
+	findPriceHistErr  productsHandlersErrCode = "products-009"
+	findLicenseKeyErr productsHandlersErrCode = "products-010"
+	addLicenseKeyErr  productsHandlersErrCode = "products-011"
 )
 
 type IProductsHandler interface {
 	FindOneProduct(c *fiber.Ctx) error
 	FindProduct(c *fiber.Ctx) error
+	FindOneAdminProduct(c *fiber.Ctx) error
+	FindAdminProduct(c *fiber.Ctx) error
 	AddProduct(c *fiber.Ctx) error
 	DeleteProduct(c *fiber.Ctx) error
 	UpdateProduct(c *fiber.Ctx) error
+	ImportProducts(c *fiber.Ctx) error
+	FindOneImportJob(c *fiber.Ctx) error
+	ExportProducts(c *fiber.Ctx) error
+	FindPriceHistory(c *fiber.Ctx) error
+	FindLicenseKeys(c *fiber.Ctx) error
+	AddLicenseKeys(c *fiber.Ctx) error
 }
 
 type productsHandler struct {
-	cfg             config.ConfigImpl
-	productsUsecase productsUsecases.IProductsUsecase
-	filesUsecase    filesUsecases.IFilesUsecase
+	cfg               config.ConfigImpl
+	productsUsecase   productsUsecases.IProductsUsecase
+	filesUsecase      filesUsecases.IFilesUsecase
+	currenciesUsecase currenciesUsecases.ICurrenciesUsecase
 }
 
-func ProductsHandler(cfg config.ConfigImpl, productsUsecase productsUsecases.IProductsUsecase, filesUsecase filesUsecases.IFilesUsecase) IProductsHandler {
+func ProductsHandler(cfg config.ConfigImpl, productsUsecase productsUsecases.IProductsUsecase, filesUsecase filesUsecases.IFilesUsecase, currenciesUsecase currenciesUsecases.ICurrenciesUsecase) IProductsHandler {
 	return &productsHandler{
-		cfg:             cfg,
-		productsUsecase: productsUsecase,
-		filesUsecase:    filesUsecase,
+		cfg:               cfg,
+		productsUsecase:   productsUsecase,
+		filesUsecase:      filesUsecase,
+		currenciesUsecase: currenciesUsecase,
 	}
 }
 
+// อัตราของสกุลเงินจาก query currency ถ้าไม่ระบุจะไม่แปลงราคา
+func (h *productsHandler) conversion(c *fiber.Ctx) (*currencies.Conversion, error) {
+	if c.Query("currency") == "" {
+		return nil, nil
+	}
+	return h.currenciesUsecase.Conversion(c.Query("currency"))
+}
+
 func (h *productsHandler) FindOneProduct(c *fiber.Ctx) error {
+	return h.findOneProduct(c, h.productsUsecase.FindOnePublishedProduct)
+}
+
+func (h *productsHandler) FindOneAdminProduct(c *fiber.Ctx) error {
+	return h.findOneProduct(c, h.productsUsecase.FindOneProduct)
+}
+
+func (h *productsHandler) findOneProduct(c *fiber.Ctx, find func(productId string) (*products.Product, error)) error {
 	productId := strings.Trim(c.Params("product_id"), " ")
 
-	product, err := h.productsUsecase.FindOneProduct(productId)
+	conversion, err := h.conversion(c)
+	if err != nil {
+		code := fiber.ErrInternalServerError.Code
+		if currencies.IsConversionError(err) {
+			code = fiber.ErrBadRequest.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(findOneProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	product, err := find(productId)
 	if err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
@@ -59,10 +104,81 @@ func (h *productsHandler) FindOneProduct(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
+	product.SetDisplay(conversion)
 	return entities.NewResponse(c).Success(fiber.StatusOK, product).Res()
 }
 
+func (h *productsHandler) FindPriceHistory(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("product_id"), " ")
+
+	history, err := h.productsUsecase.FindPriceHistory(productId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findPriceHistErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, history).Res()
+}
+
+// จำนวน license key ทั้งหมดและที่ยังว่าง ไม่ได้แสดงตัว key
+func (h *productsHandler) FindLicenseKeys(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("product_id"), " ")
+
+	summary, err := h.productsUsecase.FindLicenseKeys(productId)
+	if err != nil {
+		code := fiber.ErrInternalServerError.Code
+		if err.Error() == "product does not use license keys" {
+			code = fiber.ErrBadRequest.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(findLicenseKeyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, summary).Res()
+}
+
+func (h *productsHandler) AddLicenseKeys(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("product_id"), " ")
+	req := &struct {
+		Keys []string `json:"keys"`
+	}{}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addLicenseKeyErr),
+			err.Error(),
+		).Res()
+	}
+
+	summary, err := h.productsUsecase.AddLicenseKeys(productId, req.Keys)
+	if err != nil {
+		code := fiber.ErrInternalServerError.Code
+		if err.Error() == "product does not use license keys" || strings.HasPrefix(err.Error(), "license keys ") {
+			code = fiber.ErrBadRequest.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(addLicenseKeyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, summary).Res()
+}
+
+// endpoint สาธารณะเห็นเฉพาะสินค้าที่ publish แล้ว และกรองด้วย status ไม่ได้
 func (h *productsHandler) FindProduct(c *fiber.Ctx) error {
+	return h.findProduct(c, true)
+}
+
+func (h *productsHandler) FindAdminProduct(c *fiber.Ctx) error {
+	return h.findProduct(c, false)
+}
+
+func (h *productsHandler) findProduct(c *fiber.Ctx, publishedOnly bool) error {
 	req := &products.ProductFilter{
 		PaginationReq: &entities.PaginationReq{},
 		SortReq:       &entities.SortReq{},
@@ -76,6 +192,48 @@ func (h *productsHandler) FindProduct(c *fiber.Ctx) error {
 		).Res()
 	}
 
+	req.PublishedOnly = publishedOnly
+	if publishedOnly {
+		req.Status = ""
+	}
+	if req.Status != "" && !products.IsStatus(req.Status) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			"status is invalid",
+		).Res()
+	}
+
+	req.Attributes = attributeFilters(c)
+	if err := h.productsUsecase.ResolveAttributeFilters(req); err != nil {
+		if isLifecycleError(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(findProductErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findProductErr),
+			err.Error(),
+		).Res()
+	}
+
+	conversion, err := h.conversion(c)
+	if err != nil {
+		code := fiber.ErrInternalServerError.Code
+		if currencies.IsConversionError(err) {
+			code = fiber.ErrBadRequest.Code
+		}
+		return entities.NewResponse(c).Error(
+			code,
+			string(findProductErr),
+			err.Error(),
+		).Res()
+	}
+	req.Conversion = conversion
+
 	if req.Page < 1 {
 		req.Page = 1
 	}
@@ -94,6 +252,39 @@ func (h *productsHandler) FindProduct(c *fiber.Ctx) error {
 	return entities.NewResponse(c).Success(fiber.StatusOK, products).Res()
 }
 
+// query attr.<code>=<value> ใช้กรองด้วย attribute
+func attributeFilters(c *fiber.Ctx) []*products.AttributeFilter {
+	filters := make([]*products.AttributeFilter, 0)
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		code, ok := strings.CutPrefix(string(key), "attr.")
+		if !ok {
+			return
+		}
+		filters = append(filters, &products.AttributeFilter{
+			Code: code,
+			Raw:  string(value),
+		})
+	})
+	return filters
+}
+
+// error ที่เกิดจากสถานะ เวลา ราคา attribute หรือข้อมูลสินค้าชุดที่ส่งมาไม่ถูกต้อง
+func isLifecycleError(err error) bool {
+	if strings.HasPrefix(err.Error(), "attribute ") || strings.HasPrefix(err.Error(), "component ") || strings.HasPrefix(err.Error(), "digital ") {
+		return true
+	}
+	switch err.Error() {
+	case "product type cannot be changed", "product type is invalid", "bundle fields are only for bundle products", "stock of bundle is derived from components",
+		"price of fixed bundle must be more than 0", "bundle_pricing is invalid", "bundle_discount is invalid", "bundle must have components", "stock is invalid",
+		"download_limit is invalid":
+		return true
+	case "status is invalid", "publish_at is invalid", "unpublish_at is invalid", "unpublish_at must be after publish_at",
+		"compare_at_price is invalid", "sale_price is invalid", "sale_starts_at is invalid", "sale_ends_at is invalid", "sale_ends_at must be after sale_starts_at":
+		return true
+	}
+	return false
+}
+
 func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 	req := &products.Product{
 		Category: &appinfo.Category{},
@@ -116,6 +307,13 @@ func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 
 	product, err := h.productsUsecase.AddProduct(req)
 	if err != nil {
+		if isLifecycleError(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertProductErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(insertProductErr),
@@ -128,35 +326,22 @@ func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 	productId := strings.Trim(c.Params("product_id"), " ")
 
-	product, err := h.productsUsecase.FindOneProduct(productId)
-	if err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(deleteProductErr),
-			err.Error(),
-		).Res()
-	}
-
-	deleteFileReq := make([]*files.DeleteFileReq, 0)
-	for _, p := range product.Images {
-		deleteFileReq = append(deleteFileReq, &files.DeleteFileReq{
-			Destination: fmt.Sprintf("images/products/%s", p.FileName),
-		})
-	}
-	if err := h.filesUsecase.DeleteFileOnGCP(deleteFileReq); err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(deleteProductErr),
-			err.Error(),
-		).Res()
-	}
-
+	// รูปของสินค้ายังถูกเก็บไว้เผื่อกู้คืน และจะถูกลบโดย orphan sweeper หลังสินค้าถูกลบถาวร
 	if err := h.productsUsecase.DeleteProduct(productId); err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(deleteProductErr),
-			err.Error(),
-		).Res()
+		switch err.Error() {
+		case "product not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteProductErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteProductErr),
+				err.Error(),
+			).Res()
+		}
 	}
 
 	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
@@ -179,6 +364,13 @@ func (h *productsHandler) UpdateProduct(c *fiber.Ctx) error {
 
 	product, err := h.productsUsecase.UpdateProduct(req)
 	if err != nil {
+		if isLifecycleError(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateProductErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(updateProductErr),
@@ -14,6 +14,77 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// เงื่อนไขของสินค้าที่ลูกค้าเห็นได้ ตรวจเวลาที่ตั้งไว้ด้วยเพื่อให้แสดงและซ่อนตรงเวลาโดยไม่ต้องรอ scheduler
+const PublishedCondition = `
+		AND ("p"."status" = 'published' OR ("p"."status" = 'draft' AND "p"."publish_at" IS NOT NULL))
+		AND ("p"."publish_at" IS NULL OR "p"."publish_at" <= now())
+		AND ("p"."unpublish_at" IS NULL OR "p"."unpublish_at" > now())`
+
+// ราคาที่ขายจริงตอนนี้ของสินค้าในชุด ใช้ราคา sale เมื่ออยู่ในช่วงเวลาที่ตั้งไว้
+const componentPrice = `
+						CASE
+							WHEN "cp"."sale_price" IS NOT NULL
+							AND ("cp"."sale_starts_at" IS NULL OR "cp"."sale_starts_at" <= now())
+							AND ("cp"."sale_ends_at" IS NULL OR "cp"."sale_ends_at" > now())
+							THEN "cp"."sale_price"
+							ELSE "cp"."price"
+						END`
+
+// ราคาที่ขายจริงตอนนี้ ใช้ราคา sale เมื่ออยู่ในช่วงเวลาที่ตั้งไว้
+// สินค้าชุดแบบ sum_discount ใช้ผลรวมราคาของสินค้าในชุดหักส่วนลด
+const EffectivePriceColumn = `
+			CASE
+				WHEN "p"."bundle_pricing" = 'sum_discount' THEN (
+					SELECT
+						GREATEST(COALESCE(SUM((` + componentPrice + `) * "bi"."qty"), 0) - "p"."bundle_discount", 0)
+					FROM "product_bundle_items" "bi"
+						JOIN "products" "cp" ON "cp"."id" = "bi"."product_id"
+					WHERE "bi"."bundle_id" = "p"."id"
+				)
+				WHEN "p"."sale_price" IS NOT NULL
+				AND ("p"."sale_starts_at" IS NULL OR "p"."sale_starts_at" <= now())
+				AND ("p"."sale_ends_at" IS NULL OR "p"."sale_ends_at" > now())
+				THEN "p"."sale_price"
+				ELSE "p"."price"
+			END AS "effective_price",`
+
+// stock ของสินค้าชุดคือจำนวนชุดที่ประกอบได้จากสินค้าในชุดที่นับ stock สินค้าในชุดที่ถูกลบแล้วทำให้ประกอบไม่ได้
+// stock ของสินค้าดิจิทัลที่ใช้ license key คือจำนวน key ที่ยังว่าง
+const StockColumn = `
+			CASE
+				WHEN "p"."type" = 'bundle' THEN (
+					SELECT
+						MIN(CASE WHEN "cp"."deleted_at" IS NOT NULL THEN 0 ELSE "cp"."stock" / "bi"."qty" END)
+					FROM "product_bundle_items" "bi"
+						JOIN "products" "cp" ON "cp"."id" = "bi"."product_id"
+					WHERE "bi"."bundle_id" = "p"."id"
+				)
+				WHEN "p"."uses_license_keys" THEN (
+					SELECT
+						COUNT(*)::INT
+					FROM "product_license_keys" "lk"
+					WHERE "lk"."product_id" = "p"."id"
+					AND "lk"."products_order_id" IS NULL
+				)
+				ELSE "p"."stock"
+			END AS "stock",`
+
+const ComponentsColumn = `
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("bt" ORDER BY "bt"."product_id")), '[]'::json)
+				FROM (
+					SELECT
+						"cp"."id" AS "product_id",
+						COALESCE("cp"."sku", '') AS "sku",
+						"cp"."title",
+						"bi"."qty",` + componentPrice + ` AS "effective_price"
+					FROM "product_bundle_items" "bi"
+						JOIN "products" "cp" ON "cp"."id" = "bi"."product_id"
+					WHERE "bi"."bundle_id" = "p"."id"
+				) AS "bt"
+			) AS "components",`
+
 type IFindProductBuilder interface {
 	openJsonQuery()
 	initQuery()
@@ -55,9 +126,24 @@ func (b *findProductBuilder) initQuery() {
 	b.query += `
 		SELECT
 			"p"."id",
+			COALESCE("p"."sku", '') AS "sku",
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."compare_at_price",
+			"p"."sale_price",
+			"p"."sale_starts_at",
+			"p"."sale_ends_at",` + EffectivePriceColumn + `
+			"p"."status",
+			"p"."type",
+			COALESCE("p"."bundle_pricing"::text, '') AS "bundle_pricing",
+			"p"."bundle_discount",
+			"p"."digital_file_id",
+			"p"."download_limit",
+			"p"."uses_license_keys",` + StockColumn + ComponentsColumn + `
+			"p"."publish_at",
+			"p"."unpublish_at",
+			"p"."attributes",
 			(
 				SELECT
 					to_jsonb("ct")
@@ -68,6 +154,7 @@ func (b *findProductBuilder) initQuery() {
 					FROM "categories" "c"
 						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					AND "c"."deleted_at" IS NULL
 				) AS "ct"
 			) AS "category",
 			"p"."created_at",
@@ -79,20 +166,35 @@ func (b *findProductBuilder) initQuery() {
 					SELECT
 						"i"."id",
 						"i"."filename",
-						"i"."url"
+						"i"."url",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("rt" ORDER BY "rt"."width", "rt"."content_type")), '[]'::json)
+							FROM (
+								SELECT
+									"fr"."variant" AS "name",
+									"fr"."content_type",
+									"fr"."width",
+									"fr"."height",
+									"fr"."url"
+								FROM "files" "fo"
+									JOIN "files" "fr" ON "fr"."parent_id" = "fo"."id"
+								WHERE "fo"."url" = "i"."url"
+							) AS "rt"
+						) AS "renditions"
 					FROM "images" "i"
 					WHERE "i"."product_id" = "p"."id"
 				) AS "it"
 			) AS "images"
 		FROM "products" "p"
-		WHERE 1 = 1`
+		WHERE "p"."deleted_at" IS NULL`
 }
 func (b *findProductBuilder) countQuery() {
 	b.query += `
 		SELECT
 			COUNT(*) AS "count"
 		FROM "products" "p"
-		WHERE 1 = 1`
+		WHERE "p"."deleted_at" IS NULL`
 }
 func (b *findProductBuilder) whereQuery() {
 	var queryWhere string
@@ -106,6 +208,62 @@ func (b *findProductBuilder) whereQuery() {
 		AND "p"."id" = ?`)
 	}
 
+	// Status check
+	if b.req.Status != "" {
+		b.values = append(b.values, b.req.Status)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."status" = ?`)
+	}
+
+	// Type check
+	if b.req.Type != "" {
+		b.values = append(b.values, b.req.Type)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."type" = ?`)
+	}
+
+	// Published check
+	if b.req.PublishedOnly {
+		queryWhereStack = append(queryWhereStack, PublishedCondition)
+	}
+
+	// Category check
+	if b.req.CategoryId != 0 {
+		b.values = append(b.values, b.req.CategoryId)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND EXISTS (SELECT 1 FROM "products_categories" "pc" WHERE "pc"."product_id" = "p"."id" AND "pc"."category_id" = ?)`)
+	}
+
+	// Attributes check ค่าที่ตรงกันใช้ @> เพื่อให้ใช้ GIN index ได้ ส่วนช่วงตัวเลขเทียบเฉพาะค่าที่เป็นตัวเลข
+	for _, f := range b.req.Attributes {
+		if f.Value != nil {
+			value, _ := json.Marshal(map[string]any{f.Code: f.Value})
+			b.values = append(b.values, string(value))
+
+			queryWhereStack = append(queryWhereStack, `
+		AND "p"."attributes" @> ?::jsonb`)
+		}
+		bounds := []struct {
+			op    string
+			value *float64
+		}{
+			{">=", f.Min},
+			{"<=", f.Max},
+		}
+		for _, bound := range bounds {
+			if bound.value == nil {
+				continue
+			}
+			b.values = append(b.values, f.Code, f.Code, *bound.value)
+
+			queryWhereStack = append(queryWhereStack, `
+		AND (CASE WHEN jsonb_typeof("p"."attributes" -> ?::text) = 'number' THEN ("p"."attributes" ->> ?::text)::NUMERIC END) `+bound.op+` ?::NUMERIC`)
+		}
+	}
+
 	// Search check
 	if b.req.Search != "" {
 		b.values = append(
@@ -118,13 +276,14 @@ func (b *findProductBuilder) whereQuery() {
 		AND (LOWER("p"."title") LIKE ? OR LOWER("p"."description") LIKE ?)`)
 	}
 
-	for i := range queryWhereStack {
-		if i != len(queryWhereStack)-1 {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
-		} else {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
-			queryWhere = strings.Replace(queryWhere, "?", "$"+strconv.Itoa(i+2), 1)
+	// เลข placeholder ต้องเรียงตามลำดับของ values
+	var index int
+	for _, q := range queryWhereStack {
+		for strings.Contains(q, "?") {
+			index++
+			q = strings.Replace(q, "?", "$"+strconv.Itoa(index), 1)
 		}
+		queryWhere += q
 	}
 	// Last stack record
 	b.lastStackIndex = len(b.values)
@@ -133,31 +292,28 @@ func (b *findProductBuilder) whereQuery() {
 	b.query += queryWhere
 }
 func (b *findProductBuilder) sort() {
+	// column ที่ใช้ sort มาจาก map เท่านั้นจึงต่อเข้า query ได้โดยตรง และใช้ id เป็นตัวตัดสินเพื่อให้แบ่งหน้าได้คงที่
 	orderByMap := map[string]string{
 		"id":    "\"p\".\"id\"",
 		"title": "\"p\".\"title\"",
 		"price": "\"p\".\"price\"",
 	}
-	if orderByMap[b.req.OrderBy] == "" {
-		b.req.OrderBy = orderByMap["title"]
-	} else {
-		b.req.OrderBy = orderByMap[b.req.OrderBy]
+	orderBy := orderByMap[b.req.OrderBy]
+	if orderBy == "" {
+		orderBy = orderByMap["title"]
 	}
 
 	sortMap := map[string]string{
 		"DESC": "DESC",
 		"ASC":  "ASC",
 	}
-	if sortMap[b.req.Sort] == "" {
-		b.req.Sort = sortMap["ASC"]
-	} else {
-		b.req.Sort = sortMap[strings.ToUpper(b.req.Sort)]
+	sort := sortMap[strings.ToUpper(b.req.Sort)]
+	if sort == "" {
+		sort = sortMap["ASC"]
 	}
 
-	b.values = append(b.values, b.req.OrderBy)
 	b.query += fmt.Sprintf(`
-		ORDER BY $%d %s`, b.lastStackIndex+1, b.req.Sort)
-	b.lastStackIndex = len(b.values)
+		ORDER BY %s %s, "p"."id" ASC`, orderBy, sort)
 }
 func (b *findProductBuilder) paginate() {
 	// offset (page - 1)*limit
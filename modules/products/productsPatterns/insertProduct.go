@@ -2,10 +2,12 @@ package productsPatterns
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -13,6 +15,7 @@ type IInsertProductBuidler interface {
 	initTransaction() error
 	insertProduct() error
 	insertCategory() error
+	insertComponents() error
 	insertAttachment() error
 	commit() error
 	getProductId() string
@@ -51,9 +54,25 @@ func (b *insertProductBuilder) insertProduct() error {
 	INSERT INTO "products" (
 		"title",
 		"description",
-		"price"
+		"price",
+		"sku",
+		"status",
+		"publish_at",
+		"unpublish_at",
+		"compare_at_price",
+		"sale_price",
+		"sale_starts_at",
+		"sale_ends_at",
+		"attributes",
+		"type",
+		"bundle_pricing",
+		"bundle_discount",
+		"stock",
+		"digital_file_id",
+		"download_limit",
+		"uses_license_keys"
 	)
-	VALUES ($1, $2, $3)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, (NULLIF($6, ''))::TIMESTAMPTZ, (NULLIF($7, ''))::TIMESTAMPTZ, NULLIF($8::NUMERIC, 0), NULLIF($9::NUMERIC, 0), (NULLIF($10, ''))::TIMESTAMPTZ, (NULLIF($11, ''))::TIMESTAMPTZ, $12::jsonb, $13, (NULLIF($14, ''))::bundle_pricing, $15::NUMERIC, $16::INT, (NULLIF($17, ''))::uuid, $18::INT, $19::BOOLEAN)
 		RETURNING "id";`
 
 	if err := b.tx.QueryRowxContext(
@@ -62,6 +81,22 @@ func (b *insertProductBuilder) insertProduct() error {
 		b.req.Title,
 		b.req.Description,
 		b.req.Price,
+		b.req.Sku,
+		b.req.Status,
+		stringOrEmpty(b.req.PublishAt),
+		stringOrEmpty(b.req.UnpublishAt),
+		amountOrZero(b.req.CompareAtPrice),
+		amountOrZero(b.req.SalePrice),
+		stringOrEmpty(b.req.SaleStartsAt),
+		stringOrEmpty(b.req.SaleEndsAt),
+		attributesJson(b.req.Attributes),
+		b.req.Type,
+		b.req.BundlePricing,
+		amountOrZero(b.req.BundleDiscount),
+		b.req.Stock,
+		stringOrEmpty(b.req.DigitalFileId),
+		intOrDefault(b.req.DownloadLimit, products.DefaultDownloadLimit),
+		b.req.UsesLicenseKeys != nil && *b.req.UsesLicenseKeys,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert product failed: %v", err)
@@ -90,6 +125,13 @@ func (b *insertProductBuilder) insertCategory() error {
 	}
 	return nil
 }
+func (b *insertProductBuilder) insertComponents() error {
+	if err := insertBundleItems(b.tx, b.req.Id, b.req.Components); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
 func (b *insertProductBuilder) insertAttachment() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
@@ -155,6 +197,9 @@ func (en *insertProductEngineer) InsertProduct() (string, error) {
 	if err := en.builder.insertCategory(); err != nil {
 		return "", err
 	}
+	if err := en.builder.insertComponents(); err != nil {
+		return "", err
+	}
 	if err := en.builder.insertAttachment(); err != nil {
 		return "", err
 	}
@@ -163,3 +208,67 @@ func (en *insertProductEngineer) InsertProduct() (string, error) {
 	}
 	return en.builder.getProductId(), nil
 }
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func amountOrZero(a *money.Amount) money.Amount {
+	if a == nil {
+		return 0
+	}
+	return *a
+}
+
+func intOrDefault(i *int, def int) int {
+	if i == nil {
+		return def
+	}
+	return *i
+}
+
+// ค่าที่ผ่านการตรวจแล้วเป็น string, number หรือ bool จึง marshal ได้เสมอ
+func attributesJson(attributes map[string]any) string {
+	if len(attributes) == 0 {
+		return "{}"
+	}
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ใช้ทั้งตอนสร้างและแก้ไขสินค้าชุด
+func insertBundleItems(tx *sqlx.Tx, bundleId string, items []*products.BundleItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO "product_bundle_items" (
+		"bundle_id",
+		"product_id",
+		"qty"
+	)
+	VALUES`
+
+	values := make([]any, 0)
+	for i, item := range items {
+		values = append(values, bundleId, item.ProductId, item.Qty)
+
+		query += fmt.Sprintf(`
+		($%d, $%d, $%d)`, i*3+1, i*3+2, i*3+3)
+		if i != len(items)-1 {
+			query += ","
+		}
+	}
+
+	if _, err := tx.ExecContext(context.Background(), query+";", values...); err != nil {
+		return fmt.Errorf("insert bundle items failed: %v", err)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Doittikorn/go-e-commerce/modules/files"
 	"github.com/Doittikorn/go-e-commerce/modules/files/filesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -17,7 +18,16 @@ type IUpdateProductBuilder interface {
 	updateTitleQuery()
 	updateDescriptionQuery()
 	updatePriceQuery()
+	updateSkuQuery()
+	updateStatusQuery()
+	updateScheduleQuery()
+	updateSalePriceQuery()
+	updateAttributesQuery()
+	updateStockQuery()
+	updateBundleQuery()
+	updateDigitalQuery()
 	updateCategory() error
+	updateComponents() error
 	insertImages() error
 	getOldImages() []*entities.Image
 	deleteOldImages() error
@@ -95,6 +105,133 @@ func (b *updateProductBuilder) updatePriceQuery() {
 		"price" = $%d`, b.lastStackIndex))
 	}
 }
+func (b *updateProductBuilder) updateSkuQuery() {
+	if b.req.Sku != "" {
+		b.values = append(b.values, b.req.Sku)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"sku" = $%d`, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateStatusQuery() {
+	if b.req.Status != "" {
+		b.values = append(b.values, b.req.Status)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"status" = $%d`, b.lastStackIndex))
+	}
+}
+
+// nil คือไม่แก้ ส่วน "" คือยกเลิกเวลาที่ตั้งไว้
+func (b *updateProductBuilder) updateScheduleQuery() {
+	fields := []struct {
+		column string
+		value  *string
+	}{
+		{"publish_at", b.req.PublishAt},
+		{"unpublish_at", b.req.UnpublishAt},
+		{"sale_starts_at", b.req.SaleStartsAt},
+		{"sale_ends_at", b.req.SaleEndsAt},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		b.values = append(b.values, *f.value)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"%s" = (NULLIF($%d, ''))::TIMESTAMPTZ`, f.column, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateSalePriceQuery() {
+	// nil คือไม่แก้ ส่วน 0 คือยกเลิกราคาที่ตั้งไว้
+	fields := []struct {
+		column string
+		value  *money.Amount
+	}{
+		{"compare_at_price", b.req.CompareAtPrice},
+		{"sale_price", b.req.SalePrice},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		b.values = append(b.values, *f.value)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"%s" = NULLIF($%d::NUMERIC, 0)`, f.column, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateAttributesQuery() {
+	// usecase รวมค่าเดิมกับค่าที่ส่งมาแล้ว จึงแทนที่ทั้งหมด
+	if b.req.Attributes != nil {
+		b.values = append(b.values, attributesJson(b.req.Attributes))
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"attributes" = $%d::jsonb`, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateStockQuery() {
+	// ค่าติดลบคือเลิกนับ stock
+	if b.req.Stock == nil {
+		return
+	}
+	if *b.req.Stock < 0 {
+		b.queryFields = append(b.queryFields, `
+		"stock" = NULL`)
+		return
+	}
+	b.values = append(b.values, *b.req.Stock)
+	b.lastStackIndex = len(b.values)
+
+	b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"stock" = $%d`, b.lastStackIndex))
+}
+func (b *updateProductBuilder) updateBundleQuery() {
+	if b.req.BundlePricing != "" {
+		b.values = append(b.values, b.req.BundlePricing)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"bundle_pricing" = $%d::bundle_pricing`, b.lastStackIndex))
+	}
+	if b.req.BundleDiscount != nil {
+		b.values = append(b.values, *b.req.BundleDiscount)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"bundle_discount" = $%d`, b.lastStackIndex))
+	}
+}
+func (b *updateProductBuilder) updateDigitalQuery() {
+	// "" คือเลิกส่งไฟล์
+	if b.req.DigitalFileId != nil {
+		b.values = append(b.values, *b.req.DigitalFileId)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"digital_file_id" = (NULLIF($%d, ''))::uuid`, b.lastStackIndex))
+	}
+	if b.req.DownloadLimit != nil {
+		b.values = append(b.values, *b.req.DownloadLimit)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"download_limit" = $%d`, b.lastStackIndex))
+	}
+	if b.req.UsesLicenseKeys != nil {
+		b.values = append(b.values, *b.req.UsesLicenseKeys)
+		b.lastStackIndex = len(b.values)
+
+		b.queryFields = append(b.queryFields, fmt.Sprintf(`
+		"uses_license_keys" = $%d`, b.lastStackIndex))
+	}
+}
 func (b *updateProductBuilder) updateCategory() error {
 	if b.req.Category == nil {
 		return nil
@@ -119,6 +256,22 @@ func (b *updateProductBuilder) updateCategory() error {
 	}
 	return nil
 }
+func (b *updateProductBuilder) updateComponents() error {
+	// nil คือไม่แก้สินค้าในชุด
+	if b.req.Components == nil {
+		return nil
+	}
+
+	if _, err := b.tx.ExecContext(context.Background(), `DELETE FROM "product_bundle_items" WHERE "bundle_id" = $1;`, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("delete bundle items failed: %v", err)
+	}
+	if err := insertBundleItems(b.tx, b.req.Id, b.req.Components); err != nil {
+		b.tx.Rollback()
+		return err
+	}
+	return nil
+}
 func (b *updateProductBuilder) insertImages() error {
 	query := `
 	INSERT INTO "images" (
@@ -189,7 +342,7 @@ func (b *updateProductBuilder) deleteOldImages() error {
 				Destination: fmt.Sprintf("images/products/%s", img.FileName),
 			})
 		}
-		b.filesUsecases.DeleteFileOnGCP(deleteFileReq)
+		b.filesUsecases.DeleteFiles(deleteFileReq)
 	}
 
 	if _, err := b.tx.ExecContext(
@@ -207,7 +360,8 @@ func (b *updateProductBuilder) closeQuery() {
 	b.lastStackIndex = len(b.values)
 
 	b.query += fmt.Sprintf(`
-	WHERE "id" = $%d`, b.lastStackIndex)
+	WHERE "id" = $%d
+	AND "deleted_at" IS NULL`, b.lastStackIndex)
 }
 func (b *updateProductBuilder) updateProduct() error {
 	if _, err := b.tx.ExecContext(context.Background(), b.query, b.values...); err != nil {
@@ -236,8 +390,21 @@ func (en *updateProductEngineer) sumQueryFields() {
 	en.builder.updateTitleQuery()
 	en.builder.updateDescriptionQuery()
 	en.builder.updatePriceQuery()
+	en.builder.updateSkuQuery()
+	en.builder.updateStatusQuery()
+	en.builder.updateScheduleQuery()
+	en.builder.updateSalePriceQuery()
+	en.builder.updateAttributesQuery()
+	en.builder.updateStockQuery()
+	en.builder.updateBundleQuery()
+	en.builder.updateDigitalQuery()
 
 	fields := en.builder.getQueryFields()
+	// แก้เฉพาะหมวดหมู่ รูป หรือสินค้าในชุด ยังต้องมี field ให้ query ถูกต้อง
+	if len(fields) == 0 {
+		fields = []string{`
+		"updated_at" = now()`}
+	}
 
 	for i := range fields {
 		query := en.builder.getQuery()
@@ -268,6 +435,11 @@ func (en *updateProductEngineer) UpdateProduct() error {
 		return err
 	}
 
+	// Update bundle items
+	if err := en.builder.updateComponents(); err != nil {
+		return err
+	}
+
 	if en.builder.getImagesLen() > 0 {
 		if err := en.builder.deleteOldImages(); err != nil {
 			return err
@@ -0,0 +1,75 @@
+package productsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+)
+
+// ใช้เฉพาะไฟล์ต้นฉบับ ไม่ใช่รูปย่อที่สร้างจากไฟล์
+func (r *productsRepository) FindFileVisibility(fileId string) (string, error) {
+	query := `
+	SELECT
+		"visibility"
+	FROM "files"
+	WHERE "id"::text = $1
+	AND "parent_id" IS NULL;`
+
+	var visibility string
+	if err := r.db.Get(&visibility, query, fileId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("digital file not found")
+		}
+		return "", fmt.Errorf("get digital file failed: %v", err)
+	}
+	return visibility, nil
+}
+
+func (r *productsRepository) FindLicenseKeySummary(productId string) (*products.LicenseKeySummary, error) {
+	query := `
+	SELECT
+		COUNT(*) AS "total",
+		COUNT(*) FILTER (WHERE "products_order_id" IS NULL) AS "available"
+	FROM "product_license_keys"
+	WHERE "product_id" = $1;`
+
+	summary := &products.LicenseKeySummary{ProductId: productId}
+	if err := r.db.QueryRowx(query, productId).Scan(&summary.Total, &summary.Available); err != nil {
+		return nil, fmt.Errorf("get license keys failed: %v", err)
+	}
+	return summary, nil
+}
+
+// เพิ่ม key ทั้งหมดใน query เดียว key ที่มีอยู่แล้วจะถูกข้าม
+func (r *productsRepository) InsertLicenseKeys(productId string, keys []string) (*products.LicenseKeySummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	query := `
+	INSERT INTO "product_license_keys" (
+		"product_id",
+		"key"
+	)
+	SELECT
+		$1,
+		"k"
+	FROM unnest($2::text[]) AS "k"
+	ON CONFLICT ("product_id", "key") DO NOTHING;`
+
+	result, err := r.db.ExecContext(ctx, query, productId, keys)
+	if err != nil {
+		return nil, fmt.Errorf("insert license keys failed: %v", err)
+	}
+
+	summary, err := r.FindLicenseKeySummary(productId)
+	if err != nil {
+		return nil, err
+	}
+	added, _ := result.RowsAffected()
+	summary.Added = int(added)
+	return summary, nil
+}
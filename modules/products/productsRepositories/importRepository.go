@@ -0,0 +1,237 @@
+package productsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/modules/products/productsPatterns"
+)
+
+func (r *productsRepository) FindCategoryIds() (map[int]bool, error) {
+	ids := make([]int, 0)
+	if err := r.db.Select(&ids, `SELECT "id" FROM "categories" WHERE "deleted_at" IS NULL;`); err != nil {
+		return nil, fmt.Errorf("get categories failed: %v", err)
+	}
+
+	result := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// insert หรือ update สินค้าตาม sku คืน true ถ้าเป็นสินค้าใหม่
+// รูปเดิมที่ถูกแทนที่จะไม่ถูกลบทันที แต่จะถูกลบโดย orphan sweeper ของ files
+// sku ของสินค้าที่อยู่ในถังขยะจะถูกกู้คืนพร้อมข้อมูลใหม่
+func (r *productsRepository) UpsertProduct(row *products.ImportRow) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+	INSERT INTO "products" (
+		"sku",
+		"title",
+		"description",
+		"price",
+		"status"
+	)
+	VALUES ($1, $2, $3, $4, COALESCE((NULLIF($5, ''))::product_status, 'draft'))
+	ON CONFLICT ("sku") DO UPDATE SET
+		"title" = EXCLUDED."title",
+		"description" = EXCLUDED."description",
+		"price" = EXCLUDED."price",
+		"status" = COALESCE((NULLIF($5, ''))::product_status, "products"."status"),
+		"deleted_at" = NULL
+	RETURNING "id", (xmax = 0) AS "inserted";`
+
+	var productId string
+	var inserted bool
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		row.Sku,
+		row.Title,
+		row.Description,
+		row.Price,
+		row.Status,
+	).Scan(&productId, &inserted); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("upsert product failed: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "products_categories" WHERE "product_id" = $1;`, productId); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("delete products_categories failed: %v", err)
+	}
+	queryCategory := `
+	INSERT INTO "products_categories" (
+		"product_id",
+		"category_id"
+	)
+	VALUES ($1, $2);`
+
+	if _, err := tx.ExecContext(ctx, queryCategory, productId, row.CategoryId); err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("insert products_categories failed: %v", err)
+	}
+
+	if len(row.Images) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "images" WHERE "product_id" = $1;`, productId); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("delete images failed: %v", err)
+		}
+
+		queryImage := `
+		INSERT INTO "images" (
+			"filename",
+			"url",
+			"product_id"
+		)
+		VALUES ($1, $2, $3);`
+
+		for _, img := range row.Images {
+			if _, err := tx.ExecContext(ctx, queryImage, img.FileName, img.Url, productId); err != nil {
+				tx.Rollback()
+				return false, fmt.Errorf("insert images failed: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+func (r *productsRepository) InsertImportJob(job *products.ImportJob) error {
+	query := `
+	INSERT INTO "product_import_jobs" (
+		"owner_id",
+		"filename",
+		"file_id"
+	)
+	VALUES ($1, $2, NULLIF($3, '')::uuid)
+	RETURNING "id", "status", "created_at", "updated_at";`
+
+	if err := r.db.QueryRowx(query, job.OwnerId, job.FileName, job.FileId).Scan(
+		&job.Id,
+		&job.Status,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("insert import job failed: %v", err)
+	}
+	return nil
+}
+
+// งานที่จบแล้วจะถูกบันทึกเวลาที่จบไว้ด้วย
+func (r *productsRepository) UpdateImportJob(job *products.ImportJob) error {
+	errorsJson, err := json.Marshal(job.Errors)
+	if err != nil {
+		return fmt.Errorf("marshal import errors failed: %v", err)
+	}
+
+	query := `
+	UPDATE "product_import_jobs" SET
+		"status" = $2,
+		"total_rows" = $3,
+		"processed_rows" = $4,
+		"created_count" = $5,
+		"updated_count" = $6,
+		"failed_count" = $7,
+		"errors" = $8::jsonb,
+		"message" = $9,
+		"finished_at" = CASE WHEN $2 IN ('completed', 'failed') THEN now() ELSE NULL END
+	WHERE "id" = $1;`
+
+	if _, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		job.Id,
+		job.Status,
+		job.TotalRows,
+		job.ProcessedRows,
+		job.CreatedCount,
+		job.UpdatedCount,
+		job.FailedCount,
+		string(errorsJson),
+		job.Message,
+	); err != nil {
+		return fmt.Errorf("update import job failed: %v", err)
+	}
+	return nil
+}
+
+func (r *productsRepository) FindOneImportJob(jobId string) (*products.ImportJob, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"j"."id",
+			"j"."owner_id",
+			"j"."filename",
+			"j"."file_id",
+			"j"."status",
+			"j"."total_rows",
+			"j"."processed_rows",
+			"j"."created_count",
+			"j"."updated_count",
+			"j"."failed_count",
+			"j"."errors",
+			"j"."message",
+			"j"."finished_at",
+			"j"."created_at",
+			"j"."updated_at"
+		FROM "product_import_jobs" "j"
+		WHERE "j"."id"::text = $1
+	) AS "t";`
+
+	jobBytes := make([]byte, 0)
+	if err := r.db.Get(&jobBytes, query, jobId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("import job not found")
+		}
+		return nil, fmt.Errorf("get import job failed: %v", err)
+	}
+
+	job := new(products.ImportJob)
+	if err := json.Unmarshal(jobBytes, job); err != nil {
+		return nil, fmt.Errorf("unmarshal import job failed: %v", err)
+	}
+	return job, nil
+}
+
+// อ่านสินค้าทีละหน้าด้วย filter เดียวกับ FindProduct จนครบทุกหน้า
+func (r *productsRepository) EachProduct(req *products.ProductFilter, fn func([]*products.Product) error) error {
+	filter := *req
+	for page := 1; ; page++ {
+		filter.PaginationReq = &entities.PaginationReq{
+			Page:  page,
+			Limit: products.ExportPageSize,
+		}
+
+		builder := productsPatterns.FindProductBuilder(r.db, &filter)
+		result := productsPatterns.FindProductEngineer(builder).FindProduct().Result()
+		if len(result) == 0 {
+			return nil
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+		if len(result) < products.ExportPageSize {
+			return nil
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/files/filesUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/products"
@@ -15,10 +17,23 @@ import (
 
 type IProductsRepository interface {
 	FindOneProduct(productId string) (*products.Product, error)
+	FindOnePublishedProduct(productId string) (*products.Product, error)
 	FindProduct(req *products.ProductFilter) ([]*products.Product, int)
 	InsertProduct(req *products.Product) (*products.Product, error)
 	DeleteProduct(productId string) error
 	UpdateProduct(req *products.Product) (*products.Product, error)
+	FindCategoryIds() (map[int]bool, error)
+	UpsertProduct(row *products.ImportRow) (bool, error)
+	InsertImportJob(job *products.ImportJob) error
+	UpdateImportJob(job *products.ImportJob) error
+	FindOneImportJob(jobId string) (*products.ImportJob, error)
+	EachProduct(req *products.ProductFilter, fn func([]*products.Product) error) error
+	PublishScheduledProducts() (int, int, error)
+	FindPriceHistory(productId string) ([]*products.PriceHistory, error)
+	FindCategoryAttributes(categoryId int) ([]*appinfo.CategoryAttribute, error)
+	FindFileVisibility(fileId string) (string, error)
+	FindLicenseKeySummary(productId string) (*products.LicenseKeySummary, error)
+	InsertLicenseKeys(productId string, keys []string) (*products.LicenseKeySummary, error)
 }
 
 type productsRepository struct {
@@ -36,15 +51,44 @@ func ProductsRepository(db *sqlx.DB, cfg config.ConfigImpl, filesUsecase filesUs
 }
 
 func (r *productsRepository) FindOneProduct(productId string) (*products.Product, error) {
+	return r.findOneProduct(productId, false)
+}
+
+// สินค้าที่ลูกค้าเห็นได้ ใช้กับ endpoint สาธารณะและตอนสั่งซื้อ
+func (r *productsRepository) FindOnePublishedProduct(productId string) (*products.Product, error) {
+	return r.findOneProduct(productId, true)
+}
+
+func (r *productsRepository) findOneProduct(productId string, publishedOnly bool) (*products.Product, error) {
+	var condition string
+	if publishedOnly {
+		condition = productsPatterns.PublishedCondition
+	}
+
 	query := `
 	SELECT
 		to_jsonb("t")
 	FROM (
 		SELECT
 			"p"."id",
+			COALESCE("p"."sku", '') AS "sku",
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."compare_at_price",
+			"p"."sale_price",
+			"p"."sale_starts_at",
+			"p"."sale_ends_at",` + productsPatterns.EffectivePriceColumn + `
+			"p"."status",
+			"p"."type",
+			COALESCE("p"."bundle_pricing"::text, '') AS "bundle_pricing",
+			"p"."bundle_discount",
+			"p"."digital_file_id",
+			"p"."download_limit",
+			"p"."uses_license_keys",` + productsPatterns.StockColumn + productsPatterns.ComponentsColumn + `
+			"p"."publish_at",
+			"p"."unpublish_at",
+			"p"."attributes",
 			(
 				SELECT
 					to_jsonb("ct")
@@ -55,6 +99,7 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 					FROM "categories" "c"
 						LEFT JOIN "products_categories" "pc" ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					AND "c"."deleted_at" IS NULL
 				) AS "ct"
 			) AS "category",
 			"p"."created_at",
@@ -66,13 +111,29 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 					SELECT
 						"i"."id",
 						"i"."filename",
-						"i"."url"
+						"i"."url",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("rt" ORDER BY "rt"."width", "rt"."content_type")), '[]'::json)
+							FROM (
+								SELECT
+									"fr"."variant" AS "name",
+									"fr"."content_type",
+									"fr"."width",
+									"fr"."height",
+									"fr"."url"
+								FROM "files" "fo"
+									JOIN "files" "fr" ON "fr"."parent_id" = "fo"."id"
+								WHERE "fo"."url" = "i"."url"
+							) AS "rt"
+						) AS "renditions"
 					FROM "images" "i"
 					WHERE "i"."product_id" = "p"."id"
 				) AS "it"
 			) AS "images"
 		FROM "products" "p"
 		WHERE "p"."id" = $1
+		AND "p"."deleted_at" IS NULL` + condition + `
 		LIMIT 1
 	) AS "t";`
 
@@ -115,12 +176,21 @@ func (r *productsRepository) InsertProduct(req *products.Product) (*products.Pro
 	return product, nil
 }
 
+// ย้ายสินค้าไปถังขยะ รูปและหมวดหมู่ยังถูกเก็บไว้จนกว่าจะถูกลบถาวร
 func (r *productsRepository) DeleteProduct(productId string) error {
-	query := `DELETE FROM "products" WHERE "id" = $1;`
+	query := `
+	UPDATE "products" SET
+		"deleted_at" = now()
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
 
-	if _, err := r.db.ExecContext(context.Background(), query, productId); err != nil {
+	result, err := r.db.ExecContext(context.Background(), query, productId)
+	if err != nil {
 		return fmt.Errorf("delete product failed: %v", err)
 	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("product not found")
+	}
 	return nil
 }
 
@@ -138,3 +208,107 @@ func (r *productsRepository) UpdateProduct(req *products.Product) (*products.Pro
 	}
 	return product, nil
 }
+
+// เปลี่ยนสถานะตามเวลาที่ตั้งไว้ และล้างเวลาที่ใช้ไปแล้วเพื่อไม่ให้ถูกเปลี่ยนซ้ำเมื่อ admin แก้สถานะเอง
+// ซ่อนก่อนแสดง เพื่อให้สินค้าที่เลยทั้งสองเวลาไปแล้วจบที่ archived คืนจำนวนที่แสดงและจำนวนที่ซ่อน
+func (r *productsRepository) PublishScheduledProducts() (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	archiveQuery := `
+	UPDATE "products" SET
+		"status" = 'archived',
+		"publish_at" = NULL,
+		"unpublish_at" = NULL
+	WHERE "deleted_at" IS NULL
+	AND "unpublish_at" <= now()
+	AND ("status" = 'published' OR ("status" = 'draft' AND "publish_at" IS NOT NULL));`
+
+	archived, err := tx.ExecContext(ctx, archiveQuery)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("archive scheduled products failed: %v", err)
+	}
+
+	publishQuery := `
+	UPDATE "products" SET
+		"status" = 'published',
+		"publish_at" = NULL
+	WHERE "deleted_at" IS NULL
+	AND "status" = 'draft'
+	AND "publish_at" <= now();`
+
+	published, err := tx.ExecContext(ctx, publishQuery)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("publish scheduled products failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	publishedRows, _ := published.RowsAffected()
+	archivedRows, _ := archived.RowsAffected()
+	return int(publishedRows), int(archivedRows), nil
+}
+
+func (r *productsRepository) FindPriceHistory(productId string) ([]*products.PriceHistory, error) {
+	query := `
+	SELECT
+		"id",
+		"product_id",
+		"price",
+		"compare_at_price",
+		"sale_price",
+		"sale_starts_at",
+		"sale_ends_at",
+		"created_at"
+	FROM "product_prices"
+	WHERE "product_id" = $1
+	ORDER BY "created_at" DESC;`
+
+	history := make([]*products.PriceHistory, 0)
+	if err := r.db.Select(&history, query, productId); err != nil {
+		return nil, fmt.Errorf("get price history failed: %v", err)
+	}
+	return history, nil
+}
+
+// categoryId เป็น 0 คือทุกหมวดหมู่ ใช้ตอนกรองโดยไม่ระบุหมวดหมู่
+func (r *productsRepository) FindCategoryAttributes(categoryId int) ([]*appinfo.CategoryAttribute, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t" ORDER BY "t"."category_id", "t"."id")), '[]'::json)
+	FROM (
+		SELECT
+			"a"."id",
+			"a"."category_id",
+			"a"."code",
+			"a"."name",
+			"a"."type",
+			"a"."options",
+			"a"."unit",
+			"a"."required",
+			"a"."filterable"
+		FROM "category_attributes" "a"
+			JOIN "categories" "c" ON "c"."id" = "a"."category_id"
+		WHERE ($1 = 0 OR "a"."category_id" = $1)
+		AND "c"."deleted_at" IS NULL
+	) AS "t";`
+
+	attributesBytes := make([]byte, 0)
+	if err := r.db.Get(&attributesBytes, query, categoryId); err != nil {
+		return nil, fmt.Errorf("get category attributes failed: %v", err)
+	}
+
+	attributes := make([]*appinfo.CategoryAttribute, 0)
+	if err := json.Unmarshal(attributesBytes, &attributes); err != nil {
+		return nil, fmt.Errorf("unmarshal category attributes failed: %v", err)
+	}
+	return attributes, nil
+}
@@ -0,0 +1,193 @@
+package productsUsecases
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/appinfo"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+)
+
+// ตรวจค่า attribute กับที่หมวดหมู่กำหนด แล้วเก็บค่าที่รวมแล้วไว้ที่ req.Attributes
+// ตอนแก้ไข old คือสินค้าเดิม ถ้าไม่ได้ส่ง attribute และไม่ได้เปลี่ยนหมวดหมู่จะไม่แก้ค่าเดิม
+// ค่าเดิมที่หมวดหมู่ใหม่ไม่ได้กำหนดจะถูกตัดออกเมื่อเปลี่ยนหมวดหมู่
+func (u *productsUsecase) applyAttributes(req, old *products.Product) error {
+	var categoryId int
+	if req.Category != nil {
+		categoryId = req.Category.Id
+	}
+
+	var categoryChanged bool
+	if old != nil {
+		categoryChanged = categoryId != 0 && (old.Category == nil || old.Category.Id != categoryId)
+		if req.Attributes == nil && !categoryChanged {
+			return nil
+		}
+		if !categoryChanged && old.Category != nil {
+			categoryId = old.Category.Id
+		}
+	}
+
+	definitions := make([]*appinfo.CategoryAttribute, 0)
+	if categoryId != 0 {
+		var err error
+		if definitions, err = u.productsRepository.FindCategoryAttributes(categoryId); err != nil {
+			return err
+		}
+	}
+	defined := make(map[string]*appinfo.CategoryAttribute, len(definitions))
+	for _, d := range definitions {
+		defined[d.Code] = d
+	}
+
+	attributes := make(map[string]any)
+	if old != nil {
+		for code, value := range old.Attributes {
+			if !categoryChanged || defined[code] != nil {
+				attributes[code] = value
+			}
+		}
+	}
+	for code, value := range req.Attributes {
+		if value == nil {
+			delete(attributes, code)
+			continue
+		}
+		attributes[code] = value
+	}
+
+	codes := make([]string, 0, len(attributes))
+	for code := range attributes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		d, ok := defined[code]
+		if !ok {
+			return fmt.Errorf("attribute %s is not defined for this category", code)
+		}
+		if err := checkAttributeValue(d, attributes[code]); err != nil {
+			return err
+		}
+	}
+	for _, d := range definitions {
+		if _, ok := attributes[d.Code]; d.Required && !ok {
+			return fmt.Errorf("attribute %s is required", d.Code)
+		}
+	}
+
+	req.Attributes = attributes
+	return nil
+}
+
+// ค่าจาก JSON ตัวเลขเป็น float64 เสมอ
+func checkAttributeValue(d *appinfo.CategoryAttribute, value any) error {
+	switch d.Type {
+	case appinfo.AttributeText:
+		if v, ok := value.(string); !ok || strings.TrimSpace(v) == "" {
+			return fmt.Errorf("attribute %s must be a text", d.Code)
+		}
+	case appinfo.AttributeNumber:
+		if v, ok := value.(float64); !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("attribute %s must be a number", d.Code)
+		}
+	case appinfo.AttributeEnum:
+		v, _ := value.(string)
+		for _, o := range d.Options {
+			if v == o {
+				return nil
+			}
+		}
+		return fmt.Errorf("attribute %s must be one of %s", d.Code, strings.Join(d.Options, ", "))
+	case appinfo.AttributeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("attribute %s must be true or false", d.Code)
+		}
+	}
+	return nil
+}
+
+// แปลงค่าของ filter ตามชนิดของ attribute ถ้าไม่ระบุหมวดหมู่ code เดียวกันในทุกหมวดหมู่ต้องเป็นชนิดเดียวกัน
+func (u *productsUsecase) ResolveAttributeFilters(req *products.ProductFilter) error {
+	if len(req.Attributes) == 0 {
+		return nil
+	}
+
+	definitions, err := u.productsRepository.FindCategoryAttributes(req.CategoryId)
+	if err != nil {
+		return err
+	}
+	// "" คือมีหลายชนิด
+	types := make(map[string]string)
+	for _, d := range definitions {
+		if !d.Filterable {
+			continue
+		}
+		if t, ok := types[d.Code]; ok && t != d.Type {
+			types[d.Code] = ""
+			continue
+		}
+		types[d.Code] = d.Type
+	}
+
+	for _, f := range req.Attributes {
+		t, ok := types[f.Code]
+		if !ok {
+			return fmt.Errorf("attribute %s is not filterable", f.Code)
+		}
+		if t == "" {
+			return fmt.Errorf("attribute %s needs category_id", f.Code)
+		}
+		if err := parseAttributeFilter(f, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseAttributeFilter(f *products.AttributeFilter, attributeType string) error {
+	raw := strings.TrimSpace(f.Raw)
+	invalid := fmt.Errorf("attribute %s filter is invalid", f.Code)
+	if raw == "" {
+		return invalid
+	}
+
+	switch attributeType {
+	case appinfo.AttributeNumber:
+		if !strings.Contains(raw, "..") {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return invalid
+			}
+			f.Value = v
+			return nil
+		}
+
+		bounds := strings.SplitN(raw, "..", 2)
+		for i, target := range []**float64{&f.Min, &f.Max} {
+			if bounds[i] == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(bounds[i], 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return invalid
+			}
+			*target = &v
+		}
+		if f.Min == nil && f.Max == nil {
+			return invalid
+		}
+	case appinfo.AttributeBoolean:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return invalid
+		}
+		f.Value = v
+	default:
+		f.Value = raw
+	}
+	return nil
+}
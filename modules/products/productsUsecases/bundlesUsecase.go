@@ -0,0 +1,94 @@
+package productsUsecases
+
+import (
+	"fmt"
+
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+)
+
+// ตรวจชนิดของสินค้าและข้อมูลของสินค้าชุด ตอนแก้ไข old คือสินค้าเดิม ชนิดของสินค้าเปลี่ยนไม่ได้
+func (u *productsUsecase) validateBundle(req, old *products.Product) error {
+	productType := req.Type
+	if old != nil {
+		if productType != "" && productType != old.Type {
+			return fmt.Errorf("product type cannot be changed")
+		}
+		productType = old.Type
+	}
+	if productType == "" {
+		productType = products.TypeSimple
+	}
+	if !products.IsType(productType) {
+		return fmt.Errorf("product type is invalid")
+	}
+	req.Type = productType
+
+	if productType != products.TypeBundle {
+		if len(req.Components) > 0 || req.BundlePricing != "" || req.BundleDiscount != nil {
+			return fmt.Errorf("bundle fields are only for bundle products")
+		}
+		req.Components = nil
+		// สินค้าใหม่ไม่ต้องเลิกนับ stock
+		if productType == products.TypeSimple && old == nil && req.Stock != nil && *req.Stock < 0 {
+			req.Stock = nil
+		}
+		return nil
+	}
+
+	if req.Stock != nil {
+		return fmt.Errorf("stock of bundle is derived from components")
+	}
+
+	pricing, price := req.BundlePricing, req.Price
+	if old != nil {
+		if pricing == "" {
+			pricing = old.BundlePricing
+		}
+		if price == 0 {
+			price = old.Price
+		}
+	}
+	switch pricing {
+	case products.BundlePricingFixed:
+		if price <= 0 {
+			return fmt.Errorf("price of fixed bundle must be more than 0")
+		}
+	case products.BundlePricingSumDiscount:
+	default:
+		return fmt.Errorf("bundle_pricing is invalid")
+	}
+	if req.BundleDiscount != nil && *req.BundleDiscount < 0 {
+		return fmt.Errorf("bundle_discount is invalid")
+	}
+
+	// ตอนแก้ไข nil คือไม่แก้สินค้าในชุด
+	if old != nil && req.Components == nil {
+		return nil
+	}
+	if len(req.Components) == 0 {
+		return fmt.Errorf("bundle must have components")
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range req.Components {
+		if item == nil || item.ProductId == "" {
+			return fmt.Errorf("component product_id is required")
+		}
+		if item.Qty <= 0 {
+			return fmt.Errorf("component %s qty must be more than 0", item.ProductId)
+		}
+		if seen[item.ProductId] || item.ProductId == req.Id {
+			return fmt.Errorf("component %s is duplicated", item.ProductId)
+		}
+		seen[item.ProductId] = true
+
+		component, err := u.productsRepository.FindOneProduct(item.ProductId)
+		if err != nil {
+			return fmt.Errorf("component %s not found", item.ProductId)
+		}
+		if component.Type != products.TypeSimple {
+			return fmt.Errorf("component %s must be a simple product", item.ProductId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package productsUsecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/files"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+)
+
+// จำนวน key ที่เพิ่มได้ต่อครั้ง
+const licenseKeysMaxBatch = 1000
+
+// ตรวจข้อมูลของสินค้าดิจิทัล ต้องมีไฟล์ private หรือใช้ license key อย่างน้อยหนึ่งอย่าง
+// ต้องเรียกหลัง validateBundle ซึ่งกำหนด req.Type แล้ว
+func (u *productsUsecase) validateDigital(req, old *products.Product) error {
+	if req.Type != products.TypeDigital {
+		if req.DigitalFileId != nil || req.DownloadLimit != nil || req.UsesLicenseKeys != nil {
+			return fmt.Errorf("digital fields are only for digital products")
+		}
+		return nil
+	}
+
+	if req.Stock != nil {
+		return fmt.Errorf("digital product has no stock")
+	}
+	if req.DownloadLimit != nil && *req.DownloadLimit < 0 {
+		return fmt.Errorf("download_limit is invalid")
+	}
+
+	hasFile := req.DigitalFileId != nil && *req.DigitalFileId != ""
+	usesKeys := req.UsesLicenseKeys != nil && *req.UsesLicenseKeys
+	if old != nil {
+		if req.DigitalFileId == nil {
+			hasFile = old.DigitalFileId != nil
+		}
+		if req.UsesLicenseKeys == nil {
+			usesKeys = old.UsesLicenseKeys != nil && *old.UsesLicenseKeys
+		}
+	}
+	if !hasFile && !usesKeys {
+		return fmt.Errorf("digital product must have a file or license keys")
+	}
+
+	// ไฟล์ public ใครก็โหลดได้จาก url จึงใช้ขายไม่ได้
+	if req.DigitalFileId != nil && *req.DigitalFileId != "" {
+		visibility, err := u.productsRepository.FindFileVisibility(*req.DigitalFileId)
+		if err != nil {
+			return err
+		}
+		if visibility != files.VisibilityPrivate {
+			return fmt.Errorf("digital file must be private")
+		}
+	}
+	return nil
+}
+
+func (u *productsUsecase) FindLicenseKeys(productId string) (*products.LicenseKeySummary, error) {
+	if err := u.findLicenseKeyProduct(productId); err != nil {
+		return nil, err
+	}
+	return u.productsRepository.FindLicenseKeySummary(productId)
+}
+
+// key ที่ซ้ำกับที่มีอยู่แล้วจะถูกข้าม Added คือจำนวนที่เพิ่มได้จริง
+func (u *productsUsecase) AddLicenseKeys(productId string, keys []string) (*products.LicenseKeySummary, error) {
+	if err := u.findLicenseKeyProduct(productId); err != nil {
+		return nil, err
+	}
+
+	cleaned := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			cleaned = append(cleaned, key)
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil, fmt.Errorf("license keys are required")
+	}
+	if len(cleaned) > licenseKeysMaxBatch {
+		return nil, fmt.Errorf("license keys must not be more than %d", licenseKeysMaxBatch)
+	}
+	return u.productsRepository.InsertLicenseKeys(productId, cleaned)
+}
+
+func (u *productsUsecase) findLicenseKeyProduct(productId string) error {
+	product, err := u.productsRepository.FindOneProduct(productId)
+	if err != nil {
+		return err
+	}
+	if product.UsesLicenseKeys == nil || !*product.UsesLicenseKeys {
+		return fmt.Errorf("product does not use license keys")
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+package productsUsecases
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/products"
+	"github.com/Doittikorn/go-e-commerce/pkg/money"
+	"github.com/Doittikorn/go-e-commerce/pkg/spreadsheet"
+)
+
+// column ที่ต้องมีในไฟล์ import
+var importRequiredColumns = []string{"sku", "title", "price", "category_id"}
+
+// คัดลอกไฟล์ไว้ที่ temp ก่อนตอบกลับ เพราะไฟล์ของ request จะถูกลบเมื่อ request จบ แล้วจึง import เบื้องหลัง
+func (u *productsUsecase) ImportProducts(ownerId, fileId, fileName string, r io.Reader) (*products.ImportJob, error) {
+	format := strings.ToLower(strings.TrimPrefix(path.Ext(fileName), "."))
+	if format != spreadsheet.FormatCSV && format != spreadsheet.FormatXLSX {
+		return nil, fmt.Errorf("file type is not supported")
+	}
+
+	tmp, err := os.CreateTemp("", "product-import-*."+format)
+	if err != nil {
+		return nil, fmt.Errorf("create temp file failed: %v", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("read import file failed: %v", err)
+	}
+	tmp.Close()
+
+	job := &products.ImportJob{
+		OwnerId:  ownerId,
+		FileId:   fileId,
+		FileName: path.Base(fileName),
+		Errors:   make([]*products.ImportRowError, 0),
+	}
+	if err := u.productsRepository.InsertImportJob(job); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	go u.runImport(job, format, tmp.Name())
+	return job, nil
+}
+
+func (u *productsUsecase) FindOneImportJob(jobId string) (*products.ImportJob, error) {
+	return u.productsRepository.FindOneImportJob(jobId)
+}
+
+func (u *productsUsecase) runImport(job *products.ImportJob, format, filePath string) {
+	defer os.Remove(filePath)
+	defer func() {
+		if r := recover(); r != nil {
+			u.failImport(job, fmt.Sprintf("import panic: %v", r))
+		}
+	}()
+
+	job.Status = products.ImportRunning
+	u.saveImport(job)
+
+	rows, err := u.readImportFile(job, format, filePath)
+	if err != nil {
+		u.failImport(job, err.Error())
+		return
+	}
+	u.saveImport(job)
+
+	for i, row := range rows {
+		inserted, err := u.productsRepository.UpsertProduct(row)
+		switch {
+		case err != nil:
+			job.FailedCount++
+			addImportError(job, &products.ImportRowError{Row: row.Row, Sku: row.Sku, Message: err.Error()})
+		case inserted:
+			job.CreatedCount++
+		default:
+			job.UpdatedCount++
+		}
+		job.ProcessedRows++
+
+		if (i+1)%products.ImportProgressEvery == 0 {
+			u.saveImport(job)
+		}
+	}
+
+	job.Status = products.ImportCompleted
+	job.Message = fmt.Sprintf("created %d, updated %d, failed %d", job.CreatedCount, job.UpdatedCount, job.FailedCount)
+	u.saveImport(job)
+}
+
+// อ่านและตรวจทุกแถวก่อนเริ่มบันทึก แถวที่ไม่ผ่านจะถูกนับเป็น failed และไม่ถูกบันทึก
+func (u *productsUsecase) readImportFile(job *products.ImportJob, format, filePath string) ([]*products.ImportRow, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open import file failed: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("open import file failed: %v", err)
+	}
+	reader, err := spreadsheet.NewReader(format, file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := readImportHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := u.productsRepository.FindCategoryIds()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*products.ImportRow, 0)
+	skuRows := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read import file failed at row %d: %v", reader.Line()+1, err)
+		}
+		if isBlankRow(record) {
+			continue
+		}
+
+		job.TotalRows++
+		if job.TotalRows > products.ImportMaxRows {
+			return nil, fmt.Errorf("import file must not have more than %d rows", products.ImportMaxRows)
+		}
+
+		row, rowErrors := parseImportRow(record, reader.Line(), columns, categories)
+		if row != nil {
+			if first, ok := skuRows[row.Sku]; ok {
+				rowErrors = append(rowErrors, &products.ImportRowError{
+					Row:     row.Row,
+					Sku:     row.Sku,
+					Field:   "sku",
+					Message: fmt.Sprintf("sku is duplicated with row %d", first),
+				})
+			} else {
+				skuRows[row.Sku] = row.Row
+			}
+		}
+		if len(rowErrors) > 0 {
+			job.FailedCount++
+			job.ProcessedRows++
+			for _, e := range rowErrors {
+				addImportError(job, e)
+			}
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readImportHeader(reader spreadsheet.ReaderImpl) (map[string]int, error) {
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("import file is empty")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read import file failed: %v", err)
+		}
+		if isBlankRow(record) {
+			continue
+		}
+
+		columns := make(map[string]int)
+		for i, name := range record {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, name := range importRequiredColumns {
+			if _, ok := columns[name]; !ok {
+				return nil, fmt.Errorf("import file must have column %s", name)
+			}
+		}
+		return columns, nil
+	}
+}
+
+func isBlankRow(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// images คั่นหลาย url ด้วย | ส่วน status ไม่บังคับ
+func parseImportRow(record []string, line int, columns map[string]int, categories map[int]bool) (*products.ImportRow, []*products.ImportRowError) {
+	get := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(spreadsheet.UnescapeFormula(record[i]))
+	}
+
+	row := &products.ImportRow{
+		Row:         line,
+		Sku:         get("sku"),
+		Title:       get("title"),
+		Description: get("description"),
+		Status:      strings.ToLower(get("status")),
+		Images:      make([]*entities.Image, 0),
+	}
+	rowErrors := make([]*products.ImportRowError, 0)
+	fail := func(field, msg string) {
+		rowErrors = append(rowErrors, &products.ImportRowError{
+			Row:     line,
+			Sku:     row.Sku,
+			Field:   field,
+			Message: msg,
+		})
+	}
+
+	if row.Sku == "" {
+		fail("sku", "sku is required")
+	}
+	if row.Title == "" {
+		fail("title", "title is required")
+	}
+
+	price, err := money.Parse(get("price"))
+	if err != nil || price <= 0 {
+		fail("price", fmt.Sprintf("price must be a number greater than 0 with at most %d decimals", money.Scale))
+	}
+	row.Price = price
+
+	if row.Status != "" && !products.IsStatus(row.Status) {
+		fail("status", fmt.Sprintf("status %q is invalid", row.Status))
+	}
+
+	categoryId, err := strconv.Atoi(get("category_id"))
+	if err != nil || !categories[categoryId] {
+		fail("category_id", fmt.Sprintf("category %q does not exist", get("category_id")))
+	}
+	row.CategoryId = categoryId
+
+	for _, raw := range strings.Split(get("images"), "|") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+			fail("images", fmt.Sprintf("image url %q is invalid", raw))
+			continue
+		}
+		row.Images = append(row.Images, &entities.Image{
+			FileName: path.Base(u.Path),
+			Url:      raw,
+		})
+	}
+
+	if len(rowErrors) > 0 {
+		if row.Sku == "" {
+			return nil, rowErrors
+		}
+		return row, rowErrors
+	}
+	return row, nil
+}
+
+func addImportError(job *products.ImportJob, e *products.ImportRowError) {
+	if len(job.Errors) < products.ImportMaxErrors {
+		job.Errors = append(job.Errors, e)
+	}
+}
+
+func (u *productsUsecase) failImport(job *products.ImportJob, msg string) {
+	job.Status = products.ImportFailed
+	job.Message = msg
+	u.saveImport(job)
+}
+
+func (u *productsUsecase) saveImport(job *products.ImportJob) {
+	if err := u.productsRepository.UpdateImportJob(job); err != nil {
+		log.Printf("save import job: %s failed: %v\n", job.Id, err)
+	}
+}
+
+// stream สินค้าทุกหน้าตาม filter ลง w ทีละหน้า ถ้า w มี Flush จะถูกเรียกหลังเขียนแต่ละหน้า
+func (u *productsUsecase) ExportProducts(req *products.ProductFilter, format string, w io.Writer) error {
+	writer, err := spreadsheet.NewWriter(format, w)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(products.ExportColumns); err != nil {
+		return err
+	}
+
+	flusher, _ := w.(interface{ Flush() error })
+	if err := u.productsRepository.EachProduct(req, func(page []*products.Product) error {
+		for _, p := range page {
+			if err := writer.Write(exportRow(p)); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			return flusher.Flush()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func exportRow(p *products.Product) []string {
+	var categoryId, category string
+	if p.Category != nil {
+		categoryId = strconv.Itoa(p.Category.Id)
+		category = p.Category.Title
+	}
+	images := make([]string, 0, len(p.Images))
+	for _, img := range p.Images {
+		images = append(images, img.Url)
+	}
+
+	return []string{
+		spreadsheet.EscapeFormula(p.Sku),
+		spreadsheet.EscapeFormula(p.Title),
+		spreadsheet.EscapeFormula(p.Description),
+		p.Price.String(),
+		categoryId,
+		spreadsheet.EscapeFormula(category),
+		strings.Join(images, "|"),
+		p.Status,
+		p.Id,
+		p.CreatedAt,
+		p.UpdatedAt,
+	}
+}
@@ -1,8 +1,14 @@
 package productsUsecases
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"math"
+	"strings"
+	"time"
 
+	"github.com/Doittikorn/go-e-commerce/config"
 	"github.com/Doittikorn/go-e-commerce/modules/entities"
 	"github.com/Doittikorn/go-e-commerce/modules/products"
 	"github.com/Doittikorn/go-e-commerce/modules/products/productsRepositories"
@@ -10,18 +16,29 @@ import (
 
 type IProductsUsecase interface {
 	FindOneProduct(productId string) (*products.Product, error)
+	FindOnePublishedProduct(productId string) (*products.Product, error)
 	FindProduct(req *products.ProductFilter) *entities.PaginateRes
 	AddProduct(req *products.Product) (*products.Product, error)
 	DeleteProduct(productId string) error
 	UpdateProduct(req *products.Product) (*products.Product, error)
+	ImportProducts(ownerId, fileId, fileName string, r io.Reader) (*products.ImportJob, error)
+	FindOneImportJob(jobId string) (*products.ImportJob, error)
+	ExportProducts(req *products.ProductFilter, format string, w io.Writer) error
+	FindPriceHistory(productId string) ([]*products.PriceHistory, error)
+	ResolveAttributeFilters(req *products.ProductFilter) error
+	FindLicenseKeys(productId string) (*products.LicenseKeySummary, error)
+	AddLicenseKeys(productId string, keys []string) (*products.LicenseKeySummary, error)
+	RunScheduler()
 }
 
 type productsUsecase struct {
+	cfg                config.ConfigImpl
 	productsRepository productsRepositories.IProductsRepository
 }
 
-func ProductsUsecase(productsRepository productsRepositories.IProductsRepository) IProductsUsecase {
+func ProductsUsecase(cfg config.ConfigImpl, productsRepository productsRepositories.IProductsRepository) IProductsUsecase {
 	return &productsUsecase{
+		cfg:                cfg,
 		productsRepository: productsRepository,
 	}
 }
@@ -34,8 +51,15 @@ func (u *productsUsecase) FindOneProduct(productId string) (*products.Product, e
 	return product, nil
 }
 
+func (u *productsUsecase) FindOnePublishedProduct(productId string) (*products.Product, error) {
+	return u.productsRepository.FindOnePublishedProduct(productId)
+}
+
 func (u *productsUsecase) FindProduct(req *products.ProductFilter) *entities.PaginateRes {
 	products, count := u.productsRepository.FindProduct(req)
+	for _, p := range products {
+		p.SetDisplay(req.Conversion)
+	}
 
 	return &entities.PaginateRes{
 		Data:      products,
@@ -47,9 +71,28 @@ func (u *productsUsecase) FindProduct(req *products.ProductFilter) *entities.Pag
 }
 
 func (u *productsUsecase) AddProduct(req *products.Product) (*products.Product, error) {
+	if req.Status == "" {
+		req.Status = products.StatusDraft
+	}
+	if err := validateLifecycle(req); err != nil {
+		return nil, err
+	}
+	if err := validatePricing(req); err != nil {
+		return nil, err
+	}
+	if err := u.validateBundle(req, nil); err != nil {
+		return nil, err
+	}
+	if err := u.validateDigital(req, nil); err != nil {
+		return nil, err
+	}
+	if err := u.applyAttributes(req, nil); err != nil {
+		return nil, err
+	}
+
 	product, err := u.productsRepository.InsertProduct(req)
 	if err != nil {
-		return nil, err
+		return nil, scheduleError(err)
 	}
 	return product, nil
 }
@@ -62,9 +105,125 @@ func (u *productsUsecase) DeleteProduct(productId string) error {
 }
 
 func (u *productsUsecase) UpdateProduct(req *products.Product) (*products.Product, error) {
-	product, err := u.productsRepository.UpdateProduct(req)
+	// สินค้าที่อยู่ในถังขยะต้องกู้คืนก่อนจึงจะแก้ไขได้
+	old, err := u.productsRepository.FindOneProduct(req.Id)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateLifecycle(req); err != nil {
+		return nil, err
+	}
+	if err := validatePricing(req); err != nil {
+		return nil, err
+	}
+	if err := u.validateBundle(req, old); err != nil {
+		return nil, err
+	}
+	if err := u.validateDigital(req, old); err != nil {
+		return nil, err
+	}
+	if err := u.applyAttributes(req, old); err != nil {
+		return nil, err
+	}
+
+	product, err := u.productsRepository.UpdateProduct(req)
+	if err != nil {
+		return nil, scheduleError(err)
+	}
 	return product, nil
 }
+
+// ตรวจสถานะและเวลาที่ส่งมา ส่วนการเทียบกับเวลาเดิมของสินค้าใช้ constraint ของ database
+func validateLifecycle(req *products.Product) error {
+	if req.Status != "" && !products.IsStatus(req.Status) {
+		return fmt.Errorf("status is invalid")
+	}
+
+	var publishAt, unpublishAt time.Time
+	var err error
+	if req.PublishAt != nil && *req.PublishAt != "" {
+		if publishAt, err = time.Parse(time.RFC3339, *req.PublishAt); err != nil {
+			return fmt.Errorf("publish_at is invalid")
+		}
+	}
+	if req.UnpublishAt != nil && *req.UnpublishAt != "" {
+		if unpublishAt, err = time.Parse(time.RFC3339, *req.UnpublishAt); err != nil {
+			return fmt.Errorf("unpublish_at is invalid")
+		}
+	}
+	if !publishAt.IsZero() && !unpublishAt.IsZero() && !unpublishAt.After(publishAt) {
+		return fmt.Errorf("unpublish_at must be after publish_at")
+	}
+	return nil
+}
+
+// ราคาติดลบไม่ได้ ส่วน 0 คือไม่มีหรือยกเลิกราคานั้น
+func validatePricing(req *products.Product) error {
+	if req.CompareAtPrice != nil && *req.CompareAtPrice < 0 {
+		return fmt.Errorf("compare_at_price is invalid")
+	}
+	if req.SalePrice != nil && *req.SalePrice < 0 {
+		return fmt.Errorf("sale_price is invalid")
+	}
+
+	var startsAt, endsAt time.Time
+	var err error
+	if req.SaleStartsAt != nil && *req.SaleStartsAt != "" {
+		if startsAt, err = time.Parse(time.RFC3339, *req.SaleStartsAt); err != nil {
+			return fmt.Errorf("sale_starts_at is invalid")
+		}
+	}
+	if req.SaleEndsAt != nil && *req.SaleEndsAt != "" {
+		if endsAt, err = time.Parse(time.RFC3339, *req.SaleEndsAt); err != nil {
+			return fmt.Errorf("sale_ends_at is invalid")
+		}
+	}
+	if !startsAt.IsZero() && !endsAt.IsZero() && !endsAt.After(startsAt) {
+		return fmt.Errorf("sale_ends_at must be after sale_starts_at")
+	}
+	return nil
+}
+
+// แปลง error จาก constraint ของเวลาที่ตั้งไว้เป็นข้อความเดียวกับที่ตรวจใน usecase
+func scheduleError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), "products_schedule_check"):
+		return fmt.Errorf("unpublish_at must be after publish_at")
+	case strings.Contains(err.Error(), "products_sale_schedule_check"):
+		return fmt.Errorf("sale_ends_at must be after sale_starts_at")
+	case strings.Contains(err.Error(), "products_stock_check"):
+		return fmt.Errorf("stock is invalid")
+	case strings.Contains(err.Error(), "products_digital_file_id_fkey"):
+		return fmt.Errorf("digital file not found")
+	}
+	return err
+}
+
+// ประวัติราคาล่าสุดก่อน
+func (u *productsUsecase) FindPriceHistory(productId string) ([]*products.PriceHistory, error) {
+	if _, err := u.productsRepository.FindOneProduct(productId); err != nil {
+		return nil, err
+	}
+	return u.productsRepository.FindPriceHistory(productId)
+}
+
+func (u *productsUsecase) RunScheduler() {
+	interval := u.cfg.Product().ScheduleInterval()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		published, archived, err := u.productsRepository.PublishScheduledProducts()
+		if err != nil {
+			log.Printf("publish scheduled products failed: %v\n", err)
+			continue
+		}
+		if published > 0 || archived > 0 {
+			log.Printf("publish scheduled products: published %d, archived %d\n", published, archived)
+		}
+	}
+}
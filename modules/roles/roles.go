@@ -0,0 +1,18 @@
+package roles
+
+type Role struct {
+	Id          int      `db:"id" json:"id"`
+	Title       string   `db:"title" json:"title"`
+	Permissions []string `db:"permissions" json:"permissions"`
+}
+
+type Permission struct {
+	Id          int    `db:"id" json:"id"`
+	Code        string `db:"code" json:"code"`
+	Description string `db:"description" json:"description"`
+}
+
+type UserRoleReq struct {
+	UserId string `json:"user_id" form:"user_id"`
+	RoleId int    `json:"role_id" form:"role_id"`
+}
@@ -0,0 +1,190 @@
+package rolesHandlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/middlewares/middlewaresHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/roles"
+	"github.com/Doittikorn/go-e-commerce/modules/roles/rolesUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type rolesHandlersErrCode string
+
+const (
+	findRoleErr       rolesHandlersErrCode = "roles-001"
+	findPermissionErr rolesHandlersErrCode = "roles-002"
+	addRoleErr        rolesHandlersErrCode = "roles-003"
+	updateRoleErr     rolesHandlersErrCode = "roles-004"
+	deleteRoleErr     rolesHandlersErrCode = "roles-005"
+	updateUserRoleErr rolesHandlersErrCode = "roles-006"
+)
+
+type IRolesHandler interface {
+	FindRole(c *fiber.Ctx) error
+	FindPermission(c *fiber.Ctx) error
+	AddRole(c *fiber.Ctx) error
+	UpdateRole(c *fiber.Ctx) error
+	DeleteRole(c *fiber.Ctx) error
+	UpdateUserRole(c *fiber.Ctx) error
+}
+
+type rolesHandler struct {
+	cfg          config.ConfigImpl
+	rolesUsecase rolesUsecases.IRolesUsecase
+	mid          middlewaresHandlers.MiddlewaresHandlerImpl
+}
+
+func RolesHandler(cfg config.ConfigImpl, rolesUsecase rolesUsecases.IRolesUsecase, mid middlewaresHandlers.MiddlewaresHandlerImpl) IRolesHandler {
+	return &rolesHandler{
+		cfg:          cfg,
+		rolesUsecase: rolesUsecase,
+		mid:          mid,
+	}
+}
+
+func parseRoleId(c *fiber.Ctx) (int, bool) {
+	roleId, err := strconv.Atoi(strings.Trim(c.Params("role_id"), " "))
+	if err != nil || roleId <= 0 {
+		return 0, false
+	}
+	return roleId, true
+}
+
+func (h *rolesHandler) FindRole(c *fiber.Ctx) error {
+	result, err := h.rolesUsecase.FindRole()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findRoleErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *rolesHandler) FindPermission(c *fiber.Ctx) error {
+	result, err := h.rolesUsecase.FindPermission()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findPermissionErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *rolesHandler) AddRole(c *fiber.Ctx) error {
+	req := &roles.Role{
+		Permissions: make([]string, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addRoleErr),
+			err.Error(),
+		).Res()
+	}
+
+	role, err := h.rolesUsecase.AddRole(req)
+	if err != nil {
+		switch err.Error() {
+		case "title is required", "permission not found":
+			return entities.NewResponse(c).Error(fiber.ErrBadRequest.Code, string(addRoleErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(addRoleErr), err.Error()).Res()
+		}
+	}
+	h.mid.ClearPermissionsCache()
+	return entities.NewResponse(c).Success(fiber.StatusCreated, role).Res()
+}
+
+func (h *rolesHandler) UpdateRole(c *fiber.Ctx) error {
+	roleId, ok := parseRoleId(c)
+	if !ok {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateRoleErr),
+			"role id is invalid",
+		).Res()
+	}
+
+	req := new(roles.Role)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateRoleErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = roleId
+
+	role, err := h.rolesUsecase.UpdateRole(req)
+	if err != nil {
+		switch err.Error() {
+		case "built-in role cannot be renamed", "built-in admin permissions cannot be removed", "permission not found":
+			return entities.NewResponse(c).Error(fiber.ErrBadRequest.Code, string(updateRoleErr), err.Error()).Res()
+		case "role not found":
+			return entities.NewResponse(c).Error(fiber.ErrNotFound.Code, string(updateRoleErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(updateRoleErr), err.Error()).Res()
+		}
+	}
+	h.mid.ClearPermissionsCache()
+	return entities.NewResponse(c).Success(fiber.StatusOK, role).Res()
+}
+
+func (h *rolesHandler) DeleteRole(c *fiber.Ctx) error {
+	roleId, ok := parseRoleId(c)
+	if !ok {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(deleteRoleErr),
+			"role id is invalid",
+		).Res()
+	}
+
+	if err := h.rolesUsecase.DeleteRole(roleId); err != nil {
+		switch err.Error() {
+		case "built-in role cannot be deleted", "role is in use":
+			return entities.NewResponse(c).Error(fiber.ErrBadRequest.Code, string(deleteRoleErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(deleteRoleErr), err.Error()).Res()
+		}
+	}
+	h.mid.ClearPermissionsCache()
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			RoleId int `json:"role_id"`
+		}{
+			RoleId: roleId,
+		},
+	).Res()
+}
+
+func (h *rolesHandler) UpdateUserRole(c *fiber.Ctx) error {
+	req := new(roles.UserRoleReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserRoleErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+
+	if err := h.rolesUsecase.UpdateUserRole(req); err != nil {
+		switch err.Error() {
+		case "user not found", "role not found":
+			return entities.NewResponse(c).Error(fiber.ErrNotFound.Code, string(updateUserRoleErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(fiber.ErrInternalServerError.Code, string(updateUserRoleErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, req).Res()
+}
@@ -0,0 +1,247 @@
+package rolesRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/roles"
+	"github.com/jmoiron/sqlx"
+)
+
+type IRolesRepository interface {
+	FindRole() ([]*roles.Role, error)
+	FindOneRole(roleId int) (*roles.Role, error)
+	FindPermission() ([]*roles.Permission, error)
+	InsertRole(req *roles.Role) (int, error)
+	UpdateRole(req *roles.Role) error
+	DeleteRole(roleId int) error
+	UpdateUserRole(req *roles.UserRoleReq) error
+}
+
+type rolesRepository struct {
+	db *sqlx.DB
+}
+
+func RolesRepository(db *sqlx.DB) IRolesRepository {
+	return &rolesRepository{db: db}
+}
+
+const findRoleQuery = `
+		SELECT
+			"r"."id",
+			"r"."title",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("p"."code" ORDER BY "p"."code")), '[]'::json)
+				FROM "permissions" "p"
+					LEFT JOIN "role_permissions" "rp" ON "rp"."permission_id" = "p"."id"
+				WHERE "rp"."role_id" = "r"."id"
+			) AS "permissions"
+		FROM "roles" "r"`
+
+func (r *rolesRepository) FindRole() ([]*roles.Role, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (` + findRoleQuery + `
+		ORDER BY "r"."id"
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query); err != nil {
+		return nil, fmt.Errorf("get roles failed: %v", err)
+	}
+
+	rolesData := make([]*roles.Role, 0)
+	if err := json.Unmarshal(raw, &rolesData); err != nil {
+		return nil, fmt.Errorf("unmarshal roles failed: %v", err)
+	}
+	return rolesData, nil
+}
+
+func (r *rolesRepository) FindOneRole(roleId int) (*roles.Role, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (` + findRoleQuery + `
+		WHERE "r"."id" = $1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, roleId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("get role failed: %v", err)
+	}
+
+	role := new(roles.Role)
+	if err := json.Unmarshal(raw, role); err != nil {
+		return nil, fmt.Errorf("unmarshal role failed: %v", err)
+	}
+	return role, nil
+}
+
+func (r *rolesRepository) FindPermission() ([]*roles.Permission, error) {
+	query := `
+	SELECT
+		"id",
+		"code",
+		"description"
+	FROM "permissions"
+	ORDER BY "code";`
+
+	permissions := make([]*roles.Permission, 0)
+	if err := r.db.Select(&permissions, query); err != nil {
+		return nil, fmt.Errorf("select permissions failed: %v", err)
+	}
+	return permissions, nil
+}
+
+// แทนที่ permission ทั้งหมดของ role ด้วยรายการใหม่
+func (r *rolesRepository) replacePermissions(ctx context.Context, tx *sqlx.Tx, roleId int, permissions []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "role_permissions" WHERE "role_id" = $1;`, roleId); err != nil {
+		return fmt.Errorf("delete role_permissions failed: %v", err)
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO "role_permissions" (
+		"role_id",
+		"permission_id"
+	)
+	SELECT
+		$1,
+		"id"
+	FROM "permissions"
+	WHERE "code" = ANY($2);`
+
+	result, err := tx.ExecContext(ctx, query, roleId, permissions)
+	if err != nil {
+		return fmt.Errorf("insert role_permissions failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); int(rows) != len(permissions) {
+		return fmt.Errorf("permission not found")
+	}
+	return nil
+}
+
+func (r *rolesRepository) InsertRole(req *roles.Role) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO "roles" (
+		"title"
+	)
+	VALUES ($1)
+		RETURNING "id";`
+
+	if err := tx.QueryRowxContext(ctx, query, req.Title).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("insert role failed: %v", err)
+	}
+
+	if err := r.replacePermissions(ctx, tx, req.Id, req.Permissions); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return req.Id, nil
+}
+
+func (r *rolesRepository) UpdateRole(req *roles.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if req.Title != "" {
+		if _, err := tx.ExecContext(ctx, `UPDATE "roles" SET "title" = $1 WHERE "id" = $2;`, req.Title, req.Id); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("update role failed: %v", err)
+		}
+	}
+
+	// permissions เป็น nil แปลว่าไม่ต้องการแก้ permission
+	if req.Permissions != nil {
+		if err := r.replacePermissions(ctx, tx, req.Id, req.Permissions); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *rolesRepository) DeleteRole(roleId int) error {
+	ctx := context.Background()
+
+	// users.role_id เป็น ON DELETE CASCADE ถ้าลบ role ที่ยังมี user อยู่ user จะหายไปด้วย
+	var count int
+	if err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM "users" WHERE "role_id" = $1;`, roleId); err != nil {
+		return fmt.Errorf("count users failed: %v", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("role is in use")
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM "roles" WHERE "id" = $1;`, roleId); err != nil {
+		return fmt.Errorf("delete role failed: %v", err)
+	}
+	return nil
+}
+
+// role อยู่ใน claims ของ token จึงลบ token ของ user ทิ้ง ให้ sign in ใหม่แล้วได้ role ใหม่ทันที
+func (r *rolesRepository) UpdateUserRole(req *roles.UserRoleReq) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"role_id" = $1
+	WHERE "id" = $2;`
+
+	result, err := tx.ExecContext(ctx, query, req.RoleId, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update user role failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("user not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "oauth" WHERE "user_id" = $1;`, req.UserId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("revoke user tokens failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
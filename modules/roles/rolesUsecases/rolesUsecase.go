@@ -0,0 +1,123 @@
+package rolesUsecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/modules/roles"
+	"github.com/Doittikorn/go-e-commerce/modules/roles/rolesRepositories"
+)
+
+// role ที่ระบบสร้างไว้ตั้งแต่แรก ห้ามลบ
+var builtInRoles = map[int]string{
+	1: "customer",
+	2: "admin",
+}
+
+// permission ของ admin เพิ่มได้แต่ลบไม่ได้ ไม่เช่นนั้น admin ทุกคนอาจเข้าจัดการ role ไม่ได้อีก
+const adminRoleId = 2
+
+type IRolesUsecase interface {
+	FindRole() ([]*roles.Role, error)
+	FindPermission() ([]*roles.Permission, error)
+	AddRole(req *roles.Role) (*roles.Role, error)
+	UpdateRole(req *roles.Role) (*roles.Role, error)
+	DeleteRole(roleId int) error
+	UpdateUserRole(req *roles.UserRoleReq) error
+}
+
+type rolesUsecase struct {
+	rolesRepository rolesRepositories.IRolesRepository
+}
+
+func RolesUsecase(rolesRepository rolesRepositories.IRolesRepository) IRolesUsecase {
+	return &rolesUsecase{
+		rolesRepository: rolesRepository,
+	}
+}
+
+// ตัด permission ที่ซ้ำกันออก
+func uniquePermissions(permissions []string) []string {
+	if permissions == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, p := range permissions {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+func (u *rolesUsecase) FindRole() ([]*roles.Role, error) {
+	return u.rolesRepository.FindRole()
+}
+
+func (u *rolesUsecase) FindPermission() ([]*roles.Permission, error) {
+	return u.rolesRepository.FindPermission()
+}
+
+func (u *rolesUsecase) AddRole(req *roles.Role) (*roles.Role, error) {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	req.Permissions = uniquePermissions(req.Permissions)
+
+	roleId, err := u.rolesRepository.InsertRole(req)
+	if err != nil {
+		return nil, err
+	}
+	return u.rolesRepository.FindOneRole(roleId)
+}
+
+func (u *rolesUsecase) UpdateRole(req *roles.Role) (*roles.Role, error) {
+	old, err := u.rolesRepository.FindOneRole(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	if builtInRoles[req.Id] != "" && req.Title != "" && req.Title != builtInRoles[req.Id] {
+		return nil, fmt.Errorf("built-in role cannot be renamed")
+	}
+	req.Permissions = uniquePermissions(req.Permissions)
+
+	// nil คือไม่แก้ permission
+	if req.Id == adminRoleId && req.Permissions != nil {
+		kept := make(map[string]bool, len(req.Permissions))
+		for _, p := range req.Permissions {
+			kept[p] = true
+		}
+		for _, p := range old.Permissions {
+			if !kept[p] {
+				return nil, fmt.Errorf("built-in admin permissions cannot be removed")
+			}
+		}
+	}
+
+	if err := u.rolesRepository.UpdateRole(req); err != nil {
+		return nil, err
+	}
+	return u.rolesRepository.FindOneRole(req.Id)
+}
+
+func (u *rolesUsecase) DeleteRole(roleId int) error {
+	if builtInRoles[roleId] != "" {
+		return fmt.Errorf("built-in role cannot be deleted")
+	}
+	return u.rolesRepository.DeleteRole(roleId)
+}
+
+// token ของ user ถูกยกเลิก user ต้อง sign in ใหม่จึงได้ role ใหม่
+func (u *rolesUsecase) UpdateUserRole(req *roles.UserRoleReq) error {
+	if _, err := u.rolesRepository.FindOneRole(req.RoleId); err != nil {
+		return err
+	}
+	return u.rolesRepository.UpdateUserRole(req)
+}
@@ -0,0 +1,24 @@
+package servers
+
+import (
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys/apikeysHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys/apikeysRepositories"
+	"github.com/Doittikorn/go-e-commerce/modules/apikeys/apikeysUsecases"
+)
+
+func (m *moduleFactory) ApikeysModule() {
+	repository := apikeysRepositories.ApikeysRepository(m.server.db)
+	usecase := apikeysUsecases.ApikeysUsecase(repository)
+	handler := apikeysHandlers.ApikeysHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/apikeys", m.mid.JwtAuth(), m.mid.RequirePermission("apikeys:write"))
+
+	router.Get("/", handler.FindApiKey)
+	router.Get("/:apikey_id", handler.FindOneApiKey)
+
+	router.Post("/", handler.AddApiKey)
+
+	router.Patch("/:apikey_id", handler.UpdateApiKey)
+
+	router.Delete("/:apikey_id", handler.RevokeApiKey)
+}
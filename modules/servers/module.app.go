@@ -13,10 +13,13 @@ func (m *moduleFactory) AppinfoModule() {
 
 	router := m.router.Group("/appinfo")
 
-	router.Post("/categories", m.mid.JwtAuth(), m.mid.Authorize(2), handler.AddCategory)
+	router.Post("/categories", m.mid.JwtAuth(), m.mid.RequirePermission("categories:write"), handler.AddCategory)
 
-	router.Get("/categories", m.mid.ApiKeyAuth(), handler.FindCategory)
-	router.Get("/apikey", m.mid.JwtAuth(), m.mid.Authorize(2), handler.GenerateApiKey)
+	router.Get("/categories", m.mid.ApiKeyAuth("categories:read"), m.mid.RateLimit("catalog"), handler.FindCategory)
 
-	router.Delete("/:category_id/categories", m.mid.JwtAuth(), m.mid.Authorize(2), handler.RemoveCategory)
+	router.Delete("/:category_id/categories", m.mid.JwtAuth(), m.mid.RequirePermission("categories:write"), handler.RemoveCategory)
+
+	router.Get("/categories/:category_id/attributes", m.mid.ApiKeyAuth("categories:read"), m.mid.RateLimit("catalog"), handler.FindCategoryAttribute)
+	router.Post("/categories/:category_id/attributes", m.mid.JwtAuth(), m.mid.RequirePermission("categories:write"), handler.AddCategoryAttribute)
+	router.Delete("/categories/:category_id/attributes/:attribute_id", m.mid.JwtAuth(), m.mid.RequirePermission("categories:write"), handler.RemoveCategoryAttribute)
 }
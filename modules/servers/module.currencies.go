@@ -0,0 +1,57 @@
+package servers
+
+import (
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesRepositories"
+	"github.com/Doittikorn/go-e-commerce/modules/currencies/currenciesUsecases"
+)
+
+type ICurrenciesModule interface {
+	Init()
+	Repository() currenciesRepositories.ICurrenciesRepository
+	Usecase() currenciesUsecases.ICurrenciesUsecase
+	Handler() currenciesHandlers.ICurrenciesHandler
+}
+
+type currenciesModule struct {
+	*moduleFactory
+	repository currenciesRepositories.ICurrenciesRepository
+	usecase    currenciesUsecases.ICurrenciesUsecase
+	handler    currenciesHandlers.ICurrenciesHandler
+}
+
+func (m *moduleFactory) CurrenciesModule() ICurrenciesModule {
+	currenciesRepository := currenciesRepositories.CurrenciesRepository(m.server.db)
+	currenciesUsecase := currenciesUsecases.CurrenciesUsecase(m.server.cfg, currenciesRepository, m.server.rates)
+	currenciesHandler := currenciesHandlers.CurrenciesHandler(m.server.cfg, currenciesUsecase)
+
+	return &currenciesModule{
+		moduleFactory: m,
+		repository:    currenciesRepository,
+		usecase:       currenciesUsecase,
+		handler:       currenciesHandler,
+	}
+}
+
+func (m *currenciesModule) Init() {
+	router := m.router.Group("/currencies")
+
+	// ต้องอยู่ก่อน /:code
+	router.Get("/admin", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.FindAdminCurrency)
+	router.Post("/rates/import", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.ImportExchangeRate)
+
+	router.Get("/", m.mid.ApiKeyAuth("products:read"), m.mid.RateLimit("catalog"), m.handler.FindCurrency)
+	router.Post("/", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.AddCurrency)
+	router.Patch("/:code", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.UpdateCurrency)
+
+	router.Get("/:code/rates", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.FindExchangeRate)
+	router.Post("/:code/rates", m.mid.JwtAuth(), m.mid.RequirePermission("currencies:write"), m.handler.AddExchangeRate)
+
+	go m.usecase.RunImporter()
+}
+
+func (m *currenciesModule) Repository() currenciesRepositories.ICurrenciesRepository {
+	return m.repository
+}
+func (m *currenciesModule) Usecase() currenciesUsecases.ICurrenciesUsecase { return m.usecase }
+func (m *currenciesModule) Handler() currenciesHandlers.ICurrenciesHandler { return m.handler }
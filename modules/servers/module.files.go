@@ -2,7 +2,9 @@ package servers
 
 import (
 	"github.com/Doittikorn/go-e-commerce/modules/files/filesHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/files/filesRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/files/filesUsecases"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 )
 
 type IFilesModule interface {
@@ -18,7 +20,7 @@ type filesModule struct {
 }
 
 func (m *moduleFactory) FilesModule() IFilesModule {
-	usecase := filesUsecases.FilesUsecase(m.server.cfg)
+	usecase := filesUsecases.FilesUsecase(m.server.cfg, m.server.storage, m.server.scanner, filesRepositories.FilesRepository(m.server.db))
 	handler := filesHandlers.FilesHandler(m.server.cfg, usecase)
 
 	return &filesModule{
@@ -29,9 +31,27 @@ func (m *moduleFactory) FilesModule() IFilesModule {
 }
 
 func (f *filesModule) Init() {
+	if f.server.cfg.Storage().Driver() == storage.DriverLocal {
+		f.server.app.Use("/static", f.mid.StreamingFile())
+	}
+
 	router := f.router.Group("/files")
-	router.Post("/upload", f.mid.JwtAuth(), f.mid.Authorize(2), f.handler.UploadFiles)
-	router.Patch("/delete", f.mid.JwtAuth(), f.mid.Authorize(2), f.handler.DeleteFile)
+	router.Post("/upload", f.mid.JwtAuth(), f.mid.RequirePermission("files:write"), f.handler.UploadFiles)
+	router.Patch("/delete", f.mid.JwtAuth(), f.mid.RequirePermission("files:write"), f.handler.DeleteFile)
+	router.Get("/orphans", f.mid.JwtAuth(), f.mid.RequirePermission("files:write"), f.handler.FindOrphanFiles)
+	if f.server.cfg.Storage().Driver() == storage.DriverLocal {
+		router.Get("/signed/*", f.handler.StreamSignedFile)
+	}
+	router.Get("/:file_id/url", f.mid.JwtAuth(), f.mid.LoadPermissions(), f.handler.SignFileUrl)
+
+	uploads := router.Group("/uploads", f.mid.JwtAuth(), f.mid.RequirePermission("files:write"))
+	uploads.Post("/", f.handler.InitUpload)
+	uploads.Get("/:upload_id", f.handler.FindOneUpload)
+	uploads.Patch("/:upload_id", f.handler.AppendUpload)
+	uploads.Post("/:upload_id/complete", f.handler.CompleteUpload)
+	uploads.Delete("/:upload_id", f.handler.AbortUpload)
+
+	go f.usecase.RunSweeper()
 }
 
 func (f *filesModule) Usecase() filesUsecases.IFilesUsecase { return f.usecase }
@@ -5,6 +5,7 @@ import (
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares/middlewaresRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/middlewares/middlewaresUsecases"
 	"github.com/Doittikorn/go-e-commerce/modules/monitor/monitorHandlers"
+	"github.com/Doittikorn/go-e-commerce/pkg/ratelimit"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -12,9 +13,13 @@ type ModuleFactoryImpl interface {
 	MonitorModule()
 	UsersModule()
 	AppinfoModule()
+	CurrenciesModule() ICurrenciesModule
 	FilesModule() IFilesModule
 	ProductsModule() IProductsModule
 	OrdersModule()
+	RolesModule()
+	ApikeysModule()
+	TrashModule()
 }
 
 type moduleFactory struct {
@@ -34,7 +39,8 @@ func InitModule(r fiber.Router, s *server, m middlewaresHandlers.MiddlewaresHand
 func InitMiddlewares(s *server) middlewaresHandlers.MiddlewaresHandlerImpl {
 	repository := middlewaresRepositories.MiddlewaresRepositry(s.db)
 	usecase := middlewaresUsecases.MiddlewaresUsecase(repository)
-	return middlewaresHandlers.MiddlewaresHandler(s.cfg, usecase)
+	go usecase.RunIdempotencyPurger(s.cfg.Idempotency().PurgeInterval())
+	return middlewaresHandlers.MiddlewaresHandler(s.cfg, usecase, ratelimit.NewMemoryStore())
 }
 
 func (m *moduleFactory) MonitorModule() {
@@ -8,15 +8,29 @@ import (
 
 func (m *moduleFactory) OrdersModule() {
 	ordersRepository := ordersRepositories.OrdersRepository(m.server.db)
-	ordersUsecase := ordersUsecases.OrdersUsecase(ordersRepository, m.ProductsModule().Repository())
-	ordersHandler := ordersHandlers.OrdersHandler(m.server.cfg, ordersUsecase)
+	ordersUsecase := ordersUsecases.OrdersUsecase(m.server.cfg, ordersRepository, m.ProductsModule().Repository(), m.FilesModule().Usecase(), m.CurrenciesModule().Usecase(), m.server.mailer)
+	ordersHandler := ordersHandlers.OrdersHandler(m.server.cfg, ordersUsecase, m.CurrenciesModule().Usecase())
 
 	router := m.router.Group("/orders")
 
-	router.Post("/", m.mid.JwtAuth(), ordersHandler.InsertOrder)
+	router.Post("/", m.mid.JwtAuth(), m.mid.RateLimit("orders"), m.mid.Idempotency(), ordersHandler.InsertOrder)
 
-	router.Get("/", m.mid.JwtAuth(), m.mid.Authorize(2), ordersHandler.FindOrder)
-	router.Get("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.VerifyParamUserId(), ordersHandler.FindOneOrder)
+	// ลูกค้าที่ไม่ได้สมัครสมาชิก ดู order ได้ด้วย token ที่ส่งไปทาง email
+	router.Post("/guest", m.mid.RateLimit("orders"), m.mid.Idempotency(), ordersHandler.InsertGuestOrder)
+	router.Get("/guest/:order_id", m.mid.RateLimit("orders"), ordersHandler.FindGuestOrder)
+	router.Patch("/guest/:order_id/transfer-slip", m.mid.RateLimit("orders"), m.mid.Idempotency(), ordersHandler.AttachGuestTransferSlip)
+	router.Post("/guest/:order_id/claim", m.mid.JwtAuth(), m.mid.RateLimit("orders"), ordersHandler.ClaimGuestOrder)
 
-	router.Patch("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.VerifyParamUserId(), ordersHandler.UpdateOrder)
+	router.Get("/", m.mid.JwtAuth(), m.mid.RequirePermission("orders:read"), ordersHandler.FindOrder)
+	router.Get("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.RateLimit("orders"), m.mid.VerifyParamUserId(), ordersHandler.FindOneOrder)
+
+	router.Patch("/:user_id/:order_id", m.mid.JwtAuth(), m.mid.RateLimit("orders"), m.mid.VerifyParamUserId(), m.mid.Idempotency(), ordersHandler.UpdateOrder)
+
+	router.Get("/:user_id/:order_id/downloads", m.mid.JwtAuth(), m.mid.RateLimit("orders"), m.mid.VerifyParamUserId(), ordersHandler.FindDeliveries)
+	router.Post("/:user_id/:order_id/downloads/:download_id/link", m.mid.JwtAuth(), m.mid.RateLimit("orders"), m.mid.VerifyParamUserId(), ordersHandler.SignDownload)
+
+	// signature ใน url คือสิทธิ์ในการดาวน์โหลด จึงไม่ต้อง login
+	m.router.Get("/downloads/:download_id", ordersHandler.StreamDownload)
+
+	go ordersUsecase.RunExpirer()
 }
@@ -22,8 +22,8 @@ type productsModule struct {
 
 func (m *moduleFactory) ProductsModule() IProductsModule {
 	productsRepository := productsRepositories.ProductsRepository(m.server.db, m.server.cfg, m.FilesModule().Usecase())
-	productsUsecase := productsUsecases.ProductsUsecase(productsRepository)
-	productsHandler := productsHandlers.ProductsHandler(m.server.cfg, productsUsecase, m.FilesModule().Usecase())
+	productsUsecase := productsUsecases.ProductsUsecase(m.server.cfg, productsRepository)
+	productsHandler := productsHandlers.ProductsHandler(m.server.cfg, productsUsecase, m.FilesModule().Usecase(), m.CurrenciesModule().Usecase())
 
 	return &productsModule{
 		moduleFactory: m,
@@ -36,14 +36,27 @@ func (m *moduleFactory) ProductsModule() IProductsModule {
 func (p *productsModule) Init() {
 	router := p.router.Group("/products")
 
-	router.Post("/", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.AddProduct)
+	// ต้องอยู่ก่อน /:product_id
+	router.Post("/import", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.ImportProducts)
+	router.Get("/import/:job_id", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.FindOneImportJob)
+	router.Get("/export", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.ExportProducts)
+	// admin เห็นสินค้าทุกสถานะ ส่วน endpoint ที่ใช้ api key เห็นเฉพาะ published
+	router.Get("/admin", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.FindAdminProduct)
+	router.Get("/admin/:product_id", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.FindOneAdminProduct)
+	router.Get("/admin/:product_id/prices", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.FindPriceHistory)
+	router.Get("/admin/:product_id/license-keys", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.FindLicenseKeys)
+	router.Post("/admin/:product_id/license-keys", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.AddLicenseKeys)
 
-	router.Patch("/:product_id", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.UpdateProduct)
+	router.Post("/", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.AddProduct)
 
-	router.Get("/", p.mid.ApiKeyAuth(), p.handler.FindProduct)
-	router.Get("/:product_id", p.mid.ApiKeyAuth(), p.handler.FindOneProduct)
+	router.Patch("/:product_id", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.UpdateProduct)
 
-	router.Delete("/:product_id", p.mid.JwtAuth(), p.mid.Authorize(2), p.handler.DeleteProduct)
+	router.Get("/", p.mid.ApiKeyAuth("products:read"), p.mid.RateLimit("catalog"), p.handler.FindProduct)
+	router.Get("/:product_id", p.mid.ApiKeyAuth("products:read"), p.mid.RateLimit("catalog"), p.handler.FindOneProduct)
+
+	router.Delete("/:product_id", p.mid.JwtAuth(), p.mid.RequirePermission("products:write"), p.handler.DeleteProduct)
+
+	go p.usecase.RunScheduler()
 }
 
 func (f *productsModule) Repository() productsRepositories.IProductsRepository { return f.repository }
@@ -0,0 +1,25 @@
+package servers
+
+import (
+	"github.com/Doittikorn/go-e-commerce/modules/roles/rolesHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/roles/rolesRepositories"
+	"github.com/Doittikorn/go-e-commerce/modules/roles/rolesUsecases"
+)
+
+func (m *moduleFactory) RolesModule() {
+	repository := rolesRepositories.RolesRepository(m.server.db)
+	usecase := rolesUsecases.RolesUsecase(repository)
+	handler := rolesHandlers.RolesHandler(m.server.cfg, usecase, m.mid)
+
+	router := m.router.Group("/roles", m.mid.JwtAuth(), m.mid.RequirePermission("roles:write"))
+
+	router.Get("/", handler.FindRole)
+	router.Get("/permissions", handler.FindPermission)
+
+	router.Post("/", handler.AddRole)
+
+	router.Patch("/users/:user_id", handler.UpdateUserRole)
+	router.Patch("/:role_id", handler.UpdateRole)
+
+	router.Delete("/:role_id", handler.DeleteRole)
+}
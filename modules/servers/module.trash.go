@@ -0,0 +1,25 @@
+package servers
+
+import (
+	"github.com/Doittikorn/go-e-commerce/modules/trash/trashHandlers"
+	"github.com/Doittikorn/go-e-commerce/modules/trash/trashRepositories"
+	"github.com/Doittikorn/go-e-commerce/modules/trash/trashUsecases"
+)
+
+func (m *moduleFactory) TrashModule() {
+	repository := trashRepositories.TrashRepository(m.server.db)
+	usecase := trashUsecases.TrashUsecase(m.server.cfg, repository)
+	handler := trashHandlers.TrashHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/trash", m.mid.JwtAuth(), m.mid.RequirePermission("trash:write"))
+
+	router.Get("/", handler.FindTrash)
+
+	router.Post("/purge", handler.PurgeExpired)
+
+	router.Patch("/:type/:id/restore", handler.RestoreItem)
+
+	router.Delete("/:type/:id", handler.PurgeItem)
+
+	go usecase.RunPurger()
+}
@@ -1,23 +1,56 @@
 package servers
 
 import (
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/audits/auditsRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/users/usersHandlers"
 	"github.com/Doittikorn/go-e-commerce/modules/users/usersRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/users/usersUsecases"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc"
 )
 
 func (m *moduleFactory) UsersModule() {
 	repository := usersRepositories.New(m.server.db)
-	usecase := usersUsecases.New(m.server.cfg, repository)
+	usecase := usersUsecases.New(
+		m.server.cfg,
+		repository,
+		auditsRepositories.AuditsRepository(m.server.db),
+		oidcRegistry(m.server.cfg.OIDC()),
+		m.server.mailer,
+	)
 	handler := usersHandlers.New(m.server.cfg, usecase)
 
+	m.server.app.Get("/.well-known/jwks.json", handler.Jwks)
+
 	router := m.router.Group("/users")
 	router.Post("/signin", handler.SignIn)
 	router.Post("/signup", handler.SignUpCustomer)
+	router.Get("/verify-email", handler.VerifyEmail)
+	router.Post("/verify-email", handler.VerifyEmail)
 	router.Post("/refresh", handler.RefreshPasport)
 	router.Delete("/signout", handler.SignOut)
-	router.Post("/signup-admin", handler.SignUpAdmin)
+	router.Post("/signup-admin", m.mid.AdminTokenAuth(), handler.SignUpAdmin)
+	router.Get("/oidc/:provider", handler.OidcAuthorize)
+	router.Get("/oidc/:provider/callback", handler.OidcCallback)
 
 	router.Get("/:userId", m.mid.JwtAuth(), m.mid.VerifyParamUserId(), handler.GetUserProfile)
-	router.Get("/admin/secret", m.mid.JwtAuth(), m.mid.Authorize(2), handler.GenerateAdminToken)
+	router.Delete("/:userId", m.mid.JwtAuth(), m.mid.RequirePermission("users:write"), handler.DeleteUser)
+	router.Get("/admin/secret", m.mid.JwtAuth(), m.mid.RequirePermission("admins:write"), handler.GenerateAdminToken)
+	router.Post("/admin/invites", m.mid.JwtAuth(), m.mid.RequirePermission("admins:write"), m.mid.AdminTokenAuth(), handler.InviteAdmin)
+}
+
+func oidcRegistry(cfg config.OIDCConfigImpl) oidc.RegistryImpl {
+	providers := make([]*oidc.Config, 0)
+	for _, p := range cfg.Providers() {
+		providers = append(providers, &oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientId:     p.ClientId,
+			ClientSecret: p.ClientSecret,
+			RedirectUrl:  p.RedirectUrl,
+			Scopes:       p.Scopes,
+			AllowHS256:   p.AllowHS256,
+		})
+	}
+	return oidc.NewRegistry(providers, nil)
 }
@@ -7,7 +7,11 @@ import (
 	"os/signal"
 
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/pkg/exchangerate"
 	"github.com/Doittikorn/go-e-commerce/pkg/logger"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
+	"github.com/Doittikorn/go-e-commerce/pkg/scanner"
+	"github.com/Doittikorn/go-e-commerce/pkg/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
@@ -17,15 +21,40 @@ type ServerImpl interface {
 }
 
 type server struct {
-	app *fiber.App
-	cfg config.ConfigImpl
-	db  *sqlx.DB
+	app     *fiber.App
+	cfg     config.ConfigImpl
+	db      *sqlx.DB
+	storage storage.StorageImpl
+	scanner scanner.ScannerImpl
+	rates   exchangerate.ImporterImpl
+	mailer  mailer.MailerImpl
 }
 
 func NewServer(cfg config.ConfigImpl, db *sqlx.DB) ServerImpl {
+	fileStorage, err := storage.New(cfg.Storage())
+	if err != nil {
+		log.Fatalf("init storage failed: %v", err)
+	}
+	fileScanner, err := scanner.New(cfg.Upload().ScannerDriver(), cfg.Upload().ClamAVAddr())
+	if err != nil {
+		log.Fatalf("init scanner failed: %v", err)
+	}
+	rateImporter, err := exchangerate.New(cfg.Currency().ImporterDriver(), cfg.Currency().ImporterUrl())
+	if err != nil {
+		log.Fatalf("init exchange rate importer failed: %v", err)
+	}
+	mail, err := mailer.New(cfg.Mail())
+	if err != nil {
+		log.Fatalf("init mailer failed: %v", err)
+	}
+
 	return &server{
-		cfg: cfg,
-		db:  db,
+		cfg:     cfg,
+		db:      db,
+		storage: fileStorage,
+		scanner: fileScanner,
+		rates:   rateImporter,
+		mailer:  mail,
 		app: fiber.New(
 			fiber.Config{
 				AppName:      cfg.App().Name(),
@@ -56,9 +85,13 @@ func (s *server) Start() {
 	modules.MonitorModule()
 	modules.UsersModule()
 	modules.AppinfoModule()
+	modules.CurrenciesModule().Init()
 	modules.FilesModule().Init()
 	modules.ProductsModule().Init()
 	modules.OrdersModule()
+	modules.RolesModule()
+	modules.ApikeysModule()
+	modules.TrashModule()
 
 	s.app.Use(middlewares.RouterCheck())
 
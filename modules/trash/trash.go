@@ -0,0 +1,41 @@
+package trash
+
+import "github.com/Doittikorn/go-e-commerce/modules/entities"
+
+// ชนิดของข้อมูลที่ลบแบบ soft delete ได้
+const (
+	TypeProducts   = "products"
+	TypeCategories = "categories"
+	TypeUsers      = "users"
+)
+
+// ลำดับการลบถาวร สินค้าต้องถูกลบก่อนหมวดหมู่ที่สินค้านั้นใช้อยู่
+var Types = []string{TypeProducts, TypeCategories, TypeUsers}
+
+func IsType(t string) bool {
+	for _, v := range Types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+type Item struct {
+	Type      string `json:"type"`
+	Id        string `json:"id"`
+	Title     string `json:"title"`
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at"`
+}
+
+type TrashFilter struct {
+	Type string `query:"type"`
+	*entities.PaginationReq
+}
+
+// จำนวนที่ถูกลบถาวรและที่ถูกข้ามเพราะยังมีข้อมูลอื่นอ้างถึง แยกตามชนิด
+type PurgeReport struct {
+	Purged  map[string]int `json:"purged"`
+	Skipped map[string]int `json:"skipped"`
+}
@@ -0,0 +1,142 @@
+package trashHandlers
+
+import (
+	"strings"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/trash"
+	"github.com/Doittikorn/go-e-commerce/modules/trash/trashUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type trashHandlersErrCode string
+
+const (
+	findTrashErr    trashHandlersErrCode = "trash-001"
+	restoreItemErr  trashHandlersErrCode = "trash-002"
+	purgeItemErr    trashHandlersErrCode = "trash-003"
+	purgeExpiredErr trashHandlersErrCode = "trash-004"
+)
+
+type ITrashHandler interface {
+	FindTrash(c *fiber.Ctx) error
+	RestoreItem(c *fiber.Ctx) error
+	PurgeItem(c *fiber.Ctx) error
+	PurgeExpired(c *fiber.Ctx) error
+}
+
+type trashHandler struct {
+	cfg          config.ConfigImpl
+	trashUsecase trashUsecases.ITrashUsecase
+}
+
+func TrashHandler(cfg config.ConfigImpl, trashUsecase trashUsecases.ITrashUsecase) ITrashHandler {
+	return &trashHandler{
+		cfg:          cfg,
+		trashUsecase: trashUsecase,
+	}
+}
+
+// error ของ restore และ purge ใช้ status เดียวกัน
+func itemErrorStatus(err error) int {
+	switch err.Error() {
+	case "trash type is invalid":
+		return fiber.ErrBadRequest.Code
+	case "item not found in trash":
+		return fiber.ErrNotFound.Code
+	case "category of product is deleted", "product is still used by bundles", "category is still used by products", "user still has orders":
+		return fiber.ErrConflict.Code
+	default:
+		return fiber.ErrInternalServerError.Code
+	}
+}
+
+func (h *trashHandler) FindTrash(c *fiber.Ctx) error {
+	req := &trash.TrashFilter{
+		PaginationReq: &entities.PaginationReq{},
+	}
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findTrashErr),
+			err.Error(),
+		).Res()
+	}
+
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.Limit < 5 {
+		req.Limit = 5
+	}
+
+	result, err := h.trashUsecase.FindTrash(req)
+	if err != nil {
+		switch err.Error() {
+		case "trash type is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(findTrashErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findTrashErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *trashHandler) RestoreItem(c *fiber.Ctx) error {
+	itemType := strings.Trim(c.Params("type"), " ")
+	id := strings.Trim(c.Params("id"), " ")
+
+	if err := h.trashUsecase.RestoreItem(itemType, id); err != nil {
+		return entities.NewResponse(c).Error(
+			itemErrorStatus(err),
+			string(restoreItemErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			Type string `json:"type"`
+			Id   string `json:"id"`
+		}{
+			Type: itemType,
+			Id:   id,
+		},
+	).Res()
+}
+
+func (h *trashHandler) PurgeItem(c *fiber.Ctx) error {
+	itemType := strings.Trim(c.Params("type"), " ")
+	id := strings.Trim(c.Params("id"), " ")
+
+	if err := h.trashUsecase.PurgeItem(itemType, id); err != nil {
+		return entities.NewResponse(c).Error(
+			itemErrorStatus(err),
+			string(purgeItemErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
+}
+
+// ลบถาวรทุกอย่างที่อยู่ในถังขยะเกิน TRASH_RETENTION โดยไม่ต้องรอ job เบื้องหลัง
+func (h *trashHandler) PurgeExpired(c *fiber.Ctx) error {
+	report, err := h.trashUsecase.PurgeExpired()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(purgeExpiredErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, report).Res()
+}
@@ -0,0 +1,231 @@
+package trashRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/trash"
+	"github.com/jmoiron/sqlx"
+)
+
+type ITrashRepository interface {
+	FindTrash(req *trash.TrashFilter, retentionSeconds int) ([]*trash.Item, int, error)
+	RestoreItem(itemType, id string) error
+	PurgeItem(itemType, id string) error
+	PurgeExpired(itemType string, retentionSeconds int) (int, int, error)
+}
+
+type trashRepository struct {
+	db *sqlx.DB
+}
+
+func TrashRepository(db *sqlx.DB) ITrashRepository {
+	return &trashRepository{db: db}
+}
+
+// รวมข้อมูลที่ถูกลบทุกชนิดไว้ใน query เดียวเพื่อให้แบ่งหน้าและเรียงตามเวลาที่ลบได้
+const trashItemsQuery = `
+	SELECT 'products' AS "type", "id", "title", "deleted_at" FROM "products" WHERE "deleted_at" IS NOT NULL
+	UNION ALL
+	SELECT 'categories', "id"::text, "title", "deleted_at" FROM "categories" WHERE "deleted_at" IS NOT NULL
+	UNION ALL
+	SELECT 'users', "id", "username", "deleted_at" FROM "users" WHERE "deleted_at" IS NOT NULL`
+
+func (r *trashRepository) FindTrash(req *trash.TrashFilter, retentionSeconds int) ([]*trash.Item, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"x"."type",
+			"x"."id",
+			"x"."title",
+			"x"."deleted_at",
+			"x"."deleted_at" + make_interval(secs => $2) AS "purge_at"
+		FROM (%s) AS "x"
+		WHERE ($1 = '' OR "x"."type" = $1)
+		ORDER BY "x"."deleted_at" DESC, "x"."type", "x"."id"
+		OFFSET $3 LIMIT $4
+	) AS "t";`, trashItemsQuery)
+
+	raw := make([]byte, 0)
+	if err := r.db.GetContext(ctx, &raw, query, req.Type, retentionSeconds, (req.Page-1)*req.Limit, req.Limit); err != nil {
+		return nil, 0, fmt.Errorf("find trash failed: %v", err)
+	}
+	items := make([]*trash.Item, 0)
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, 0, fmt.Errorf("unmarshal trash failed: %v", err)
+	}
+
+	var count int
+	countQuery := fmt.Sprintf(`
+	SELECT
+		COUNT(*)
+	FROM (%s) AS "x"
+	WHERE ($1 = '' OR "x"."type" = $1);`, trashItemsQuery)
+
+	if err := r.db.GetContext(ctx, &count, countQuery, req.Type); err != nil {
+		return nil, 0, fmt.Errorf("count trash failed: %v", err)
+	}
+	return items, count, nil
+}
+
+// สินค้าจะกู้คืนได้เมื่อหมวดหมู่ของสินค้ายังไม่ถูกลบ
+var restoreQueries = map[string]string{
+	trash.TypeProducts: `
+	UPDATE "products" SET
+		"deleted_at" = NULL
+	WHERE "id" = $1
+	AND "deleted_at" IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "products_categories" "pc"
+			JOIN "categories" "c" ON "c"."id" = "pc"."category_id"
+		WHERE "pc"."product_id" = "products"."id"
+		AND "c"."deleted_at" IS NOT NULL
+	)
+	RETURNING "id";`,
+	trash.TypeCategories: `
+	UPDATE "categories" SET
+		"deleted_at" = NULL
+	WHERE "id"::text = $1
+	AND "deleted_at" IS NOT NULL
+	RETURNING "id"::text;`,
+	trash.TypeUsers: `
+	UPDATE "users" SET
+		"deleted_at" = NULL
+	WHERE "id" = $1
+	AND "deleted_at" IS NOT NULL
+	RETURNING "id";`,
+}
+
+// สินค้าที่อยู่ในสินค้าชุด หมวดหมู่ที่ยังมีสินค้าอ้างถึง และ user ที่ยังมี order จะลบถาวรไม่ได้
+var purgeQueries = map[string]string{
+	trash.TypeProducts: `
+	DELETE FROM "products"
+	WHERE "id" = $1
+	AND "deleted_at" IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "product_bundle_items" "bi"
+		WHERE "bi"."product_id" = "products"."id"
+	)
+	RETURNING "id";`,
+	trash.TypeCategories: `
+	DELETE FROM "categories"
+	WHERE "id"::text = $1
+	AND "deleted_at" IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "products_categories" "pc"
+		WHERE "pc"."category_id" = "categories"."id"
+	)
+	RETURNING "id"::text;`,
+	trash.TypeUsers: `
+	DELETE FROM "users"
+	WHERE "id" = $1
+	AND "deleted_at" IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "orders" "o"
+		WHERE "o"."user_id" = "users"."id"
+	)
+	RETURNING "id";`,
+}
+
+var restoreBlockedMessages = map[string]string{
+	trash.TypeProducts: "category of product is deleted",
+}
+
+var purgeBlockedMessages = map[string]string{
+	trash.TypeProducts:   "product is still used by bundles",
+	trash.TypeCategories: "category is still used by products",
+	trash.TypeUsers:      "user still has orders",
+}
+
+func (r *trashRepository) RestoreItem(itemType, id string) error {
+	return r.execItem(restoreQueries[itemType], itemType, id, "restore", restoreBlockedMessages[itemType])
+}
+
+func (r *trashRepository) PurgeItem(itemType, id string) error {
+	return r.execItem(purgeQueries[itemType], itemType, id, "purge", purgeBlockedMessages[itemType])
+}
+
+// ถ้าไม่มีแถวถูกแก้ไข ตรวจต่อว่าไม่อยู่ในถังขยะ หรือถูกกันไว้เพราะยังมีข้อมูลอื่นอ้างถึง
+func (r *trashRepository) execItem(query, itemType, id, action, blockedMessage string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var result string
+	err := r.db.QueryRowxContext(ctx, query, id).Scan(&result)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %s failed: %v", action, itemType, err)
+	}
+
+	var exists bool
+	existsQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s" WHERE "id"::text = $1 AND "deleted_at" IS NOT NULL);`, itemType)
+	if err := r.db.GetContext(ctx, &exists, existsQuery, id); err != nil {
+		return fmt.Errorf("%s %s failed: %v", action, itemType, err)
+	}
+	if !exists {
+		return fmt.Errorf("item not found in trash")
+	}
+	if blockedMessage == "" {
+		return fmt.Errorf("%s %s failed", action, itemType)
+	}
+	return errors.New(blockedMessage)
+}
+
+// ลบถาวรทุกแถวของชนิดนี้ที่อยู่ในถังขยะเกิน retention คืนจำนวนที่ลบและจำนวนที่ลบไม่ได้
+func (r *trashRepository) PurgeExpired(itemType string, retentionSeconds int) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ids := make([]string, 0)
+	query := fmt.Sprintf(`
+	SELECT
+		"id"::text
+	FROM "%s"
+	WHERE "deleted_at" < now() - make_interval(secs => $1)
+	FOR UPDATE SKIP LOCKED;`, itemType)
+
+	if err := tx.SelectContext(ctx, &ids, query, retentionSeconds); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("find expired %s failed: %v", itemType, err)
+	}
+
+	var purged, skipped int
+	for _, id := range ids {
+		var result string
+		err := tx.QueryRowxContext(ctx, purgeQueries[itemType], id).Scan(&result)
+		switch {
+		case err == nil:
+			purged++
+		case errors.Is(err, sql.ErrNoRows):
+			skipped++
+		default:
+			tx.Rollback()
+			return 0, 0, fmt.Errorf("purge %s failed: %v", itemType, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return purged, skipped, nil
+}
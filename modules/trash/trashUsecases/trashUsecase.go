@@ -0,0 +1,109 @@
+package trashUsecases
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/entities"
+	"github.com/Doittikorn/go-e-commerce/modules/trash"
+	"github.com/Doittikorn/go-e-commerce/modules/trash/trashRepositories"
+)
+
+type ITrashUsecase interface {
+	FindTrash(req *trash.TrashFilter) (*entities.PaginateRes, error)
+	RestoreItem(itemType, id string) error
+	PurgeItem(itemType, id string) error
+	PurgeExpired() (*trash.PurgeReport, error)
+	RunPurger()
+}
+
+type trashUsecase struct {
+	cfg             config.ConfigImpl
+	trashRepository trashRepositories.ITrashRepository
+}
+
+func TrashUsecase(cfg config.ConfigImpl, trashRepository trashRepositories.ITrashRepository) ITrashUsecase {
+	return &trashUsecase{
+		cfg:             cfg,
+		trashRepository: trashRepository,
+	}
+}
+
+func (u *trashUsecase) retentionSeconds() int {
+	return int(u.cfg.Trash().Retention() / time.Second)
+}
+
+func (u *trashUsecase) FindTrash(req *trash.TrashFilter) (*entities.PaginateRes, error) {
+	if req.Type != "" && !trash.IsType(req.Type) {
+		return nil, fmt.Errorf("trash type is invalid")
+	}
+
+	items, count, err := u.trashRepository.FindTrash(req, u.retentionSeconds())
+	if err != nil {
+		return nil, err
+	}
+	return &entities.PaginateRes{
+		Data:      items,
+		Page:      req.Page,
+		Limit:     req.Limit,
+		TotalItem: count,
+		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
+	}, nil
+}
+
+func (u *trashUsecase) RestoreItem(itemType, id string) error {
+	if !trash.IsType(itemType) {
+		return fmt.Errorf("trash type is invalid")
+	}
+	return u.trashRepository.RestoreItem(itemType, id)
+}
+
+// ลบถาวรทันทีโดยไม่รอ retention
+func (u *trashUsecase) PurgeItem(itemType, id string) error {
+	if !trash.IsType(itemType) {
+		return fmt.Errorf("trash type is invalid")
+	}
+	return u.trashRepository.PurgeItem(itemType, id)
+}
+
+func (u *trashUsecase) PurgeExpired() (*trash.PurgeReport, error) {
+	report := &trash.PurgeReport{
+		Purged:  make(map[string]int),
+		Skipped: make(map[string]int),
+	}
+	for _, t := range trash.Types {
+		purged, skipped, err := u.trashRepository.PurgeExpired(t, u.retentionSeconds())
+		if err != nil {
+			return nil, err
+		}
+		report.Purged[t] = purged
+		report.Skipped[t] = skipped
+	}
+	return report, nil
+}
+
+func (u *trashUsecase) RunPurger() {
+	interval := u.cfg.Trash().PurgeInterval()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := u.PurgeExpired()
+		if err != nil {
+			log.Printf("purge trash failed: %v\n", err)
+			continue
+		}
+		for _, t := range trash.Types {
+			if report.Purged[t] > 0 || report.Skipped[t] > 0 {
+				log.Printf("purge trash: %s purged %d, skipped %d\n", t, report.Purged[t], report.Skipped[t])
+			}
+		}
+	}
+}
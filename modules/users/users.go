@@ -1,6 +1,8 @@
 package users
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
 
@@ -30,7 +32,11 @@ func (obj *UserRegisterReq) BcryptHashing() error {
 }
 
 func (obj *UserRegisterReq) IsEmail() bool {
-	match, err := regexp.MatchString(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`, obj.Email)
+	return IsEmail(obj.Email)
+}
+
+func IsEmail(email string) bool {
+	match, err := regexp.MatchString(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`, email)
 	if err != nil {
 		return false
 	}
@@ -86,3 +92,115 @@ type Oauth struct {
 type UserRemoveCredential struct {
 	OathId string `db:"id" json:"oath_id" form:"oath_id"`
 }
+
+// scope ของการนับจำนวนครั้งที่ sign in ผิดพลาด
+const (
+	SignInScopeEmail = "email"
+	SignInScopeIp    = "ip"
+)
+
+// ค่าที่ใช้ในการป้องกัน brute-force
+const (
+	SignInMaxEmailAttempts = 5
+	SignInMaxIpAttempts    = 20
+	SignInAttemptWindow    = 15 * 60 // seconds
+	SignInLockoutBase      = 30      // seconds
+	SignInLockoutMax       = 60 * 60 // seconds
+)
+
+type SignInAttempt struct {
+	Scope       string `db:"scope"`
+	Key         string `db:"key"`
+	FailedCount int    `db:"failed_count"`
+	IsLocked    bool   `db:"is_locked"`
+	RetryAfter  int    `db:"retry_after"` // seconds
+}
+
+// email หรือ ip ถูก lock อยู่ RetryAfter คือ seconds ที่ต้องรอก่อน sign in ได้อีกครั้ง
+type SignInLockedError struct {
+	RetryAfter int
+}
+
+func (e *SignInLockedError) Error() string {
+	return "too many sign in attempts, please try again later"
+}
+
+// คำนวณเวลาที่ต้องรอ โดยเพิ่มขึ้นเป็นเท่าตัวทุกครั้งที่ผิดเกินจำนวนที่กำหนด
+func (obj *SignInAttempt) LockoutDuration(maxAttempts int) int {
+	if obj.FailedCount < maxAttempts {
+		return 0
+	}
+	duration := SignInLockoutBase
+	for i := maxAttempts; i < obj.FailedCount; i++ {
+		duration *= 2
+		if duration >= SignInLockoutMax {
+			return SignInLockoutMax
+		}
+	}
+	return duration
+}
+
+// invite สำหรับสมัคร admin มีอายุ 3 วัน
+const AdminInviteExpires = 72 * 60 * 60 // seconds
+
+type AdminInviteReq struct {
+	Email     string `json:"email" form:"email"`
+	InvitedBy string `json:"-" form:"-"`
+}
+
+type AdminInvite struct {
+	Id        string `db:"id" json:"id"`
+	Email     string `db:"email" json:"email"`
+	InvitedBy string `db:"invited_by" json:"invited_by"`
+	Token     string `db:"-" json:"token,omitempty"`
+	ExpiresAt string `db:"expires_at" json:"expires_at"`
+}
+
+// state ของการ sign in ด้วย oidc มีอายุ 10 นาที
+const OidcStateExpires = 10 * 60 // seconds
+
+type OidcState struct {
+	State        string `db:"state"`
+	Provider     string `db:"provider"`
+	CodeVerifier string `db:"code_verifier"`
+	Nonce        string `db:"nonce"`
+}
+
+type OidcAuthorize struct {
+	Provider         string `json:"provider"`
+	AuthorizationUrl string `json:"authorization_url"`
+}
+
+type OidcCallbackReq struct {
+	Code  string `query:"code"`
+	State string `query:"state"`
+	Error string `query:"error"`
+}
+
+// บัญชีจาก provider ภายนอกที่ผูกกับ user
+type UserIdentity struct {
+	Id       string `db:"id" json:"id"`
+	UserId   string `db:"user_id" json:"user_id"`
+	Provider string `db:"provider" json:"provider"`
+	Subject  string `db:"subject" json:"subject"`
+	Email    string `db:"email" json:"email"`
+}
+
+// link ยืนยัน email ที่ส่งไปหลังสมัครสมาชิกมีอายุ 24 ชั่วโมง
+const EmailVerificationExpires = 24 * 60 * 60 // seconds
+
+type EmailVerifyReq struct {
+	Token string `json:"token" form:"token" query:"token"`
+}
+
+type EmailVerified struct {
+	UserId        string `json:"user_id"`
+	Email         string `json:"email"`
+	ClaimedOrders int64  `json:"claimed_orders"`
+}
+
+// เก็บเฉพาะ hash ของ token ยืนยัน email ลง database
+func HashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
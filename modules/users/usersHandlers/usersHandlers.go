@@ -1,7 +1,9 @@
 package usersHandlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/Doittikorn/go-e-commerce/config"
@@ -22,16 +24,27 @@ const (
 	signUpAdminErr        userHandlerErrcode = "users_handler_005"
 	generateAdminTokenErr userHandlerErrcode = "users_handler_006"
 	getUserProfileErr     userHandlerErrcode = "users_handler_007"
+	inviteAdminErr        userHandlerErrcode = "users_handler_008"
+	oidcAuthorizeErr      userHandlerErrcode = "users_handler_009"
+	oidcSignInErr         userHandlerErrcode = "users_handler_010"
+	deleteUserErr         userHandlerErrcode = "users_handler_011"
+	verifyEmailErr        userHandlerErrcode = "users_handler_012"
 )
 
 type UsersHandlersImpl interface {
 	SignUpCustomer(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
 	SignIn(c *fiber.Ctx) error
 	RefreshPasport(c *fiber.Ctx) error
 	SignOut(c *fiber.Ctx) error
 	SignUpAdmin(c *fiber.Ctx) error
 	GenerateAdminToken(c *fiber.Ctx) error
 	GetUserProfile(c *fiber.Ctx) error
+	DeleteUser(c *fiber.Ctx) error
+	InviteAdmin(c *fiber.Ctx) error
+	OidcAuthorize(c *fiber.Ctx) error
+	OidcCallback(c *fiber.Ctx) error
+	Jwks(c *fiber.Ctx) error
 }
 
 type usersHandler struct {
@@ -54,6 +67,7 @@ func (h *usersHandler) SignUpCustomer(c *fiber.Ctx) error {
 	}
 
 	// Email validation
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 	if !req.IsEmail() {
 		return entities.NewResponse(c).Error(
 			http.StatusBadRequest,
@@ -62,7 +76,7 @@ func (h *usersHandler) SignUpCustomer(c *fiber.Ctx) error {
 		).Res()
 	}
 
-	result, err := h.usersUsecase.InsertCustomer(req)
+	result, err := h.usersUsecase.SignUpCustomer(req)
 	if err != nil {
 		switch err.Error() {
 		case "username has been used":
@@ -77,38 +91,103 @@ func (h *usersHandler) SignUpCustomer(c *fiber.Ctx) error {
 	return entities.NewResponse(c).Success(http.StatusCreated, result).Res()
 }
 
+// รับ token จาก link ใน email (query) หรือจาก body ก็ได้
+func (h *usersHandler) VerifyEmail(c *fiber.Ctx) error {
+	req := new(users.EmailVerifyReq)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(http.StatusBadRequest, string(verifyEmailErr), err.Error()).Res()
+	}
+	if req.Token == "" && len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(verifyEmailErr), err.Error()).Res()
+		}
+	}
+
+	result, err := h.usersUsecase.VerifyEmail(req.Token)
+	if err != nil {
+		switch err.Error() {
+		case "token is invalid":
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(verifyEmailErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(verifyEmailErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(http.StatusOK, result).Res()
+}
+
 func (h *usersHandler) SignUpAdmin(c *fiber.Ctx) error {
+	// Admin token ต้องเป็น token ที่ได้จากการเชิญเท่านั้น
+	inviteId, _ := c.Locals("adminTokenId").(string)
+	if subject, _ := c.Locals("adminTokenSubject").(string); subject != "admin-invite" {
+		return entities.NewResponse(c).Error(
+			http.StatusUnauthorized,
+			string(signUpAdminErr),
+			"invite is invalid",
+		).Res()
+	}
+
 	// Requset body parsers
 	req := new(users.UserRegisterReq)
 	if err := c.BodyParser(req); err != nil {
-		return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpCustomerErr), err.Error()).Res()
+		return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpAdminErr), err.Error()).Res()
 	}
 
 	// Email validation
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 	if !req.IsEmail() {
 		return entities.NewResponse(c).Error(
 			http.StatusBadRequest,
-			string(signUpCustomerErr),
+			string(signUpAdminErr),
 			"invalid email",
 		).Res()
 	}
 
 	// Insert admin
-	result, err := h.usersUsecase.InsertAdmin(req)
+	result, err := h.usersUsecase.InsertAdmin(req, inviteId, c.IP())
 	if err != nil {
 		switch err.Error() {
+		case "invite is invalid":
+			return entities.NewResponse(c).Error(http.StatusUnauthorized, string(signUpAdminErr), err.Error()).Res()
 		case "username has been used":
-			return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpCustomerErr), err.Error()).Res()
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpAdminErr), err.Error()).Res()
 		case "email has been used":
-			return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpCustomerErr), err.Error()).Res()
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(signUpAdminErr), err.Error()).Res()
 		default:
-			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(signUpCustomerErr), err.Error()).Res()
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(signUpAdminErr), err.Error()).Res()
 
 		}
 	}
 	return entities.NewResponse(c).Success(http.StatusCreated, result).Res()
 }
 
+func (h *usersHandler) InviteAdmin(c *fiber.Ctx) error {
+	// ต้องใช้ admin token จาก /admin/secret ไม่ใช่ token ของ invite
+	if subject, _ := c.Locals("adminTokenSubject").(string); subject != "admin-token" {
+		return entities.NewResponse(c).Error(
+			http.StatusUnauthorized,
+			string(inviteAdminErr),
+			"admin token is invalid",
+		).Res()
+	}
+
+	req := new(users.AdminInviteReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(http.StatusBadRequest, string(inviteAdminErr), err.Error()).Res()
+	}
+	req.InvitedBy = c.Locals("userId").(string)
+
+	invite, err := h.usersUsecase.InviteAdmin(req, c.IP())
+	if err != nil {
+		switch err.Error() {
+		case "invalid email":
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(inviteAdminErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(inviteAdminErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(http.StatusCreated, invite).Res()
+}
+
 func (h *usersHandler) SignIn(c *fiber.Ctx) error {
 	req := new(users.UserCredential)
 	if err := c.BodyParser(req); err != nil {
@@ -118,13 +197,19 @@ func (h *usersHandler) SignIn(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
-	passport, err := h.usersUsecase.GetPassport(req)
+	passport, err := h.usersUsecase.GetPassport(req, c.IP())
 	if err != nil {
-		return entities.NewResponse(c).Error(
-			http.StatusBadRequest,
-			string(signInErr),
-			err.Error(),
-		).Res()
+		var locked *users.SignInLockedError
+		if errors.As(err, &locked) {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(locked.RetryAfter))
+			return entities.NewResponse(c).Error(http.StatusTooManyRequests, string(signInErr), err.Error()).Res()
+		}
+		switch err.Error() {
+		case "email or password is invalid":
+			return entities.NewResponse(c).Error(http.StatusUnauthorized, string(signInErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(signInErr), "sign in failed").Res()
+		}
 	}
 	return entities.NewResponse(c).Success(http.StatusOK, passport).Res()
 }
@@ -199,3 +284,69 @@ func (h *usersHandler) GetUserProfile(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(http.StatusOK, result).Res()
 }
+
+// ย้าย user ไปถังขยะ กู้คืนหรือลบถาวรได้ที่ /v1/trash
+func (h *usersHandler) DeleteUser(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("userId"), " ")
+	if err := h.usersUsecase.DeleteUser(userId, c.Locals("userId").(string), c.IP()); err != nil {
+		switch err.Error() {
+		case "cannot delete yourself":
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(deleteUserErr), err.Error()).Res()
+		case "user not found":
+			return entities.NewResponse(c).Error(http.StatusNotFound, string(deleteUserErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(deleteUserErr), err.Error()).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(http.StatusNoContent, nil).Res()
+}
+
+// ส่ง url สำหรับไป sign in ที่ provider ถ้าส่ง ?redirect=true จะ redirect ไปเลย
+func (h *usersHandler) OidcAuthorize(c *fiber.Ctx) error {
+	result, err := h.usersUsecase.OidcAuthorize(strings.ToLower(c.Params("provider")))
+	if err != nil {
+		switch err.Error() {
+		case "provider not found":
+			return entities.NewResponse(c).Error(http.StatusNotFound, string(oidcAuthorizeErr), err.Error()).Res()
+		case "oidc sign in failed":
+			return entities.NewResponse(c).Error(http.StatusBadGateway, string(oidcAuthorizeErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(oidcAuthorizeErr), err.Error()).Res()
+		}
+	}
+
+	if c.QueryBool("redirect") {
+		return c.Redirect(result.AuthorizationUrl, http.StatusFound)
+	}
+	return entities.NewResponse(c).Success(http.StatusOK, result).Res()
+}
+
+func (h *usersHandler) OidcCallback(c *fiber.Ctx) error {
+	req := new(users.OidcCallbackReq)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(http.StatusBadRequest, string(oidcSignInErr), err.Error()).Res()
+	}
+
+	passport, err := h.usersUsecase.OidcSignIn(strings.ToLower(c.Params("provider")), req, c.IP())
+	if err != nil {
+		switch err.Error() {
+		case "provider not found":
+			return entities.NewResponse(c).Error(http.StatusNotFound, string(oidcSignInErr), err.Error()).Res()
+		case "state is invalid":
+			return entities.NewResponse(c).Error(http.StatusBadRequest, string(oidcSignInErr), err.Error()).Res()
+		case "authorization denied", "oidc sign in failed":
+			return entities.NewResponse(c).Error(http.StatusUnauthorized, string(oidcSignInErr), err.Error()).Res()
+		case "email has been used", "username has been used":
+			return entities.NewResponse(c).Error(http.StatusConflict, string(oidcSignInErr), err.Error()).Res()
+		default:
+			return entities.NewResponse(c).Error(http.StatusInternalServerError, string(oidcSignInErr), "sign in failed").Res()
+		}
+	}
+	return entities.NewResponse(c).Success(http.StatusOK, passport).Res()
+}
+
+// ไม่ห่อด้วย response ของระบบเพราะ client ที่อ่าน jwks ต้องการรูปแบบตาม RFC 7517
+func (h *usersHandler) Jwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(http.StatusOK).JSON(auth.Jwks(h.cfg.JWT()))
+}
@@ -84,7 +84,8 @@ func (f *userReq) Customer() (InsertUserImpl, error) {
 		switch err.Error() {
 		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
 			return nil, fmt.Errorf("username has been used")
-		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)":
+		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)",
+			"ERROR: duplicate key value violates unique constraint \"users_email_lower_key\" (SQLSTATE 23505)":
 			return nil, fmt.Errorf("email has been used")
 		default:
 			return nil, fmt.Errorf("insert user failed: %v", err)
@@ -119,7 +120,8 @@ func (f *userReq) Admin() (InsertUserImpl, error) {
 		switch err.Error() {
 		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
 			return nil, fmt.Errorf("username has been used")
-		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)":
+		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)",
+			"ERROR: duplicate key value violates unique constraint \"users_email_lower_key\" (SQLSTATE 23505)":
 			return nil, fmt.Errorf("email has been used")
 		default:
 			return nil, fmt.Errorf("insert user failed: %v", err)
@@ -2,7 +2,10 @@ package usersRepositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Doittikorn/go-e-commerce/modules/users"
@@ -18,6 +21,20 @@ type UsersRepositoriesImpl interface {
 	UpdateOauth(req *users.UserToken) error
 	GetProfile(userId string) (*users.User, error)
 	DeleteOauth(oauthId string) error
+	FindSignInAttempt(scope, key string) (*users.SignInAttempt, error)
+	IncreaseSignInAttempt(scope, key string) (*users.SignInAttempt, error)
+	LockSignIn(scope, key string, seconds int) error
+	ResetSignInAttempt(scope, key string) error
+	InsertAdminInvite(req *users.AdminInviteReq) (*users.AdminInvite, error)
+	UseAdminInvite(inviteId, email string) (*users.AdminInvite, error)
+	ReleaseAdminInvite(inviteId string) error
+	InsertOidcState(req *users.OidcState) error
+	UseOidcState(state, provider string) (*users.OidcState, error)
+	FindUserByIdentity(provider, subject string) (*users.User, error)
+	InsertUserIdentity(req *users.UserIdentity) error
+	DeleteUser(userId string) error
+	InsertEmailVerification(userId, tokenHash string) error
+	VerifyEmail(tokenHash string) (*users.EmailVerified, error)
 }
 
 type usersRepository struct {
@@ -31,6 +48,7 @@ func New(db *sqlx.DB) UsersRepositoriesImpl {
 }
 
 func (r *usersRepository) InsertUser(req *users.UserRegisterReq, isAdmin bool) (*users.UserPassport, error) {
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
 	result := usersPatterns.InsertUser(r.db, req, isAdmin)
 	var err error
@@ -54,8 +72,9 @@ func (r *usersRepository) InsertUser(req *users.UserRegisterReq, isAdmin bool) (
 	return user, nil
 }
 
+// email ถูกเก็บเป็นตัวพิมพ์เล็กและไม่ซ้ำกันแบบไม่สนตัวพิมพ์ (unique index บน LOWER("email"))
 func (r *usersRepository) FindOneUserByEmail(email string) (*users.UserCredentialCheck, error) {
-	query := `SELECT "id", "email", "password","username","role_id" FROM users WHERE email = $1;`
+	query := `SELECT "id", "email", "password","username","role_id" FROM users WHERE LOWER("email") = LOWER($1) AND "deleted_at" IS NULL;`
 	user := new(users.UserCredentialCheck)
 	if err := r.db.Get(user, query, email); err != nil {
 		return nil, err
@@ -125,7 +144,8 @@ func (r *usersRepository) GetProfile(userId string) (*users.User, error) {
 		"username",
 		"role_id"
 	FROM "users"
-	WHERE "id" = $1;`
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
 
 	profile := new(users.User)
 	if err := r.db.Get(profile, query, userId); err != nil {
@@ -142,3 +162,374 @@ func (r *usersRepository) DeleteOauth(oauthId string) error {
 	}
 	return nil
 }
+
+func (r *usersRepository) FindSignInAttempt(scope, key string) (*users.SignInAttempt, error) {
+	query := `
+	SELECT
+		"scope",
+		"key",
+		"failed_count",
+		COALESCE("locked_until" > now(), FALSE) AS "is_locked",
+		COALESCE(CEIL(EXTRACT(EPOCH FROM ("locked_until" - now())))::INT, 0) AS "retry_after"
+	FROM "signin_attempts"
+	WHERE "scope" = $1
+	AND "key" = $2;`
+
+	attempt := new(users.SignInAttempt)
+	if err := r.db.Get(attempt, query, scope, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &users.SignInAttempt{Scope: scope, Key: key}, nil
+		}
+		return nil, fmt.Errorf("get signin attempt failed: %v", err)
+	}
+	return attempt, nil
+}
+
+// เพิ่มจำนวนครั้งที่ผิดพลาด ถ้าผิดครั้งล่าสุดนานเกิน window จะเริ่มนับใหม่
+func (r *usersRepository) IncreaseSignInAttempt(scope, key string) (*users.SignInAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "signin_attempts" (
+		"scope",
+		"key",
+		"failed_count",
+		"last_failed_at"
+	)
+	VALUES ($1, $2, 1, now())
+	ON CONFLICT ("scope", "key") DO UPDATE SET
+		"failed_count" = (
+			CASE WHEN "signin_attempts"."last_failed_at" < now() - make_interval(secs => $3)
+			THEN 1
+			ELSE "signin_attempts"."failed_count" + 1 END
+		),
+		"last_failed_at" = now()
+	RETURNING
+		"scope",
+		"key",
+		"failed_count",
+		COALESCE("locked_until" > now(), FALSE) AS "is_locked",
+		0 AS "retry_after";`
+
+	attempt := new(users.SignInAttempt)
+	if err := r.db.QueryRowxContext(ctx, query, scope, key, users.SignInAttemptWindow).StructScan(attempt); err != nil {
+		return nil, fmt.Errorf("increase signin attempt failed: %v", err)
+	}
+	return attempt, nil
+}
+
+func (r *usersRepository) LockSignIn(scope, key string, seconds int) error {
+	query := `
+	UPDATE "signin_attempts" SET
+		"locked_until" = now() + make_interval(secs => $3)
+	WHERE "scope" = $1
+	AND "key" = $2;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, scope, key, seconds); err != nil {
+		return fmt.Errorf("lock signin failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) ResetSignInAttempt(scope, key string) error {
+	query := `DELETE FROM "signin_attempts" WHERE "scope" = $1 AND "key" = $2;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, scope, key); err != nil {
+		return fmt.Errorf("reset signin attempt failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) InsertAdminInvite(req *users.AdminInviteReq) (*users.AdminInvite, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "admin_invites" (
+		"email",
+		"invited_by",
+		"expires_at"
+	)
+	VALUES ($1, $2, now() + make_interval(secs => $3))
+	RETURNING
+		"id",
+		"email",
+		"invited_by",
+		"expires_at";`
+
+	invite := new(users.AdminInvite)
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.Email,
+		req.InvitedBy,
+		users.AdminInviteExpires,
+	).StructScan(invite); err != nil {
+		return nil, fmt.Errorf("insert admin invite failed: %v", err)
+	}
+	return invite, nil
+}
+
+// ใช้ invite ได้ครั้งเดียว ถ้าถูกใช้ไปแล้ว หมดอายุ หรือ email ไม่ตรงจะไม่มี row ที่ถูก update
+func (r *usersRepository) UseAdminInvite(inviteId, email string) (*users.AdminInvite, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE "admin_invites" SET
+		"used_at" = now()
+	WHERE "id" = $1
+	AND LOWER("email") = LOWER($2)
+	AND "used_at" IS NULL
+	AND "expires_at" > now()
+	RETURNING
+		"id",
+		"email",
+		"invited_by",
+		"expires_at";`
+
+	invite := new(users.AdminInvite)
+	if err := r.db.QueryRowxContext(ctx, query, inviteId, email).StructScan(invite); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invite is invalid")
+		}
+		return nil, fmt.Errorf("use admin invite failed: %v", err)
+	}
+	return invite, nil
+}
+
+// คืน invite กลับเมื่อสร้าง admin ไม่สำเร็จ
+func (r *usersRepository) ReleaseAdminInvite(inviteId string) error {
+	query := `UPDATE "admin_invites" SET "used_at" = NULL WHERE "id" = $1;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, inviteId); err != nil {
+		return fmt.Errorf("release admin invite failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) InsertOidcState(req *users.OidcState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// ลบ state ที่หมดอายุไปพร้อมกัน เพราะ user ที่ไม่กลับมาที่ callback จะทิ้ง state ไว้
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM "oidc_states" WHERE "expires_at" < now();`); err != nil {
+		return fmt.Errorf("insert oidc state failed: %v", err)
+	}
+
+	query := `
+	INSERT INTO "oidc_states" (
+		"state",
+		"provider",
+		"code_verifier",
+		"nonce",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5));`
+
+	if _, err := r.db.ExecContext(
+		ctx,
+		query,
+		req.State,
+		req.Provider,
+		req.CodeVerifier,
+		req.Nonce,
+		users.OidcStateExpires,
+	); err != nil {
+		return fmt.Errorf("insert oidc state failed: %v", err)
+	}
+	return nil
+}
+
+// state ใช้ได้ครั้งเดียว จึงลบทิ้งทันทีที่อ่าน
+func (r *usersRepository) UseOidcState(state, provider string) (*users.OidcState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	DELETE FROM "oidc_states"
+	WHERE "state" = $1
+	AND "provider" = $2
+	AND "expires_at" > now()
+	RETURNING
+		"state",
+		"provider",
+		"code_verifier",
+		"nonce";`
+
+	result := new(users.OidcState)
+	if err := r.db.QueryRowxContext(ctx, query, state, provider).StructScan(result); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("state is invalid")
+		}
+		return nil, fmt.Errorf("use oidc state failed: %v", err)
+	}
+	return result, nil
+}
+
+func (r *usersRepository) FindUserByIdentity(provider, subject string) (*users.User, error) {
+	query := `
+	SELECT
+		"u"."id",
+		"u"."email",
+		"u"."username",
+		"u"."role_id"
+	FROM "user_identities" "i"
+	JOIN "users" "u" ON "u"."id" = "i"."user_id"
+	WHERE "i"."provider" = $1
+	AND "i"."subject" = $2
+	AND "u"."deleted_at" IS NULL;`
+
+	user := new(users.User)
+	if err := r.db.Get(user, query, provider, subject); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *usersRepository) InsertUserIdentity(req *users.UserIdentity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "user_identities" (
+		"user_id",
+		"provider",
+		"subject",
+		"email"
+	)
+	VALUES ($1, $2, $3, NULLIF($4, ''))
+	RETURNING "id";`
+
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		req.UserId,
+		req.Provider,
+		req.Subject,
+		req.Email,
+	).Scan(&req.Id); err != nil {
+		return fmt.Errorf("insert user identity failed: %v", err)
+	}
+	return nil
+}
+
+// ย้าย user ไปถังขยะและลบ token ทั้งหมดของ user ทำให้ sign in และใช้ token เดิมไม่ได้ทันที
+func (r *usersRepository) DeleteUser(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"deleted_at" = now()
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
+
+	result, err := tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("user not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "oauth" WHERE "user_id" = $1;`, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete oauth failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ขอ link ใหม่จะแทนที่ token เดิมของ user
+func (r *usersRepository) InsertEmailVerification(userId, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO "email_verifications" (
+		"user_id",
+		"token_hash",
+		"expires_at"
+	)
+	VALUES ($1, $2, now() + make_interval(secs => $3))
+	ON CONFLICT ("user_id") DO UPDATE SET
+		"token_hash" = EXCLUDED."token_hash",
+		"expires_at" = EXCLUDED."expires_at";`
+
+	if _, err := r.db.ExecContext(ctx, query, userId, tokenHash, users.EmailVerificationExpires); err != nil {
+		return fmt.Errorf("insert email verification failed: %v", err)
+	}
+	return nil
+}
+
+// ใช้ token ได้ครั้งเดียว เมื่อยืนยันแล้วจะย้าย order ที่สั่งโดยไม่ได้สมัครสมาชิกด้วย email นี้เข้าบัญชี
+func (r *usersRepository) VerifyEmail(tokenHash string) (*users.EmailVerified, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(users.EmailVerified)
+	query := `
+	DELETE FROM "email_verifications"
+	WHERE "token_hash" = $1
+	AND "expires_at" > now()
+	RETURNING "user_id";`
+
+	if err := tx.QueryRowxContext(ctx, query, tokenHash).Scan(&result.UserId); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("token is invalid")
+		}
+		return nil, fmt.Errorf("verify email failed: %v", err)
+	}
+
+	query = `
+	UPDATE "users" SET
+		"email_verified_at" = now()
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL
+	RETURNING "email";`
+
+	if err := tx.QueryRowxContext(ctx, query, result.UserId).Scan(&result.Email); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("token is invalid")
+		}
+		return nil, fmt.Errorf("verify email failed: %v", err)
+	}
+
+	query = `
+	UPDATE "orders" SET
+		"user_id" = $1,
+		"lookup_token_hash" = NULL
+	WHERE "user_id" IS NULL
+	AND LOWER("guest_email") = LOWER($2);`
+
+	claimed, err := tx.ExecContext(ctx, query, result.UserId, result.Email)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("claim guest orders failed: %v", err)
+	}
+	result.ClaimedOrders, _ = claimed.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
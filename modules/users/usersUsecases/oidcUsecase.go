@@ -0,0 +1,205 @@
+package usersUsecases
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/modules/audits"
+	"github.com/Doittikorn/go-e-commerce/modules/users"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc"
+)
+
+// error ที่เกิดจากฝั่ง provider จะตอบกลับด้วยข้อความเดียว รายละเอียดดูได้จาก log
+const oidcSignInFailedMsg = "oidc sign in failed"
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._]`)
+
+func (u *usersUsecase) OidcAuthorize(provider string) (*users.OidcAuthorize, error) {
+	p, ok := u.oidcProviders.Get(provider)
+	if !ok {
+		return nil, fmt.Errorf("provider not found")
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	url, err := p.AuthCodeUrl(ctx, state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		log.Printf("oidc authorize failed: %v\n", err)
+		return nil, fmt.Errorf(oidcSignInFailedMsg)
+	}
+
+	if err := u.usersRepository.InsertOidcState(&users.OidcState{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &users.OidcAuthorize{
+		Provider:         provider,
+		AuthorizationUrl: url,
+	}, nil
+}
+
+func (u *usersUsecase) OidcSignIn(provider string, req *users.OidcCallbackReq, ip string) (*users.UserPassport, error) {
+	p, ok := u.oidcProviders.Get(provider)
+	if !ok {
+		return nil, fmt.Errorf("provider not found")
+	}
+
+	state, err := u.usersRepository.UseOidcState(req.State, provider)
+	if err != nil {
+		return nil, err
+	}
+	if req.Error != "" || req.Code == "" {
+		return nil, fmt.Errorf("authorization denied")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	token, err := p.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc sign in failed: %v\n", err)
+		return nil, fmt.Errorf(oidcSignInFailedMsg)
+	}
+	claims, err := p.VerifyIdToken(ctx, token.IdToken, state.Nonce)
+	if err != nil {
+		log.Printf("oidc sign in failed: %v\n", err)
+		return nil, fmt.Errorf(oidcSignInFailedMsg)
+	}
+
+	user, err := u.findOrCreateOidcUser(provider, claims, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.signPassport(&users.UserResponse{
+		Id:       user.Id,
+		Email:    user.Email,
+		Username: user.Username,
+		RoleId:   user.RoleId,
+	})
+}
+
+// หา user จาก identity ที่เคยผูกไว้ ถ้าไม่เจอจะผูกกับ user ที่มี email เดียวกัน
+// (เฉพาะเมื่อ provider ยืนยัน email แล้ว) หรือสร้าง customer ใหม่
+func (u *usersUsecase) findOrCreateOidcUser(provider string, claims *oidc.IdTokenClaims, ip string) (*users.User, error) {
+	user, err := u.usersRepository.FindUserByIdentity(provider, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("find user identity failed: %v", err)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	identity := &users.UserIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    email,
+	}
+
+	if email != "" {
+		existing, err := u.usersRepository.FindOneUserByEmail(email)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if existing != nil {
+			// ไม่ผูกกับ email ที่ provider ไม่ได้ยืนยัน เพื่อกันการยึดบัญชีคนอื่น
+			if !claims.IsEmailVerified() {
+				return nil, fmt.Errorf("email has been used")
+			}
+			identity.UserId = existing.Id
+			if err := u.usersRepository.InsertUserIdentity(identity); err != nil {
+				return nil, err
+			}
+			if err := u.auditsRepository.InsertAudit(&audits.Audit{
+				ActorId: existing.Id,
+				Action:  audits.IdentityLinked,
+				Target:  existing.Id,
+				Ip:      ip,
+				Detail: map[string]any{
+					"provider": provider,
+					"subject":  claims.Subject,
+				},
+			}); err != nil {
+				log.Printf("oidc sign in failed: %v\n", err)
+			}
+			return &users.User{
+				Id:       existing.Id,
+				Email:    existing.Email,
+				Username: existing.Username,
+				RoleId:   existing.RoleId,
+			}, nil
+		}
+	}
+
+	// provider บางตัวไม่ส่ง email มา (เช่น LINE ที่ไม่ได้ขอ scope email) จึงใช้ email ที่ไม่มีอยู่จริงแทน
+	// email ที่ provider ไม่ได้ยืนยันก็ใช้ไม่ได้ เพื่อไม่ให้จอง email ของคนอื่นไว้กับบัญชีนี้
+	if email == "" || !claims.IsEmailVerified() {
+		email = fmt.Sprintf("%s.%s@users.noreply", provider, strings.ToLower(claims.Subject))
+	}
+
+	// password สุ่มที่ไม่มีใครรู้ user นี้จึง sign in ได้ผ่าน provider เท่านั้น
+	password, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("generate username failed: %v", err)
+	}
+	req := &users.UserRegisterReq{
+		Email:    email,
+		Username: fmt.Sprintf("%s_%x", oidcUsername(email, provider), suffix),
+		Password: password,
+	}
+	passport, err := u.InsertCustomer(req)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.UserId = passport.User.Id
+	if err := u.usersRepository.InsertUserIdentity(identity); err != nil {
+		return nil, err
+	}
+	return &users.User{
+		Id:       passport.User.Id,
+		Email:    passport.User.Email,
+		Username: passport.User.Username,
+		RoleId:   passport.User.RoleId,
+	}, nil
+}
+
+func oidcUsername(email, provider string) string {
+	name := strings.SplitN(email, "@", 2)[0]
+	name = usernameInvalidChars.ReplaceAllString(strings.ToLower(name), "")
+	if name == "" || strings.HasSuffix(email, "@users.noreply") {
+		return provider
+	}
+	return name
+}
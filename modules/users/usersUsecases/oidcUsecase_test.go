@@ -0,0 +1,320 @@
+package usersUsecases
+
+import (
+	"crypto"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/audits"
+	"github.com/Doittikorn/go-e-commerce/modules/users"
+	"github.com/Doittikorn/go-e-commerce/modules/users/usersRepositories"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testProvider = "mock"
+
+type testConfig struct {
+	config.ConfigImpl
+}
+
+func (testConfig) JWT() config.JWTConfigImpl { return testJwtConfig{} }
+
+// sign access token ด้วย secret แบบเดิม ไม่ต้องมี signing key
+type testJwtConfig struct {
+	config.JWTConfigImpl
+}
+
+func (testJwtConfig) SecretKey() []byte         { return []byte("secret") }
+func (testJwtConfig) SigningKey() crypto.Signer { return nil }
+func (testJwtConfig) AccessExpiresAt() int      { return 60 }
+func (testJwtConfig) RefreshExpiresAt() int     { return 60 }
+
+// repository ในหน่วยความจำ เฉพาะส่วนที่ flow ของ oidc ใช้
+type memoryUsersRepository struct {
+	usersRepositories.UsersRepositoriesImpl
+
+	mu         sync.Mutex
+	users      map[string]*users.UserCredentialCheck
+	states     map[string]*users.OidcState
+	identities map[string]*users.UserIdentity
+}
+
+func newMemoryUsersRepository() *memoryUsersRepository {
+	return &memoryUsersRepository{
+		users:      make(map[string]*users.UserCredentialCheck),
+		states:     make(map[string]*users.OidcState),
+		identities: make(map[string]*users.UserIdentity),
+	}
+}
+
+func (r *memoryUsersRepository) InsertUser(req *users.UserRegisterReq, isAdmin bool) (*users.UserPassport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == req.Email {
+			return nil, fmt.Errorf("email has been used")
+		}
+	}
+	user := &users.UserCredentialCheck{
+		Id:       fmt.Sprintf("U%06d", len(r.users)+1),
+		Email:    req.Email,
+		Password: req.Password,
+		Username: req.Username,
+		RoleId:   1,
+	}
+	r.users[user.Id] = user
+	return &users.UserPassport{User: &users.UserResponse{
+		Id:       user.Id,
+		Email:    user.Email,
+		Username: user.Username,
+		RoleId:   user.RoleId,
+	}}, nil
+}
+
+func (r *memoryUsersRepository) FindOneUserByEmail(email string) (*users.UserCredentialCheck, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memoryUsersRepository) InsertOauth(req *users.UserPassport) error { return nil }
+
+func (r *memoryUsersRepository) InsertOidcState(req *users.OidcState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.states[req.State] = req
+	return nil
+}
+
+func (r *memoryUsersRepository) UseOidcState(state, provider string) (*users.OidcState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.states[state]
+	if !ok || s.Provider != provider {
+		return nil, fmt.Errorf("state is invalid")
+	}
+	delete(r.states, state)
+	return s, nil
+}
+
+func (r *memoryUsersRepository) FindUserByIdentity(provider, subject string) (*users.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[provider+"|"+subject]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	u := r.users[identity.UserId]
+	return &users.User{Id: u.Id, Email: u.Email, Username: u.Username, RoleId: u.RoleId}, nil
+}
+
+func (r *memoryUsersRepository) InsertUserIdentity(req *users.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.identities[req.Provider+"|"+req.Subject] = req
+	return nil
+}
+
+type memoryAuditsRepository struct {
+	audits []*audits.Audit
+}
+
+func (r *memoryAuditsRepository) InsertAudit(req *audits.Audit) error {
+	r.audits = append(r.audits, req)
+	return nil
+}
+
+type oidcFixture struct {
+	issuer  *oidctest.Issuer
+	repo    *memoryUsersRepository
+	audits  *memoryAuditsRepository
+	usecase UsersUsecasesImpl
+}
+
+func newOidcFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	issuer := oidctest.NewIssuer("client-id", "client-secret")
+	t.Cleanup(issuer.Close)
+
+	f := &oidcFixture{
+		issuer: issuer,
+		repo:   newMemoryUsersRepository(),
+		audits: &memoryAuditsRepository{},
+	}
+	registry := oidc.NewRegistry([]*oidc.Config{{
+		Name:         testProvider,
+		Issuer:       issuer.URL,
+		ClientId:     issuer.ClientId,
+		ClientSecret: issuer.ClientSecret,
+		RedirectUrl:  "http://localhost/callback",
+	}}, nil)
+	f.usecase = New(testConfig{}, f.repo, f.audits, registry, mailer.NewLog())
+	return f
+}
+
+// เริ่ม flow ตั้งแต่ขอ authorization url จนได้ callback ที่ provider ส่งกลับมา
+func (f *oidcFixture) callback(t *testing.T, claims jwt.MapClaims) *users.OidcCallbackReq {
+	t.Helper()
+	authorize, err := f.usecase.OidcAuthorize(testProvider)
+	if err != nil {
+		t.Fatalf("oidc authorize failed: %v", err)
+	}
+	code, err := f.issuer.Authorize(authorize.AuthorizationUrl, claims)
+	if err != nil {
+		t.Fatalf("authorize at issuer failed: %v", err)
+	}
+
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	for state := range f.repo.states {
+		if strings.Contains(authorize.AuthorizationUrl, "state="+state) {
+			return &users.OidcCallbackReq{Code: code, State: state}
+		}
+	}
+	t.Fatal("state not found in authorization url")
+	return nil
+}
+
+func (f *oidcFixture) insertUser(t *testing.T, email string) string {
+	t.Helper()
+	passport, err := f.repo.InsertUser(&users.UserRegisterReq{Email: email, Username: "existing"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passport.User.Id
+}
+
+func TestOidcSignInStateIsSingleUse(t *testing.T) {
+	f := newOidcFixture(t)
+
+	req := f.callback(t, jwt.MapClaims{"sub": "subject-1"})
+	if _, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1"); err != nil {
+		t.Fatalf("oidc sign in failed: %v", err)
+	}
+	if _, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1"); err == nil || err.Error() != "state is invalid" {
+		t.Fatalf("err = %v, want state is invalid", err)
+	}
+}
+
+func TestOidcSignInRequiresCodeVerifier(t *testing.T) {
+	f := newOidcFixture(t)
+
+	req := f.callback(t, jwt.MapClaims{"sub": "subject-1"})
+	f.repo.mu.Lock()
+	f.repo.states[req.State].CodeVerifier = "other-verifier"
+	f.repo.mu.Unlock()
+
+	if _, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1"); err == nil || err.Error() != oidcSignInFailedMsg {
+		t.Fatalf("err = %v, want %s", err, oidcSignInFailedMsg)
+	}
+}
+
+func TestOidcSignInRejectsWrongNonce(t *testing.T) {
+	f := newOidcFixture(t)
+
+	req := f.callback(t, jwt.MapClaims{"sub": "subject-1", "nonce": "replayed"})
+	if _, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1"); err == nil || err.Error() != oidcSignInFailedMsg {
+		t.Fatalf("err = %v, want %s", err, oidcSignInFailedMsg)
+	}
+}
+
+func TestOidcSignInLinksVerifiedEmail(t *testing.T) {
+	f := newOidcFixture(t)
+	userId := f.insertUser(t, "buyer@example.com")
+
+	req := f.callback(t, jwt.MapClaims{"sub": "subject-1", "email": "Buyer@Example.com", "email_verified": true})
+	passport, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("oidc sign in failed: %v", err)
+	}
+	if passport.User.Id != userId {
+		t.Fatalf("user id = %q, want existing user %q", passport.User.Id, userId)
+	}
+	if len(f.audits.audits) != 1 || f.audits.audits[0].Action != audits.IdentityLinked {
+		t.Fatalf("audits = %+v, want one identity_linked", f.audits.audits)
+	}
+
+	// sign in ครั้งต่อไปหา user จาก identity ที่ผูกไว้
+	req = f.callback(t, jwt.MapClaims{"sub": "subject-1", "email": "changed@example.com", "email_verified": true})
+	passport, err = f.usecase.OidcSignIn(testProvider, req, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("oidc sign in failed: %v", err)
+	}
+	if passport.User.Id != userId {
+		t.Fatalf("user id = %q, want linked user %q", passport.User.Id, userId)
+	}
+}
+
+func TestOidcSignInDoesNotLinkUnverifiedEmail(t *testing.T) {
+	f := newOidcFixture(t)
+	f.insertUser(t, "buyer@example.com")
+
+	req := f.callback(t, jwt.MapClaims{"sub": "subject-1", "email": "buyer@example.com", "email_verified": false})
+	if _, err := f.usecase.OidcSignIn(testProvider, req, "127.0.0.1"); err == nil || err.Error() != "email has been used" {
+		t.Fatalf("err = %v, want email has been used", err)
+	}
+	if len(f.repo.identities) != 0 {
+		t.Fatalf("identities = %d, want none", len(f.repo.identities))
+	}
+}
+
+func TestOidcSignInCreatesUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		wantEmail string
+	}{
+		{
+			name:      "verified email",
+			claims:    jwt.MapClaims{"sub": "Subject-1", "email": "New@Example.com", "email_verified": "true"},
+			wantEmail: "new@example.com",
+		},
+		{
+			name:      "unverified email",
+			claims:    jwt.MapClaims{"sub": "Subject-1", "email": "new@example.com", "email_verified": false},
+			wantEmail: testProvider + ".subject-1@users.noreply",
+		},
+		{
+			name:      "no email",
+			claims:    jwt.MapClaims{"sub": "Subject-1"},
+			wantEmail: testProvider + ".subject-1@users.noreply",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOidcFixture(t)
+
+			passport, err := f.usecase.OidcSignIn(testProvider, f.callback(t, tt.claims), "127.0.0.1")
+			if err != nil {
+				t.Fatalf("oidc sign in failed: %v", err)
+			}
+			if passport.User.Email != tt.wantEmail {
+				t.Fatalf("email = %q, want %q", passport.User.Email, tt.wantEmail)
+			}
+			if passport.Token == nil || passport.Token.AccessToken == "" {
+				t.Fatal("access token is empty")
+			}
+			if _, ok := f.repo.identities[testProvider+"|Subject-1"]; !ok {
+				t.Fatal("identity was not linked to the new user")
+			}
+		})
+	}
+}
@@ -1,34 +1,65 @@
 package usersUsecases
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/Doittikorn/go-e-commerce/config"
+	"github.com/Doittikorn/go-e-commerce/modules/audits"
+	"github.com/Doittikorn/go-e-commerce/modules/audits/auditsRepositories"
 	"github.com/Doittikorn/go-e-commerce/modules/users"
 	"github.com/Doittikorn/go-e-commerce/modules/users/usersRepositories"
 	"github.com/Doittikorn/go-e-commerce/pkg/auth"
+	"github.com/Doittikorn/go-e-commerce/pkg/mailer"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersUsecasesImpl interface {
 	InsertCustomer(req *users.UserRegisterReq) (*users.UserPassport, error)
-	GetPassport(req *users.UserCredential) (*users.UserPassport, error)
+	SignUpCustomer(req *users.UserRegisterReq) (*users.UserPassport, error)
+	VerifyEmail(token string) (*users.EmailVerified, error)
+	GetPassport(req *users.UserCredential, ip string) (*users.UserPassport, error)
 	RefreshPassport(req *users.UserRefreshCredential) (*users.UserPassport, error)
 	DeleteOauth(oauthId string) error
 	GetUserProfile(userId string) (*users.User, error)
+	DeleteUser(userId, actorId, ip string) error
 
-	InsertAdmin(req *users.UserRegisterReq) (*users.UserPassport, error)
+	InsertAdmin(req *users.UserRegisterReq, inviteId, ip string) (*users.UserPassport, error)
+	InviteAdmin(req *users.AdminInviteReq, ip string) (*users.AdminInvite, error)
+
+	OidcAuthorize(provider string) (*users.OidcAuthorize, error)
+	OidcSignIn(provider string, req *users.OidcCallbackReq, ip string) (*users.UserPassport, error)
 }
 
+// ข้อความ error ของการ sign in ต้องเหมือนกันทุกกรณี เพื่อไม่ให้รู้ว่า email มีอยู่จริงหรือไม่
+const (
+	signInInvalidMsg = "email or password is invalid"
+)
+
+// ใช้ compare แทนเมื่อไม่พบ user เพื่อให้เวลาตอบกลับใกล้เคียงกับกรณีที่พบ user
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("never gonna let you down"), 10)
+
 type usersUsecase struct {
-	cfg             config.ConfigImpl
-	usersRepository usersRepositories.UsersRepositoriesImpl
+	cfg              config.ConfigImpl
+	usersRepository  usersRepositories.UsersRepositoriesImpl
+	auditsRepository auditsRepositories.IAuditsRepository
+	oidcProviders    oidc.RegistryImpl
+	mailer           mailer.MailerImpl
 }
 
-func New(cfg config.ConfigImpl, userRepository usersRepositories.UsersRepositoriesImpl) UsersUsecasesImpl {
+func New(cfg config.ConfigImpl, userRepository usersRepositories.UsersRepositoriesImpl, auditsRepository auditsRepositories.IAuditsRepository, oidcProviders oidc.RegistryImpl, mailer mailer.MailerImpl) UsersUsecasesImpl {
 	return &usersUsecase{
-		cfg:             cfg,
-		usersRepository: userRepository,
+		cfg:              cfg,
+		usersRepository:  userRepository,
+		auditsRepository: auditsRepository,
+		oidcProviders:    oidcProviders,
+		mailer:           mailer,
 	}
 }
 
@@ -49,10 +80,60 @@ func (u *usersUsecase) InsertCustomer(req *users.UserRegisterReq) (*users.UserPa
 	return result, nil
 }
 
-func (u *usersUsecase) InsertAdmin(req *users.UserRegisterReq) (*users.UserPassport, error) {
+// สมัครสมาชิกด้วย email และ password แล้วส่ง link ยืนยัน email
+// email ยังไม่ถูกยืนยันตอนสมัคร จึงยังไม่ย้าย order ของ guest เข้าบัญชีจนกว่าจะยืนยัน
+func (u *usersUsecase) SignUpCustomer(req *users.UserRegisterReq) (*users.UserPassport, error) {
+	result, err := u.InsertCustomer(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// สมัครสมาชิกได้แล้ว ส่ง email ไม่ได้จึงไม่ทำให้การสมัครล้มเหลว
+	if err := u.sendEmailVerification(result.User); err != nil {
+		log.Printf("send email verification of user: %s failed: %v\n", result.User.Id, err)
+	}
+	return result, nil
+}
+
+func (u *usersUsecase) sendEmailVerification(user *users.UserResponse) error {
+	token, err := oidc.RandomString(32)
+	if err != nil {
+		return err
+	}
+	if err := u.usersRepository.InsertEmailVerification(user.Id, users.HashVerificationToken(token)); err != nil {
+		return err
+	}
+	return u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Please verify your email at:\n%s?token=%s\n\nOrders placed with this email without an account will be moved into your account once it is verified.\n",
+			u.cfg.Mail().VerifyEmailUrl(),
+			url.QueryEscape(token),
+		),
+	})
+}
+
+func (u *usersUsecase) VerifyEmail(token string) (*users.EmailVerified, error) {
+	if token == "" {
+		return nil, fmt.Errorf("token is invalid")
+	}
+	return u.usersRepository.VerifyEmail(users.HashVerificationToken(token))
+}
+
+// สมัคร admin ได้เฉพาะเมื่อมี invite ที่ยังไม่ถูกใช้และ email ตรงกัน
+func (u *usersUsecase) InsertAdmin(req *users.UserRegisterReq, inviteId, ip string) (*users.UserPassport, error) {
+	if inviteId == "" {
+		return nil, fmt.Errorf("invite is invalid")
+	}
+	invite, err := u.usersRepository.UseAdminInvite(inviteId, req.Email)
+	if err != nil {
+		return nil, err
+	}
 
 	// hash password
 	if err := req.BcryptHashing(); err != nil {
+		u.releaseAdminInvite(invite.Id)
 		return nil, err
 	}
 
@@ -60,22 +141,171 @@ func (u *usersUsecase) InsertAdmin(req *users.UserRegisterReq) (*users.UserPassp
 	result, err := u.usersRepository.InsertUser(req, true)
 
 	if err != nil {
+		u.releaseAdminInvite(invite.Id)
 		return nil, err
 	}
 
+	if err := u.auditsRepository.InsertAudit(&audits.Audit{
+		ActorId: invite.InvitedBy,
+		Action:  audits.AdminCreated,
+		Target:  result.User.Id,
+		Ip:      ip,
+		Detail: map[string]any{
+			"email":     result.User.Email,
+			"invite_id": invite.Id,
+		},
+	}); err != nil {
+		log.Printf("insert admin failed: %v\n", err)
+	}
+
 	return result, nil
 }
 
-func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPassport, error) {
-	user, err := u.usersRepository.FindOneUserByEmail(req.Email)
+func (u *usersUsecase) releaseAdminInvite(inviteId string) {
+	if err := u.usersRepository.ReleaseAdminInvite(inviteId); err != nil {
+		log.Printf("insert admin failed: %v\n", err)
+	}
+}
+
+func (u *usersUsecase) InviteAdmin(req *users.AdminInviteReq, ip string) (*users.AdminInvite, error) {
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if !users.IsEmail(req.Email) {
+		return nil, fmt.Errorf("invalid email")
+	}
 
+	invite, err := u.usersRepository.InsertAdminInvite(req)
 	if err != nil {
 		return nil, err
 	}
+
+	token := auth.NewAdminInvite(
+		u.cfg.JWT(),
+		invite.Id,
+		time.Now().Add(time.Duration(users.AdminInviteExpires)*time.Second),
+	)
+	invite.Token = token.SignToken()
+
+	if err := u.auditsRepository.InsertAudit(&audits.Audit{
+		ActorId: req.InvitedBy,
+		Action:  audits.AdminInvited,
+		Target:  invite.Email,
+		Ip:      ip,
+		Detail: map[string]any{
+			"invite_id": invite.Id,
+		},
+	}); err != nil {
+		log.Printf("invite admin failed: %v\n", err)
+	}
+
+	return invite, nil
+}
+
+// ตรวจสอบว่า email หรือ ip นี้ถูก lock อยู่หรือไม่ ถ้าถูก lock ทั้งคู่จะรอจนกว่าตัวที่ปลดช้ากว่าจะหมดเวลา
+func (u *usersUsecase) checkSignInLocked(email, ip string) error {
+	scopes := map[string]string{
+		users.SignInScopeEmail: email,
+		users.SignInScopeIp:    ip,
+	}
+	var locked *users.SignInLockedError
+	for scope, key := range scopes {
+		attempt, err := u.usersRepository.FindSignInAttempt(scope, key)
+		if err != nil {
+			return err
+		}
+		if !attempt.IsLocked {
+			continue
+		}
+		if locked == nil {
+			locked = &users.SignInLockedError{RetryAfter: 1}
+		}
+		if attempt.RetryAfter > locked.RetryAfter {
+			locked.RetryAfter = attempt.RetryAfter
+		}
+	}
+	if locked != nil {
+		return locked
+	}
+	return nil
+}
+
+// บันทึกการ sign in ที่ผิดพลาด และ lock เมื่อผิดเกินจำนวนที่กำหนด
+func (u *usersUsecase) signInFailed(email, ip string) {
+	limits := []struct {
+		scope       string
+		key         string
+		maxAttempts int
+	}{
+		{users.SignInScopeEmail, email, users.SignInMaxEmailAttempts},
+		{users.SignInScopeIp, ip, users.SignInMaxIpAttempts},
+	}
+
+	for _, l := range limits {
+		attempt, err := u.usersRepository.IncreaseSignInAttempt(l.scope, l.key)
+		if err != nil {
+			log.Printf("signin failed: %v\n", err)
+			continue
+		}
+
+		seconds := attempt.LockoutDuration(l.maxAttempts)
+		if seconds == 0 {
+			continue
+		}
+		if err := u.usersRepository.LockSignIn(l.scope, l.key, seconds); err != nil {
+			log.Printf("signin failed: %v\n", err)
+			continue
+		}
+
+		if err := u.auditsRepository.InsertAudit(&audits.Audit{
+			Action: audits.SignInLocked,
+			Target: l.key,
+			Ip:     ip,
+			Detail: map[string]any{
+				"scope":        l.scope,
+				"failed_count": attempt.FailedCount,
+				"lock_seconds": seconds,
+			},
+		}); err != nil {
+			log.Printf("signin failed: %v\n", err)
+		}
+	}
+}
+
+func (u *usersUsecase) GetPassport(req *users.UserCredential, ip string) (*users.UserPassport, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if err := u.checkSignInLocked(email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := u.usersRepository.FindOneUserByEmail(email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		u.signInFailed(email, ip)
+		return nil, fmt.Errorf(signInInvalidMsg)
+	}
 	// compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("password is invalid")
+		u.signInFailed(email, ip)
+		return nil, fmt.Errorf(signInInvalidMsg)
+	}
+
+	// sign in สำเร็จ เริ่มนับใหม่เฉพาะ email เพราะ ip อาจถูกใช้ร่วมกันหลายคน
+	if err := u.usersRepository.ResetSignInAttempt(users.SignInScopeEmail, email); err != nil {
+		return nil, err
 	}
+	return u.signPassport(&users.UserResponse{
+		Id:       user.Id,
+		Email:    user.Email,
+		Username: user.Username,
+		RoleId:   user.RoleId,
+	})
+}
+
+// สร้าง token ชุดใหม่ให้ user และบันทึกลง oauth
+func (u *usersUsecase) signPassport(user *users.UserResponse) (*users.UserPassport, error) {
 	// Sign Token
 	accessToken, err := auth.New(auth.Access, u.cfg.JWT(), &users.UserClaims{
 		Id:     user.Id,
@@ -95,12 +325,7 @@ func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPasspo
 	}
 	// Set passport
 	passport := &users.UserPassport{
-		User: &users.UserResponse{
-			Id:       user.Id,
-			Email:    user.Email,
-			Username: user.Username,
-			RoleId:   user.RoleId,
-		},
+		User: user,
 		Token: &users.UserToken{
 			AccessToken:  accessToken.SignToken(),
 			RefreshToken: refreshToken.SignToken(),
@@ -194,3 +419,23 @@ func (u *usersUsecase) GetUserProfile(userId string) (*users.User, error) {
 	}
 	return profile, nil
 }
+
+// admin ลบตัวเองไม่ได้ เพื่อไม่ให้ระบบไม่เหลือ admin ที่ใช้งานได้
+func (u *usersUsecase) DeleteUser(userId, actorId, ip string) error {
+	if userId == actorId {
+		return fmt.Errorf("cannot delete yourself")
+	}
+	if err := u.usersRepository.DeleteUser(userId); err != nil {
+		return err
+	}
+
+	if err := u.auditsRepository.InsertAudit(&audits.Audit{
+		ActorId: actorId,
+		Action:  audits.UserDeleted,
+		Target:  userId,
+		Ip:      ip,
+	}); err != nil {
+		log.Printf("delete user failed: %v\n", err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"math"
@@ -17,7 +19,6 @@ const (
 	Access  TokeyType = "access"
 	Refresh TokeyType = "refresh"
 	Admin   TokeyType = "admin"
-	ApiKey  TokeyType = "apiKey"
 )
 
 type auth struct {
@@ -29,10 +30,6 @@ type admin struct {
 	*auth
 }
 
-type authApiKey struct {
-	*auth
-}
-
 type authMapClaims struct {
 	Claims *users.UserClaims `json:"claims"`
 	jwt.RegisteredClaims
@@ -65,8 +62,6 @@ func New(tokenType TokeyType, cfg config.JWTConfigImpl, claims *users.UserClaims
 		return newRefreshToken(cfg, claims), nil
 	case Admin:
 		return newAdminToken(cfg), nil
-	case ApiKey:
-		return newApiKey(cfg), nil
 	default:
 		return nil, fmt.Errorf("unknown token type")
 	}
@@ -75,10 +70,7 @@ func New(tokenType TokeyType, cfg config.JWTConfigImpl, claims *users.UserClaims
 // check token ว่าถูกสร้างขึ้นโดยเราหรือไม่
 func ParseToken(cfg config.JWTConfigImpl, tokenString string) (*authMapClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("signing method is invalid")
-		}
-		return cfg.SecretKey(), nil
+		return verifyKey(cfg, t)
 	})
 
 	if err != nil {
@@ -98,6 +90,27 @@ func ParseToken(cfg config.JWTConfigImpl, tokenString string) (*authMapClaims, e
 	}
 }
 
+// api key แบบ JWT รุ่นเก่า ใช้ได้จนถึง JWT_API_KEY_LEGACY_UNTIL เท่านั้น
+func ParseLegacyApiKey(cfg config.JWTConfigImpl, tokenString string) error {
+	if !cfg.AcceptsLegacyApiKey() {
+		return fmt.Errorf("legacy api key is not accepted")
+	}
+
+	claims := new(authMapClaims)
+	if _, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("signing method is invalid")
+		}
+		return cfg.ApiKey(), nil
+	}); err != nil {
+		return fmt.Errorf("parse legacy api key failed: %v", err)
+	}
+	if claims.Subject != "api-key" {
+		return fmt.Errorf("legacy api key is invalid")
+	}
+	return nil
+}
+
 func ParseAdminToken(cfg config.JWTConfigImpl, tokenString string) (*authMapClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -123,6 +136,33 @@ func ParseAdminToken(cfg config.JWTConfigImpl, tokenString string) (*authMapClai
 	}
 }
 
+// เลือก key ตาม alg และ kid ของ token ทำให้ token ที่ sign ด้วย key ก่อนหน้ายังใช้ได้จนกว่าจะหมดอายุ
+func verifyKey(cfg config.JWTConfigImpl, t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if !cfg.AcceptsSecretKey() {
+			return nil, fmt.Errorf("signing method is invalid")
+		}
+		return cfg.SecretKey(), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		kid, _ := t.Header["kid"].(string)
+		key, ok := cfg.PublicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("kid is invalid")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("signing method is invalid")
+	}
+}
+
+func signingMethod(key crypto.Signer) jwt.SigningMethod {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
 func RepeatToken(cfg config.JWTConfigImpl, claims *users.UserClaims, exp int64) string {
 	obj := &auth{
 		cfg: cfg,
@@ -141,9 +181,18 @@ func RepeatToken(cfg config.JWTConfigImpl, claims *users.UserClaims, exp int64)
 	return obj.SignToken()
 }
 
+// sign ด้วย key ปัจจุบันพร้อม kid ถ้าตั้งค่า key ไว้ ไม่อย่างนั้นใช้ secret แบบเดิม
 func (a *auth) SignToken() string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
-	signedToken, _ := token.SignedString([]byte(a.cfg.SecretKey()))
+	key := a.cfg.SigningKey()
+	if key == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
+		signedToken, _ := token.SignedString([]byte(a.cfg.SecretKey()))
+		return signedToken
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key), a.mapClaims)
+	token.Header["kid"] = a.cfg.SigningKeyId()
+	signedToken, _ := token.SignedString(key)
 	return signedToken
 }
 
@@ -153,12 +202,6 @@ func (a *admin) SignToken() string {
 	return signedToken
 }
 
-func (a *authApiKey) SignToken() string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
-	signedToken, _ := token.SignedString([]byte(a.cfg.ApiKey()))
-	return signedToken
-}
-
 func newAccessToken(cfg config.JWTConfigImpl, claims *users.UserClaims) AuthImpl {
 	return &auth{
 		cfg: cfg,
@@ -215,41 +258,19 @@ func newAdminToken(cfg config.JWTConfigImpl) AuthImpl {
 
 }
 
-func ParseApiKey(cfg config.JWTConfigImpl, tokenString string) (*authMapClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("signing method is invalid")
-		}
-		return cfg.ApiKey(), nil
-	})
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, fmt.Errorf("token format is invalid")
-		} else if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, fmt.Errorf("token had expired")
-		} else {
-			return nil, fmt.Errorf("parse token failed: %v", err)
-		}
-	}
-
-	if claims, ok := token.Claims.(*authMapClaims); ok {
-		return claims, nil
-	} else {
-		return nil, fmt.Errorf("claims type is invalid")
-	}
-}
-
-func newApiKey(cfg config.JWTConfigImpl) AuthImpl {
-	return &authApiKey{
+// สร้าง token สำหรับเชิญ admin ใหม่ โดยใช้ id ของ invite เป็น jti
+func NewAdminInvite(cfg config.JWTConfigImpl, inviteId string, expiresAt time.Time) AuthImpl {
+	return &admin{
 		auth: &auth{
 			cfg: cfg,
 			mapClaims: &authMapClaims{
 				Claims: nil,
 				RegisteredClaims: jwt.RegisteredClaims{
+					ID:        inviteId,
 					Issuer:    "go-e-commerce",
-					Subject:   "api-key",
-					Audience:  []string{"admin", "customer"},
-					ExpiresAt: jwt.NewNumericDate(time.Now().AddDate(2, 0, 0)),
+					Subject:   "admin-invite",
+					Audience:  []string{"admin"},
+					ExpiresAt: jwt.NewNumericDate(expiresAt),
 					NotBefore: jwt.NewNumericDate(time.Now()),
 					IssuedAt:  jwt.NewNumericDate(time.Now()),
 				},
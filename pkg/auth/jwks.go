@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+)
+
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JsonWebKeySet struct {
+	Keys []*JsonWebKey `json:"keys"`
+}
+
+// public key ทั้งหมดที่ยังใช้ verify ได้ สำหรับ service อื่นที่ต้องการตรวจ token โดยไม่ต้องรู้ secret
+func Jwks(cfg config.JWTConfigImpl) *JsonWebKeySet {
+	set := &JsonWebKeySet{Keys: make([]*JsonWebKey, 0)}
+	for kid, key := range cfg.PublicKeys() {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, &JsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, &JsonWebKey{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(k),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,43 @@
+package exchangerate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DriverNone = "none"
+	DriverHTTP = "http"
+)
+
+var ErrNotConfigured = errors.New("exchange rate importer is not configured")
+
+// ดึงอัตราแลกเปลี่ยนต่อ 1 หน่วยของสกุลเงินหลัก key เป็นรหัสสกุลเงิน ISO 4217
+type ImporterImpl interface {
+	Name() string
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+}
+
+func New(driver, url string) (ImporterImpl, error) {
+	switch driver {
+	case "", DriverNone:
+		return NewNoop(), nil
+	case DriverHTTP:
+		return NewHTTP(url, 15*time.Second)
+	default:
+		return nil, fmt.Errorf("exchange rate importer %q is not supported", driver)
+	}
+}
+
+type noop struct{}
+
+// ไม่ดึงอัตราจากที่ใด admin ต้องตั้งอัตราเอง ใช้เป็นค่าเริ่มต้นเมื่อไม่ได้ตั้งค่า importer
+func NewNoop() ImporterImpl { return noop{} }
+
+func (noop) Name() string { return DriverNone }
+
+func (noop) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	return nil, ErrNotConfigured
+}
@@ -0,0 +1,72 @@
+package exchangerate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// response ที่ใหญ่กว่านี้ไม่ใช่ตารางอัตราแลกเปลี่ยน
+const httpMaxBody = 1 << 20
+
+type httpImporter struct {
+	url    string
+	client *http.Client
+}
+
+// url ใส่ {base} ไว้แทนรหัสสกุลเงินหลักได้ เช่น https://api.frankfurter.app/latest?from={base}
+// response ต้องเป็น JSON ที่มี rates เช่น {"rates": {"USD": 0.028, "EUR": 0.026}}
+func NewHTTP(rawUrl string, timeout time.Duration) (ImporterImpl, error) {
+	if rawUrl == "" {
+		return nil, fmt.Errorf("exchange rate url is required")
+	}
+	if _, err := url.Parse(strings.ReplaceAll(rawUrl, "{base}", "THB")); err != nil {
+		return nil, fmt.Errorf("exchange rate url %q is invalid: %v", rawUrl, err)
+	}
+	return &httpImporter{
+		url:    rawUrl,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (h *httpImporter) Name() string { return DriverHTTP }
+
+func (h *httpImporter) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(h.url, "{base}", url.QueryEscape(base)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create exchange rate request failed: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get exchange rates failed: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get exchange rates failed: status %d", res.StatusCode)
+	}
+
+	var body struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, httpMaxBody)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode exchange rates failed: %v", err)
+	}
+
+	// ข้ามอัตราที่ใช้ไม่ได้แทนที่จะล้มทั้งชุด
+	rates := make(map[string]float64, len(body.Rates))
+	for code, rate := range body.Rates {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			continue
+		}
+		rates[strings.ToUpper(code)] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatJpeg = "jpeg"
+	FormatPng  = "png"
+	FormatWebp = "webp"
+	FormatAvif = "avif"
+)
+
+type encoder interface {
+	Encode(img image.Image, quality int) ([]byte, error)
+	ContentType() string
+	Extension() string
+}
+
+type jpegEncoder struct{}
+
+func (jpegEncoder) Encode(img image.Image, quality int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+func (jpegEncoder) ContentType() string { return "image/jpeg" }
+func (jpegEncoder) Extension() string   { return "jpg" }
+
+type pngEncoder struct{}
+
+func (pngEncoder) Encode(img image.Image, quality int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	e := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := e.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("encode png failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+func (pngEncoder) ContentType() string { return "image/png" }
+func (pngEncoder) Extension() string   { return "png" }
+
+// Go ไม่มี encoder ของ webp และ avif จึงเรียก cwebp / avifenc ถ้าติดตั้งไว้ในเครื่อง
+type commandEncoder struct {
+	path        string
+	args        func(quality int, in, out string) []string
+	contentType string
+	extension   string
+}
+
+func (e *commandEncoder) Encode(img image.Image, quality int) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "imaging-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out."+e.extension)
+
+	buf := new(bytes.Buffer)
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(buf, img); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if output, err := exec.CommandContext(ctx, e.path, e.args(quality, in, out)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("encode %s failed: %v: %s", e.extension, err, output)
+	}
+	return os.ReadFile(out)
+}
+func (e *commandEncoder) ContentType() string { return e.contentType }
+func (e *commandEncoder) Extension() string   { return e.extension }
+
+// encoder ของ format เพิ่มเติมที่ใช้งานได้ในเครื่องนี้
+var extraEncoders = func() map[string]encoder {
+	result := make(map[string]encoder)
+	if path, err := exec.LookPath("cwebp"); err == nil {
+		result[FormatWebp] = &commandEncoder{
+			path: path,
+			args: func(quality int, in, out string) []string {
+				return []string{"-quiet", "-metadata", "none", "-q", strconv.Itoa(quality), in, "-o", out}
+			},
+			contentType: "image/webp",
+			extension:   "webp",
+		}
+	}
+	if path, err := exec.LookPath("avifenc"); err == nil {
+		result[FormatAvif] = &commandEncoder{
+			path: path,
+			args: func(quality int, in, out string) []string {
+				return []string{"--speed", "8", "-q", strconv.Itoa(quality), in, out}
+			},
+			contentType: "image/avif",
+			extension:   "avif",
+		}
+	}
+	return result
+}()
+
+func encoderOf(format string) (encoder, bool) {
+	switch format {
+	case FormatJpeg:
+		return jpegEncoder{}, true
+	case FormatPng:
+		return pngEncoder{}, true
+	default:
+		e, ok := extraEncoders[format]
+		return e, ok
+	}
+}
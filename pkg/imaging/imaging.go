@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+)
+
+type Rendition struct {
+	Name    string
+	MaxSize int // px ของด้านที่ยาวที่สุด
+}
+
+type Options struct {
+	Renditions []*Rendition
+	Formats    []string // format เพิ่มเติมนอกจาก format ของรูปต้นฉบับ เช่น webp, avif
+	Quality    int
+}
+
+type Output struct {
+	Name        string // "" คือรูปต้นฉบับ
+	Width       int
+	Height      int
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+func IsSupported(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// ตรวจว่าเป็นรูปที่ decode ได้จริง และจำนวน pixel ไม่เกินที่กำหนด
+func Validate(data []byte, maxPixels int) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("image is invalid")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return fmt.Errorf("image dimension %dx%d is not acceptable", cfg.Width, cfg.Height)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("image is invalid")
+	}
+	return nil
+}
+
+// แปลงรูปให้ตั้งตรง ลบ metadata ทั้งหมด (EXIF, GPS) ด้วยการ encode ใหม่ และสร้างรูปขนาดต่าง ๆ
+// ผลลัพธ์ตัวแรกคือรูปต้นฉบับที่ผ่านการลบ metadata แล้ว
+func Process(data []byte, opts *Options) ([]*Output, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %v", err)
+	}
+
+	img := toNRGBA(src)
+	if format == FormatJpeg {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	original, _ := encoderOf(format)
+	outputs := make([]*Output, 0, 1+len(opts.Renditions)*(1+len(opts.Formats)))
+
+	// รูปต้นฉบับใช้ quality สูงกว่ารูปย่อเพราะเป็นรูปที่ใช้ขยายดู
+	encoded, err := encode(original, img, "", max(opts.Quality, 90))
+	if err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, encoded)
+
+	for _, r := range opts.Renditions {
+		w, h := fit(img.Rect.Dx(), img.Rect.Dy(), r.MaxSize)
+		resized := resize(img, w, h)
+
+		encoded, err := encode(original, resized, r.Name, opts.Quality)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, encoded)
+
+		for _, f := range opts.Formats {
+			e, ok := encoderOf(f)
+			if !ok || f == format {
+				continue
+			}
+			encoded, err := encode(e, resized, r.Name, opts.Quality)
+			if err != nil {
+				// format เสริมสร้างไม่ได้ไม่ถือว่า upload ล้มเหลว
+				log.Printf("process image failed: %v\n", err)
+				continue
+			}
+			outputs = append(outputs, encoded)
+		}
+	}
+	return outputs, nil
+}
+
+func encode(e encoder, img *image.NRGBA, name string, quality int) (*Output, error) {
+	data, err := e.Encode(img, quality)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{
+		Name:        name,
+		Width:       img.Rect.Dx(),
+		Height:      img.Rect.Dy(),
+		ContentType: e.ContentType(),
+		Extension:   e.Extension(),
+		Data:        data,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	if img, ok := src.(*image.NRGBA); ok && img.Rect.Min == (image.Point{}) {
+		return img
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// หมุนหรือกลับรูปตามค่า EXIF Orientation ให้ตั้งตรงโดยไม่ต้องพึ่ง metadata
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// ขนาดใหม่ที่ด้านยาวไม่เกิน maxSize และไม่ขยายรูปที่เล็กกว่า
+func fit(w, h, maxSize int) (int, int) {
+	if w <= maxSize && h <= maxSize {
+		return w, h
+	}
+	if w >= h {
+		return maxSize, max(1, h*maxSize/w)
+	}
+	return max(1, w*maxSize/h), maxSize
+}
+
+// ย่อรูปด้วยการเฉลี่ยพื้นที่ (box filter) ถ่วงน้ำหนักด้วย alpha เพื่อไม่ให้ขอบโปร่งใสเป็นสีดำ
+func resize(src *image.NRGBA, w, h int) *image.NRGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if sw == w && sh == h {
+		return src
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					pa := uint64(src.Pix[i+3])
+					r += uint64(src.Pix[i]) * pa
+					g += uint64(src.Pix[i+1]) * pa
+					b += uint64(src.Pix[i+2]) * pa
+					a += pa
+					n++
+					i += 4
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[o] = uint8(r / a)
+				dst.Pix[o+1] = uint8(g / a)
+				dst.Pix[o+2] = uint8(b / a)
+			}
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+)
+
+const (
+	DriverLog  = "log"
+	DriverSMTP = "smtp"
+)
+
+// email แบบข้อความธรรมดา
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type MailerImpl interface {
+	Send(msg *Message) error
+}
+
+// เลือกวิธีส่งตาม MAIL_DRIVER ถ้าไม่ได้ตั้งค่าจะเขียนลง log
+func New(cfg config.MailConfigImpl) (MailerImpl, error) {
+	switch cfg.Driver() {
+	case "", DriverLog:
+		return NewLog(), nil
+	case DriverSMTP:
+		return NewSMTP(&SMTPConfig{
+			Host:     cfg.SMTPHost(),
+			Port:     cfg.SMTPPort(),
+			Username: cfg.SMTPUsername(),
+			Password: cfg.SMTPPassword(),
+			From:     cfg.From(),
+		})
+	default:
+		return nil, fmt.Errorf("mail driver %q is not supported", cfg.Driver())
+	}
+}
+
+type logMailer struct{}
+
+// ไม่ได้ส่งจริง ข้อความรวมถึง token ที่อยู่ใน email จะอยู่ใน log จึงใช้ตอนพัฒนาเท่านั้น
+func NewLog() MailerImpl { return logMailer{} }
+
+func (logMailer) Send(msg *Message) error {
+	log.Printf("mail to: %s subject: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
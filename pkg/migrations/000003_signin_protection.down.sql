@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_signin_attempts_table ON "signin_attempts";
+
+DROP TABLE IF EXISTS "signin_attempts" CASCADE;
+DROP TABLE IF EXISTS "audit_logs" CASCADE;
+
+COMMIT;
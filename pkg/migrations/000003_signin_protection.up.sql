@@ -0,0 +1,30 @@
+BEGIN;
+
+-- เก็บจำนวนครั้งที่ sign in ผิดพลาด แยกตาม scope (email, ip)
+CREATE TABLE "signin_attempts" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "scope" VARCHAR NOT NULL,
+  "key" VARCHAR NOT NULL,
+  "failed_count" INT NOT NULL DEFAULT 0,
+  "last_failed_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "locked_until" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("scope", "key")
+);
+
+CREATE TABLE "audit_logs" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "actor_id" VARCHAR,
+  "action" VARCHAR NOT NULL,
+  "target" VARCHAR NOT NULL DEFAULT '',
+  "ip" VARCHAR NOT NULL DEFAULT '',
+  "detail" jsonb,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "audit_logs_action_idx" ON "audit_logs" ("action", "created_at");
+
+CREATE TRIGGER set_updated_at_timestamp_signin_attempts_table BEFORE UPDATE ON "signin_attempts" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
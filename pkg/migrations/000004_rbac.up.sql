@@ -0,0 +1,57 @@
+BEGIN;
+
+CREATE TABLE "permissions" (
+  "id" SERIAL PRIMARY KEY,
+  "code" VARCHAR UNIQUE NOT NULL,
+  "description" VARCHAR NOT NULL DEFAULT ''
+);
+
+CREATE TABLE "role_permissions" (
+  "role_id" INT NOT NULL,
+  "permission_id" INT NOT NULL,
+  PRIMARY KEY ("role_id", "permission_id")
+);
+
+ALTER TABLE "role_permissions" ADD FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE CASCADE;
+ALTER TABLE "role_permissions" ADD FOREIGN KEY ("permission_id") REFERENCES "permissions" ("id") ON DELETE CASCADE;
+
+INSERT INTO "permissions" (
+    "code",
+    "description"
+)
+VALUES
+    ('products:read', 'read products'),
+    ('products:write', 'create, update and delete products'),
+    ('categories:read', 'read categories'),
+    ('categories:write', 'create and delete categories'),
+    ('files:write', 'upload and delete files'),
+    ('orders:read', 'read orders of every user'),
+    ('apikeys:write', 'generate api keys'),
+    ('admins:write', 'generate admin tokens'),
+    ('roles:write', 'manage roles and permissions');
+
+-- admin ได้ทุก permission
+INSERT INTO "role_permissions" (
+    "role_id",
+    "permission_id"
+)
+SELECT
+    "r"."id",
+    "p"."id"
+FROM "roles" "r"
+    CROSS JOIN "permissions" "p"
+WHERE "r"."title" = 'admin';
+
+INSERT INTO "role_permissions" (
+    "role_id",
+    "permission_id"
+)
+SELECT
+    "r"."id",
+    "p"."id"
+FROM "roles" "r"
+    CROSS JOIN "permissions" "p"
+WHERE "r"."title" = 'customer'
+AND "p"."code" IN ('products:read', 'categories:read');
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE "admin_invites" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "email" VARCHAR NOT NULL,
+  "invited_by" VARCHAR NOT NULL,
+  "expires_at" TIMESTAMP NOT NULL,
+  "used_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "admin_invites" ADD FOREIGN KEY ("invited_by") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+COMMIT;
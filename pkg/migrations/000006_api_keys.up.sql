@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE "api_keys" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "name" VARCHAR NOT NULL,
+  "owner_id" VARCHAR NOT NULL,
+  "prefix" VARCHAR NOT NULL,
+  "key_hash" VARCHAR UNIQUE NOT NULL,
+  "scopes" jsonb NOT NULL DEFAULT '[]'::jsonb,
+  "expires_at" TIMESTAMP,
+  "last_used_at" TIMESTAMP,
+  "revoked_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("owner_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_api_keys_table BEFORE UPDATE ON "api_keys" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE "api_keys" ADD COLUMN "tier" VARCHAR NOT NULL DEFAULT 'default';
+
+COMMIT;
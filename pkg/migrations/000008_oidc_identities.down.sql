@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "oidc_states";
+DROP TABLE IF EXISTS "user_identities";
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+CREATE TABLE "user_identities" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "user_id" VARCHAR NOT NULL,
+  "provider" VARCHAR NOT NULL,
+  "subject" VARCHAR NOT NULL,
+  "email" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("provider", "subject")
+);
+
+CREATE TABLE "oidc_states" (
+  "state" VARCHAR NOT NULL UNIQUE PRIMARY KEY,
+  "provider" VARCHAR NOT NULL,
+  "code_verifier" VARCHAR NOT NULL,
+  "nonce" VARCHAR NOT NULL,
+  "expires_at" TIMESTAMP NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX ON "user_identities" ("user_id");
+
+COMMIT;
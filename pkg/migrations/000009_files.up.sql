@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE "files" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "owner_id" VARCHAR,
+  "storage_key" VARCHAR NOT NULL UNIQUE,
+  "url" VARCHAR NOT NULL,
+  "filename" VARCHAR NOT NULL,
+  "size" BIGINT NOT NULL DEFAULT 0,
+  "content_type" VARCHAR NOT NULL,
+  "checksum" VARCHAR NOT NULL,
+  "referenced_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "files" ADD FOREIGN KEY ("owner_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE INDEX ON "files" ("url");
+CREATE INDEX ON "files" ("created_at") WHERE "referenced_by" IS NULL;
+CREATE INDEX ON "images" ("url");
+
+CREATE TRIGGER set_updated_at_timestamp_files_table BEFORE UPDATE ON "files" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
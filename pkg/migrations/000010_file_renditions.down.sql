@@ -0,0 +1,10 @@
+BEGIN;
+
+DELETE FROM "files" WHERE "parent_id" IS NOT NULL;
+
+ALTER TABLE "files" DROP COLUMN IF EXISTS "height";
+ALTER TABLE "files" DROP COLUMN IF EXISTS "width";
+ALTER TABLE "files" DROP COLUMN IF EXISTS "variant";
+ALTER TABLE "files" DROP COLUMN IF EXISTS "parent_id";
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE "files" ADD COLUMN "parent_id" uuid;
+ALTER TABLE "files" ADD COLUMN "variant" VARCHAR;
+ALTER TABLE "files" ADD COLUMN "width" INT;
+ALTER TABLE "files" ADD COLUMN "height" INT;
+
+ALTER TABLE "files" ADD FOREIGN KEY ("parent_id") REFERENCES "files" ("id") ON DELETE CASCADE;
+
+CREATE INDEX ON "files" ("parent_id");
+
+COMMIT;
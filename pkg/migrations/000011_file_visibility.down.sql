@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "files" DROP CONSTRAINT IF EXISTS "files_visibility_check";
+ALTER TABLE "files" DROP COLUMN IF EXISTS "visibility";
+
+COMMIT;
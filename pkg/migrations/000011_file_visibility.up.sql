@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "files" ADD COLUMN "visibility" VARCHAR NOT NULL DEFAULT 'public';
+ALTER TABLE "files" ADD CONSTRAINT "files_visibility_check" CHECK ("visibility" IN ('public', 'private'));
+
+COMMIT;
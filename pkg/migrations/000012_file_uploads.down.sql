@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_file_uploads_table ON "file_uploads";
+
+DROP TABLE IF EXISTS "file_upload_parts" CASCADE;
+DROP TABLE IF EXISTS "file_uploads" CASCADE;
+
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+
+CREATE TABLE "file_uploads" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "owner_id" VARCHAR NOT NULL,
+  "storage_key" VARCHAR NOT NULL,
+  "filename" VARCHAR NOT NULL,
+  "content_type" VARCHAR NOT NULL,
+  "visibility" VARCHAR NOT NULL DEFAULT 'public',
+  "size" BIGINT NOT NULL,
+  "offset" BIGINT NOT NULL DEFAULT 0,
+  "checksum" VARCHAR NOT NULL,
+  "expires_at" TIMESTAMP NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "file_upload_parts" (
+  "upload_id" uuid NOT NULL,
+  "offset" BIGINT NOT NULL,
+  "size" BIGINT NOT NULL,
+  "storage_key" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY ("upload_id", "offset")
+);
+
+ALTER TABLE "file_uploads" ADD FOREIGN KEY ("owner_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "file_uploads" ADD CONSTRAINT "file_uploads_visibility_check" CHECK ("visibility" IN ('public', 'private'));
+ALTER TABLE "file_upload_parts" ADD FOREIGN KEY ("upload_id") REFERENCES "file_uploads" ("id") ON DELETE CASCADE;
+
+CREATE INDEX ON "file_uploads" ("expires_at");
+
+CREATE TRIGGER set_updated_at_timestamp_file_uploads_table BEFORE UPDATE ON "file_uploads" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
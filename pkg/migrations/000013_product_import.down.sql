@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_product_import_jobs_table ON "product_import_jobs";
+
+DROP TABLE IF EXISTS "product_import_jobs" CASCADE;
+DROP TYPE IF EXISTS "import_status";
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "sku";
+
+COMMIT;
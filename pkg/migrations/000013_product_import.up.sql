@@ -0,0 +1,33 @@
+BEGIN;
+
+ALTER TABLE "products" ADD COLUMN "sku" VARCHAR UNIQUE;
+
+CREATE TYPE "import_status" AS ENUM (
+  'pending',
+  'running',
+  'completed',
+  'failed'
+);
+
+CREATE TABLE "product_import_jobs" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "owner_id" VARCHAR NOT NULL,
+  "filename" VARCHAR NOT NULL,
+  "status" import_status NOT NULL DEFAULT 'pending',
+  "total_rows" INT NOT NULL DEFAULT 0,
+  "processed_rows" INT NOT NULL DEFAULT 0,
+  "created_count" INT NOT NULL DEFAULT 0,
+  "updated_count" INT NOT NULL DEFAULT 0,
+  "failed_count" INT NOT NULL DEFAULT 0,
+  "errors" jsonb NOT NULL DEFAULT '[]'::jsonb,
+  "message" VARCHAR NOT NULL DEFAULT '',
+  "finished_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "product_import_jobs" ADD FOREIGN KEY ("owner_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_product_import_jobs_table BEFORE UPDATE ON "product_import_jobs" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
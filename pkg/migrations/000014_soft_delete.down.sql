@@ -0,0 +1,18 @@
+BEGIN;
+
+DELETE FROM "permissions" WHERE "code" IN ('users:write', 'trash:write');
+
+ALTER TABLE "orders" DROP CONSTRAINT "orders_user_id_fkey";
+ALTER TABLE "orders" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "products_categories" DROP CONSTRAINT "products_categories_category_id_fkey";
+ALTER TABLE "products_categories" ADD FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS "users_deleted_at_idx";
+DROP INDEX IF EXISTS "categories_deleted_at_idx";
+DROP INDEX IF EXISTS "products_deleted_at_idx";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "categories" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "deleted_at";
+
+COMMIT;
@@ -0,0 +1,37 @@
+BEGIN;
+
+ALTER TABLE "products" ADD COLUMN "deleted_at" TIMESTAMP;
+ALTER TABLE "categories" ADD COLUMN "deleted_at" TIMESTAMP;
+ALTER TABLE "users" ADD COLUMN "deleted_at" TIMESTAMP;
+
+CREATE INDEX "products_deleted_at_idx" ON "products" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX "categories_deleted_at_idx" ON "categories" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX "users_deleted_at_idx" ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+
+-- ลบถาวรได้เฉพาะหมวดหมู่ที่ไม่มีสินค้าแล้ว และ user ที่ไม่มี order แล้ว
+ALTER TABLE "products_categories" DROP CONSTRAINT "products_categories_category_id_fkey";
+ALTER TABLE "products_categories" ADD FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON DELETE RESTRICT;
+ALTER TABLE "orders" DROP CONSTRAINT "orders_user_id_fkey";
+ALTER TABLE "orders" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE RESTRICT;
+
+INSERT INTO "permissions" (
+    "code",
+    "description"
+)
+VALUES
+    ('users:write', 'delete users'),
+    ('trash:write', 'list, restore and purge deleted products, categories and users');
+
+INSERT INTO "role_permissions" (
+    "role_id",
+    "permission_id"
+)
+SELECT
+    "r"."id",
+    "p"."id"
+FROM "roles" "r"
+    CROSS JOIN "permissions" "p"
+WHERE "r"."title" = 'admin'
+AND "p"."code" IN ('users:write', 'trash:write');
+
+COMMIT;
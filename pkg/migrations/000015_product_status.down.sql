@@ -0,0 +1,14 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "products_unpublish_at_idx";
+DROP INDEX IF EXISTS "products_publish_at_idx";
+DROP INDEX IF EXISTS "products_status_idx";
+
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_schedule_check";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "unpublish_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "publish_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "status";
+
+DROP TYPE IF EXISTS "product_status";
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TYPE "product_status" AS ENUM (
+  'draft',
+  'published',
+  'archived'
+);
+
+-- สินค้าเดิมแสดงอยู่แล้วจึงเป็น published ส่วนสินค้าใหม่เริ่มเป็น draft
+ALTER TABLE "products" ADD COLUMN "status" product_status NOT NULL DEFAULT 'published';
+ALTER TABLE "products" ALTER COLUMN "status" SET DEFAULT 'draft';
+ALTER TABLE "products" ADD COLUMN "publish_at" TIMESTAMP;
+ALTER TABLE "products" ADD COLUMN "unpublish_at" TIMESTAMP;
+ALTER TABLE "products" ADD CONSTRAINT "products_schedule_check" CHECK ("publish_at" IS NULL OR "unpublish_at" IS NULL OR "unpublish_at" > "publish_at");
+
+CREATE INDEX "products_status_idx" ON "products" ("status");
+CREATE INDEX "products_publish_at_idx" ON "products" ("publish_at") WHERE "publish_at" IS NOT NULL;
+CREATE INDEX "products_unpublish_at_idx" ON "products" ("unpublish_at") WHERE "unpublish_at" IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS insert_product_price_history_on_update ON "products";
+DROP TRIGGER IF EXISTS insert_product_price_history_on_insert ON "products";
+DROP FUNCTION IF EXISTS insert_product_price_history();
+
+DROP TABLE IF EXISTS "product_prices" CASCADE;
+
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_sale_schedule_check";
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_sale_price_check";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "sale_ends_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "sale_starts_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "sale_price";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "compare_at_price";
+
+COMMIT;
@@ -0,0 +1,65 @@
+BEGIN;
+
+ALTER TABLE "products" ADD COLUMN "compare_at_price" FLOAT;
+ALTER TABLE "products" ADD COLUMN "sale_price" FLOAT;
+ALTER TABLE "products" ADD COLUMN "sale_starts_at" TIMESTAMP;
+ALTER TABLE "products" ADD COLUMN "sale_ends_at" TIMESTAMP;
+ALTER TABLE "products" ADD CONSTRAINT "products_sale_price_check" CHECK ("sale_price" IS NULL OR "sale_price" >= 0);
+ALTER TABLE "products" ADD CONSTRAINT "products_sale_schedule_check" CHECK ("sale_starts_at" IS NULL OR "sale_ends_at" IS NULL OR "sale_ends_at" > "sale_starts_at");
+
+CREATE TABLE "product_prices" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "product_id" VARCHAR(7) NOT NULL,
+  "price" FLOAT NOT NULL,
+  "compare_at_price" FLOAT,
+  "sale_price" FLOAT,
+  "sale_starts_at" TIMESTAMP,
+  "sale_ends_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "product_prices" ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "product_prices_product_id_idx" ON "product_prices" ("product_id", "created_at");
+
+-- บันทึกประวัติราคาด้วย trigger เพื่อให้ครอบคลุมทุกทางที่แก้ราคา ทั้ง api และ import
+CREATE OR REPLACE FUNCTION insert_product_price_history()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO "product_prices" (
+        "product_id",
+        "price",
+        "compare_at_price",
+        "sale_price",
+        "sale_starts_at",
+        "sale_ends_at"
+    )
+    VALUES (NEW.id, NEW.price, NEW.compare_at_price, NEW.sale_price, NEW.sale_starts_at, NEW.sale_ends_at);
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER insert_product_price_history_on_insert AFTER INSERT ON "products" FOR EACH ROW EXECUTE PROCEDURE insert_product_price_history();
+CREATE TRIGGER insert_product_price_history_on_update AFTER UPDATE ON "products" FOR EACH ROW
+WHEN (
+    OLD.price IS DISTINCT FROM NEW.price
+    OR OLD.compare_at_price IS DISTINCT FROM NEW.compare_at_price
+    OR OLD.sale_price IS DISTINCT FROM NEW.sale_price
+    OR OLD.sale_starts_at IS DISTINCT FROM NEW.sale_starts_at
+    OR OLD.sale_ends_at IS DISTINCT FROM NEW.sale_ends_at
+)
+EXECUTE PROCEDURE insert_product_price_history();
+
+-- ราคาปัจจุบันของสินค้าเดิมเป็นประวัติแรก
+INSERT INTO "product_prices" (
+    "product_id",
+    "price",
+    "created_at"
+)
+SELECT
+    "id",
+    "price",
+    "updated_at"
+FROM "products";
+
+COMMIT;
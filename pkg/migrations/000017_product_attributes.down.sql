@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "products_attributes_idx";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "attributes";
+
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_category_attributes_table ON "category_attributes";
+
+DROP TABLE IF EXISTS "category_attributes" CASCADE;
+DROP TYPE IF EXISTS "attribute_type";
+
+COMMIT;
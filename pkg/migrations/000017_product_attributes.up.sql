@@ -0,0 +1,61 @@
+BEGIN;
+
+CREATE TYPE "attribute_type" AS ENUM (
+  'text',
+  'number',
+  'enum',
+  'boolean'
+);
+
+CREATE TABLE "category_attributes" (
+  "id" SERIAL PRIMARY KEY,
+  "category_id" INT NOT NULL,
+  "code" VARCHAR NOT NULL,
+  "name" VARCHAR NOT NULL,
+  "type" attribute_type NOT NULL,
+  "options" jsonb NOT NULL DEFAULT '[]'::jsonb,
+  "unit" VARCHAR NOT NULL DEFAULT '',
+  "required" BOOLEAN NOT NULL DEFAULT FALSE,
+  "filterable" BOOLEAN NOT NULL DEFAULT FALSE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("category_id", "code")
+);
+
+ALTER TABLE "category_attributes" ADD FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON DELETE CASCADE;
+
+CREATE TRIGGER set_updated_at_timestamp_category_attributes_table BEFORE UPDATE ON "category_attributes" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- ค่าของ attribute เก็บเป็น object ของ code กับค่า
+ALTER TABLE "products" ADD COLUMN "attributes" jsonb NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX "products_attributes_idx" ON "products" USING GIN ("attributes" jsonb_path_ops);
+
+INSERT INTO "category_attributes" (
+    "category_id",
+    "code",
+    "name",
+    "type",
+    "options",
+    "unit",
+    "filterable"
+)
+SELECT
+    "c"."id",
+    "a"."code",
+    "a"."name",
+    "a"."type"::attribute_type,
+    "a"."options"::jsonb,
+    "a"."unit",
+    "a"."filterable"
+FROM "categories" "c"
+    JOIN (
+        VALUES
+            ('gadget', 'ram', 'RAM', 'number', '[]', 'GB', TRUE),
+            ('gadget', 'storage', 'Storage', 'number', '[]', 'GB', TRUE),
+            ('gadget', 'color', 'Color', 'enum', '["black", "white", "silver", "gold"]', '', TRUE),
+            ('fashion', 'material', 'Material', 'text', '[]', '', FALSE),
+            ('fashion', 'color', 'Color', 'text', '[]', '', TRUE)
+    ) AS "a" ("category", "code", "name", "type", "options", "unit", "filterable") ON "a"."category" = "c"."title";
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "product_bundle_items" CASCADE;
+
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_bundle_discount_check";
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_bundle_check";
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_stock_check";
+
+-- สินค้าชุดไม่มีความหมายเมื่อไม่มีสินค้าในชุด
+DELETE FROM "products" WHERE "type" = 'bundle';
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "stock";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "bundle_discount";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "bundle_pricing";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "type";
+
+DROP TYPE IF EXISTS "bundle_pricing";
+DROP TYPE IF EXISTS "product_type";
+
+COMMIT;
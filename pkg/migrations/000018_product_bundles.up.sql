@@ -0,0 +1,41 @@
+BEGIN;
+
+CREATE TYPE "product_type" AS ENUM (
+  'simple',
+  'bundle'
+);
+
+-- fixed ใช้ราคาของสินค้าชุดเอง ส่วน sum_discount คือผลรวมราคาของสินค้าในชุดหักส่วนลด
+CREATE TYPE "bundle_pricing" AS ENUM (
+  'fixed',
+  'sum_discount'
+);
+
+ALTER TABLE "products" ADD COLUMN "type" product_type NOT NULL DEFAULT 'simple';
+ALTER TABLE "products" ADD COLUMN "bundle_pricing" bundle_pricing;
+ALTER TABLE "products" ADD COLUMN "bundle_discount" FLOAT NOT NULL DEFAULT 0;
+-- NULL คือไม่นับ stock ส่วน stock ของสินค้าชุดคำนวณจากสินค้าในชุด
+ALTER TABLE "products" ADD COLUMN "stock" INT;
+
+ALTER TABLE "products" ADD CONSTRAINT "products_stock_check" CHECK ("stock" IS NULL OR "stock" >= 0);
+ALTER TABLE "products" ADD CONSTRAINT "products_bundle_check" CHECK (
+  ("type" = 'simple' AND "bundle_pricing" IS NULL)
+  OR ("type" = 'bundle' AND "bundle_pricing" IS NOT NULL AND "stock" IS NULL)
+);
+ALTER TABLE "products" ADD CONSTRAINT "products_bundle_discount_check" CHECK ("bundle_discount" >= 0);
+
+CREATE TABLE "product_bundle_items" (
+  "bundle_id" VARCHAR(7) NOT NULL,
+  "product_id" VARCHAR(7) NOT NULL,
+  "qty" INT NOT NULL CHECK ("qty" > 0),
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY ("bundle_id", "product_id")
+);
+
+ALTER TABLE "product_bundle_items" ADD FOREIGN KEY ("bundle_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+-- สินค้าที่อยู่ในชุดจะลบถาวรไม่ได้
+ALTER TABLE "product_bundle_items" ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE RESTRICT;
+
+CREATE INDEX "product_bundle_items_product_id_idx" ON "product_bundle_items" ("product_id");
+
+COMMIT;
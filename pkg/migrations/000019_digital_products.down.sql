@@ -0,0 +1,32 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "order_downloads" CASCADE;
+DROP TABLE IF EXISTS "product_license_keys" CASCADE;
+
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_digital_check";
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_download_limit_check";
+
+-- enum ลบค่าออกไม่ได้ จึงลบสินค้าดิจิทัลแล้วสร้าง type ใหม่
+DELETE FROM "products" WHERE "type"::text = 'digital';
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "uses_license_keys";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "download_limit";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "digital_file_id";
+
+ALTER TABLE "products" DROP CONSTRAINT IF EXISTS "products_bundle_check";
+ALTER TABLE "products" ALTER COLUMN "type" DROP DEFAULT;
+ALTER TYPE "product_type" RENAME TO "product_type_old";
+CREATE TYPE "product_type" AS ENUM (
+  'simple',
+  'bundle'
+);
+ALTER TABLE "products" ALTER COLUMN "type" TYPE product_type USING "type"::text::product_type;
+ALTER TABLE "products" ALTER COLUMN "type" SET DEFAULT 'simple';
+DROP TYPE "product_type_old";
+
+ALTER TABLE "products" ADD CONSTRAINT "products_bundle_check" CHECK (
+  ("type" = 'simple' AND "bundle_pricing" IS NULL)
+  OR ("type" = 'bundle' AND "bundle_pricing" IS NOT NULL AND "stock" IS NULL)
+);
+
+COMMIT;
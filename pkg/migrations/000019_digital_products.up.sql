@@ -0,0 +1,64 @@
+BEGIN;
+
+ALTER TYPE "product_type" ADD VALUE IF NOT EXISTS 'digital';
+
+-- ค่าใหม่ของ enum ใช้ใน transaction เดียวกันไม่ได้ จึงเทียบ 'digital' เป็น text
+ALTER TABLE "products" DROP CONSTRAINT "products_bundle_check";
+ALTER TABLE "products" ADD CONSTRAINT "products_bundle_check" CHECK (
+  ("type" <> 'bundle' AND "bundle_pricing" IS NULL)
+  OR ("type" = 'bundle' AND "bundle_pricing" IS NOT NULL AND "stock" IS NULL)
+);
+
+-- ไฟล์ต้องเป็น private ของ files ส่วน stock ของสินค้าที่ใช้ license key คือจำนวน key ที่ยังไม่ถูกใช้
+ALTER TABLE "products" ADD COLUMN "digital_file_id" uuid;
+ALTER TABLE "products" ADD COLUMN "download_limit" INT NOT NULL DEFAULT 5;
+ALTER TABLE "products" ADD COLUMN "uses_license_keys" BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE "products" ADD FOREIGN KEY ("digital_file_id") REFERENCES "files" ("id") ON DELETE RESTRICT;
+ALTER TABLE "products" ADD CONSTRAINT "products_download_limit_check" CHECK ("download_limit" >= 0);
+ALTER TABLE "products" ADD CONSTRAINT "products_digital_check" CHECK (
+  ("type"::text = 'digital' AND "stock" IS NULL)
+  OR ("type"::text <> 'digital' AND "digital_file_id" IS NULL AND NOT "uses_license_keys")
+);
+
+-- key ที่ products_order_id เป็น NULL คือยังว่าง
+CREATE TABLE "product_license_keys" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "product_id" VARCHAR(7) NOT NULL,
+  "key" VARCHAR NOT NULL,
+  "products_order_id" uuid,
+  "assigned_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE ("product_id", "key")
+);
+
+ALTER TABLE "product_license_keys" ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+ALTER TABLE "product_license_keys" ADD FOREIGN KEY ("products_order_id") REFERENCES "products_orders" ("id") ON DELETE SET NULL;
+
+CREATE INDEX "product_license_keys_available_idx" ON "product_license_keys" ("product_id", "created_at") WHERE "products_order_id" IS NULL;
+CREATE INDEX "product_license_keys_products_order_id_idx" ON "product_license_keys" ("products_order_id");
+
+-- สิทธิ์ดาวน์โหลดของแต่ละรายการใน order download_limit เป็น 0 คือไม่จำกัด
+CREATE TABLE "order_downloads" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "products_order_id" uuid NOT NULL UNIQUE,
+  "order_id" VARCHAR NOT NULL,
+  "file_id" uuid NOT NULL,
+  "download_limit" INT NOT NULL,
+  "download_count" INT NOT NULL DEFAULT 0,
+  "last_downloaded_at" TIMESTAMP,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+  CHECK ("download_limit" = 0 OR "download_count" <= "download_limit")
+);
+
+ALTER TABLE "order_downloads" ADD FOREIGN KEY ("products_order_id") REFERENCES "products_orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_downloads" ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+-- ไฟล์ที่ลูกค้าซื้อไปแล้วจะลบไม่ได้
+ALTER TABLE "order_downloads" ADD FOREIGN KEY ("file_id") REFERENCES "files" ("id") ON DELETE RESTRICT;
+
+CREATE INDEX "order_downloads_order_id_idx" ON "order_downloads" ("order_id");
+
+CREATE TRIGGER set_updated_at_timestamp_order_downloads_table BEFORE UPDATE ON "order_downloads" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DELETE FROM "permissions" WHERE "code" = 'currencies:write';
+
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "orders_exchange_rate_check";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "exchange_rate";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "currency";
+
+DROP TABLE IF EXISTS "exchange_rates" CASCADE;
+DROP TABLE IF EXISTS "currencies" CASCADE;
+
+COMMIT;
@@ -0,0 +1,71 @@
+BEGIN;
+
+-- ราคาสินค้าทั้งหมดเก็บเป็นสกุลเงินหลักซึ่งมีได้สกุลเดียว สกุลอื่นใช้แสดงราคาเท่านั้น
+CREATE TABLE "currencies" (
+  "code" VARCHAR(3) PRIMARY KEY CHECK ("code" ~ '^[A-Z]{3}$'),
+  "name" VARCHAR NOT NULL,
+  "symbol" VARCHAR NOT NULL DEFAULT '',
+  "decimals" INT NOT NULL DEFAULT 2 CHECK ("decimals" BETWEEN 0 AND 4),
+  "is_base" BOOLEAN NOT NULL DEFAULT FALSE,
+  "active" BOOLEAN NOT NULL DEFAULT TRUE,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+  CHECK (NOT "is_base" OR "active")
+);
+
+CREATE UNIQUE INDEX "currencies_is_base_idx" ON "currencies" ("is_base") WHERE "is_base";
+
+CREATE TRIGGER set_updated_at_timestamp_currencies_table BEFORE UPDATE ON "currencies" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- อัตราต่อ 1 หน่วยของสกุลเงินหลัก เก็บทุกครั้งที่เปลี่ยน อัตราล่าสุดคืออัตราที่ใช้
+CREATE TABLE "exchange_rates" (
+  "id" uuid NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+  "currency_code" VARCHAR(3) NOT NULL,
+  "rate" FLOAT NOT NULL CHECK ("rate" > 0),
+  "source" VARCHAR NOT NULL DEFAULT 'manual',
+  "created_by" VARCHAR,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "exchange_rates" ADD FOREIGN KEY ("currency_code") REFERENCES "currencies" ("code") ON DELETE CASCADE;
+ALTER TABLE "exchange_rates" ADD FOREIGN KEY ("created_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+
+CREATE INDEX "exchange_rates_currency_code_created_at_idx" ON "exchange_rates" ("currency_code", "created_at" DESC);
+
+INSERT INTO "currencies" (
+  "code",
+  "name",
+  "symbol",
+  "is_base"
+)
+VALUES
+  ('THB', 'Thai Baht', '฿', TRUE),
+  ('USD', 'US Dollar', '$', FALSE),
+  ('EUR', 'Euro', '€', FALSE);
+
+-- สกุลเงินและอัตราที่ลูกค้าเลือกตอนสั่งซื้อ ยอดที่ชำระยังเป็นสกุลเงินหลัก
+ALTER TABLE "orders" ADD COLUMN "currency" VARCHAR(3) NOT NULL DEFAULT 'THB';
+ALTER TABLE "orders" ADD COLUMN "exchange_rate" FLOAT NOT NULL DEFAULT 1;
+ALTER TABLE "orders" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code") ON DELETE RESTRICT;
+ALTER TABLE "orders" ADD CONSTRAINT "orders_exchange_rate_check" CHECK ("exchange_rate" > 0);
+
+INSERT INTO "permissions" (
+    "code",
+    "description"
+)
+VALUES
+    ('currencies:write', 'manage currencies and exchange rates');
+
+INSERT INTO "role_permissions" (
+    "role_id",
+    "permission_id"
+)
+SELECT
+    "r"."id",
+    "p"."id"
+FROM "roles" "r"
+    CROSS JOIN "permissions" "p"
+WHERE "r"."title" = 'admin'
+AND "p"."code" = 'currencies:write';
+
+COMMIT;
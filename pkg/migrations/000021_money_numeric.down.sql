@@ -0,0 +1,14 @@
+BEGIN;
+
+ALTER TABLE "product_prices"
+    ALTER COLUMN "price" TYPE FLOAT USING "price"::FLOAT,
+    ALTER COLUMN "compare_at_price" TYPE FLOAT USING "compare_at_price"::FLOAT,
+    ALTER COLUMN "sale_price" TYPE FLOAT USING "sale_price"::FLOAT;
+
+ALTER TABLE "products"
+    ALTER COLUMN "price" TYPE FLOAT USING "price"::FLOAT,
+    ALTER COLUMN "compare_at_price" TYPE FLOAT USING "compare_at_price"::FLOAT,
+    ALTER COLUMN "sale_price" TYPE FLOAT USING "sale_price"::FLOAT,
+    ALTER COLUMN "bundle_discount" TYPE FLOAT USING "bundle_discount"::FLOAT;
+
+COMMIT;
@@ -0,0 +1,85 @@
+BEGIN;
+
+-- ค่าที่เป็นแค่เศษจาก binary floating point ต่างจากค่าที่ปัดแล้วไม่ถึง 0.000001
+-- ถ้ามีราคาที่มีทศนิยมเกิน 2 ตำแหน่งจริงจะหยุด migration แทนที่จะปัดทิ้ง
+CREATE OR REPLACE FUNCTION is_money_lossless(v NUMERIC)
+RETURNS BOOLEAN AS $$
+    SELECT v IS NULL OR abs(v - round(v, 2)) < 0.000001;
+$$ language 'sql' IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION round_money_jsonb(v JSONB)
+RETURNS JSONB AS $$
+    SELECT CASE WHEN jsonb_typeof(v) = 'number' THEN to_jsonb(round(v::text::NUMERIC, 2)) ELSE v END;
+$$ language 'sql' IMMUTABLE;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM "products"
+        WHERE NOT is_money_lossless("price"::NUMERIC)
+        OR NOT is_money_lossless("compare_at_price"::NUMERIC)
+        OR NOT is_money_lossless("sale_price"::NUMERIC)
+        OR NOT is_money_lossless("bundle_discount"::NUMERIC)
+    ) OR EXISTS (
+        SELECT 1
+        FROM "product_prices"
+        WHERE NOT is_money_lossless("price"::NUMERIC)
+        OR NOT is_money_lossless("compare_at_price"::NUMERIC)
+        OR NOT is_money_lossless("sale_price"::NUMERIC)
+    ) OR EXISTS (
+        SELECT 1
+        FROM "products_orders" "po"
+            CROSS JOIN LATERAL jsonb_each("po"."product") AS "f"
+        WHERE "f"."key" IN ('price', 'compare_at_price', 'sale_price', 'effective_price', 'bundle_discount')
+        AND jsonb_typeof("f"."value") = 'number'
+        AND NOT is_money_lossless("f"."value"::text::NUMERIC)
+    ) THEN
+        RAISE EXCEPTION 'found prices with more than 2 decimals, fix them before converting to NUMERIC';
+    END IF;
+END $$;
+
+ALTER TABLE "products"
+    ALTER COLUMN "price" TYPE NUMERIC(12, 2) USING round("price"::NUMERIC, 2),
+    ALTER COLUMN "compare_at_price" TYPE NUMERIC(12, 2) USING round("compare_at_price"::NUMERIC, 2),
+    ALTER COLUMN "sale_price" TYPE NUMERIC(12, 2) USING round("sale_price"::NUMERIC, 2),
+    ALTER COLUMN "bundle_discount" TYPE NUMERIC(12, 2) USING round("bundle_discount"::NUMERIC, 2);
+
+ALTER TABLE "product_prices"
+    ALTER COLUMN "price" TYPE NUMERIC(12, 2) USING round("price"::NUMERIC, 2),
+    ALTER COLUMN "compare_at_price" TYPE NUMERIC(12, 2) USING round("compare_at_price"::NUMERIC, 2),
+    ALTER COLUMN "sale_price" TYPE NUMERIC(12, 2) USING round("sale_price"::NUMERIC, 2);
+
+-- snapshot ของสินค้าใน order ตัดเศษจาก FLOAT ทิ้ง ทั้งราคาของสินค้าและราคาของสินค้าในชุด
+UPDATE "products_orders" SET
+    "product" = (
+        SELECT
+            jsonb_object_agg(
+                "f"."key",
+                CASE
+                    WHEN "f"."key" IN ('price', 'compare_at_price', 'sale_price', 'effective_price', 'bundle_discount')
+                    THEN round_money_jsonb("f"."value")
+                    WHEN "f"."key" = 'components' AND jsonb_typeof("f"."value") = 'array'
+                    THEN (
+                        SELECT
+                            COALESCE(jsonb_agg(
+                                CASE
+                                    WHEN "c"."item" ? 'effective_price'
+                                    THEN jsonb_set("c"."item", '{effective_price}', round_money_jsonb("c"."item"->'effective_price'))
+                                    ELSE "c"."item"
+                                END
+                                ORDER BY "c"."i"
+                            ), '[]'::jsonb)
+                        FROM jsonb_array_elements("f"."value") WITH ORDINALITY AS "c"("item", "i")
+                    )
+                    ELSE "f"."value"
+                END
+            )
+        FROM jsonb_each("products_orders"."product") AS "f"
+    )
+WHERE jsonb_typeof("product") = 'object';
+
+DROP FUNCTION IF EXISTS round_money_jsonb(JSONB);
+DROP FUNCTION IF EXISTS is_money_lossless(NUMERIC);
+
+COMMIT;
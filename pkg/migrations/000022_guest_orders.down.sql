@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "orders_guest_email_idx";
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "orders_owner_check";
+
+-- order ที่ยังไม่มีเจ้าของอยู่ต่อไม่ได้เมื่อ user_id ต้องไม่เป็น NULL
+DELETE FROM "orders" WHERE "user_id" IS NULL;
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "lookup_token_hash";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "guest_email";
+ALTER TABLE "orders" ALTER COLUMN "user_id" SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- order ของลูกค้าที่ไม่ได้สมัครสมาชิกผูกกับ email และดูได้ด้วย token ที่ส่งไปทาง email เก็บเฉพาะ hash ของ token
+-- ลูกค้าย้าย order เข้าบัญชีได้ด้วย token เดียวกัน หรือสมัครสมาชิกด้วย email นี้แล้วยืนยัน email โดยยังเก็บ guest_email ไว้
+ALTER TABLE "orders" ALTER COLUMN "user_id" DROP NOT NULL;
+ALTER TABLE "orders" ADD COLUMN "guest_email" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "lookup_token_hash" VARCHAR;
+ALTER TABLE "orders" ADD CONSTRAINT "orders_owner_check" CHECK (
+  "user_id" IS NOT NULL
+  OR ("guest_email" IS NOT NULL AND "lookup_token_hash" IS NOT NULL)
+);
+
+CREATE INDEX "orders_guest_email_idx" ON "orders" (LOWER("guest_email")) WHERE "user_id" IS NULL;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+-- response ของ request ที่ส่ง Idempotency-Key มา แยกตาม client ที่ส่ง (api key, user หรือ ip)
+-- status_code เป็น NULL คือ request แรกยังทำงานอยู่
+CREATE TABLE "idempotency_keys" (
+  "scope" VARCHAR NOT NULL,
+  "key" VARCHAR(255) NOT NULL,
+  "fingerprint" VARCHAR NOT NULL,
+  "status_code" INT,
+  "content_type" VARCHAR,
+  "response" BYTEA,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+  "expires_at" TIMESTAMP NOT NULL,
+  PRIMARY KEY ("scope", "key")
+);
+
+CREATE INDEX "idempotency_keys_expires_at_idx" ON "idempotency_keys" ("expires_at");
+
+COMMIT;
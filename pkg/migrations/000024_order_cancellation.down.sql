@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "orders_unpaid_idx";
+ALTER TABLE "orders" DROP CONSTRAINT IF EXISTS "orders_canceled_by_check";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "canceled_at";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "canceled_by";
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "cancel_reason";
+
+COMMIT;
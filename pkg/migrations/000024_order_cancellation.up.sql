@@ -0,0 +1,12 @@
+BEGIN;
+
+-- เก็บว่าใครยกเลิก order เมื่อไหร่และเพราะอะไร system คือถูกยกเลิกอัตโนมัติเพราะไม่ได้ชำระเงินตามเวลา
+ALTER TABLE "orders" ADD COLUMN "cancel_reason" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "canceled_by" VARCHAR;
+ALTER TABLE "orders" ADD COLUMN "canceled_at" TIMESTAMP;
+ALTER TABLE "orders" ADD CONSTRAINT "orders_canceled_by_check" CHECK ("canceled_by" IN ('customer', 'admin', 'system'));
+
+-- ใช้หา order ที่ยังไม่แนบ slip เกินเวลา
+CREATE INDEX "orders_unpaid_idx" ON "orders" ("created_at") WHERE "status" = 'waiting' AND "transfer_slip" IS NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "orders_transfer_slip_url_idx";
+DROP INDEX IF EXISTS "product_import_jobs_file_id_idx";
+ALTER TABLE "product_import_jobs" DROP COLUMN IF EXISTS "file_id";
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- ไฟล์ต้นทางของงาน import ที่ upload ไว้ก่อน ถูกนับเป็นไฟล์ที่ถูกอ้างถึงเพื่อไม่ให้ sweeper ลบ
+ALTER TABLE "product_import_jobs" ADD COLUMN "file_id" uuid REFERENCES "files" ("id") ON DELETE SET NULL;
+CREATE INDEX "product_import_jobs_file_id_idx" ON "product_import_jobs" ("file_id") WHERE "file_id" IS NOT NULL;
+
+-- sweeper หาไฟล์จาก url ของสลิปใน order
+CREATE INDEX "orders_transfer_slip_url_idx" ON "orders" (("transfer_slip"->>'url')) WHERE "transfer_slip" IS NOT NULL;
+
+COMMIT;
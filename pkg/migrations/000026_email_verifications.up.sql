@@ -0,0 +1,16 @@
+BEGIN;
+
+-- email ที่สมัครสมาชิกต้องยืนยันก่อน จึงจะย้าย order ที่สั่งโดยไม่ได้สมัครสมาชิกด้วย email นี้เข้าบัญชี
+-- เก็บเฉพาะ hash ของ token หนึ่ง user มี token ที่ใช้ได้อันเดียว
+ALTER TABLE "users" ADD COLUMN "email_verified_at" TIMESTAMP;
+
+CREATE TABLE "email_verifications" (
+  "user_id" VARCHAR NOT NULL UNIQUE PRIMARY KEY,
+  "token_hash" VARCHAR NOT NULL UNIQUE,
+  "expires_at" TIMESTAMP NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE "email_verifications" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+COMMIT;
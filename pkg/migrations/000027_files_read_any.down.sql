@@ -0,0 +1,5 @@
+BEGIN;
+
+DELETE FROM "permissions" WHERE "code" = 'files:read_any';
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- อ่านและสร้าง signed url ของไฟล์ที่ไม่ใช่ของตัวเองได้ แทนการตรวจ role id ของ admin
+INSERT INTO "permissions" (
+    "code",
+    "description"
+)
+VALUES
+    ('files:read_any', 'read and sign files of every user');
+
+INSERT INTO "role_permissions" (
+    "role_id",
+    "permission_id"
+)
+SELECT
+    "r"."id",
+    "p"."id"
+FROM "roles" "r"
+    CROSS JOIN "permissions" "p"
+WHERE "r"."title" = 'admin'
+AND "p"."code" = 'files:read_any';
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "users_email_lower_key";
+
+COMMIT;
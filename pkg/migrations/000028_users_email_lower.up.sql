@@ -0,0 +1,29 @@
+BEGIN;
+
+-- email ของ user เก็บเป็นตัวพิมพ์เล็กและห้ามซ้ำกันแบบไม่สนตัวพิมพ์ เพื่อให้ sign in และ lockout ผูกกับบัญชีเดียว
+-- บัญชีที่ email ต่างกันแค่ตัวพิมพ์ต้องรวมหรือลบเองก่อน migration จะไม่เลือกให้ว่าบัญชีไหนเป็นของจริง
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg("email", ', ')
+    INTO duplicates
+    FROM (
+        SELECT LOWER(TRIM("email")) AS "email"
+        FROM "users"
+        GROUP BY LOWER(TRIM("email"))
+        HAVING COUNT(*) > 1
+    ) "d";
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'found users whose emails differ only by case: %, merge or remove them before adding the unique index', duplicates;
+    END IF;
+END $$;
+
+UPDATE "users" SET
+    "email" = LOWER(TRIM("email"))
+WHERE "email" <> LOWER(TRIM("email"));
+
+CREATE UNIQUE INDEX "users_email_lower_key" ON "users" (LOWER("email"));
+
+COMMIT;
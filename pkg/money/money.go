@@ -0,0 +1,181 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// จำนวนทศนิยมของเงินทุกจำนวนในระบบ ตรงกับ NUMERIC(12, 2) ใน database
+const Scale = 2
+
+const unit = 100
+
+// เก็บเป็นหน่วยย่อย (สตางค์) เพื่อไม่ให้มีเศษจาก binary floating point
+// JSON เป็นตัวเลขทศนิยม และเก็บใน database เป็น NUMERIC
+type Amount int64
+
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// รับเฉพาะทศนิยมไม่เกิน Scale ตำแหน่ง ไม่ปัดเศษให้
+func Parse(s string) (Amount, error) {
+	raw := strings.TrimSpace(s)
+	invalid := fmt.Errorf("amount %q is invalid", s)
+
+	value := strings.TrimLeft(raw, "+-")
+	negative := strings.HasPrefix(raw, "-")
+	if len(raw)-len(value) > 1 {
+		return 0, invalid
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" || len(whole) > 15 || !isDigits(whole) || !isDigits(fraction) {
+		return 0, invalid
+	}
+	if len(fraction) > Scale {
+		return 0, fmt.Errorf("amount %q has more than %d decimals", s, Scale)
+	}
+	fraction += strings.Repeat("0", Scale-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount(minor), nil
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+func (a Amount) Float64() float64 {
+	return float64(a) / unit
+}
+
+func (a Amount) Mul(qty int) Amount {
+	return a * Amount(qty)
+}
+
+// คูณด้วยอัตราแลกเปลี่ยนแล้วปัดตามจำนวนทศนิยมของสกุลเงินปลายทาง ใช้เพื่อแสดงผลเท่านั้น
+// สกุลเงินปลายทางมีทศนิยมมากกว่า Scale ได้ เช่น KWD ที่มี 3 ตำแหน่ง
+func (a Amount) Convert(rate float64, decimals int) Display {
+	return Display{
+		minor:    int64(math.Round(float64(a) * rate * math.Pow10(decimals-Scale))),
+		decimals: decimals,
+	}
+}
+
+// จำนวนเงินที่แปลงสกุลแล้วเพื่อแสดงผล จำนวนทศนิยมตามสกุลเงินปลายทาง ไม่ใช้คิดเงินและไม่เก็บลง database
+type Display struct {
+	minor    int64
+	decimals int
+}
+
+func (d Display) String() string {
+	sign, minor := "", d.minor
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	if d.decimals <= 0 {
+		return fmt.Sprintf("%s%d", sign, minor)
+	}
+	unit := int64(math.Pow10(d.decimals))
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, d.decimals, minor%unit)
+}
+
+func (d Display) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// จำนวนทศนิยมใช้ตามที่ส่งมา
+func (d *Display) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	whole, fraction, _ := strings.Cut(strings.TrimSpace(s), ".")
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || !isDigits(fraction) {
+		return fmt.Errorf("amount %q is invalid", s)
+	}
+	*d = Display{minor: minor, decimals: len(fraction)}
+	return nil
+}
+
+func (a Amount) String() string {
+	sign, minor := "", int64(a)
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, Scale, minor%unit)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// รับทั้งตัวเลขและ string ของตัวเลข
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = Amount(v * unit)
+		return nil
+	case float64:
+		*a = Amount(math.Round(v * unit))
+		return nil
+	case []byte:
+		src = string(v)
+	}
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("scan amount from %T is not supported", src)
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
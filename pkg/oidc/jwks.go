@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []*jsonWebKey `json:"keys"`
+}
+
+type keySet struct {
+	keys map[string]any
+	// ใช้เมื่อ token ไม่มี kid และ jwks มี key เดียว
+	only any
+}
+
+func (s *keySet) find(kid string) (any, bool) {
+	if kid == "" && s.only != nil {
+		return s.only, true
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k *jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode rsa modulus failed: %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode rsa exponent failed: %v", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curve %q is not supported", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode ec x failed: %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode ec y failed: %v", err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("key type %q is not supported", k.Kty)
+	}
+}
+
+func (s *jsonWebKeySet) parse() (*keySet, error) {
+	result := &keySet{keys: make(map[string]any)}
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		result.keys[k.Kid] = key
+		if len(s.Keys) == 1 {
+			result.only = key
+		}
+	}
+	if len(result.keys) == 0 {
+		return nil, fmt.Errorf("jwks has no usable key")
+	}
+	return result, nil
+}
@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+	// LINE web login sign id_token ด้วย channel secret provider อื่นต้องใช้ RS256 หรือ ES256 จาก jwks เท่านั้น
+	AllowHS256 bool
+}
+
+// ข้อมูลจาก /.well-known/openid-configuration ของ issuer
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IdToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// บาง provider ส่ง email_verified มาเป็น string
+type boolOrString bool
+
+func (b *boolOrString) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+type IdTokenClaims struct {
+	Email         string       `json:"email"`
+	EmailVerified boolOrString `json:"email_verified"`
+	Name          string       `json:"name"`
+	Nonce         string       `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (c *IdTokenClaims) IsEmailVerified() bool {
+	return bool(c.EmailVerified)
+}
+
+type ProviderImpl interface {
+	Name() string
+	AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (*Token, error)
+	VerifyIdToken(ctx context.Context, rawIdToken, nonce string) (*IdTokenClaims, error)
+}
+
+type provider struct {
+	cfg    *Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+	// เวลาที่โหลด jwks ล่าสุด และ channel ที่ปิดเมื่อการโหลดที่กำลังทำอยู่เสร็จ
+	keysFetchedAt time.Time
+	keysFetching  chan struct{}
+}
+
+// kid ที่ไม่รู้จักจะโหลด jwks ใหม่ได้ไม่เกินนาทีละครั้ง กัน id_token ปลอมยิง request ไปหา provider ไม่จำกัด
+const jwksRefreshInterval = time.Minute
+
+func NewProvider(cfg *Config, client *http.Client) ProviderImpl {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (p *provider) Name() string { return p.cfg.Name }
+
+func (p *provider) getJson(ctx context.Context, endpoint string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s failed: status %d", endpoint, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(dest)
+}
+
+// โหลด discovery document ครั้งแรกที่ใช้งานแล้ว cache ไว้
+func (p *provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	d := new(discovery)
+	endpoint := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJson(ctx, endpoint, d); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %v", err)
+	}
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery failed: issuer %q does not match %q", d.Issuer, p.cfg.Issuer)
+	}
+	p.discovery = d
+	return d, nil
+}
+
+func (p *provider) AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientId)
+	query.Set("redirect_uri", p.cfg.RedirectUrl)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectUrl)
+	form.Set("client_id", p.cfg.ClientId)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc exchange failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oidc exchange failed: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc exchange failed: status %d: %s", res.StatusCode, body)
+	}
+
+	token := new(Token)
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("oidc exchange failed: %v", err)
+	}
+	if token.IdToken == "" {
+		return nil, fmt.Errorf("oidc exchange failed: id_token is empty")
+	}
+	return token, nil
+}
+
+func (p *provider) VerifyIdToken(ctx context.Context, rawIdToken, nonce string) (*IdTokenClaims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// secret ว่างใช้ verify ไม่ได้ ไม่อย่างนั้นใครก็ sign id_token เองได้
+	methods := []string{"RS256", "ES256"}
+	if p.cfg.AllowHS256 && p.cfg.ClientSecret != "" {
+		methods = append(methods, "HS256")
+	}
+
+	claims := new(IdTokenClaims)
+	if _, err := jwt.ParseWithClaims(
+		rawIdToken,
+		claims,
+		func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+				if !p.cfg.AllowHS256 || p.cfg.ClientSecret == "" {
+					return nil, fmt.Errorf("signing method %s is not allowed", t.Method.Alg())
+				}
+				return []byte(p.cfg.ClientSecret), nil
+			}
+			kid, _ := t.Header["kid"].(string)
+			return p.findKey(ctx, d.JwksUri, kid)
+		},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientId),
+	); err != nil {
+		return nil, fmt.Errorf("verify id_token failed: %v", err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("verify id_token failed: exp is required")
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("verify id_token failed: nonce is invalid")
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("verify id_token failed: subject is empty")
+	}
+	return claims, nil
+}
+
+// หา public key จาก jwks ถ้าไม่เจอ kid จะโหลด jwks ใหม่ เผื่อ provider เพิ่งหมุน key
+// โหลดนอก lock และมีได้ทีละครั้ง request อื่นที่ต้องการ key จะรอผลจากการโหลดนั้น
+func (p *provider) findKey(ctx context.Context, jwksUri, kid string) (any, error) {
+	p.mu.Lock()
+	if p.keys != nil {
+		if key, ok := p.keys.find(kid); ok {
+			p.mu.Unlock()
+			return key, nil
+		}
+	}
+
+	wait := p.keysFetching
+	if wait == nil && time.Since(p.keysFetchedAt) >= jwksRefreshInterval {
+		done := make(chan struct{})
+		p.keysFetching = done
+		p.keysFetchedAt = time.Now()
+		p.mu.Unlock()
+
+		keys, err := p.fetchKeys(ctx, jwksUri)
+
+		p.mu.Lock()
+		if err == nil {
+			p.keys = keys
+		}
+		p.keysFetching = nil
+		close(done)
+		p.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.mu.Unlock()
+		if wait != nil {
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil {
+		if key, ok := p.keys.find(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q not found", kid)
+}
+
+func (p *provider) fetchKeys(ctx context.Context, jwksUri string) (*keySet, error) {
+	set := new(jsonWebKeySet)
+	if err := p.getJson(ctx, jwksUri, set); err != nil {
+		return nil, fmt.Errorf("get jwks failed: %v", err)
+	}
+	return set.parse()
+}
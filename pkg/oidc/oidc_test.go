@@ -0,0 +1,228 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc"
+	"github.com/Doittikorn/go-e-commerce/pkg/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	clientId     = "client-id"
+	clientSecret = "client-secret"
+)
+
+func newProvider(t *testing.T, allowHS256 bool) (*oidctest.Issuer, oidc.ProviderImpl) {
+	t.Helper()
+	issuer := oidctest.NewIssuer(clientId, clientSecret)
+	t.Cleanup(issuer.Close)
+
+	return issuer, oidc.NewProvider(&oidc.Config{
+		Name:         "mock",
+		Issuer:       issuer.URL,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		RedirectUrl:  "http://localhost/callback",
+		AllowHS256:   allowHS256,
+	}, nil)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// ตัวอย่างจาก RFC 7636 appendix B
+	got := oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Fatalf("code challenge = %q, want %q", got, want)
+	}
+}
+
+func TestAuthCodeUrl(t *testing.T) {
+	issuer, p := newProvider(t, false)
+
+	raw, err := p.AuthCodeUrl(context.Background(), "state", "nonce", oidc.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != issuer.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %q", got)
+	}
+
+	query := u.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             clientId,
+		"redirect_uri":          "http://localhost/callback",
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidc.CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer, p := newProvider(t, false)
+	ctx := context.Background()
+
+	authorize := func(verifier string) string {
+		t.Helper()
+		raw, err := p.AuthCodeUrl(ctx, "state", "nonce", oidc.CodeChallenge(verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := issuer.Authorize(raw, jwt.MapClaims{"sub": "user-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	if _, err := p.Exchange(ctx, authorize("verifier"), "other-verifier"); err == nil {
+		t.Error("exchange with wrong code verifier should fail")
+	}
+
+	code := authorize("verifier")
+	token, err := p.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	claims, err := p.VerifyIdToken(ctx, token.IdToken, "nonce")
+	if err != nil {
+		t.Fatalf("verify id_token failed: %v", err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("subject = %q, want user-1", claims.Subject)
+	}
+
+	if _, err := p.Exchange(ctx, code, "verifier"); err == nil {
+		t.Error("code should be usable only once")
+	}
+}
+
+func hs256Token(t *testing.T, issuer *oidctest.Issuer, secret string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   issuer.URL,
+		"aud":   clientId,
+		"sub":   "user-1",
+		"nonce": "nonce",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyIdToken(t *testing.T) {
+	issuer, p := newProvider(t, false)
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss":   issuer.URL,
+		"aud":   clientId,
+		"sub":   "user-1",
+		"nonce": "nonce",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr bool
+	}{
+		{"valid", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce"}), "nonce", false},
+		{"wrong issuer", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce", "iss": "https://evil.example"}), "nonce", true},
+		{"wrong audience", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce", "aud": "other-client"}), "nonce", true},
+		{"wrong nonce", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "other"}), "nonce", true},
+		{"expired", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce", "exp": time.Now().Add(-time.Minute).Unix()}), "nonce", true},
+		{"missing exp", issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce", "exp": nil}), "nonce", true},
+		{"missing subject", issuer.SignIdToken(jwt.MapClaims{"nonce": "nonce"}), "nonce", true},
+		{"hs256 not allowed", hs256Token(t, issuer, clientSecret), "nonce", true},
+		{"alg none", none, "nonce", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.VerifyIdToken(context.Background(), tt.token, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIdTokenHS256(t *testing.T) {
+	issuer, p := newProvider(t, true)
+	ctx := context.Background()
+
+	if _, err := p.VerifyIdToken(ctx, hs256Token(t, issuer, clientSecret), "nonce"); err != nil {
+		t.Fatalf("hs256 should be accepted when allowed: %v", err)
+	}
+	if _, err := p.VerifyIdToken(ctx, hs256Token(t, issuer, "other-secret"), "nonce"); err == nil {
+		t.Fatal("hs256 signed with another secret should fail")
+	}
+
+	// เปิด HS256 ไว้แต่ไม่มี secret ต้องไม่ยอมรับ token ที่ sign ด้วย secret ว่าง
+	empty := oidc.NewProvider(&oidc.Config{
+		Name:       "mock",
+		Issuer:     issuer.URL,
+		ClientId:   clientId,
+		AllowHS256: true,
+	}, nil)
+	if _, err := empty.VerifyIdToken(ctx, hs256Token(t, issuer, ""), "nonce"); err == nil {
+		t.Fatal("hs256 with empty secret should fail")
+	}
+}
+
+func TestJwksRefetchIsLimited(t *testing.T) {
+	issuer, p := newProvider(t, false)
+	ctx := context.Background()
+
+	valid := issuer.SignIdToken(jwt.MapClaims{"sub": "user-1", "nonce": "nonce"})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.VerifyIdToken(ctx, valid, "nonce"); err != nil {
+				t.Errorf("verify id_token failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := issuer.JwksRequests(); got != 1 {
+		t.Fatalf("jwks requests = %d, want 1", got)
+	}
+
+	// token ที่มี kid ไม่รู้จักต้องไม่ทำให้โหลด jwks ใหม่ทุกครั้ง
+	for i := 0; i < 10; i++ {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-1"})
+		token.Header["kid"] = "unknown"
+		raw, err := token.SignedString(issuer.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.VerifyIdToken(ctx, raw, "nonce"); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Fatalf("err = %v, want key not found", err)
+		}
+	}
+	if got := issuer.JwksRequests(); got != 1 {
+		t.Fatalf("jwks requests = %d, want 1", got)
+	}
+}
@@ -0,0 +1,198 @@
+// issuer จำลองสำหรับทดสอบ flow ของ oidc โดยไม่ต้องต่อ provider จริง
+// เปิด discovery, jwks และ token endpoint ด้วย httptest และ sign id_token ด้วย RS256
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const KeyId = "test-key"
+
+type Issuer struct {
+	*httptest.Server
+	ClientId     string
+	ClientSecret string
+	Key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]*grant
+	jwks   atomic.Int64
+}
+
+// code ที่ออกให้จาก Authorize ใช้แลก token ได้ครั้งเดียว
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func NewIssuer(clientId, clientSecret string) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("generate rsa key failed: %v", err))
+	}
+	i := &Issuer{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Key:          key,
+		grants:       make(map[string]*grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/jwks", i.keys)
+	mux.HandleFunc("/token", i.token)
+	i.Server = httptest.NewServer(mux)
+	return i
+}
+
+// จำนวนครั้งที่มีการโหลด jwks
+func (i *Issuer) JwksRequests() int {
+	return int(i.jwks.Load())
+}
+
+// ทำหน้าที่แทนผู้ใช้ที่ยืนยันตัวตนที่ authorization url แล้วคืน code ที่จะส่งกลับไปยัง callback
+// nonce ใน url จะถูกใส่ลงใน id_token ถ้า claims ไม่ได้กำหนดไว้เอง
+func (i *Issuer) Authorize(authorizationUrl string, claims jwt.MapClaims) (string, error) {
+	u, err := url.Parse(authorizationUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	switch {
+	case query.Get("client_id") != i.ClientId:
+		return "", fmt.Errorf("client_id is invalid")
+	case query.Get("response_type") != "code":
+		return "", fmt.Errorf("response_type is invalid")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", fmt.Errorf("code_challenge is invalid")
+	}
+
+	copied := jwt.MapClaims{}
+	for k, v := range claims {
+		copied[k] = v
+	}
+	if _, ok := copied["nonce"]; !ok {
+		copied["nonce"] = query.Get("nonce")
+	}
+
+	code := randomString()
+	i.mu.Lock()
+	i.grants[code] = &grant{
+		challenge: query.Get("code_challenge"),
+		claims:    copied,
+	}
+	i.mu.Unlock()
+	return code, nil
+}
+
+// sign id_token ด้วย key ของ issuer ค่า iss, aud, iat และ exp ที่ไม่ได้กำหนดจะใช้ค่าที่ถูกต้อง
+// กำหนดค่าเป็น nil เพื่อให้ claim นั้นไม่มีค่า
+func (i *Issuer) SignIdToken(claims jwt.MapClaims) string {
+	copied := jwt.MapClaims{
+		"iss": i.URL,
+		"aud": i.ClientId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range claims {
+		copied[k] = v
+	}
+	for k, v := range copied {
+		if v == nil {
+			delete(copied, k)
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, copied)
+	token.Header["kid"] = KeyId
+	signed, err := token.SignedString(i.Key)
+	if err != nil {
+		panic(fmt.Sprintf("sign id_token failed: %v", err))
+	}
+	return signed
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) keys(w http.ResponseWriter, r *http.Request) {
+	i.jwks.Add(1)
+	pub := i.Key.PublicKey
+	writeJson(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"kid": KeyId,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != i.ClientId ||
+		r.PostForm.Get("client_secret") != i.ClientSecret {
+		writeJson(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	g, ok := i.grants[r.PostForm.Get("code")]
+	delete(i.grants, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJson(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"id_token":     i.SignIdToken(g.claims),
+		"expires_in":   3600,
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// รวม provider ทั้งหมดที่เปิดใช้งาน ค้นหาด้วยชื่อ เช่น google, line
+type RegistryImpl interface {
+	Get(name string) (ProviderImpl, bool)
+	Names() []string
+}
+
+type registry struct {
+	providers map[string]ProviderImpl
+	names     []string
+}
+
+func NewRegistry(cfgs []*Config, client *http.Client) RegistryImpl {
+	r := &registry{
+		providers: make(map[string]ProviderImpl),
+		names:     make([]string, 0),
+	}
+	for _, cfg := range cfgs {
+		r.providers[cfg.Name] = NewProvider(cfg, client)
+		r.names = append(r.names, cfg.Name)
+	}
+	return r
+}
+
+func (r *registry) Get(name string) (ProviderImpl, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *registry) Names() []string { return r.names }
+
+// สร้างค่าแบบสุ่มสำหรับ state, nonce และ code verifier
+func RandomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random string failed: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCE code challenge แบบ S256
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ลบ bucket ที่เต็มแล้วทุกๆ ช่วงเวลานี้ เพื่อไม่ให้ map โตไปเรื่อยๆ
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() StoreImpl {
+	return &memoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *memoryStore) Take(key string, limit Limit) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	rate := limit.rate()
+	burst := float64(limit.Burst)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+
+	// เติม token ตามเวลาที่ผ่านไป
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := &Result{
+		Limit: limit.Burst,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.ResetAfter = secondsToDuration((burst - b.tokens) / rate)
+	return result, nil
+}
+
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		full := b.tokens + now.Sub(b.last).Seconds()*b.limit.rate()
+		if full >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0
+	}
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
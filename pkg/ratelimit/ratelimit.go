@@ -0,0 +1,26 @@
+package ratelimit
+
+import "time"
+
+// จำนวน request ที่ยอมให้ต่อนาที และจำนวนที่ยอมให้ยิงติดกันได้ในครั้งเดียว
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // เวลาที่ bucket จะเต็มอีกครั้ง
+	RetryAfter time.Duration // เวลาที่ต้องรอก่อนยิงได้อีกครั้ง เมื่อ Allowed เป็น false
+}
+
+// ที่เก็บ bucket ของแต่ละ client ถ้าต้องการใช้ร่วมกันหลาย instance ให้ implement ด้วย backend กลาง เช่น redis
+type StoreImpl interface {
+	Take(key string, limit Limit) (*Result, error)
+}
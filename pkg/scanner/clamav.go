@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ขนาด chunk ที่ส่งให้ clamd ต้องไม่เกิน StreamMaxLength ของ clamd
+const clamavChunkSize = 64 * 1024
+
+type clamav struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// addr เป็น unix:///var/run/clamav/clamd.ctl หรือ tcp://127.0.0.1:3310
+func NewClamAV(addr string, timeout time.Duration) (ScannerImpl, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("clamav address %q is invalid: %v", addr, err)
+	}
+
+	c := &clamav{
+		network: u.Scheme,
+		timeout: timeout,
+	}
+	switch u.Scheme {
+	case "unix":
+		c.address = u.Path
+	case "tcp":
+		c.address = u.Host
+	default:
+		return nil, fmt.Errorf("clamav address %q must be unix:// or tcp://", addr)
+	}
+	return c, nil
+}
+
+// ส่งไฟล์ผ่านคำสั่ง INSTREAM https://docs.clamav.net/manual/Usage/Scanning.html#clamd
+func (c *clamav) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("connect clamav failed: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("write clamav failed: %v", err)
+	}
+
+	buf := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return nil, fmt.Errorf("write clamav failed: %v", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read file failed: %v", err)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("write clamav failed: %v", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read clamav failed: %v", err)
+	}
+	return parseClamavReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// รูปแบบคำตอบ "stream: OK", "stream: Eicar-Signature FOUND" หรือ "... ERROR"
+func parseClamavReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamav scan failed: %s", reply)
+	}
+}
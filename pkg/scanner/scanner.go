@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	DriverNone   = "none"
+	DriverClamAV = "clamav"
+)
+
+type Result struct {
+	Clean     bool
+	Signature string // ชื่อ malware ที่พบ
+}
+
+// ตรวจไฟล์ก่อนบันทึก error หมายถึงตรวจไม่ได้ ไม่ใช่ไฟล์ติดไวรัส
+type ScannerImpl interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+func New(driver, addr string) (ScannerImpl, error) {
+	switch driver {
+	case "", DriverNone:
+		return NewNoop(), nil
+	case DriverClamAV:
+		return NewClamAV(addr, 30*time.Second)
+	default:
+		return nil, fmt.Errorf("scanner driver %q is not supported", driver)
+	}
+}
+
+type noop struct{}
+
+// ไม่ตรวจอะไรเลย ใช้เป็นค่าเริ่มต้นเมื่อไม่ได้ตั้งค่า scanner
+func NewNoop() ScannerImpl { return noop{} }
+
+func (noop) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	return &Result{Clean: true}, nil
+}
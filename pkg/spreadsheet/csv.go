@@ -0,0 +1,58 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+)
+
+type csvReader struct {
+	r    *csv.Reader
+	line int
+}
+
+// ข้าม UTF-8 BOM ที่ Excel ใส่ไว้ต้นไฟล์ csv
+func NewCSVReader(r io.Reader) ReaderImpl {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr}
+}
+
+func (r *csvReader) Read() ([]string, error) {
+	row, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.line, _ = r.r.FieldPos(0)
+	return row, nil
+}
+
+func (r *csvReader) Line() int { return r.line }
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer) WriterImpl {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(row []string) error {
+	return w.w.Write(row)
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) Close() error {
+	return w.Flush()
+}
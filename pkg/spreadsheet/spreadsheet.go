@@ -0,0 +1,72 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// อ่านทีละแถว คืน io.EOF เมื่อหมดไฟล์
+type ReaderImpl interface {
+	Read() ([]string, error)
+	// เลขแถวในไฟล์ของแถวที่อ่านล่าสุด เริ่มที่ 1 ใช้ตอนรายงาน error
+	Line() int
+}
+
+// เขียนทีละแถวแบบ stream ต้องเรียก Close เพื่อปิดไฟล์ให้สมบูรณ์
+type WriterImpl interface {
+	Write(row []string) error
+	Flush() error
+	Close() error
+}
+
+// xlsx ต้องอ่านแบบ random access เพราะเป็น zip
+func NewReader(format string, r io.ReaderAt, size int64) (ReaderImpl, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(io.NewSectionReader(r, 0, size)), nil
+	case FormatXLSX:
+		return NewXLSXReader(r, size)
+	default:
+		return nil, fmt.Errorf("format %q is not supported", format)
+	}
+}
+
+func NewWriter(format string, w io.Writer) (WriterImpl, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("format %q is not supported", format)
+	}
+}
+
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// กัน formula injection ตอนเปิดไฟล์ด้วยโปรแกรม spreadsheet โดยเติม ' หน้าค่าที่ขึ้นต้นด้วยอักขระของสูตร
+func EscapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// คืนค่าเดิมของค่าที่ผ่าน EscapeFormula
+func UnescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
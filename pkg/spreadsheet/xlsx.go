@@ -0,0 +1,306 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// อ่านเฉพาะ sheet แรกของ workbook และอ่านค่าที่เก็บไว้ใน cell ตรง ๆ ไม่ได้คำนวณสูตรหรือแปลงรูปแบบวันที่
+
+type xlsxReader struct {
+	file    io.ReadCloser
+	decoder *xml.Decoder
+	shared  []string
+	line    int
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		R  string    `xml:"r,attr"`
+		T  string    `xml:"t,attr"`
+		V  string    `xml:"v"`
+		Is *xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+func NewXLSXReader(r io.ReaderAt, size int64) (ReaderImpl, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx failed: %v", err)
+	}
+	entries := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	shared, err := readSharedStrings(entries["xl/sharedStrings.xml"])
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := entries[firstSheetPath(entries)]
+	if sheet == nil {
+		return nil, fmt.Errorf("open xlsx failed: worksheet not found")
+	}
+	file, err := sheet.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx failed: %v", err)
+	}
+	return &xlsxReader{
+		file:    file,
+		decoder: xml.NewDecoder(file),
+		shared:  shared,
+	}, nil
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	if f == nil {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx shared strings failed: %v", err)
+	}
+	defer rc.Close()
+
+	var sst struct {
+		Items []*xlsxText `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sst); err != nil {
+		return nil, fmt.Errorf("read xlsx shared strings failed: %v", err)
+	}
+	shared := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		shared[i] = item.String()
+	}
+	return shared, nil
+}
+
+// หา path ของ sheet แรกจาก workbook.xml และ relationships ถ้าอ่านไม่ได้ใช้ sheet1.xml
+func firstSheetPath(entries map[string]*zip.File) string {
+	fallback := "xl/worksheets/sheet1.xml"
+
+	var workbook struct {
+		Sheets []struct {
+			Id string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			Id     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if decodeEntry(entries["xl/workbook.xml"], &workbook) != nil || len(workbook.Sheets) == 0 {
+		return fallback
+	}
+	if decodeEntry(entries["xl/_rels/workbook.xml.rels"], &rels) != nil {
+		return fallback
+	}
+	for _, rel := range rels.Relationships {
+		if rel.Id != workbook.Sheets[0].Id {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return fallback
+}
+
+func decodeEntry(f *zip.File, v any) error {
+	if f == nil {
+		return fmt.Errorf("entry not found")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+func (r *xlsxReader) Read() ([]string, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			r.file.Close()
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("read xlsx failed: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		row := new(xlsxRow)
+		if err := r.decoder.DecodeElement(row, &start); err != nil {
+			r.file.Close()
+			return nil, fmt.Errorf("read xlsx failed: %v", err)
+		}
+		if row.R > 0 {
+			r.line = row.R
+		} else {
+			r.line++
+		}
+
+		values := make([]string, 0, len(row.Cells))
+		for _, c := range row.Cells {
+			col := len(values)
+			if c.R != "" {
+				col = columnIndex(c.R)
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch c.T {
+			case "s":
+				i, err := strconv.Atoi(c.V)
+				if err != nil || i < 0 || i >= len(r.shared) {
+					return nil, fmt.Errorf("read xlsx failed: shared string %q is invalid", c.V)
+				}
+				values[col] = r.shared[i]
+			case "inlineStr":
+				if c.Is != nil {
+					values[col] = c.Is.String()
+				}
+			case "b":
+				values[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[c.V]
+			default:
+				values[col] = c.V
+			}
+		}
+		return values, nil
+	}
+}
+
+func (r *xlsxReader) Line() int { return r.line }
+
+// แปลง reference เช่น AB12 เป็น index ของ column เริ่มที่ 0
+func columnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+	}
+	return col - 1
+}
+
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// เขียน xlsx แบบ stream ผ่าน zip โดยใช้ inline string ทุก cell จึงไม่ต้องเก็บ shared strings ไว้ใน memory
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	line  int
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func NewXLSXWriter(w io.Writer) (WriterImpl, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("write xlsx failed: %v", err)
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, fmt.Errorf("write xlsx failed: %v", err)
+		}
+	}
+
+	// sheet ต้องเป็นไฟล์สุดท้ายใน zip เพราะเขียนต่อไปเรื่อย ๆ จนกว่าจะ Close
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("write xlsx failed: %v", err)
+	}
+	sheet := bufio.NewWriter(f)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	return &xlsxWriter{
+		zw:    zw,
+		sheet: sheet,
+	}, nil
+}
+
+func (w *xlsxWriter) Write(row []string) error {
+	w.line++
+	fmt.Fprintf(w.sheet, `<row r="%d">`, w.line)
+	for i, value := range row {
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(w.sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(i), w.line)
+		if err := xml.EscapeText(w.sheet, []byte(value)); err != nil {
+			return fmt.Errorf("write xlsx failed: %v", err)
+		}
+		w.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (w *xlsxWriter) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Flush()
+}
+
+func (w *xlsxWriter) Close() error {
+	w.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+type gcsStorage struct {
+	client    *gcs.Client
+	bucket    string
+	publicUrl string
+}
+
+// ใช้ Application Default Credentials ของ GCP
+func NewGCS(ctx context.Context, bucket, publicUrl string) (StorageImpl, error) {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %v", err)
+	}
+	if publicUrl == "" {
+		publicUrl = fmt.Sprintf("https://storage.googleapis.com/%s", bucket)
+	}
+	return &gcsStorage{
+		client:    client,
+		bucket:    bucket,
+		publicUrl: publicUrl,
+	}, nil
+}
+
+func (s *gcsStorage) object(key string) *gcs.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(CleanKey(key))
+}
+
+func (s *gcsStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	wc := s.object(key).NewWriter(ctx)
+	wc.ContentType = contentType
+
+	if _, err := io.Copy(wc, r); err != nil {
+		wc.Close()
+		return fmt.Errorf("io.Copy: %v", err)
+	}
+	// Data can continue to be added to the file until the writer is closed.
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("Writer.Close: %v", err)
+	}
+
+	if IsPrivate(key) {
+		return nil
+	}
+	acl := s.object(key).ACL()
+	if err := acl.Set(ctx, gcs.AllUsers, gcs.RoleReader); err != nil {
+		return fmt.Errorf("ACLHandle.Set: %v", err)
+	}
+	return nil
+}
+
+func (s *gcsStorage) Delete(ctx context.Context, key string) error {
+	o := s.object(key)
+
+	// set a generation-match precondition to avoid potential race
+	// conditions and data corruptions.
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("object.Attrs: %v", err)
+	}
+	o = o.If(gcs.Conditions{GenerationMatch: attrs.Generation})
+
+	if err := o.Delete(ctx); err != nil {
+		return fmt.Errorf("Object(%q).Delete: %v", key, err)
+	}
+	return nil
+}
+
+func (s *gcsStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	attrs, err := s.object(key).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("object.Attrs: %v", err)
+	}
+	return &ObjectInfo{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		UpdatedAt:   attrs.Updated,
+	}, nil
+}
+
+func (s *gcsStorage) Url(key string) string {
+	return joinUrl(s.publicUrl, CleanKey(key))
+}
+
+func (s *gcsStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := s.object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("Object(%q).NewReader: %v", key, err)
+	}
+	return r, nil
+}
+
+// V4 signed url ต้องใช้ credentials ของ service account ที่ sign ได้
+func (s *gcsStorage) SignedUrl(ctx context.Context, key string, expires time.Duration) (string, error) {
+	u, err := s.client.Bucket(s.bucket).SignedURL(CleanKey(key), &gcs.SignedURLOptions{
+		Scheme:  gcs.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expires),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Bucket(%q).SignedURL: %v", s.bucket, err)
+	}
+	return u, nil
+}
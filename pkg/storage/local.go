@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type local struct {
+	root       string
+	publicUrl  string
+	signedUrl  string // url ของ handler ที่ตรวจ signature แล้วส่งไฟล์ให้
+	signingKey []byte
+}
+
+func NewLocal(root, publicUrl, signedUrl string, signingKey []byte) StorageImpl {
+	return &local{
+		root:       root,
+		publicUrl:  publicUrl,
+		signedUrl:  signedUrl,
+		signingKey: signingKey,
+	}
+}
+
+func (s *local) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(CleanKey(key)))
+}
+
+// เขียนลงไฟล์ชั่วคราวก่อนแล้วค่อย rename เพื่อไม่ให้มีไฟล์ที่เขียนไม่ครบ
+func (s *local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	dest := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("mkdir %q failed: %v", filepath.Dir(dest), err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("write file failed: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file failed: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write file failed: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("write file failed: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("write file failed: %v", err)
+	}
+	return nil
+}
+
+func (s *local) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("remove file: %s failed: %v", key, err)
+	}
+	return nil
+}
+
+func (s *local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := os.Stat(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("stat file: %s failed: %v", key, err)
+	}
+	return &ObjectInfo{
+		Key:         CleanKey(key),
+		Size:        info.Size(),
+		ContentType: ContentType(key),
+		UpdatedAt:   info.ModTime(),
+	}, nil
+}
+
+func (s *local) Url(key string) string {
+	return joinUrl(s.publicUrl, CleanKey(key))
+}
+
+func (s *local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("open file: %s failed: %v", key, err)
+	}
+	return file, nil
+}
+
+func (s *local) SignedUrl(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if len(s.signingKey) == 0 {
+		return "", fmt.Errorf("storage signing key is not configured")
+	}
+	exp := time.Now().Add(expires).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(exp, 10)},
+		"signature": {Sign(s.signingKey, key, exp)},
+	}
+	return joinUrl(s.signedUrl, CleanKey(key)) + "?" + query.Encode(), nil
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	Endpoint  string // เช่น https://s3.ap-southeast-1.amazonaws.com หรือ http://127.0.0.1:9000 ของ MinIO
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicUrl string
+}
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// sha256 ของ body ว่าง ใช้กับ request ที่ไม่มี body
+var emptyPayload = sha256Hex(nil)
+
+// S3 compatible storage ใช้ path-style url และ sign request ด้วย AWS Signature V4
+// bucket policy ควรเปิดให้อ่านแบบ public ทุก key ยกเว้น private/*
+type s3Storage struct {
+	cfg      *S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3(cfg *S3Config) (StorageImpl, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q is invalid", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PublicUrl == "" {
+		cfg.PublicUrl = joinUrl(cfg.Endpoint, cfg.Bucket)
+	}
+	return &s3Storage{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// stream body ไปที่ S3 โดยไม่ hash payload ก่อน ไฟล์ใหญ่จึงไม่ต้องอ่านเข้า memory ทั้งไฟล์
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	res, err := s.do(ctx, http.MethodPut, key, r, size, unsignedPayload, map[string]string{
+		"Content-Type": contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("put object: %s failed: %v", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("put object: %s failed: %s", key, s.readError(res))
+	}
+	return nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodDelete, key, nil, 0, emptyPayload, nil)
+	if err != nil {
+		return fmt.Errorf("delete object: %s failed: %v", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("delete object: %s failed: %s", key, s.readError(res))
+	}
+	return nil
+}
+
+func (s *s3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	res, err := s.do(ctx, http.MethodHead, key, nil, 0, emptyPayload, nil)
+	if err != nil {
+		return nil, fmt.Errorf("stat object: %s failed: %v", key, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("stat object: %s failed: status %d", key, res.StatusCode)
+	}
+
+	size, _ := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	updatedAt, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return &ObjectInfo{
+		Key:         CleanKey(key),
+		Size:        size,
+		ContentType: res.Header.Get("Content-Type"),
+		UpdatedAt:   updatedAt,
+	}, nil
+}
+
+func (s *s3Storage) Url(key string) string {
+	return joinUrl(s.cfg.PublicUrl, CleanKey(key))
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, 0, emptyPayload, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get object: %s failed: %v", key, err)
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer res.Body.Close()
+		return nil, fmt.Errorf("get object: %s failed: %s", key, s.readError(res))
+	}
+}
+
+func (s *s3Storage) readError(res *http.Response) string {
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Sprintf("status %d: %s", res.StatusCode, bytes.TrimSpace(b))
+}
+
+func (s *s3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, payloadHash string, headers map[string]string) (*http.Response, error) {
+	objectPath := "/" + s.cfg.Bucket + "/" + CleanKey(key)
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = strings.TrimSuffix(u.Path, objectPath) + s3Escape(objectPath)
+
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.ContentLength = size
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// AWS Signature Version 4 https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *s3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSha256(s.signingKey(date), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// presigned url แบบ query string https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (s *s3Storage) SignedUrl(ctx context.Context, key string, expires time.Duration) (string, error) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	objectPath := "/" + s.cfg.Bucket + "/" + CleanKey(key)
+	escapedPath := strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + s3Escape(objectPath)
+
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.cfg.AccessKey + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	// url.Values.Encode แปลงช่องว่างเป็น + แต่ SigV4 ต้องการ %20
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		escapedPath,
+		canonicalQuery,
+		"host:" + s.endpoint.Host,
+		"",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSha256(s.signingKey(date), stringToSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", s.endpoint.Scheme, s.endpoint.Host, escapedPath, canonicalQuery, signature), nil
+}
+
+func (s *s3Storage) signingKey(date string) []byte {
+	key := hmacSha256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSha256(key, s.cfg.Region)
+	key = hmacSha256(key, "s3")
+	return hmacSha256(key, "aws4_request")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// encode path ตาม rule ของ S3 คือเก็บไว้เฉพาะ A-Z a-z 0-9 - _ . ~ และ /
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// key ที่ขึ้นต้นด้วย private/ จะไม่ถูกเปิดให้อ่านแบบ public ต้องเข้าผ่าน signed url เท่านั้น
+const PrivatePrefix = "private/"
+
+var (
+	ErrSignatureInvalid   = errors.New("signature is invalid")
+	ErrUrlExpired         = errors.New("url has expired")
+	ErrDestinationInvalid = errors.New("destination is invalid")
+)
+
+func IsPrivate(key string) bool {
+	return strings.HasPrefix(CleanKey(key), PrivatePrefix)
+}
+
+// สร้าง key ของไฟล์ใหม่จาก destination ที่ client ส่งมา
+// ห้ามมี .. หรือขึ้นต้นด้วย / เพราะจะหลุดออกจาก private/ ไปเป็น key ที่ public อ่านได้
+func ObjectKey(destination, fileName string, private bool) (string, error) {
+	if strings.HasPrefix(destination, "/") || strings.Contains(destination, "..") {
+		return "", ErrDestinationInvalid
+	}
+	key := CleanKey(destination + "/" + fileName)
+	if private {
+		key = PrivatePrefix + key
+	}
+	if IsPrivate(key) != private {
+		return "", ErrDestinationInvalid
+	}
+	return key, nil
+}
+
+// HMAC-SHA256 ของ key และเวลาหมดอายุ ใช้กับ signed url ของ local storage
+func Sign(signingKey []byte, key string, expires int64) string {
+	return hex.EncodeToString(hmacSha256(signingKey, CleanKey(key)+"\n"+strconv.FormatInt(expires, 10)))
+}
+
+func VerifySignature(signingKey []byte, key string, expires int64, signature string) error {
+	if len(signingKey) == 0 {
+		return ErrSignatureInvalid
+	}
+	expected := Sign(signingKey, key, expires)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expires {
+		return ErrUrlExpired
+	}
+	return nil
+}
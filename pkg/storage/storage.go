@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Doittikorn/go-e-commerce/config"
+)
+
+const (
+	DriverLocal = "local"
+	DriverGCS   = "gcs"
+	DriverS3    = "s3"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	UpdatedAt   time.Time
+}
+
+// ที่เก็บไฟล์ key คือ path ภายใน bucket หรือ root เช่น images/products/xxx.png
+type StorageImpl interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Url(key string) string
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	SignedUrl(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// เลือก storage ตาม STORAGE_DRIVER
+func New(cfg config.StorageConfigImpl) (StorageImpl, error) {
+	switch cfg.Driver() {
+	case DriverLocal:
+		return NewLocal(cfg.LocalRoot(), cfg.PublicUrl(), cfg.SignedUrl(), cfg.SigningKey()), nil
+	case DriverGCS:
+		return NewGCS(context.Background(), cfg.Bucket(), cfg.PublicUrl())
+	case DriverS3:
+		return NewS3(&S3Config{
+			Endpoint:  cfg.S3Endpoint(),
+			Region:    cfg.S3Region(),
+			Bucket:    cfg.Bucket(),
+			AccessKey: cfg.S3AccessKey(),
+			SecretKey: cfg.S3SecretKey(),
+			PublicUrl: cfg.PublicUrl(),
+		})
+	default:
+		return nil, fmt.Errorf("storage driver %q is not supported", cfg.Driver())
+	}
+}
+
+// ตัด / และ .. ออกจาก key กันการเขียนไฟล์ออกนอก root
+func CleanKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func joinUrl(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}